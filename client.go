@@ -1,20 +1,37 @@
 package backupssdk
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
 	"olares.com/backups-sdk/cmd/backup"
+	"olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/daemon"
 	"olares.com/backups-sdk/cmd/download"
+	cmdhistory "olares.com/backups-sdk/cmd/history"
+	"olares.com/backups-sdk/cmd/locks"
+	"olares.com/backups-sdk/cmd/outbox"
 	"olares.com/backups-sdk/cmd/region"
 	"olares.com/backups-sdk/cmd/restore"
+	"olares.com/backups-sdk/cmd/serve"
 	"olares.com/backups-sdk/cmd/snapshots"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/jobs"
 	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/orchestrator"
+	"olares.com/backups-sdk/pkg/scheduler"
+	"olares.com/backups-sdk/pkg/server"
 	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/notification"
 )
 
 func NewBackupCommands() *cobra.Command {
+	var logOptions = logger.NewOptions()
 	cmds := &cobra.Command{
 		Use:   "backups",
 		Short: "Olares backup tool-kit",
@@ -23,7 +40,18 @@ func NewBackupCommands() *cobra.Command {
 				panic(errors.New("Windows system is not currently supported. Please switch to WSL (Windows Subsystem for Linux)."))
 			}
 
-			logger.InitLogger(true)
+			if err := logger.Init(logOptions); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := config.Apply(cmd); err != nil {
+				logger.Fatalf("%v", err)
+			}
+
+			if err := telemetry.Start(); err != nil {
+				logger.Fatalf("%v", err)
+			}
 		},
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
@@ -36,36 +64,102 @@ func NewBackupCommands() *cobra.Command {
 	cmds.AddCommand(snapshots.NewCmdSnapshots())
 	cmds.AddCommand(download.NewCmdDownload())
 	cmds.AddCommand(region.NewCmdRegions())
+	cmds.AddCommand(locks.NewCmdLocks())
+	cmds.AddCommand(cmdhistory.NewCmdHistory())
+	cmds.AddCommand(outbox.NewCmdOutbox())
+	cmds.AddCommand(daemon.NewCmdDaemon())
+	cmds.AddCommand(serve.NewCmdServe())
+	cmds.AddCommand(config.NewCmdConfig())
+
+	config.AddFlags(cmds)
+	telemetry.AddFlags(cmds)
+	cmds.PersistentFlags().StringVarP(&logOptions.Level, "log-level", "", logOptions.Level, "Console log level: debug, info, warn or error")
+	cmds.PersistentFlags().StringVarP(&logOptions.Format, "log-format", "", logOptions.Format, "Console log format: console or json")
 
 	return cmds
 }
 
 func NewBackupService(option *storage.BackupOption) *storage.BackupService {
-	logger.SetLogger(option.Logger)
-
 	return storage.NewBackupService(option)
 }
 
 func NewRestoreService(option *storage.RestoreOption) *storage.RestoreService {
-	logger.SetLogger(option.Logger)
-
 	return storage.NewRestoreService(option)
 }
 
 func NewRegionService(option *storage.RegionOption) *storage.RegionService {
-	logger.SetLogger(option.Logger)
-
 	return storage.NewRegionService(option)
 }
 
 func NewStatsService(option *storage.SnapshotsOption) *storage.StatsService {
-	logger.SetLogger(option.Logger)
-
 	return storage.NewStatsService(option)
 }
 
 func NewSnapshotsService(option *storage.SnapshotsOption) *storage.SnapshotsService {
-	logger.SetLogger(option.Logger)
-
 	return storage.NewSnapshotsService(option)
 }
+
+func NewLocksService(option *storage.SnapshotsOption) *storage.LocksService {
+	return storage.NewLocksService(option)
+}
+
+func NewLsService(option *storage.SnapshotsOption) *storage.LsService {
+	return storage.NewLsService(option)
+}
+
+func NewForgetService(option *storage.SnapshotsOption) *storage.ForgetService {
+	return storage.NewForgetService(option)
+}
+
+func NewCheckService(option *storage.SnapshotsOption) *storage.CheckService {
+	return storage.NewCheckService(option)
+}
+
+// RegisterBackend adds a custom location, commands created after registration include it
+func RegisterBackend(backend *storage.Backend) error {
+	return storage.Register(backend)
+}
+
+// NewHistoryStore opens the job history, the default one under the Olares base dir when file is empty
+func NewHistoryStore(file string) *history.Store {
+	if file == "" {
+		file = history.DefaultPath()
+	}
+	return history.NewStore(file)
+}
+
+// NewNotifier sends finished jobs to the channels of the matching rules, set it as Notifier of the job options
+func NewNotifier(channels []notification.Channel, rules []*notification.Rule) (*notification.Notifier, error) {
+	return notification.NewNotifier(channels, rules)
+}
+
+// NewOutbox opens the queue of cloud records, the default one under the Olares base dir when dir is empty
+func NewOutbox(dir string) *notification.Outbox {
+	if dir == "" {
+		dir = notification.DefaultOutboxDir()
+	}
+	return notification.NewOutbox(dir)
+}
+
+// NewScheduler runs tasks on cron schedules, add the tasks of scheduler.Job for backups with retention and checks.
+// state remembers the last runs to catch up after downtime, nil keeps them in memory
+func NewScheduler(state *scheduler.State) *scheduler.Scheduler {
+	return scheduler.New(state)
+}
+
+// NewJobManager runs backups, restores, retention and checks in the background and tracks them by job id,
+// nil options run DefaultConcurrency jobs at a time
+func NewJobManager(options *jobs.Options) *jobs.Manager {
+	return jobs.NewManager(options)
+}
+
+// NewServer serves the services as a REST API, mount its Handler or call ListenAndServe
+func NewServer(options *server.Options) (*server.Server, error) {
+	return server.New(options)
+}
+
+// RunBackups runs a batch of backups with a pool of workers, per-location limits and a shared upload budget,
+// the summary has a result for each backup in the order of the batch
+func RunBackups(ctx context.Context, backups []*storage.BackupOption, options *orchestrator.Options) (*orchestrator.Summary, error) {
+	return orchestrator.Run(ctx, backups, options)
+}
@@ -2,9 +2,19 @@ package backup
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	cmdconfig "olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/config"
 	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage"
 	"olares.com/backups-sdk/pkg/utils"
@@ -16,70 +26,97 @@ var dryRun = false
 func NewCmdBackup() *cobra.Command {
 	rootBackupCmds := &cobra.Command{
 		Use:               "backup",
-		Short:             "Back up data to multiple storage targets: Space, S3, COS, and local",
+		Short:             "Back up data to multiple storage targets: Space, S3, COS, SFTP, REST server, and local",
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
 
-	rootBackupCmds.AddCommand(NewCmdSpace())
-	rootBackupCmds.AddCommand(NewCmdS3())
-	rootBackupCmds.AddCommand(NewCmdCos())
-	rootBackupCmds.AddCommand(NewCmdFs())
+	for _, backend := range storage.BackendsFor(storage.OperationBackup) {
+		rootBackupCmds.AddCommand(NewCmdBackend(backend))
+	}
 
 	return rootBackupCmds
 }
 
-func NewCmdSpace() *cobra.Command {
-	o := options.NewBackupSpaceOption()
-	cmd := &cobra.Command{
-		Use:   "space",
-		Short: "Backup data to the Space",
-		Run: func(cmd *cobra.Command, args []string) {
-			var backupService = storage.NewBackupService(&storage.BackupOption{Ctx: context.WithValue(context.TODO(), constants.TraceId, utils.NewUUID()), Space: o, Operator: constants.StorageOperatorCli})
-			backupService.Backup(dryRun, p)
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
-}
-
-func NewCmdS3() *cobra.Command {
-	o := options.NewBackupAwsOption()
+func NewCmdBackend(backend *storage.Backend) *cobra.Command {
+	o := backend.NewOption(storage.OperationBackup)
+	po := options.NewPasswordOption()
+	var targets []string
+	var policy string
+	var sequential bool
+	var uploadSchedule []string
+	var force bool
 	cmd := &cobra.Command{
-		Use:   "s3",
-		Short: "Backup data to Amazon S3 or S3-compatible storage",
+		Use:         backend.Name,
+		Annotations: map[string]string{config.AnnotationLocation: backend.Name},
+		Short:       fmt.Sprintf("Backup data to %s", backend.Description),
 		Run: func(cmd *cobra.Command, args []string) {
-			var backupService = storage.NewBackupService(&storage.BackupOption{Ctx: context.WithValue(context.TODO(), constants.TraceId, utils.NewUUID()), Aws: o, Operator: constants.StorageOperatorCli})
-			backupService.Backup(dryRun, p)
+			notifier, err := cmdconfig.Notifier()
+			if err != nil {
+				logger.Errorf("load notifications error: %v", err)
+				telemetry.Flush()
+				os.Exit(1)
+			}
+			backupTargets, err := parseTargets(targets)
+			if err != nil {
+				logger.Errorf("%v", err)
+				telemetry.Flush()
+				os.Exit(1)
+			}
+			schedule, err := bandwidth.ParseSchedule(uploadSchedule)
+			if err != nil {
+				logger.Errorf("%v", err)
+				telemetry.Flush()
+				os.Exit(1)
+			}
+			var backupService = storage.NewBackupService(&storage.BackupOption{PasswordFile: po.PasswordFile, PasswordCommand: po.PasswordCommand, SecretRefs: true, Location: backend.Name, LocationOption: o, Operator: constants.StorageOperatorCli, History: history.NewStore(history.DefaultPath()), Notifier: notifier,
+				Targets: backupTargets, Policy: storage.FanOutPolicy(policy), Sequential: sequential, UploadSchedule: schedule, Force: force})
+			var ctx = context.WithValue(cmd.Context(), constants.TraceId, utils.NewUUID())
+			if len(backupTargets) > 0 {
+				_, err = backupService.FanOutContext(ctx, dryRun, p)
+			} else {
+				_, _, err = backupService.BackupContext(ctx, dryRun, p)
+			}
+			telemetry.Flush()
+			if err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 	o.AddFlags(cmd)
+	po.AddFlags(cmd)
+	cmd.Flags().StringArrayVarP(&targets, "target", "", nil, `Also back up to this repository, a JSON descriptor like {"location":"fs","repo_name":"home","endpoint":"/mnt/disk"}, repeat for more targets. The snapshots share a backup-set tag, a target with its own password_file or password_command does not share the password`)
+	cmd.Flags().StringVarP(&policy, "policy", "", string(storage.FailAny), "When a backup with targets fails: fail-any or fail-all")
+	cmd.Flags().StringArrayVarP(&uploadSchedule, "upload-schedule", "", nil, `Limits uploads by time of day, a window like "mon-fri 09:00-18:00 1024" in KiB/s, repeat for more windows. restic is restarted when the rate changes`)
+	cmd.Flags().BoolVarP(&force, "force", "", false, "Back up even when the local repository may not have enough free space")
+	cmd.Flags().BoolVarP(&sequential, "sequential", "", false, "Back up to the targets one after another instead of in parallel")
 	return cmd
 }
 
-func NewCmdCos() *cobra.Command {
-	o := options.NewBackupTencentCloudOption()
-	cmd := &cobra.Command{
-		Use:   "cos",
-		Short: "Backup data to Tencent Cloud Object Storage (COS)",
-		Run: func(cmd *cobra.Command, args []string) {
-			var backupService = storage.NewBackupService(&storage.BackupOption{Ctx: context.WithValue(context.TODO(), constants.TraceId, utils.NewUUID()), TencentCloud: o, Operator: constants.StorageOperatorCli})
-			backupService.Backup(dryRun, p)
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
+// target is a --target descriptor, the password of the backup is used when it has no password file or command
+type target struct {
+	options.RepositoryBackupOption
+	PasswordFile    string `json:"password_file,omitempty"`
+	PasswordCommand string `json:"password_command,omitempty"`
 }
 
-func NewCmdFs() *cobra.Command {
-	o := options.NewBackupFilesystemOption()
-	cmd := &cobra.Command{
-		Use:   "fs",
-		Short: "Backup data to the local filesystem or disk",
-		Run: func(cmd *cobra.Command, args []string) {
-			var backupService = storage.NewBackupService(&storage.BackupOption{Ctx: context.WithValue(context.TODO(), constants.TraceId, utils.NewUUID()), Filesystem: o, Operator: constants.StorageOperatorCli})
-			backupService.Backup(dryRun, p)
-		},
+// parseTargets reads the --target descriptors, the source of the backup is used when they have no path
+func parseTargets(values []string) ([]*storage.BackupTarget, error) {
+	var res []*storage.BackupTarget
+	for _, v := range values {
+		var t = &target{}
+		var dec = json.NewDecoder(strings.NewReader(v))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(t); err != nil {
+			return nil, fmt.Errorf("invalid target %s: %v", v, err)
+		}
+		var repo = &t.RepositoryBackupOption
+		if err := repo.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid target %s: %v", v, err)
+		}
+		if _, ok := storage.LookupBackend(repo.Location); !ok {
+			return nil, fmt.Errorf("invalid target %s: location %s is not registered", v, repo.Location)
+		}
+		res = append(res, &storage.BackupTarget{Repository: repo, PasswordFile: t.PasswordFile, PasswordCommand: t.PasswordCommand})
 	}
-	o.AddFlags(cmd)
-	return cmd
+	return res, nil
 }
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	pkgconfig "olares.com/backups-sdk/pkg/config"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/notification"
+	"sigs.k8s.io/yaml"
+)
+
+var configFile string
+var profile string
+
+// AddFlags adds the global --config and --profile flags to the root command
+func AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&configFile, "config", "", "", fmt.Sprintf("Config file (default: %s)", pkgconfig.DefaultPath()))
+	cmd.PersistentFlags().StringVarP(&profile, "profile", "", os.Getenv(constants.ENV_BACKUPS_PROFILE), "Repository profile of the config file, flags override the profile values")
+}
+
+// Apply applies the config to the flags of the command that is about to run
+func Apply(cmd *cobra.Command) error {
+	return pkgconfig.Apply(cmd, configFile, profile)
+}
+
+// Load reads the config file, the file must exist when --config is set
+func Load() (*pkgconfig.Config, error) {
+	return pkgconfig.Load(pkgconfig.Path(configFile), configFile != "")
+}
+
+// Notifier builds the notifier of the config file, it is nil when the file has no notification rules
+func Notifier() (*notification.Notifier, error) {
+	c, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Notifications.Validate(); err != nil {
+		return nil, fmt.Errorf("config notifications: %v", err)
+	}
+	return c.Notifications.Notifier()
+}
+
+func NewCmdConfig() *cobra.Command {
+	rootConfigCmds := &cobra.Command{
+		Use:               "config",
+		Short:             "Validate and show the backups config file",
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		PersistentPreRun:  func(cmd *cobra.Command, args []string) {},
+	}
+
+	rootConfigCmds.AddCommand(newCmdValidate())
+	rootConfigCmds.AddCommand(newCmdShow())
+
+	return rootConfigCmds
+}
+
+func newCmdValidate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the config file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, file, err := load()
+			if err != nil {
+				return err
+			}
+			if err := c.Validate(); err != nil {
+				return err
+			}
+			for _, name := range c.ProfileNames() {
+				var location = c.Profiles[name].Location
+				if _, ok := storage.LookupBackend(location); !ok {
+					return fmt.Errorf("profile %s: location %s is not registered", name, location)
+				}
+			}
+			fmt.Printf("config %s is valid, %d profile(s)\n", file, len(c.Profiles))
+			return nil
+		},
+	}
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func newCmdShow() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective config with secrets redacted, only the merged profile when --profile is set",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := load()
+			if err != nil {
+				return err
+			}
+
+			var v interface{} = c.Redacted()
+			if profile != "" {
+				p, err := c.Profile(profile)
+				if err != nil {
+					return err
+				}
+				v = p.Redacted()
+			}
+
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func load() (*pkgconfig.Config, string, error) {
+	var file = pkgconfig.Path(configFile)
+	c, err := pkgconfig.Load(file, true)
+	return c, file, err
+}
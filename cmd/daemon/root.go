@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdconfig "olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/metrics"
+	"olares.com/backups-sdk/pkg/scheduler"
+	"olares.com/backups-sdk/pkg/storage/notification"
+)
+
+func NewCmdDaemon() *cobra.Command {
+	var stateFile, metricsListen string
+	var outboxInterval time.Duration
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the schedules of the config file until interrupted",
+		Long: `Run the backup, retention and check schedules of the config file until interrupted.
+Runs of the same repository never overlap, runs missed while the daemon was down are caught up at start.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer telemetry.Flush()
+
+			c, err := cmdconfig.Load()
+			if err != nil {
+				return err
+			}
+			if err := c.Validate(); err != nil {
+				return err
+			}
+			if len(c.Schedules) == 0 {
+				return fmt.Errorf("the config file has no schedules")
+			}
+			notifier, err := c.Notifications.Notifier()
+			if err != nil {
+				return err
+			}
+
+			if stateFile == "" {
+				stateFile = scheduler.DefaultStatePath()
+			}
+			state, err := scheduler.LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			var s = scheduler.New(state)
+			var store = history.NewStore(history.DefaultPath())
+			for _, name := range c.ScheduleNames() {
+				job, err := c.Job(name)
+				if err != nil {
+					return err
+				}
+				job.History, job.Notifier = store, notifier
+				tasks, err := job.Tasks()
+				if err != nil {
+					return err
+				}
+				for _, t := range tasks {
+					if err := s.Add(t); err != nil {
+						return err
+					}
+				}
+			}
+
+			var ctx = cmd.Context()
+			var wg sync.WaitGroup
+			if metricsListen != "" {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := metrics.Serve(ctx, metricsListen); err != nil {
+						logger.Errorf("serve metrics error: %v", err)
+					}
+				}()
+			}
+			if outboxInterval > 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					notification.NewOutbox(notification.DefaultOutboxDir()).Run(ctx, outboxInterval)
+				}()
+			}
+
+			logger.Infof("daemon started, %d schedule(s)", len(c.Schedules))
+			for _, t := range s.Status() {
+				logger.Infof("task %s next run %s", t.Name, t.Next.Format(time.RFC3339))
+			}
+			err = s.Run(ctx)
+			wg.Wait()
+			logger.Infof("daemon stopped")
+			if err == context.Canceled {
+				return nil
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&stateFile, "state-file", "", "", "Scheduler state file, it remembers the last runs (default: {base dir}/scheduler.json)")
+	cmd.Flags().StringVarP(&metricsListen, "metrics-listen", "", "", "Serve the Prometheus metrics on an address like :9090, /metrics")
+	cmd.Flags().DurationVarP(&outboxInterval, "outbox-interval", "", 5*time.Minute, "Interval to deliver the pending cloud records of the outbox, 0 disables it")
+	cmd.SilenceUsage = true
+	return cmd
+}
@@ -0,0 +1,82 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	pkghistory "olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+func NewCmdHistory() *cobra.Command {
+	o := options.NewHistoryOption()
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the finished backup, restore, check and forget jobs, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := o.Filter()
+			if err != nil {
+				return err
+			}
+
+			var file = o.File
+			if file == "" {
+				file = pkghistory.DefaultPath()
+			}
+			records, err := pkghistory.NewStore(file).List(filter)
+			if err != nil {
+				return err
+			}
+
+			if o.Json {
+				if records == nil {
+					records = []*pkghistory.Record{}
+				}
+				data, err := json.MarshalIndent(records, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			printTable(records)
+			return nil
+		},
+	}
+	o.AddFlags(cmd)
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func printTable(records []*pkghistory.Record) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Started", "Operation", "Location", "Repo", "Snapshot", "Duration", "Size", "Outcome", "Error"})
+
+	for _, r := range records {
+		var snapshotId = r.SnapshotId
+		if len(snapshotId) > 8 {
+			snapshotId = snapshotId[:8]
+		}
+		var outcome = string(r.Outcome)
+		if r.ErrorCategory != pkghistory.CategoryNone {
+			outcome = fmt.Sprintf("%s (%s)", r.Outcome, r.ErrorCategory)
+		}
+		table.Append([]string{
+			r.StartedAt.Local().Format(time.DateTime),
+			string(r.Operation),
+			r.Location,
+			r.Repo,
+			snapshotId,
+			(time.Duration(r.Duration * float64(time.Second))).Round(time.Second).String(),
+			utils.FormatBytes(r.Bytes),
+			outcome,
+			r.Error,
+		})
+	}
+	table.Render()
+}
@@ -0,0 +1,45 @@
+package locks
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/config"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage"
+)
+
+func NewCmdLocks() *cobra.Command {
+	rootLocksCmds := &cobra.Command{
+		Use:               "locks",
+		Short:             "Show the object lock expiry of backup snapshots",
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+
+	rootLocksCmds.AddCommand(NewCmdS3())
+
+	return rootLocksCmds
+}
+
+func NewCmdS3() *cobra.Command {
+	o := options.NewSnapshotsAwsOption()
+	po := options.NewPasswordOption()
+	cmd := &cobra.Command{
+		Use:         "s3",
+		Annotations: map[string]string{config.AnnotationLocation: storage.LocationAws},
+		Short:       "Snapshot locks from S3",
+		Run: func(cmd *cobra.Command, args []string) {
+			var locksService = storage.NewLocksService(&storage.SnapshotsOption{PasswordFile: po.PasswordFile, PasswordCommand: po.PasswordCommand, SecretRefs: true, Aws: o, Operator: constants.StorageOperatorCli})
+			_, err := locksService.LocksContext(cmd.Context())
+			telemetry.Flush()
+			if err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+	o.AddFlags(cmd)
+	po.AddFlags(cmd)
+	return cmd
+}
@@ -1,29 +1,51 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"olares.com/backups-sdk/cmd/backup"
+	"olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/daemon"
 	"olares.com/backups-sdk/cmd/download"
+	"olares.com/backups-sdk/cmd/history"
+	"olares.com/backups-sdk/cmd/locks"
+	"olares.com/backups-sdk/cmd/outbox"
 	"olares.com/backups-sdk/cmd/region"
 	"olares.com/backups-sdk/cmd/restore"
+	"olares.com/backups-sdk/cmd/serve"
 	"olares.com/backups-sdk/cmd/snapshots"
 	"olares.com/backups-sdk/cmd/stats"
+	"olares.com/backups-sdk/cmd/telemetry"
 	"olares.com/backups-sdk/pkg/logger"
 )
 
 func main() {
+	var logOptions = logger.NewOptions()
 	cmds := &cobra.Command{
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			if runtime.GOOS == "windows" {
 				panic(errors.New("Windows system is not currently supported. Please switch to WSL (Windows Subsystem for Linux)."))
 			}
 
-			logger.InitLogger(true)
+			if err := logger.Init(logOptions); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := config.Apply(cmd); err != nil {
+				logger.Fatalf("%v", err)
+			}
+
+			if err := telemetry.Start(); err != nil {
+				logger.Fatalf("%v", err)
+			}
 		},
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
@@ -37,8 +59,22 @@ func main() {
 	cmds.AddCommand(region.NewCmdRegions())
 	cmds.AddCommand(download.NewCmdDownload())
 	cmds.AddCommand(stats.NewCmdStats())
+	cmds.AddCommand(locks.NewCmdLocks())
+	cmds.AddCommand(history.NewCmdHistory())
+	cmds.AddCommand(outbox.NewCmdOutbox())
+	cmds.AddCommand(daemon.NewCmdDaemon())
+	cmds.AddCommand(serve.NewCmdServe())
+	cmds.AddCommand(config.NewCmdConfig())
+
+	config.AddFlags(cmds)
+	telemetry.AddFlags(cmds)
+	cmds.PersistentFlags().StringVarP(&logOptions.Level, "log-level", "", logOptions.Level, "Console log level: debug, info, warn or error")
+	cmds.PersistentFlags().StringVarP(&logOptions.Format, "log-format", "", logOptions.Format, "Console log format: console or json")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	if err := cmds.Execute(); err != nil {
+	if err := cmds.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/notification"
+)
+
+func NewCmdOutbox() *cobra.Command {
+	o := options.NewOutboxOption()
+	rootOutboxCmds := &cobra.Command{
+		Use:               "outbox",
+		Short:             "Show, flush or discard the cloud records that wait for delivery",
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+
+	rootOutboxCmds.AddCommand(newCmdList(o))
+	rootOutboxCmds.AddCommand(newCmdFlush(o))
+	rootOutboxCmds.AddCommand(newCmdDiscard(o))
+	o.AddFlags(rootOutboxCmds)
+
+	return rootOutboxCmds
+}
+
+func newCmdList(o *options.OutboxOption) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the pending and failed deliveries, oldest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			messages, err := outbox(o).List()
+			if err != nil {
+				return err
+			}
+			if o.Json {
+				if messages == nil {
+					messages = []*notification.Message{}
+				}
+				return printJson(messages)
+			}
+			printTable(messages)
+			return nil
+		},
+	}
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func newCmdFlush(o *options.OutboxOption) *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Deliver the pending records that are due, --all delivers every record now, failed ones included",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := outbox(o).Flush(cmd.Context(), all)
+			if err != nil {
+				return err
+			}
+			if o.Json {
+				return printJson(res)
+			}
+			fmt.Printf("delivered %d, retrying %d, failed %d\n", res.Delivered, res.Retrying, res.Failed)
+			if res.Retrying+res.Failed > 0 {
+				return fmt.Errorf("%d record(s) were not delivered", res.Retrying+res.Failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&all, "all", "", false, "Deliver every record now, failed and not yet due ones included")
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func newCmdDiscard(o *options.OutboxOption) *cobra.Command {
+	var failed, all bool
+	cmd := &cobra.Command{
+		Use:   "discard [id...]",
+		Short: "Remove records without delivering them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var box = outbox(o)
+			var ids = args
+			if failed || all {
+				if len(args) > 0 {
+					return fmt.Errorf("ids can not be combined with --failed or --all")
+				}
+				messages, err := box.List()
+				if err != nil {
+					return err
+				}
+				for _, m := range messages {
+					if all || m.Status == notification.MessageFailed {
+						ids = append(ids, m.Id)
+					}
+				}
+			} else if len(args) == 0 {
+				return fmt.Errorf("ids, --failed or --all is required")
+			}
+
+			if err := box.Discard(ids...); err != nil {
+				return err
+			}
+			fmt.Printf("discarded %d record(s)\n", len(ids))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&failed, "failed", "", false, "Discard the records that gave up")
+	cmd.Flags().BoolVarP(&all, "all", "", false, "Discard every record")
+	cmd.SilenceUsage = true
+	return cmd
+}
+
+func outbox(o *options.OutboxOption) *notification.Outbox {
+	var dir = o.Dir
+	if dir == "" {
+		dir = notification.DefaultOutboxDir()
+	}
+	return notification.NewOutbox(dir)
+}
+
+func printJson(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func printTable(messages []*notification.Message) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Id", "Created", "Status", "Attempts", "Next Attempt", "Error"})
+
+	for _, m := range messages {
+		var next = m.NextAttemptAt.Local().Format(time.DateTime)
+		if m.Status == notification.MessageFailed {
+			next = "-"
+		}
+		table.Append([]string{
+			m.Id,
+			m.CreatedAt.Local().Format(time.DateTime),
+			string(m.Status),
+			strconv.Itoa(m.Attempts),
+			next,
+			m.LastError,
+		})
+	}
+	table.Render()
+}
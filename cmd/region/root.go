@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/config"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage"
 	"olares.com/backups-sdk/pkg/utils"
@@ -25,11 +27,13 @@ func NewCmdRegions() *cobra.Command {
 func newSpaceRegions() *cobra.Command {
 	o := options.NewRegionSpaceOption()
 	cmd := &cobra.Command{
-		Use:   "space",
-		Short: "Space Storage Regions",
+		Use:         "space",
+		Annotations: map[string]string{config.AnnotationLocation: storage.LocationSpace},
+		Short:       "Space Storage Regions",
 		Run: func(cmd *cobra.Command, args []string) {
-			var regionService = storage.NewRegionService(&storage.RegionOption{Space: o})
-			data, err := regionService.Regions()
+			var regionService = storage.NewRegionService(&storage.RegionOption{Space: o, SecretRefs: true})
+			data, err := regionService.RegionsContext(cmd.Context())
+			telemetry.Flush()
 			if err != nil {
 				panic(fmt.Errorf("Get space regions error: %v\n", err))
 			}
@@ -1,10 +1,17 @@
 package restore
 
 import (
-	"context"
+	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+	cmdconfig "olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/config"
 	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage"
 )
@@ -14,70 +21,50 @@ var p = func(percentDone float64) {}
 func NewCmdRestore() *cobra.Command {
 	rootBackupCmds := &cobra.Command{
 		Use:               "restore",
-		Short:             "Restore data from multiple storage targets: Space, S3, COS, and local",
+		Short:             "Restore data from multiple storage targets: Space, S3, COS, SFTP, REST server, and local",
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
 
-	rootBackupCmds.AddCommand(NewCmdSpace())
-	rootBackupCmds.AddCommand(NewCmdS3())
-	rootBackupCmds.AddCommand(NewCmdCos())
-	rootBackupCmds.AddCommand(NewCmdFs())
-
-	return rootBackupCmds
-}
-
-func NewCmdSpace() *cobra.Command {
-	o := options.NewRestoreSpaceOption()
-	cmd := &cobra.Command{
-		Use:   "space",
-		Short: "Restore data from Space",
-		Run: func(cmd *cobra.Command, args []string) {
-			var restoreService = storage.NewRestoreService(&storage.RestoreOption{Ctx: context.TODO(), Space: o, Operator: constants.StorageOperatorCli})
-			restoreService.Restore(p)
-		},
+	for _, backend := range storage.BackendsFor(storage.OperationRestore) {
+		rootBackupCmds.AddCommand(NewCmdBackend(backend))
 	}
-	o.AddFlags(cmd)
-	return cmd
-}
 
-func NewCmdS3() *cobra.Command {
-	o := options.NewRestoreAwsOption()
-	cmd := &cobra.Command{
-		Use:   "s3",
-		Short: "Restore data from Amazon S3 or S3-compatible storage",
-		Run: func(cmd *cobra.Command, args []string) {
-			var restoreService = storage.NewRestoreService(&storage.RestoreOption{Ctx: context.TODO(), Aws: o, Operator: constants.StorageOperatorCli})
-			restoreService.Restore(p)
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
-}
-
-func NewCmdCos() *cobra.Command {
-	o := options.NewRestoreTencentCloudOption()
-	cmd := &cobra.Command{
-		Use:   "cos",
-		Short: "Restore data from Tencent Cloud Object Storage (COS)",
-		Run: func(cmd *cobra.Command, args []string) {
-			var restoreService = storage.NewRestoreService(&storage.RestoreOption{Ctx: context.TODO(), TencentCloud: o, Operator: constants.StorageOperatorCli})
-			restoreService.Restore(p)
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
+	return rootBackupCmds
 }
 
-func NewCmdFs() *cobra.Command {
-	o := options.NewRestoreFilesystemOption()
+func NewCmdBackend(backend *storage.Backend) *cobra.Command {
+	o := backend.NewOption(storage.OperationRestore)
+	po := options.NewPasswordOption()
+	var downloadSchedule []string
+	var force bool
 	cmd := &cobra.Command{
-		Use:   "fs",
-		Short: "Restore data from the local filesystem or disk",
+		Use:         backend.Name,
+		Annotations: map[string]string{config.AnnotationLocation: backend.Name},
+		Short:       fmt.Sprintf("Restore data from %s", backend.Description),
 		Run: func(cmd *cobra.Command, args []string) {
-			var restoreService = storage.NewRestoreService(&storage.RestoreOption{Ctx: context.TODO(), Filesystem: o, Operator: constants.StorageOperatorCli})
-			restoreService.Restore(p)
+			notifier, err := cmdconfig.Notifier()
+			if err != nil {
+				logger.Errorf("load notifications error: %v", err)
+				telemetry.Flush()
+				os.Exit(1)
+			}
+			schedule, err := bandwidth.ParseSchedule(downloadSchedule)
+			if err != nil {
+				logger.Errorf("%v", err)
+				telemetry.Flush()
+				os.Exit(1)
+			}
+			var restoreService = storage.NewRestoreService(&storage.RestoreOption{PasswordFile: po.PasswordFile, PasswordCommand: po.PasswordCommand, SecretRefs: true, Location: backend.Name, LocationOption: o, Operator: constants.StorageOperatorCli, History: history.NewStore(history.DefaultPath()), Notifier: notifier, DownloadSchedule: schedule, Force: force})
+			_, _, _, err = restoreService.RestoreContext(cmd.Context(), p)
+			telemetry.Flush()
+			if err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 	o.AddFlags(cmd)
+	po.AddFlags(cmd)
+	cmd.Flags().StringArrayVarP(&downloadSchedule, "download-schedule", "", nil, `Limits downloads by time of day, a window like "mon-fri 09:00-18:00 1024" in KiB/s, repeat for more windows. restic is restarted when the rate changes`)
+	cmd.Flags().BoolVarP(&force, "force", "", false, "Restore even when the target may not have enough free space")
 	return cmd
 }
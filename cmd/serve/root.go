@@ -0,0 +1,95 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+	cmdconfig "olares.com/backups-sdk/cmd/config"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/jobs"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/metrics"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/server"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+func NewCmdServe() *cobra.Command {
+	var listen, certFile, keyFile, metricsListen string
+	var tokens []string
+	var concurrency int
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve backups, restores and repository queries as a REST API",
+		Long: `Serve backups, restores and repository queries as a REST API until interrupted.
+Backups, restores, forget and check run as jobs, their progress is streamed as server-sent events.
+Every API request needs one of the bearer tokens, the OpenAPI document is served on /openapi.json.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer telemetry.Flush()
+
+			var resolved []string
+			for _, token := range tokens {
+				t, err := utils.ResolveSecret(token)
+				if err != nil {
+					return fmt.Errorf("api token: %v", err)
+				}
+				redact.Register(t)
+				resolved = append(resolved, t)
+			}
+			notifier, err := cmdconfig.Notifier()
+			if err != nil {
+				return err
+			}
+			s, err := server.New(&server.Options{
+				Tokens:   resolved,
+				Jobs:     jobs.NewManager(&jobs.Options{Concurrency: concurrency}),
+				History:  history.NewStore(history.DefaultPath()),
+				Notifier: notifier,
+			})
+			if err != nil {
+				return err
+			}
+
+			var ctx = cmd.Context()
+			var wg sync.WaitGroup
+			if metricsListen != "" {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := metrics.Serve(ctx, metricsListen); err != nil {
+						logger.Errorf("serve metrics error: %v", err)
+					}
+				}()
+			}
+			err = s.ListenAndServe(ctx, listen, certFile, keyFile)
+			wg.Wait()
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&listen, "listen", "", "127.0.0.1:8080", "Address to serve the API on")
+	cmd.Flags().StringArrayVarP(&tokens, "token", "", nil, "Bearer token accepted by the API, repeat it for more tokens, env:NAME, file:PATH and cmd:COMMAND read it from elsewhere")
+	cmd.Flags().StringVarP(&certFile, "tls-cert", "", "", "TLS certificate file, the API is served over HTTPS when set with --tls-key")
+	cmd.Flags().StringVarP(&keyFile, "tls-key", "", "", "TLS private key file")
+	cmd.Flags().IntVarP(&concurrency, "concurrency", "", jobs.DefaultConcurrency, "Jobs running at the same time, the others wait in the queue")
+	cmd.Flags().StringVarP(&metricsListen, "metrics-listen", "", "", "Serve the Prometheus metrics on an address like :9090, /metrics")
+	cmd.SilenceUsage = true
+
+	cmd.AddCommand(NewCmdOpenAPI())
+	return cmd
+}
+
+func NewCmdOpenAPI() *cobra.Command {
+	return &cobra.Command{
+		Use:   "openapi",
+		Short: "Print the OpenAPI document of the API",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var enc = json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(server.Spec())
+		},
+	}
+}
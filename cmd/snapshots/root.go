@@ -1,7 +1,12 @@
 package snapshots
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/config"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage"
 )
@@ -13,67 +18,30 @@ func NewCmdSnapshots() *cobra.Command {
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
 
-	rootSnapshotsCmds.AddCommand(NewCmdSpace())
-	rootSnapshotsCmds.AddCommand(NewCmdS3())
-	rootSnapshotsCmds.AddCommand(NewCmdCos())
-	rootSnapshotsCmds.AddCommand(NewCmdFs())
-
-	return rootSnapshotsCmds
-}
-
-func NewCmdSpace() *cobra.Command {
-	o := options.NewSnapshotsSpaceOption()
-	cmd := &cobra.Command{
-		Use:   "space",
-		Short: "Backup snapshots from Space",
-		Run: func(cmd *cobra.Command, args []string) {
-			var snapshotsService = storage.NewSnapshotsService(&storage.SnapshotsOption{Space: o})
-			snapshotsService.Snapshots()
-		},
+	for _, backend := range storage.BackendsFor(storage.OperationSnapshots) {
+		rootSnapshotsCmds.AddCommand(NewCmdBackend(backend))
 	}
-	o.AddFlags(cmd)
-	return cmd
-}
 
-func NewCmdS3() *cobra.Command {
-	o := options.NewSnapshotsAwsOption()
-	cmd := &cobra.Command{
-		Use:   "s3",
-		Short: "Backup snapshots from S3",
-		Run: func(cmd *cobra.Command, args []string) {
-			var snapshotsService = storage.NewSnapshotsService(&storage.SnapshotsOption{Aws: o})
-			snapshotsService.Snapshots()
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
-}
-
-func NewCmdCos() *cobra.Command {
-	o := options.NewSnapshotsTencentCloudOption()
-	cmd := &cobra.Command{
-		Use:   "cos",
-		Short: "Backup snapshots from Tencent COS",
-		Run: func(cmd *cobra.Command, args []string) {
-			var snapshotsService = storage.NewSnapshotsService(&storage.SnapshotsOption{TencentCloud: o})
-			snapshotsService.Snapshots()
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
+	return rootSnapshotsCmds
 }
 
-func NewCmdFs() *cobra.Command {
-	o := options.NewSnapshotsFilesystemOption()
+func NewCmdBackend(backend *storage.Backend) *cobra.Command {
+	o := backend.NewOption(storage.OperationSnapshots)
+	po := options.NewPasswordOption()
 	cmd := &cobra.Command{
-		Use:   "fs",
-		Short: "Backup snapshots from FileSystem",
+		Use:         backend.Name,
+		Annotations: map[string]string{config.AnnotationLocation: backend.Name},
+		Short:       fmt.Sprintf("Backup snapshots from %s", backend.Description),
 		Run: func(cmd *cobra.Command, args []string) {
-			var snapshotsService = storage.NewSnapshotsService(&storage.SnapshotsOption{Filesystem: o})
-			snapshotsService.Snapshots()
-
+			var snapshotsService = storage.NewSnapshotsService(&storage.SnapshotsOption{PasswordFile: po.PasswordFile, PasswordCommand: po.PasswordCommand, SecretRefs: true, Location: backend.Name, LocationOption: o})
+			_, err := snapshotsService.SnapshotsContext(cmd.Context())
+			telemetry.Flush()
+			if err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 	o.AddFlags(cmd)
+	po.AddFlags(cmd)
 	return cmd
 }
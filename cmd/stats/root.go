@@ -1,7 +1,12 @@
 package stats
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/cmd/telemetry"
+	"olares.com/backups-sdk/pkg/config"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage"
 )
@@ -13,67 +18,30 @@ func NewCmdStats() *cobra.Command {
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
 
-	rootStatsCmds.AddCommand(NewCmdSpace())
-	rootStatsCmds.AddCommand(NewCmdS3())
-	rootStatsCmds.AddCommand(NewCmdCos())
-	rootStatsCmds.AddCommand(NewCmdFs())
-
-	return rootStatsCmds
-}
-
-func NewCmdSpace() *cobra.Command {
-	o := options.NewSnapshotsSpaceOption()
-	cmd := &cobra.Command{
-		Use:   "space",
-		Short: "Repository stats from Space",
-		Run: func(cmd *cobra.Command, args []string) {
-			var statsService = storage.NewStatsService(&storage.SnapshotsOption{Space: o})
-			statsService.Stats()
-		},
+	for _, backend := range storage.BackendsFor(storage.OperationStats) {
+		rootStatsCmds.AddCommand(NewCmdBackend(backend))
 	}
-	o.AddFlags(cmd)
-	return cmd
-}
 
-func NewCmdS3() *cobra.Command {
-	o := options.NewSnapshotsAwsOption()
-	cmd := &cobra.Command{
-		Use:   "s3",
-		Short: "Repository stats from S3",
-		Run: func(cmd *cobra.Command, args []string) {
-			var statsService = storage.NewStatsService(&storage.SnapshotsOption{Aws: o})
-			statsService.Stats()
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
-}
-
-func NewCmdCos() *cobra.Command {
-	o := options.NewSnapshotsTencentCloudOption()
-	cmd := &cobra.Command{
-		Use:   "cos",
-		Short: "Repository stats from Tencent COS",
-		Run: func(cmd *cobra.Command, args []string) {
-			var statsService = storage.NewStatsService(&storage.SnapshotsOption{TencentCloud: o})
-			statsService.Stats()
-		},
-	}
-	o.AddFlags(cmd)
-	return cmd
+	return rootStatsCmds
 }
 
-func NewCmdFs() *cobra.Command {
-	o := options.NewSnapshotsFilesystemOption()
+func NewCmdBackend(backend *storage.Backend) *cobra.Command {
+	o := backend.NewOption(storage.OperationStats)
+	po := options.NewPasswordOption()
 	cmd := &cobra.Command{
-		Use:   "fs",
-		Short: "Repository stats from FileSystem",
+		Use:         backend.Name,
+		Annotations: map[string]string{config.AnnotationLocation: backend.Name},
+		Short:       fmt.Sprintf("Repository stats from %s", backend.Description),
 		Run: func(cmd *cobra.Command, args []string) {
-			var statsService = storage.NewStatsService(&storage.SnapshotsOption{Filesystem: o})
-			statsService.Stats()
-
+			var statsService = storage.NewStatsService(&storage.SnapshotsOption{PasswordFile: po.PasswordFile, PasswordCommand: po.PasswordCommand, SecretRefs: true, Location: backend.Name, LocationOption: o})
+			_, err := statsService.StatsContext(cmd.Context())
+			telemetry.Flush()
+			if err != nil {
+				os.Exit(1)
+			}
 		},
 	}
 	o.AddFlags(cmd)
+	po.AddFlags(cmd)
 	return cmd
 }
@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/metrics"
+	"olares.com/backups-sdk/pkg/tracing"
+)
+
+var textfile string
+var traceFile string
+var shutdownTracing func(context.Context) error
+
+// AddFlags adds the global --metrics-textfile and --trace-file flags to the root command
+func AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&textfile, "metrics-textfile", "", os.Getenv(constants.ENV_BACKUPS_METRICS), "Write the metrics of the run to a file for the node_exporter textfile collector, for example /var/lib/node_exporter/backups.prom")
+	cmd.PersistentFlags().StringVarP(&traceFile, "trace-file", "", os.Getenv(constants.ENV_BACKUPS_TRACE), "Append the OpenTelemetry spans of the run to a file as json, the trace id is the traceId of the logs")
+}
+
+// Start sets up tracing when --trace-file is set, call it before the command runs
+func Start() error {
+	if traceFile == "" {
+		return nil
+	}
+	shutdown, err := tracing.Init(traceFile)
+	if err != nil {
+		return fmt.Errorf("init tracing error: %v", err)
+	}
+	shutdownTracing = shutdown
+	return nil
+}
+
+// Flush writes the metrics file and the pending spans, call it before the command exits.
+// The metrics file is replaced on every run, so the last successes are restored from the job history first
+func Flush() {
+	if shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warnf("write trace file %s error: %v", traceFile, err)
+		}
+		cancel()
+		shutdownTracing = nil
+	}
+
+	if textfile == "" {
+		return
+	}
+
+	records, err := history.NewStore(history.DefaultPath()).List(&history.Filter{Outcome: history.OutcomeSuccess})
+	if err != nil {
+		logger.Warnf("read job history error: %v", err)
+	}
+	for _, r := range records {
+		if !r.DryRun {
+			metrics.RestoreLastSuccess(string(r.Operation), r.Location, r.Repo, r.FinishedAt)
+		}
+	}
+
+	if err := metrics.WriteTextfile(textfile); err != nil {
+		logger.Warnf("write metrics textfile %s error: %v", textfile, err)
+	}
+}
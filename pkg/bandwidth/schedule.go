@@ -0,0 +1,202 @@
+package bandwidth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Window limits the rate on its weekdays from Start to End in local time,
+// an End that is not after Start ends on the next day, like 22:00-06:00
+type Window struct {
+	Days  [7]bool       // indexed by time.Weekday, the day the window starts
+	Start time.Duration // since midnight
+	End   time.Duration // since midnight, 24h at most
+	Rate  int64         // KiB/s
+}
+
+// Schedule is a list of windows, the lowest rate applies when windows overlap and
+// there is no limit outside of them. Its text form is a list like
+//
+//	["mon-fri 09:00-18:00 1024", "sat,sun 10:00-16:00 4096", "* 22:00-06:00 8192"]
+type Schedule []Window
+
+// ParseWindow reads a window like "mon-fri 09:00-18:00 1024", the days are a list of
+// weekdays or ranges, or * for every day, and the rate is in KiB/s
+func ParseWindow(s string) (Window, error) {
+	var w Window
+	var fields = strings.Fields(s)
+	if len(fields) != 3 {
+		return w, fmt.Errorf("bandwidth window %q must look like \"mon-fri 09:00-18:00 1024\"", s)
+	}
+	if err := w.parseDays(fields[0]); err != nil {
+		return w, fmt.Errorf("bandwidth window %q: %v", s, err)
+	}
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	var err error
+	if !ok {
+		return w, fmt.Errorf("bandwidth window %q: time range %q must look like 09:00-18:00", s, fields[1])
+	}
+	if w.Start, err = parseClock(start); err != nil || w.Start == 24*time.Hour {
+		return w, fmt.Errorf("bandwidth window %q: invalid start %q", s, start)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("bandwidth window %q: invalid end %q", s, end)
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("bandwidth window %q is empty", s)
+	}
+
+	if w.Rate, err = strconv.ParseInt(fields[2], 10, 64); err != nil || w.Rate <= 0 {
+		return w, fmt.Errorf("bandwidth window %q: rate %q must be a positive number of KiB/s", s, fields[2])
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(s string) error {
+	if s == "*" {
+		for i := range w.Days {
+			w.Days[i] = true
+		}
+		return nil
+	}
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", from)
+		}
+		var last = first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return fmt.Errorf("unknown weekday %q", to)
+			}
+		}
+		// a range may wrap around the week, like fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock reads hh:mm, 24:00 is the end of the day
+func parseClock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok || len(h) != 2 || len(m) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	hours, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(m)
+	if err != nil {
+		return 0, err
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func (w Window) String() string {
+	var days []string
+	for d := 0; d < 7; d++ {
+		if w.Days[d] {
+			days = append(days, weekdayNames[d])
+		}
+	}
+	if len(days) == 7 {
+		days = []string{"*"}
+	}
+	var clock = func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%s %s-%s %d", strings.Join(days, ","), clock(w.Start), clock(w.End), w.Rate)
+}
+
+func (w Window) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+func (w *Window) UnmarshalText(data []byte) error {
+	res, err := ParseWindow(string(data))
+	if err != nil {
+		return err
+	}
+	*w = res
+	return nil
+}
+
+// ParseSchedule reads the windows of a schedule, nil when there are none
+func ParseSchedule(windows []string) (Schedule, error) {
+	var res Schedule
+	for _, s := range windows {
+		w, err := ParseWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, nil
+}
+
+// At is the rate at t, zero when no window limits it, and the next time the rate changes,
+// the zero time when it never changes
+func (s Schedule) At(t time.Time) (rate int64, next time.Time) {
+	rate, next = s.boundary(t)
+	// adjacent windows of the same rate do not change it, a week of boundaries repeats
+	for i := 0; i < 7*2*len(s) && !next.IsZero(); i++ {
+		r, n := s.boundary(next)
+		if r != rate {
+			return rate, next
+		}
+		next = n
+	}
+	return rate, time.Time{}
+}
+
+// boundary is the rate at t and the next start or end of a window
+func (s Schedule) boundary(t time.Time) (rate int64, next time.Time) {
+	for _, w := range s {
+		// a window that started yesterday may still be open
+		for offset := -1; offset <= 7; offset++ {
+			var day = time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, t.Location())
+			if !w.Days[day.Weekday()] {
+				continue
+			}
+			var start = at(day, w.Start)
+			var end = at(day, w.End)
+			if w.End <= w.Start {
+				end = at(day.AddDate(0, 0, 1), w.End)
+			}
+			if !t.Before(start) && t.Before(end) && (rate == 0 || w.Rate < rate) {
+				rate = w.Rate
+			}
+			for _, b := range []time.Time{start, end} {
+				if b.After(t) && (next.IsZero() || b.Before(next)) {
+					next = b
+				}
+			}
+		}
+	}
+	return rate, next
+}
+
+// at is the wall clock d after midnight of day, it follows daylight saving time changes
+func at(day time.Time, d time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, day.Location())
+}
@@ -0,0 +1,97 @@
+package bandwidth
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestParseWindow(t *testing.T) {
+	var tests = []struct {
+		spec string
+		text string
+		err  bool
+	}{
+		{spec: "mon-fri 09:00-18:00 1024", text: "mon,tue,wed,thu,fri 09:00-18:00 1024"},
+		{spec: "SAT,sun 10:00-16:00 4096", text: "sun,sat 10:00-16:00 4096"},
+		{spec: "fri-mon 22:00-06:00 512", text: "sun,mon,fri,sat 22:00-06:00 512"},
+		{spec: "* 00:00-24:00 100", text: "* 00:00-24:00 100"},
+		{spec: "mon 09:00-18:00", err: true},
+		{spec: "funday 09:00-18:00 1", err: true},
+		{spec: "mon 9:00-18:00 1", err: true},
+		{spec: "mon 09:00-25:00 1", err: true},
+		{spec: "mon 24:00-06:00 1", err: true},
+		{spec: "mon 09:00-09:00 1", err: true},
+		{spec: "mon 09:00-18:00 0", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			w, err := ParseWindow(tt.spec)
+			assert.Equal(t, err != nil, tt.err)
+			if err == nil {
+				assert.Equal(t, w.String(), tt.text)
+			}
+		})
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	schedule, err := ParseSchedule([]string{"mon-fri 09:00-18:00 1024", "mon-fri 12:00-13:00 256", "fri 22:00-06:00 512"})
+	assert.Equal(t, err, nil)
+
+	var date = func(day, hour, minute int) time.Time {
+		// 2026-10-19 is a monday
+		return time.Date(2026, 10, 19+day, hour, minute, 0, 0, time.UTC)
+	}
+	var tests = []struct {
+		name string
+		t    time.Time
+		rate int64
+		next time.Time
+	}{
+		{name: "monday night", t: date(0, 3, 0), rate: 0, next: date(0, 9, 0)},
+		{name: "monday morning", t: date(0, 9, 0), rate: 1024, next: date(0, 12, 0)},
+		{name: "lowest rate of overlapping windows", t: date(0, 12, 30), rate: 256, next: date(0, 13, 0)},
+		{name: "monday evening", t: date(0, 18, 0), rate: 0, next: date(1, 9, 0)},
+		{name: "friday night", t: date(4, 23, 0), rate: 512, next: date(5, 6, 0)},
+		{name: "saturday morning", t: date(5, 5, 59), rate: 512, next: date(5, 6, 0)},
+		{name: "weekend", t: date(5, 12, 0), rate: 0, next: date(7, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, next := schedule.At(tt.t)
+			assert.Equal(t, rate, tt.rate)
+			assert.Equal(t, next, tt.next)
+		})
+	}
+
+	rate, next := Schedule(nil).At(date(0, 12, 0))
+	assert.Equal(t, rate, int64(0))
+	assert.Equal(t, next.IsZero(), true)
+}
+
+func TestScheduleJSON(t *testing.T) {
+	var s Schedule
+	assert.Equal(t, json.Unmarshal([]byte(`["mon-fri 09:00-18:00 1024", "* 22:00-06:00 8192"]`), &s), nil)
+	assert.Equal(t, len(s), 2)
+	data, err := json.Marshal(s)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(data), `["mon,tue,wed,thu,fri 09:00-18:00 1024","* 22:00-06:00 8192"]`)
+	assert.NotEqual(t, json.Unmarshal([]byte(`["mon 09:00"]`), &s), nil)
+}
+
+func TestScheduleAdjacentWindows(t *testing.T) {
+	schedule, err := ParseSchedule([]string{"* 08:00-12:00 1024", "* 12:00-20:00 1024"})
+	assert.Equal(t, err, nil)
+	rate, next := schedule.At(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, rate, int64(1024))
+	assert.Equal(t, next, time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC))
+
+	schedule, err = ParseSchedule([]string{"* 00:00-24:00 1024"})
+	assert.Equal(t, err, nil)
+	rate, next = schedule.At(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, rate, int64(1024))
+	assert.Equal(t, next.IsZero(), true)
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the backups config file, by default {base dir}/backups.yaml
+//
+//	defaults:
+//	  limit_upload_rate: "2048"
+//	  upload_schedule: ["mon-fri 09:00-18:00 1024"]
+//	  excludes: ["*.tmp"]
+//	  retention:
+//	    keep_daily: 7
+//	profiles:
+//	  home:
+//	    location: space
+//	    repo_name: home
+//	    credentials:
+//	      access_token: xxx
+//	    params:
+//	      olares-did: did:key:xxx
+//
+// see Notifications and Schedule for the notifications and schedules sections
+type Config struct {
+	Defaults      Defaults             `json:"defaults,omitempty"`
+	Profiles      map[string]*Profile  `json:"profiles,omitempty"`
+	Notifications *Notifications       `json:"notifications,omitempty"`
+	Schedules     map[string]*Schedule `json:"schedules,omitempty"`
+}
+
+// Defaults apply to every command, a profile can override them
+type Defaults struct {
+	LimitUploadRate   string     `json:"limit_upload_rate,omitempty"`
+	LimitDownloadRate string     `json:"limit_download_rate,omitempty"`
+	UploadSchedule    []string   `json:"upload_schedule,omitempty"`   // windows like "mon-fri 09:00-18:00 1024"
+	DownloadSchedule  []string   `json:"download_schedule,omitempty"` // windows like "mon-fri 09:00-18:00 1024"
+	Excludes          []string   `json:"excludes,omitempty"`
+	Retention         *Retention `json:"retention,omitempty"`
+}
+
+// Retention is the snapshot retention policy, it follows the keep options of restic forget
+type Retention struct {
+	KeepLast    int    `json:"keep_last,omitempty"`
+	KeepHourly  int    `json:"keep_hourly,omitempty"`
+	KeepDaily   int    `json:"keep_daily,omitempty"`
+	KeepWeekly  int    `json:"keep_weekly,omitempty"`
+	KeepMonthly int    `json:"keep_monthly,omitempty"`
+	KeepYearly  int    `json:"keep_yearly,omitempty"`
+	KeepWithin  string `json:"keep_within,omitempty"`
+}
+
+// Profile is a named repository with its own defaults
+//
+// secrets may be given as references env:NAME, file:PATH or cmd:COMMAND to keep them out of the file
+type Profile struct {
+	options.Repository
+	Defaults
+	PasswordFile    string `json:"password_file,omitempty"`
+	PasswordCommand string `json:"password_command,omitempty"`
+}
+
+func DefaultPath() string {
+	return path.Join(utils.GetBaseDir(), constants.DefaultConfig)
+}
+
+// Load reads the config file, a missing file is an empty config unless required is set
+func Load(file string, required bool) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("read config %s error: %v", file, err)
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Config, error) {
+	var c = &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("parse config error: %v", err)
+	}
+	return c, nil
+}
+
+func (c *Config) Validate() error {
+	if err := c.Defaults.Validate(); err != nil {
+		return fmt.Errorf("defaults: %v", err)
+	}
+	for _, name := range c.ProfileNames() {
+		var p = c.Profiles[name]
+		if p == nil {
+			return fmt.Errorf("profile %s is empty", name)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %v", name, err)
+		}
+	}
+	if err := c.Notifications.Validate(); err != nil {
+		return fmt.Errorf("notifications: %v", err)
+	}
+	for _, name := range c.ScheduleNames() {
+		var s = c.Schedules[name]
+		if s == nil {
+			return fmt.Errorf("schedule %s is empty", name)
+		}
+		if err := s.Validate(c); err != nil {
+			return fmt.Errorf("schedule %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) ProfileNames() []string {
+	var names = make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the profile merged with the defaults, profile values win and excludes add up
+func (c *Config) Profile(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok || p == nil {
+		return nil, fmt.Errorf("profile %s not found", name)
+	}
+
+	var res = *p
+	res.LimitUploadRate = utils.DefaultValue(c.Defaults.LimitUploadRate, p.LimitUploadRate)
+	res.LimitDownloadRate = utils.DefaultValue(c.Defaults.LimitDownloadRate, p.LimitDownloadRate)
+	res.Excludes = append(append([]string{}, c.Defaults.Excludes...), p.Excludes...)
+	if len(res.UploadSchedule) == 0 {
+		res.UploadSchedule = c.Defaults.UploadSchedule
+	}
+	if len(res.DownloadSchedule) == 0 {
+		res.DownloadSchedule = c.Defaults.DownloadSchedule
+	}
+	if res.Retention == nil {
+		res.Retention = c.Defaults.Retention
+	}
+	return &res, nil
+}
+
+// Redacted returns a copy that is safe to print
+func (c *Config) Redacted() *Config {
+	var res = &Config{Defaults: c.Defaults, Notifications: c.Notifications.Redacted(), Schedules: c.Schedules}
+	if c.Profiles != nil {
+		res.Profiles = make(map[string]*Profile, len(c.Profiles))
+		for name, p := range c.Profiles {
+			if p != nil {
+				res.Profiles[name] = p.Redacted()
+			}
+		}
+	}
+	return res
+}
+
+func (d *Defaults) Validate() error {
+	if err := validateRate(d.LimitUploadRate); err != nil {
+		return fmt.Errorf("limit_upload_rate %v", err)
+	}
+	if err := validateRate(d.LimitDownloadRate); err != nil {
+		return fmt.Errorf("limit_download_rate %v", err)
+	}
+	if _, err := bandwidth.ParseSchedule(d.UploadSchedule); err != nil {
+		return fmt.Errorf("upload_schedule: %v", err)
+	}
+	if _, err := bandwidth.ParseSchedule(d.DownloadSchedule); err != nil {
+		return fmt.Errorf("download_schedule: %v", err)
+	}
+	if d.Retention != nil {
+		if err := d.Retention.Validate(); err != nil {
+			return fmt.Errorf("retention: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *Retention) Validate() error {
+	for _, v := range []int{r.KeepLast, r.KeepHourly, r.KeepDaily, r.KeepWeekly, r.KeepMonthly, r.KeepYearly} {
+		if v < 0 {
+			return fmt.Errorf("keep values must not be negative")
+		}
+	}
+	if r.IsEmpty() {
+		return fmt.Errorf("at least one keep value is required")
+	}
+	return nil
+}
+
+func (r *Retention) IsEmpty() bool {
+	return r.KeepLast == 0 && r.KeepHourly == 0 && r.KeepDaily == 0 && r.KeepWeekly == 0 &&
+		r.KeepMonthly == 0 && r.KeepYearly == 0 && r.KeepWithin == ""
+}
+
+func (p *Profile) Validate() error {
+	if err := p.Repository.Validate(); err != nil {
+		return err
+	}
+	if _, err := p.Flags(); err != nil {
+		return err
+	}
+	return p.Defaults.Validate()
+}
+
+func (p *Profile) Redacted() *Profile {
+	var res = *p
+	if p.Credentials != nil {
+		var c = *p.Credentials
+		for _, v := range []*string{&c.AccessKey, &c.SecretAccessKey, &c.SessionToken, &c.AccessToken, &c.Password} {
+			*v = redactSecret(*v)
+		}
+		res.Credentials = &c
+	}
+	return &res
+}
+
+// Flags maps the profile to cli flag values, a command only takes the flags it defines
+func (p *Profile) Flags() (map[string][]string, error) {
+	var flags = make(map[string][]string)
+	var set = func(name, value string) {
+		if value != "" {
+			flags[name] = []string{value}
+		}
+	}
+
+	for k, v := range p.Params {
+		set(k, v)
+	}
+	set("repo-name", p.RepoName)
+	set("repo-id", p.RepoId)
+
+	if p.Location == "sftp" && p.Endpoint != "" {
+		u, err := url.Parse(p.Endpoint)
+		if err != nil || u.Scheme != "sftp" {
+			return nil, fmt.Errorf("sftp endpoint must look like sftp://user@host:port/path, got %s", p.Endpoint)
+		}
+		if port := u.Port(); port != "" {
+			if _, err := strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("sftp endpoint port %s is invalid", port)
+			}
+			set("port", port)
+		}
+		if u.User != nil {
+			set("user", u.User.Username())
+		}
+		set("host", u.Hostname())
+		set("remote-path", u.Path)
+	} else {
+		set("endpoint", p.Endpoint)
+	}
+
+	var creds = p.GetCredentials()
+	set("access-key", creds.AccessKey)
+	set("secret-access-key", creds.SecretAccessKey)
+	set("session-token", creds.SessionToken)
+	set("aws-profile", creds.Profile)
+	set("access-token", creds.AccessToken)
+	set("username", creds.Username)
+	set("rest-password", creds.Password)
+	set("key-file", creds.KeyFile)
+	set("password-file", p.PasswordFile)
+	set("password-command", p.PasswordCommand)
+
+	for k, v := range p.Defaults.Flags() {
+		flags[k] = v
+	}
+	return flags, nil
+}
+
+// Flags maps the defaults to cli flag values
+func (d *Defaults) Flags() map[string][]string {
+	var flags = make(map[string][]string)
+	if d.LimitUploadRate != "" {
+		flags["limit-upload-rate"] = []string{d.LimitUploadRate}
+	}
+	if d.LimitDownloadRate != "" {
+		flags["limit-download-rate"] = []string{d.LimitDownloadRate}
+	}
+	if len(d.UploadSchedule) > 0 {
+		flags["upload-schedule"] = d.UploadSchedule
+	}
+	if len(d.DownloadSchedule) > 0 {
+		flags["download-schedule"] = d.DownloadSchedule
+	}
+	if len(d.Excludes) > 0 {
+		flags["exclude"] = d.Excludes
+	}
+	return flags
+}
+
+func validateRate(rate string) error {
+	if rate == "" {
+		return nil
+	}
+	if v, err := strconv.ParseInt(strings.TrimSpace(rate), 10, 64); err != nil || v < 0 {
+		return fmt.Errorf("%q must be a number of KiB/s", rate)
+	}
+	return nil
+}
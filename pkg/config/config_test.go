@@ -0,0 +1,250 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/redact"
+)
+
+var testConfig = `
+defaults:
+  limit_upload_rate: "2048"
+  upload_schedule: ["mon-fri 09:00-18:00 1024"]
+  excludes: ["*.tmp"]
+  retention:
+    keep_daily: 7
+profiles:
+  home:
+    location: s3
+    repo_name: home
+    endpoint: https://bucket.s3.us-east-1.amazonaws.com/prefix
+    credentials:
+      access_key: AKIAEXAMPLE
+      secret_access_key: supersecret
+    excludes: ["cache"]
+    password_file: /root/.backups/home.password
+  nas:
+    location: sftp
+    repo_name: nas
+    endpoint: sftp://backup@nas.local:2222/srv/backups
+    credentials:
+      key_file: /root/.ssh/id_ed25519
+    params:
+      known-hosts: /root/.ssh/known_hosts
+    limit_upload_rate: "512"
+    password_command: pass show backups/nas
+    retention:
+      keep_last: 3
+`
+
+func TestProfile(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.Validate(), nil)
+	assert.Equal(t, c.ProfileNames(), []string{"home", "nas"})
+
+	home, err := c.Profile("home")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, home.LimitUploadRate, "2048")
+	assert.Equal(t, home.Excludes, []string{"*.tmp", "cache"})
+	assert.Equal(t, home.Retention.KeepDaily, 7)
+
+	nas, err := c.Profile("nas")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, nas.LimitUploadRate, "512")
+	assert.Equal(t, nas.Retention.KeepLast, 3)
+
+	flags, err := nas.Flags()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, flags["host"], []string{"nas.local"})
+	assert.Equal(t, flags["port"], []string{"2222"})
+	assert.Equal(t, flags["user"], []string{"backup"})
+	assert.Equal(t, flags["remote-path"], []string{"/srv/backups"})
+	assert.Equal(t, flags["known-hosts"], []string{"/root/.ssh/known_hosts"})
+	assert.Equal(t, flags["key-file"], []string{"/root/.ssh/id_ed25519"})
+	assert.Equal(t, flags["upload-schedule"], []string{"mon-fri 09:00-18:00 1024"})
+
+	_, err = c.Profile("missing")
+	assert.NotEqual(t, err, nil)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	var tests = []struct {
+		name   string
+		config string
+	}{
+		{name: "unknown field", config: "profiles:\n  a:\n    location: fs\n    repo_name: a\n    bucket: x\n"},
+		{name: "missing repo name", config: "profiles:\n  a:\n    location: fs\n"},
+		{name: "invalid rate", config: "defaults:\n  limit_upload_rate: fast\n"},
+		{name: "invalid upload schedule", config: "defaults:\n  upload_schedule: [\"mon 09:00-18:00\"]\n"},
+		{name: "empty retention", config: "defaults:\n  retention: {}\n"},
+		{name: "invalid sftp endpoint", config: "profiles:\n  a:\n    location: sftp\n    repo_name: a\n    endpoint: nas.local:/srv\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse([]byte(tt.config))
+			if err == nil {
+				err = c.Validate()
+			}
+			assert.NotEqual(t, err, nil)
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	assert.Equal(t, err, nil)
+
+	var r = c.Redacted()
+	assert.Equal(t, r.Profiles["home"].Credentials.AccessKey, redact.Mask)
+	assert.Equal(t, r.Profiles["home"].Credentials.SecretAccessKey, redact.Mask)
+	assert.Equal(t, r.Profiles["nas"].Credentials.KeyFile, "/root/.ssh/id_ed25519")
+
+	// the original config is untouched
+	assert.Equal(t, c.Profiles["home"].Credentials.SecretAccessKey, "supersecret")
+}
+
+func TestApplyFlags(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	assert.Equal(t, err, nil)
+	home, err := c.Profile("home")
+	assert.Equal(t, err, nil)
+	flags, err := home.Flags()
+	assert.Equal(t, err, nil)
+
+	var o = options.NewBackupAwsOption()
+	var cmd = &cobra.Command{Use: "s3"}
+	o.AddFlags(cmd)
+	assert.Equal(t, cmd.Flags().Parse([]string{"--repo-name", "override", "--path", "/data"}), nil)
+
+	assert.Equal(t, ApplyFlags(cmd, flags), nil)
+	assert.Equal(t, o.RepoName, "override")
+	assert.Equal(t, o.Path, "/data")
+	assert.Equal(t, o.Endpoint, "https://bucket.s3.us-east-1.amazonaws.com/prefix")
+	assert.Equal(t, o.SecretAccessKey, "supersecret")
+	assert.Equal(t, o.LimitUploadRate, "2048")
+	assert.Equal(t, strings.Join(o.Excludes, ","), "*.tmp,cache")
+}
+
+var testNotifications = `
+notifications:
+  webhooks:
+    ops:
+      url: https://hooks.example.com/backups
+      secret: hooksecret
+  smtp:
+    admin:
+      host: smtp.example.com
+      password: env:BACKUPS_TEST_SMTP_PASSWORD
+      from: backups@example.com
+      to: [admin@example.com]
+  rules:
+    - events: [backup_failure, check_failure]
+      channels: [ops, admin]
+`
+
+func TestNotifications(t *testing.T) {
+	c, err := Parse([]byte(testNotifications))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.Validate(), nil)
+
+	var r = c.Redacted()
+	assert.Equal(t, r.Notifications.Webhooks["ops"].Secret, redact.Mask)
+	assert.Equal(t, r.Notifications.Smtp["admin"].Password, "env:BACKUPS_TEST_SMTP_PASSWORD")
+	assert.Equal(t, c.Notifications.Webhooks["ops"].Secret, "hooksecret")
+
+	t.Setenv("BACKUPS_TEST_SMTP_PASSWORD", "smtpsecret")
+	n, err := c.Notifications.Notifier()
+	assert.Equal(t, err, nil)
+	assert.NotEqual(t, n, nil)
+
+	n, err = (&Config{}).Notifications.Notifier()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n == nil, true)
+
+	for _, invalid := range []string{
+		"notifications:\n  webhooks:\n    ops:\n      url: ftp://x\n",
+		"notifications:\n  smtp:\n    admin:\n      host: smtp.example.com\n",
+		"notifications:\n  rules:\n    - events: [backup_failure]\n      channels: [missing]\n",
+	} {
+		c, err := Parse([]byte(invalid))
+		assert.Equal(t, err, nil)
+		assert.NotEqual(t, c.Validate(), nil)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	c, err := Parse([]byte(testConfig + `
+schedules:
+  home-nightly:
+    profile: home
+    cron: "0 2 * * *"
+    path: /data/home
+    excludes: ["*.iso"]
+    jitter: 10m
+    retention_cron: "@weekly"
+    prune: true
+  nas-check:
+    profile: nas
+    check_cron: "0 5 1 * *"
+    read_data_subset: 5%
+    catch_up: false
+    upload_schedule: ["* 08:00-20:00 256"]
+`))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.Validate(), nil)
+	assert.Equal(t, c.ScheduleNames(), []string{"home-nightly", "nas-check"})
+
+	home, err := c.Job("home-nightly")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, home.Repository.RepoName, "home")
+	assert.Equal(t, home.Excludes, []string{"*.tmp", "cache", "*.iso"})
+	assert.Equal(t, home.LimitUploadRate, "2048")
+	assert.Equal(t, home.UploadSchedule[0].String(), "mon,tue,wed,thu,fri 09:00-18:00 1024")
+	assert.Equal(t, home.Jitter, 10*time.Minute)
+	assert.Equal(t, home.CatchUp, true)
+	assert.Equal(t, home.Retention.KeepDaily, 7)
+	assert.Equal(t, home.Retention.Prune, true)
+	tasks, err := home.Tasks()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(tasks), 2)
+	assert.Equal(t, tasks[0].Name, "home-nightly/backup")
+	assert.Equal(t, tasks[1].Name, "home-nightly/retention")
+	assert.Equal(t, tasks[0].Repo, tasks[1].Repo)
+
+	nas, err := c.Job("nas-check")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, nas.CatchUp, false)
+	assert.Equal(t, nas.UploadSchedule[0].Rate, int64(256))
+	assert.Equal(t, nas.Retention.KeepLast, 3)
+	tasks, err = nas.Tasks()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(tasks), 1)
+	assert.Equal(t, tasks[0].Name, "nas-check/check")
+
+	var base = "profiles:\n  home:\n    location: fs\n    repo_name: home\n    password_file: /root/.backups/home.password\n  other:\n    location: fs\n    repo_name: other\n"
+	for _, invalid := range []string{
+		"schedules:\n  a:\n    profile: other\n    check_cron: \"@daily\"\n",
+		"schedules:\n  a:\n    profile: missing\n    cron: \"@daily\"\n    path: /data\n",
+		"schedules:\n  a:\n    profile: home\n",
+		"schedules:\n  a:\n    profile: home\n    cron: \"0 25 * * *\"\n    path: /data\n",
+		"schedules:\n  a:\n    profile: home\n    cron: \"@daily\"\n",
+		"schedules:\n  a:\n    profile: home\n    retention_cron: \"@daily\"\n",
+		"schedules:\n  a:\n    profile: home\n    check_cron: \"@daily\"\n    jitter: soon\n",
+		"schedules:\n  a:\n    profile: home\n    check_cron: \"@daily\"\n    upload_schedule: [\"* 08:00-20:00 0\"]\n",
+	} {
+		c, err := Parse([]byte(base + invalid))
+		assert.Equal(t, err, nil)
+		assert.NotEqual(t, c.Validate(), nil)
+	}
+
+	c, err = Parse([]byte(base + "schedules:\n  a:\n    profile: home\n    check_cron: \"@daily\"\n"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.Validate(), nil)
+}
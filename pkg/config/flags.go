@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// AnnotationLocation marks the location a command works on, a profile of another location is refused
+const AnnotationLocation = "location"
+
+// ApplyFlags sets the flags the command defines and the user did not set, so flags override the config
+func ApplyFlags(cmd *cobra.Command, flags map[string][]string) error {
+	var names = make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var f = cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		for _, v := range flags[name] {
+			if err := cmd.Flags().Set(name, v); err != nil {
+				return fmt.Errorf("config value of --%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply loads the config file and applies the defaults, or the profile when one is given, to the command
+func Apply(cmd *cobra.Command, file, profile string) error {
+	c, err := Load(Path(file), file != "")
+	if err != nil {
+		return err
+	}
+
+	if profile == "" {
+		if err := c.Defaults.Validate(); err != nil {
+			return fmt.Errorf("config defaults: %v", err)
+		}
+		return ApplyFlags(cmd, c.Defaults.Flags())
+	}
+
+	p, err := c.Profile(profile)
+	if err != nil {
+		return err
+	}
+	if location, ok := cmd.Annotations[AnnotationLocation]; ok && location != p.Location {
+		return fmt.Errorf("profile %s is a %s repository, it can not be used with %s", profile, p.Location, cmd.CommandPath())
+	}
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("profile %s: %v", profile, err)
+	}
+	flags, err := p.Flags()
+	if err != nil {
+		return err
+	}
+	return ApplyFlags(cmd, flags)
+}
+
+// Path returns the config file to read, the default one when file is empty
+func Path(file string) string {
+	if file != "" {
+		return file
+	}
+	return DefaultPath()
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/storage/notification"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// Notifications routes finished jobs to channels, every channel is named and rules refer to the names
+//
+//	notifications:
+//	  webhooks:
+//	    ops:
+//	      url: https://hooks.example.com/backups
+//	      secret: env:BACKUPS_WEBHOOK_SECRET
+//	  smtp:
+//	    admin:
+//	      host: smtp.example.com
+//	      username: backups@example.com
+//	      password: file:/etc/backups/smtp
+//	      from: backups@example.com
+//	      to: [admin@example.com]
+//	  rules:
+//	    - events: [backup_failure, restore_failure, check_failure]
+//	      channels: [ops, admin]
+type Notifications struct {
+	Webhooks map[string]*Webhook `json:"webhooks,omitempty"`
+	Smtp     map[string]*Smtp    `json:"smtp,omitempty"`
+	Cloud    map[string]*Cloud   `json:"cloud,omitempty"`
+	Rules    []*Rule             `json:"rules,omitempty"`
+}
+
+type Webhook struct {
+	Url     string            `json:"url"`
+	Secret  string            `json:"secret,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type Smtp struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Cloud records successful backups as cloud snapshots through the outbox, {base dir}/outbox
+type Cloud struct {
+	CloudApiUrl string `json:"cloud_api_url,omitempty"`
+	OlaresDid   string `json:"olares_did"`
+	AccessToken string `json:"access_token"`
+}
+
+type Rule struct {
+	Events   []notification.EventType `json:"events"`
+	Channels []string                 `json:"channels"`
+	Subject  string                   `json:"subject,omitempty"`
+	Template string                   `json:"template,omitempty"`
+}
+
+func (n *Notifications) IsEmpty() bool {
+	return n == nil || len(n.Rules) == 0
+}
+
+// Validate checks the section without resolving the secrets
+func (n *Notifications) Validate() error {
+	if n == nil {
+		return nil
+	}
+	for _, name := range sortedKeys(n.Webhooks) {
+		if w := n.Webhooks[name]; w == nil || !strings.HasPrefix(w.Url, "http://") && !strings.HasPrefix(w.Url, "https://") {
+			return fmt.Errorf("webhook %s: url must be http or https", name)
+		}
+	}
+	for _, name := range sortedKeys(n.Smtp) {
+		if s := n.Smtp[name]; s == nil || s.Host == "" || s.From == "" || len(s.To) == 0 {
+			return fmt.Errorf("smtp %s: host, from and to are required", name)
+		} else if s.Port < 0 || s.Port > 65535 {
+			return fmt.Errorf("smtp %s: port %d is invalid", name, s.Port)
+		}
+	}
+	for _, name := range sortedKeys(n.Cloud) {
+		if c := n.Cloud[name]; c == nil || c.OlaresDid == "" || c.AccessToken == "" {
+			return fmt.Errorf("cloud %s: olares_did and access_token are required", name)
+		}
+	}
+	_, err := notification.NewNotifier(n.channels(), n.rules())
+	return err
+}
+
+// Notifier resolves the secrets of the channels, it is nil when no rule is configured
+func (n *Notifications) Notifier() (*notification.Notifier, error) {
+	if n.IsEmpty() {
+		return nil, nil
+	}
+	var channels = n.channels()
+	for _, c := range channels {
+		var secrets []*string
+		switch v := c.(type) {
+		case *notification.Webhook:
+			secrets = []*string{&v.Secret}
+		case *notification.Smtp:
+			secrets = []*string{&v.Password}
+		case *notification.Cloud:
+			secrets = []*string{&v.Token}
+		}
+		for _, s := range secrets {
+			value, err := utils.ResolveSecret(*s)
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: %v", c.Name(), err)
+			}
+			redact.Register(value)
+			*s = value
+		}
+	}
+	return notification.NewNotifier(channels, n.rules())
+}
+
+func (n *Notifications) channels() []notification.Channel {
+	var res []notification.Channel
+	for _, name := range sortedKeys(n.Webhooks) {
+		var w = n.Webhooks[name]
+		res = append(res, &notification.Webhook{ChannelName: name, Url: w.Url, Secret: w.Secret, Headers: w.Headers})
+	}
+	for _, name := range sortedKeys(n.Smtp) {
+		var s = n.Smtp[name]
+		res = append(res, &notification.Smtp{ChannelName: name, Host: s.Host, Port: s.Port, Username: s.Username,
+			Password: s.Password, From: s.From, To: s.To})
+	}
+	for _, name := range sortedKeys(n.Cloud) {
+		var c = n.Cloud[name]
+		res = append(res, &notification.Cloud{ChannelName: name, CloudApiUrl: c.CloudApiUrl, UserId: c.OlaresDid, Token: c.AccessToken,
+			Outbox: notification.NewOutbox(notification.DefaultOutboxDir())})
+	}
+	return res
+}
+
+func (n *Notifications) rules() []*notification.Rule {
+	var res = make([]*notification.Rule, 0, len(n.Rules))
+	for _, r := range n.Rules {
+		if r == nil {
+			r = &Rule{}
+		}
+		res = append(res, &notification.Rule{Events: r.Events, Channels: r.Channels, Subject: r.Subject, Template: r.Template})
+	}
+	return res
+}
+
+// Redacted returns a copy that is safe to print
+func (n *Notifications) Redacted() *Notifications {
+	if n == nil {
+		return nil
+	}
+	var res = &Notifications{Rules: n.Rules}
+	if n.Webhooks != nil {
+		res.Webhooks = make(map[string]*Webhook, len(n.Webhooks))
+		for name, w := range n.Webhooks {
+			if w != nil {
+				var c = *w
+				c.Secret = redactSecret(c.Secret)
+				res.Webhooks[name] = &c
+			}
+		}
+	}
+	if n.Smtp != nil {
+		res.Smtp = make(map[string]*Smtp, len(n.Smtp))
+		for name, s := range n.Smtp {
+			if s != nil {
+				var c = *s
+				c.Password = redactSecret(c.Password)
+				res.Smtp[name] = &c
+			}
+		}
+	}
+	if n.Cloud != nil {
+		res.Cloud = make(map[string]*Cloud, len(n.Cloud))
+		for name, s := range n.Cloud {
+			if s != nil {
+				var c = *s
+				c.AccessToken = redactSecret(c.AccessToken)
+				res.Cloud[name] = &c
+			}
+		}
+	}
+	return res
+}
+
+// redactSecret masks a secret value, env and file references are not secret and tell where the value comes from
+func redactSecret(v string) string {
+	if v != "" && !strings.HasPrefix(v, utils.SecretEnvPrefix) && !strings.HasPrefix(v, utils.SecretFilePrefix) {
+		return redact.Mask
+	}
+	return v
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
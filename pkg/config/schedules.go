@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/scheduler"
+)
+
+// Schedule runs the backup of a profile for the daemon, retention and check may run on their own schedules
+//
+//	schedules:
+//	  home-nightly:
+//	    profile: home
+//	    cron: "0 2 * * *"
+//	    path: /olares/userdata/home
+//	    jitter: 10m
+//	    retention_cron: "0 4 * * 0"
+//	    prune: true
+//	    check_cron: "0 5 1 * *"
+//	    read_data_subset: 5%
+type Schedule struct {
+	Profile         string     `json:"profile"`
+	Cron            string     `json:"cron,omitempty"` // backup schedule, empty runs no backup
+	Path            string     `json:"path,omitempty"`
+	Files           []string   `json:"files,omitempty"`
+	Excludes        []string   `json:"excludes,omitempty"`          // added to the excludes of the profile
+	LimitUploadRate string     `json:"limit_upload_rate,omitempty"` // the profile rate when empty
+	UploadSchedule  []string   `json:"upload_schedule,omitempty"`   // the profile schedule when empty
+	Retention       *Retention `json:"retention,omitempty"`         // the profile retention when empty
+	RetentionCron   string     `json:"retention_cron,omitempty"`    // empty applies the retention after every successful backup
+	Prune           bool       `json:"prune,omitempty"`
+	CheckCron       string     `json:"check_cron,omitempty"`
+	ReadDataSubset  string     `json:"read_data_subset,omitempty"`
+	Jitter          string     `json:"jitter,omitempty"`   // a duration like 10m
+	CatchUp         *bool      `json:"catch_up,omitempty"` // run missed schedules after downtime, true when unset
+}
+
+func (s *Schedule) Validate(c *Config) error {
+	p, err := c.Profile(s.Profile)
+	if err != nil {
+		return err
+	}
+	// the daemon runs the tasks in parallel without a terminal to ask for the password
+	if p.PasswordFile == "" && p.PasswordCommand == "" {
+		return fmt.Errorf("profile %s needs password_file or password_command to be scheduled", s.Profile)
+	}
+	if s.Cron == "" && s.RetentionCron == "" && s.CheckCron == "" {
+		return fmt.Errorf("cron, retention_cron or check_cron is required")
+	}
+	if s.Cron != "" && s.Path == "" && len(s.Files) == 0 {
+		return fmt.Errorf("path or files is required to back up")
+	}
+	for _, expr := range []string{s.Cron, s.RetentionCron, s.CheckCron} {
+		if expr == "" {
+			continue
+		}
+		if _, err := scheduler.ParseSchedule(expr); err != nil {
+			return err
+		}
+	}
+	if err := validateRate(s.LimitUploadRate); err != nil {
+		return fmt.Errorf("limit_upload_rate %v", err)
+	}
+	if _, err := bandwidth.ParseSchedule(s.UploadSchedule); err != nil {
+		return fmt.Errorf("upload_schedule: %v", err)
+	}
+	if s.Retention != nil {
+		if err := s.Retention.Validate(); err != nil {
+			return fmt.Errorf("retention: %v", err)
+		}
+	}
+	if s.RetentionCron != "" && s.Retention == nil && p.Retention == nil {
+		return fmt.Errorf("retention_cron requires a retention policy")
+	}
+	if _, err := s.JitterDuration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Schedule) JitterDuration() (time.Duration, error) {
+	if s.Jitter == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s.Jitter)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("jitter %q must be a duration like 10m", s.Jitter)
+	}
+	return d, nil
+}
+
+// Job maps the schedule to a scheduler job, the profile values fill what the schedule leaves empty
+func (c *Config) Job(name string) (*scheduler.Job, error) {
+	s, ok := c.Schedules[name]
+	if !ok || s == nil {
+		return nil, fmt.Errorf("schedule %s not found", name)
+	}
+	if err := s.Validate(c); err != nil {
+		return nil, fmt.Errorf("schedule %s: %v", name, err)
+	}
+	p, err := c.Profile(s.Profile)
+	if err != nil {
+		return nil, err
+	}
+	jitter, _ := s.JitterDuration()
+
+	var job = &scheduler.Job{
+		Name:              name,
+		Repository:        p.Repository,
+		PasswordFile:      p.PasswordFile,
+		PasswordCommand:   p.PasswordCommand,
+		SecretRefs:        true,
+		Backup:            s.Cron,
+		Path:              s.Path,
+		Files:             s.Files,
+		Excludes:          append(append([]string{}, p.Excludes...), s.Excludes...),
+		LimitUploadRate:   s.LimitUploadRate,
+		RetentionSchedule: s.RetentionCron,
+		Check:             s.CheckCron,
+		ReadDataSubset:    s.ReadDataSubset,
+		Jitter:            jitter,
+		CatchUp:           s.CatchUp == nil || *s.CatchUp,
+	}
+	if job.LimitUploadRate == "" {
+		job.LimitUploadRate = p.LimitUploadRate
+	}
+	var windows = s.UploadSchedule
+	if len(windows) == 0 {
+		windows = p.UploadSchedule
+	}
+	if job.UploadSchedule, err = bandwidth.ParseSchedule(windows); err != nil {
+		return nil, fmt.Errorf("schedule %s: %v", name, err)
+	}
+	var retention = s.Retention
+	if retention == nil {
+		retention = p.Retention
+	}
+	if retention != nil {
+		job.Retention = retention.Policy(s.Prune)
+	}
+	return job, nil
+}
+
+func (c *Config) ScheduleNames() []string {
+	return sortedKeys(c.Schedules)
+}
+
+// Policy is the restic forget policy of the retention
+func (r *Retention) Policy(prune bool) *restic.ForgetPolicy {
+	return &restic.ForgetPolicy{
+		KeepLast:    r.KeepLast,
+		KeepHourly:  r.KeepHourly,
+		KeepDaily:   r.KeepDaily,
+		KeepWeekly:  r.KeepWeekly,
+		KeepMonthly: r.KeepMonthly,
+		KeepYearly:  r.KeepYearly,
+		KeepWithin:  r.KeepWithin,
+		Prune:       prune,
+	}
+}
@@ -6,14 +6,25 @@ import (
 )
 
 const (
-	DefaultBaseDir = ".olares"
-	DefaultLogsDir = "logs"
+	DefaultBaseDir        = ".olares"
+	DefaultLogsDir        = "logs"
+	DefaultConfig         = "backups.yaml"
+	DefaultHistory        = "history.jsonl"
+	DefaultOutbox         = "outbox"
+	DefaultSchedulerState = "scheduler.json"
 
 	OlaresReleaseFile          = "/etc/olares/release"
 	OlaresStorageDefaultPrefix = "olares-backups"
 
 	ENV_OLARES_BASE_DIR = "OLARES_BASE_DIR"
 	ENV_OLARES_VERSION  = "OLARES_VERSION"
+	ENV_BACKUPS_PROFILE = "OLARES_BACKUPS_PROFILE"
+	ENV_BACKUPS_METRICS = "OLARES_BACKUPS_METRICS_TEXTFILE"
+	ENV_BACKUPS_TRACE   = "OLARES_BACKUPS_TRACE_FILE"
+
+	ENV_RESTIC_PASSWORD         = "RESTIC_PASSWORD"
+	ENV_RESTIC_PASSWORD_FILE    = "RESTIC_PASSWORD_FILE"
+	ENV_RESTIC_PASSWORD_COMMAND = "RESTIC_PASSWORD_COMMAND"
 
 	DefaultCloudApiUrl = "https://cloud-api.bttcdn.com"
 	DefaultDownloadUrl = "https://dc3p1870nn3cj.cloudfront.net"
@@ -23,12 +34,22 @@ const (
 	SendBackupUrl      = "/v1/resource/backup/save"
 	SendSnapshotUrl    = "/v1/resource/snapshot/save"
 
-	StorageS3Domain     = "amazonaws.com"
-	StorageTencentDoman = "myqcloud.com"
+	StorageS3Domain      = "amazonaws.com"
+	StorageS3ChinaDomain = "amazonaws.com.cn"
+	StorageTencentDoman  = "myqcloud.com"
+
+	CloudAWSName          = "aws"
+	CloudS3CompatibleName = "s3compatible"
+	CloudTencentName      = "tencentcloud"
+	CloudFilesystemName   = "filesystem"
+	CloudSftpName         = "sftp"
+	CloudRestName         = "rest"
+
+	AwsPartitionDefault  = "aws"
+	AwsPartitionChina    = "aws-cn"
+	AwsPartitionGovCloud = "aws-us-gov"
 
-	CloudAWSName        = "aws"
-	CloudTencentName    = "tencentcloud"
-	CloudFilesystemName = "filesystem"
+	S3CompatibleDefaultRegion = "us-east-1"
 
 	FullyBackup       string = "fully"
 	IncrementalBackup string = "incremental"
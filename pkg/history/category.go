@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/restic"
+)
+
+// Category is the typed cause of a failed job, it groups the many restic and cloud messages
+type Category string
+
+const (
+	CategoryNone       Category = ""
+	CategoryCanceled   Category = "canceled"
+	CategoryTimeout    Category = "timeout"
+	CategoryAuth       Category = "auth"
+	CategoryNetwork    Category = "network"
+	CategoryLocked     Category = "locked"
+	CategoryNotFound   Category = "not_found"
+	CategoryRepository Category = "repository"
+	CategoryStorage    Category = "storage"
+	CategoryUnknown    Category = "unknown"
+)
+
+// the first category with a matching message wins, messages are compared in lower case
+var categoryMessages = []struct {
+	category Category
+	messages []string
+}{
+	{category: CategoryCanceled, messages: []string{
+		restic.ERROR_MESSAGE_BACKUP_CANCELED.ToLower(),
+		restic.ERROR_MESSAGE_RESTORE_CANCELED.ToLower(),
+	}},
+	{category: CategoryAuth, messages: []string{
+		restic.ERROR_MESSAGE_WRONG_PASSWORD_OR_NO_KEY_FOUND.ToLower(),
+		restic.ERROR_MESSAGE_WRONG_PASSWORD.ToLower(),
+		restic.ERROR_MESSAGE_TOKEN_EXPIRED.ToLower(),
+		restic.ERROR_MESSAGE_COS_TOKEN_EXPIRED.ToLower(),
+		restic.ERROR_MESSAGE_ACCESS_DENIED.ToLower(),
+		"password is required",
+		"no valid aws credentials",
+		"invalidaccesskeyid",
+		"signaturedoesnotmatch",
+		"get sts token",
+	}},
+	{category: CategoryLocked, messages: []string{
+		restic.ERROR_MESSAGE_LOCKED.ToLower(),
+	}},
+	{category: CategoryNotFound, messages: []string{
+		restic.ERROR_MESSAGE_SNAPSHOT_NOT_FOUND.ToLower(),
+		restic.ERROR_MESSAGE_FILES_NOT_FOUND.ToLower(),
+		restic.ERROR_MESSAGE_REPOSITORY_DOES_NOT_EXIST.ToLower(),
+		restic.ERROR_MESSAGE_REPOSITORY_DOES_NOT_EXIST_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_UNABLE_TO_OPEN_CONFIG_FILE_MESSAGE.ToLower(),
+	}},
+	{category: CategoryStorage, messages: []string{
+		restic.ERROR_MESSAGE_NO_SPACE_LEFT_ON_DEVICE.ToLower(),
+		restic.ERROR_MESSAGE_NO_SPACE_LEFT_ON_DEVICE_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_NO_SUCH_DEVICE.ToLower(),
+		restic.ERROR_MESSAGE_NO_SUCH_DEVICE_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_COS_ACCOUNT_ARREARS.ToLower(),
+		restic.ERROR_MESSAGE_COS_ACCOUNT_ARREARS_MESSAGE.ToLower(),
+	}},
+	{category: CategoryNetwork, messages: []string{
+		restic.ERROR_MESSAGE_SERVER_MISBEHAVING.ToLower(),
+		restic.ERROR_MESSAGE_SERVER_MISBEHAVING_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_HOST_IS_DOWN.ToLower(),
+		restic.ERROR_MESSAGE_HOST_IS_DOWN_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_RESOURCE_TEMPORARILY_UNAVAILABLE.ToLower(),
+		"connection refused",
+		"connection reset",
+		"no such host",
+		"i/o timeout",
+		"tls handshake",
+	}},
+	{category: CategoryRepository, messages: []string{
+		restic.ERROR_MESSAGE_REPOSITORY_BE_DAMAGED.ToLower(),
+		restic.ERROR_MESSAGE_REPOSITORY_BE_DAMAGED_MESSAGE.ToLower(),
+		restic.ERROR_MESSAGE_CONFIG_INVALID.ToLower(),
+		restic.ERROR_MESSAGE_UNABLE_TO_OPEN_REPOSITORY.ToLower(),
+		restic.ERROR_MESSAGE_REPOSITORY_IMMUTABLE.ToLower(),
+		restic.ERROR_MESSAGE_REPOSITORY_APPEND_ONLY.ToLower(),
+	}},
+}
+
+// Classify returns the category of err, CategoryNone for nil and CategoryUnknown when nothing matches
+func Classify(err error) Category {
+	if err == nil {
+		return CategoryNone
+	}
+	if errors.Is(err, context.Canceled) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CategoryTimeout
+	}
+
+	var msg = strings.ToLower(err.Error())
+	for _, c := range categoryMessages {
+		for _, m := range c.messages {
+			if strings.Contains(msg, m) {
+				return c.category
+			}
+		}
+	}
+	return CategoryUnknown
+}
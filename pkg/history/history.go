@@ -0,0 +1,239 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/storage/model"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type Operation string
+
+const (
+	OperationBackup  Operation = "backup"
+	OperationRestore Operation = "restore"
+	OperationCheck   Operation = "check"
+	OperationForget  Operation = "forget"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess  Outcome = "success"
+	OutcomeFailed   Outcome = "failed"
+	OutcomeCanceled Outcome = "canceled"
+)
+
+// Record is one finished job
+type Record struct {
+	TraceId       string    `json:"trace_id"`
+	Operation     Operation `json:"operation"`
+	Location      string    `json:"location"`
+	Repo          string    `json:"repo"`
+	RepoId        string    `json:"repo_id,omitempty"`
+	SnapshotId    string    `json:"snapshot_id,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Duration      float64   `json:"duration"` // in seconds
+	Bytes         uint64    `json:"bytes"`
+	BytesAdded    uint64    `json:"bytes_added,omitempty"`
+	Files         uint64    `json:"files,omitempty"`
+	FilesNew      uint64    `json:"files_new,omitempty"`
+	FilesChanged  uint64    `json:"files_changed,omitempty"`
+	DryRun        bool      `json:"dry_run,omitempty"`
+	Outcome       Outcome   `json:"outcome"`
+	ErrorCategory Category  `json:"error_category,omitempty"`
+	Error         string    `json:"error,omitempty"`
+
+	Storage *model.StorageInfo `json:"storage,omitempty"` // where a backup was uploaded
+}
+
+// Start returns a record of a job that starts now
+func Start(op Operation, location, repo, traceId string) *Record {
+	return &Record{
+		TraceId:   traceId,
+		Operation: op,
+		Location:  location,
+		Repo:      repo,
+		StartedAt: time.Now(),
+	}
+}
+
+// Finish sets the end time and the outcome, the error message is redacted
+func (r *Record) Finish(err error) *Record {
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt).Seconds()
+	r.ErrorCategory = Classify(err)
+
+	switch r.ErrorCategory {
+	case CategoryNone:
+		r.Outcome = OutcomeSuccess
+	case CategoryCanceled:
+		r.Outcome = OutcomeCanceled
+	default:
+		r.Outcome = OutcomeFailed
+	}
+	if err != nil {
+		r.Error = redact.String(err.Error())
+	}
+	return r
+}
+
+// Filter selects records, empty fields match everything
+type Filter struct {
+	Operation Operation
+	Location  string
+	Repo      string
+	Outcome   Outcome
+	Since     time.Time
+	Limit     int // newest records to return, zero returns all
+}
+
+func (f *Filter) Match(r *Record) bool {
+	if f == nil {
+		return true
+	}
+	switch {
+	case f.Operation != "" && f.Operation != r.Operation,
+		f.Location != "" && f.Location != r.Location,
+		f.Repo != "" && f.Repo != r.Repo,
+		f.Outcome != "" && f.Outcome != r.Outcome,
+		!f.Since.IsZero() && r.StartedAt.Before(f.Since):
+		return false
+	}
+	return true
+}
+
+// Store keeps the records as append-only json lines
+type Store struct {
+	file string
+	mu   sync.Mutex
+}
+
+// DefaultPath is {base dir}/history.jsonl
+func DefaultPath() string {
+	return path.Join(utils.GetBaseDir(), constants.DefaultHistory)
+}
+
+func NewStore(file string) *Store {
+	return &Store{file: file}
+}
+
+func (s *Store) Path() string {
+	return s.file
+}
+
+// Append writes the record as one line, a record is never rewritten
+func (s *Store) Append(r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(path.Dir(s.file), 0755); err != nil {
+		return fmt.Errorf("create history dir error: %v", err)
+	}
+	f, err := os.OpenFile(s.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open history error: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write history error: %v", err)
+	}
+	return nil
+}
+
+// List returns the matching records, newest first, a missing file is an empty history
+func (s *Store) List(filter *Filter) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open history error: %v", err)
+	}
+	defer f.Close()
+
+	var res []*Record
+	var scanner = bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		// a line cut by a crash is skipped, the records around it are still valid
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if filter.Match(&r) {
+			res = append(res, &r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read history error: %v", err)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartedAt.After(res[j].StartedAt) })
+	if filter != nil && filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+	return res, nil
+}
+
+// Last returns the newest matching record, nil when there is none
+func (s *Store) Last(filter *Filter) (*Record, error) {
+	var f Filter
+	if filter != nil {
+		f = *filter
+	}
+	f.Limit = 1
+
+	res, err := s.List(&f)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// ParseSince reads an absolute time (RFC3339 or 2006-01-02) or an age before now like 36h or 7d
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+
+	var age time.Duration
+	if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") {
+		age = time.Duration(days) * 24 * time.Hour
+	} else if d, err := time.ParseDuration(s); err == nil {
+		age = d
+	} else {
+		return time.Time{}, fmt.Errorf("since %q must be a time like 2006-01-02 or an age like 36h or 7d", s)
+	}
+	if age < 0 {
+		return time.Time{}, fmt.Errorf("since %q must not be negative", s)
+	}
+	return now.Add(-age), nil
+}
@@ -0,0 +1,133 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/restic"
+)
+
+func TestStore(t *testing.T) {
+	var store = NewStore(path.Join(t.TempDir(), "sub", "history.jsonl"))
+
+	records, err := store.List(nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(records), 0)
+
+	var now = time.Now()
+	var jobs = []*Record{
+		{TraceId: "1", Operation: OperationBackup, Location: "s3", Repo: "home", StartedAt: now.Add(-3 * time.Hour), Outcome: OutcomeSuccess, SnapshotId: "aaaa"},
+		{TraceId: "2", Operation: OperationBackup, Location: "s3", Repo: "home", StartedAt: now.Add(-2 * time.Hour), Outcome: OutcomeFailed, ErrorCategory: CategoryNetwork},
+		{TraceId: "3", Operation: OperationRestore, Location: "s3", Repo: "home", StartedAt: now.Add(-1 * time.Hour), Outcome: OutcomeSuccess},
+		{TraceId: "4", Operation: OperationBackup, Location: "fs", Repo: "photos", StartedAt: now, Outcome: OutcomeSuccess},
+	}
+	for _, r := range jobs {
+		assert.Equal(t, store.Append(r), nil)
+	}
+
+	// a line cut by a crash does not hide the other records
+	f, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Equal(t, err, nil)
+	_, _ = f.WriteString(`{"trace_id":"5","operation":"bac`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		ids    []string
+	}{
+		{name: "all", filter: nil, ids: []string{"4", "3", "2", "1"}},
+		{name: "operation", filter: &Filter{Operation: OperationRestore}, ids: []string{"3"}},
+		{name: "repo and outcome", filter: &Filter{Repo: "home", Outcome: OutcomeSuccess}, ids: []string{"3", "1"}},
+		{name: "location", filter: &Filter{Location: "fs"}, ids: []string{"4"}},
+		{name: "since", filter: &Filter{Since: now.Add(-90 * time.Minute)}, ids: []string{"4", "3"}},
+		{name: "limit", filter: &Filter{Limit: 2}, ids: []string{"4", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.List(tt.filter)
+			assert.Equal(t, err, nil)
+			var ids []string
+			for _, r := range records {
+				ids = append(ids, r.TraceId)
+			}
+			assert.Equal(t, ids, tt.ids)
+		})
+	}
+
+	// when did repo home last back up successfully
+	last, err := store.Last(&Filter{Operation: OperationBackup, Repo: "home", Outcome: OutcomeSuccess})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, last.SnapshotId, "aaaa")
+
+	last, err = store.Last(&Filter{Repo: "missing"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, last == nil, true)
+}
+
+func TestFinish(t *testing.T) {
+	var r = Start(OperationBackup, "s3", "home", "trace").Finish(nil)
+	assert.Equal(t, r.Outcome, OutcomeSuccess)
+	assert.Equal(t, r.ErrorCategory, CategoryNone)
+	assert.Equal(t, r.FinishedAt.IsZero(), false)
+
+	r = Start(OperationBackup, "space", "home", "trace").Finish(fmt.Errorf("get sts token invalid, data: token=abcdef123456"))
+	assert.Equal(t, r.Outcome, OutcomeFailed)
+	assert.Equal(t, r.ErrorCategory, CategoryAuth)
+	assert.Equal(t, r.Error, "get sts token invalid, data: token=******")
+
+	r = Start(OperationRestore, "fs", "home", "trace").Finish(context.Canceled)
+	assert.Equal(t, r.Outcome, OutcomeCanceled)
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		category Category
+	}{
+		{err: nil, category: CategoryNone},
+		{err: fmt.Errorf("backup: %w", context.Canceled), category: CategoryCanceled},
+		{err: context.DeadlineExceeded, category: CategoryTimeout},
+		{err: errors.New(restic.ERROR_MESSAGE_BACKUP_CANCELED.Error()), category: CategoryCanceled},
+		{err: errors.New("Fatal: wrong password or no key found"), category: CategoryAuth},
+		{err: errors.New("Fatal: repository is already locked by PID 12 on host"), category: CategoryLocked},
+		{err: errors.New("Fatal: no matching ID found for prefix \"abc\""), category: CategoryNotFound},
+		{err: errors.New("dial tcp: lookup s3.example.com: no such host"), category: CategoryNetwork},
+		{err: errors.New("write /backup/data: no space left on device"), category: CategoryStorage},
+		{err: errors.New("Fatal: the repository could be damaged"), category: CategoryRepository},
+		{err: errors.New("something else"), category: CategoryUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, Classify(tt.err), tt.category)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  time.Time
+		err   bool
+	}{
+		{input: "", want: time.Time{}},
+		{input: "36h", want: now.Add(-36 * time.Hour)},
+		{input: "7d", want: now.Add(-7 * 24 * time.Hour)},
+		{input: "2024-05-01T00:00:00Z", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "yesterday", err: true},
+		{input: "-1h", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.input, now)
+		assert.Equal(t, err != nil, tt.err)
+		if !tt.err {
+			assert.Equal(t, got.Equal(tt.want), true)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+const (
+	DefaultConcurrency = 2
+	DefaultRetain      = 100
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+	ErrShutdown    = errors.New("job manager is shut down")
+)
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCanceled  State = "canceled"
+)
+
+// Finished reports whether the job will not change anymore
+func (s State) Finished() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCanceled
+}
+
+// Progress is the last progress event of a job
+type Progress struct {
+	PercentDone float64   `json:"percent_done"`
+	Time        time.Time `json:"time"`
+}
+
+// Job is a snapshot of a job for status queries, the id is also the trace id of the operation
+type Job struct {
+	Id         string            `json:"id"`
+	Operation  history.Operation `json:"operation"`
+	State      State             `json:"state"`
+	Progress   *Progress         `json:"progress,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Result     interface{}       `json:"result,omitempty"`
+}
+
+// RunFunc does the work of a job, it reports the percent done to progress and returns when ctx is canceled
+type RunFunc func(ctx context.Context, progress func(percentDone float64)) (interface{}, error)
+
+type Options struct {
+	Concurrency int // jobs running at the same time, the others wait in the queue, DefaultConcurrency when zero
+	Retain      int // finished jobs kept for queries, the oldest are dropped first, DefaultRetain when zero
+}
+
+type job struct {
+	Job
+	cancel   context.CancelFunc
+	canceled bool
+	done     chan struct{}
+	watchers []chan *Job
+}
+
+// Manager runs operations in the background and keeps their status by job id
+type Manager struct {
+	ctx    context.Context
+	stop   context.CancelFunc
+	slots  chan struct{}
+	retain int
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	ids  []string // in the order of submission
+}
+
+func NewManager(opts *Options) *Manager {
+	var concurrency, retain = DefaultConcurrency, DefaultRetain
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	if opts != nil && opts.Retain > 0 {
+		retain = opts.Retain
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:    ctx,
+		stop:   stop,
+		slots:  make(chan struct{}, concurrency),
+		retain: retain,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Submit queues run and returns the job id at once. The job keeps the values of ctx, like the logger,
+// but not its cancellation, cancel the job with Cancel
+func (m *Manager) Submit(ctx context.Context, op history.Operation, run RunFunc) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if m.ctx.Err() != nil {
+		return "", ErrShutdown
+	}
+
+	var id = utils.NewUUID()
+	jobCtx, cancel := context.WithCancel(context.WithValue(context.WithoutCancel(ctx), constants.TraceId, id))
+	var stop = context.AfterFunc(m.ctx, cancel)
+	var j = &job{
+		Job:    Job{Id: id, Operation: op, State: StateQueued, CreatedAt: time.Now()},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.jobs[id] = j
+	m.ids = append(m.ids, id)
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer stop()
+		defer cancel()
+		m.run(jobCtx, j, run)
+	}()
+
+	return id, nil
+}
+
+func (m *Manager) run(ctx context.Context, j *job, run RunFunc) {
+	var log = logger.FromContext(ctx)
+
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.finish(j, nil, ctx.Err())
+		return
+	}
+
+	m.update(j, func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		var now = time.Now()
+		j.State, j.StartedAt = StateRunning, &now
+		return true
+	})
+	if ctx.Err() != nil {
+		m.finish(j, nil, ctx.Err())
+		return
+	}
+
+	log.Infof("job %s %s started", j.Id, j.Operation)
+	result, err := run(ctx, func(percentDone float64) {
+		m.update(j, func() bool {
+			j.Progress = &Progress{PercentDone: percentDone, Time: time.Now()}
+			return true
+		})
+	})
+	m.finish(j, result, err)
+	log.Infof("job %s %s %s", j.Id, j.Operation, m.state(j))
+}
+
+func (m *Manager) finish(j *job, result interface{}, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var now = time.Now()
+	j.Result, j.FinishedAt = result, &now
+	switch {
+	case err == nil:
+		j.State = StateSucceeded
+	case j.canceled || errors.Is(err, context.Canceled):
+		j.State, j.Error = StateCanceled, redact.String(err.Error())
+	default:
+		j.State, j.Error = StateFailed, redact.String(err.Error())
+	}
+
+	var snapshot = j.Job
+	for _, w := range j.watchers {
+		send(w, &snapshot)
+		close(w)
+	}
+	j.watchers = nil
+	close(j.done)
+	m.prune()
+}
+
+// update changes the job under the lock and sends it to the watchers when fn returns true
+func (m *Manager) update(j *job, fn func() bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j.State.Finished() || !fn() {
+		return
+	}
+	var snapshot = j.Job
+	for _, w := range j.watchers {
+		send(w, &snapshot)
+	}
+}
+
+func (m *Manager) state(j *job) State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return j.State
+}
+
+// prune drops the oldest finished jobs over the retain limit, it is called with the lock held
+func (m *Manager) prune() {
+	var finished int
+	for _, id := range m.ids {
+		if m.jobs[id].State.Finished() {
+			finished++
+		}
+	}
+	var ids = m.ids[:0]
+	for _, id := range m.ids {
+		if finished > m.retain && m.jobs[id].State.Finished() {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		ids = append(ids, id)
+	}
+	m.ids = ids
+}
+
+// send replaces an update the watcher has not received yet, so a slow watcher only misses intermediate progress
+func send(w chan *Job, snapshot *Job) {
+	select {
+	case w <- snapshot:
+		return
+	default:
+	}
+	select {
+	case <-w:
+	default:
+	}
+	w <- snapshot
+}
+
+// Get returns the current status of the job
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	var snapshot = j.Job
+	return &snapshot, nil
+}
+
+// List returns the jobs in the given states, all running, queued and retained finished jobs when none,
+// the newest first
+func (m *Manager) List(states ...State) []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res = make([]*Job, 0, len(m.ids))
+	for i := len(m.ids) - 1; i >= 0; i-- {
+		var j = m.jobs[m.ids[i]]
+		if len(states) > 0 && !hasState(states, j.State) {
+			continue
+		}
+		var snapshot = j.Job
+		res = append(res, &snapshot)
+	}
+	return res
+}
+
+func hasState(states []State, s State) bool {
+	for _, state := range states {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancel cancels a queued or running job, the restic process group of a running job is terminated.
+// The job is canceled once Wait returns
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.State.Finished() {
+		return ErrJobFinished
+	}
+	j.canceled = true
+	j.cancel()
+	return nil
+}
+
+// Wait waits until the job is finished or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var snapshot = j.Job
+	return &snapshot, nil
+}
+
+// Watch returns a channel that receives the job on every change, starting with its current status,
+// and is closed after the finished job is sent. Intermediate updates are skipped when the receiver is slow.
+// Call the returned func to stop watching early
+func (m *Manager) Watch(id string) (<-chan *Job, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, ErrJobNotFound
+	}
+
+	var w = make(chan *Job, 1)
+	var snapshot = j.Job
+	w <- &snapshot
+	if j.State.Finished() {
+		close(w)
+		return w, func() {}, nil
+	}
+	j.watchers = append(j.watchers, w)
+
+	var unwatch = func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, watcher := range j.watchers {
+			if watcher == w {
+				j.watchers = append(j.watchers[:i], j.watchers[i+1:]...)
+				close(w)
+				return
+			}
+		}
+	}
+	return w, unwatch, nil
+}
+
+// Shutdown cancels all jobs and waits for them to return until ctx is done, no job can be submitted afterwards
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stop()
+	var done = make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
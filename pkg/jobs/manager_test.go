@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+func wait(t *testing.T, m *Manager, id string) *Job {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	j, err := m.Wait(ctx, id)
+	assert.Equal(t, err, nil)
+	return j
+}
+
+func TestManager(t *testing.T) {
+	var tests = []struct {
+		name   string
+		run    RunFunc
+		state  State
+		result interface{}
+		error  string
+	}{
+		{
+			name: "succeeded",
+			run: func(ctx context.Context, progress func(float64)) (interface{}, error) {
+				progress(0.5)
+				return "done", nil
+			},
+			state:  StateSucceeded,
+			result: "done",
+		},
+		{
+			name: "failed",
+			run: func(ctx context.Context, progress func(float64)) (interface{}, error) {
+				return nil, errors.New("repository is locked")
+			},
+			state: StateFailed,
+			error: "repository is locked",
+		},
+	}
+
+	var m = NewManager(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Submit(context.Background(), history.OperationBackup, tt.run)
+			assert.Equal(t, err, nil)
+			var j = wait(t, m, id)
+			assert.Equal(t, j.Id, id)
+			assert.Equal(t, j.State, tt.state)
+			assert.Equal(t, j.Result, tt.result)
+			assert.Equal(t, j.Error, tt.error)
+		})
+	}
+
+	_, err := m.Get("missing")
+	assert.Equal(t, err, ErrJobNotFound)
+}
+
+func TestProgressAndTraceId(t *testing.T) {
+	var m = NewManager(nil)
+	var reported, release = make(chan struct{}), make(chan struct{})
+	var traceId string
+	id, err := m.Submit(context.Background(), history.OperationRestore, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		traceId = utils.GetTraceId(ctx)
+		progress(0.25)
+		close(reported)
+		<-release
+		return nil, nil
+	})
+	assert.Equal(t, err, nil)
+
+	<-reported
+	j, err := m.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, j.State, StateRunning)
+	assert.Equal(t, j.Progress.PercentDone, 0.25)
+
+	close(release)
+	wait(t, m, id)
+	assert.Equal(t, traceId, id)
+}
+
+func TestCancel(t *testing.T) {
+	var m = NewManager(&Options{Concurrency: 1})
+	var started = make(chan struct{})
+	var blocking = func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	running, err := m.Submit(context.Background(), history.OperationBackup, blocking)
+	assert.Equal(t, err, nil)
+	<-started
+	queued, err := m.Submit(context.Background(), history.OperationBackup, blocking)
+	assert.Equal(t, err, nil)
+
+	j, _ := m.Get(queued)
+	assert.Equal(t, j.State, StateQueued)
+	assert.Equal(t, len(m.List(StateRunning)), 1)
+	assert.Equal(t, len(m.List(StateQueued)), 1)
+
+	assert.Equal(t, m.Cancel(queued), nil)
+	j = wait(t, m, queued)
+	assert.Equal(t, j.State, StateCanceled)
+	assert.Equal(t, j.StartedAt == nil, true)
+
+	assert.Equal(t, m.Cancel(running), nil)
+	j = wait(t, m, running)
+	assert.Equal(t, j.State, StateCanceled)
+
+	assert.Equal(t, m.Cancel(running), ErrJobFinished)
+	assert.Equal(t, m.Cancel("missing"), ErrJobNotFound)
+}
+
+func TestConcurrency(t *testing.T) {
+	var m = NewManager(&Options{Concurrency: 2})
+	var running, max = make(chan int, 10), 0
+	var active int
+	var done = make(chan struct{})
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := m.Submit(context.Background(), history.OperationCheck, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+			running <- 1
+			time.Sleep(20 * time.Millisecond)
+			running <- -1
+			return nil, nil
+		})
+		assert.Equal(t, err, nil)
+		ids = append(ids, id)
+	}
+	go func() {
+		for d := range running {
+			active += d
+			if active > max {
+				max = active
+			}
+		}
+		close(done)
+	}()
+	for _, id := range ids {
+		wait(t, m, id)
+	}
+	close(running)
+	<-done
+	assert.Equal(t, max, 2)
+}
+
+func TestListAndRetain(t *testing.T) {
+	var m = NewManager(&Options{Retain: 2})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := m.Submit(context.Background(), history.OperationForget, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+			return nil, nil
+		})
+		assert.Equal(t, err, nil)
+		wait(t, m, id)
+		ids = append(ids, id)
+	}
+
+	var jobs = m.List()
+	assert.Equal(t, len(jobs), 2)
+	assert.Equal(t, jobs[0].Id, ids[2])
+	assert.Equal(t, jobs[1].Id, ids[1])
+	_, err := m.Get(ids[0])
+	assert.Equal(t, err, ErrJobNotFound)
+}
+
+func TestWatch(t *testing.T) {
+	var m = NewManager(nil)
+	var release = make(chan struct{})
+	id, err := m.Submit(context.Background(), history.OperationBackup, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		<-release
+		progress(1)
+		return nil, nil
+	})
+	assert.Equal(t, err, nil)
+
+	updates, unwatch, err := m.Watch(id)
+	assert.Equal(t, err, nil)
+	defer unwatch()
+	close(release)
+
+	var last *Job
+	for j := range updates {
+		last = j
+	}
+	assert.Equal(t, last.State, StateSucceeded)
+}
+
+func TestShutdown(t *testing.T) {
+	var m = NewManager(nil)
+	id, err := m.Submit(context.Background(), history.OperationBackup, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, m.Shutdown(context.Background()), nil)
+	j, _ := m.Get(id)
+	assert.Equal(t, j.State, StateCanceled)
+
+	_, err = m.Submit(context.Background(), history.OperationBackup, nil)
+	assert.Equal(t, err, ErrShutdown)
+}
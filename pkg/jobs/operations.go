@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+// BackupResult is the result of a backup job
+type BackupResult struct {
+	Summary     *restic.SummaryOutput `json:"summary,omitempty"`
+	StorageInfo *model.StorageInfo    `json:"storage_info,omitempty"`
+}
+
+// RestoreResult is the result of a restore job
+type RestoreResult struct {
+	Summary    map[string]*restic.RestoreSummaryOutput `json:"summary,omitempty"`
+	Metadata   string                                  `json:"metadata,omitempty"`
+	TotalBytes uint64                                  `json:"total_bytes"`
+}
+
+// Backup starts a backup job, its result is a *BackupResult
+func (m *Manager) Backup(ctx context.Context, option *storage.BackupOption, dryRun bool) (string, error) {
+	var service = storage.NewBackupService(option)
+	return m.Submit(ctx, history.OperationBackup, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		summary, storageInfo, err := service.BackupContext(ctx, dryRun, progress)
+		if err != nil {
+			return nil, err
+		}
+		return &BackupResult{Summary: summary, StorageInfo: storageInfo}, nil
+	})
+}
+
+// Restore starts a restore job, its result is a *RestoreResult
+func (m *Manager) Restore(ctx context.Context, option *storage.RestoreOption) (string, error) {
+	var service = storage.NewRestoreService(option)
+	return m.Submit(ctx, history.OperationRestore, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		summary, metadata, totalBytes, err := service.RestoreContext(ctx, progress)
+		if err != nil {
+			return nil, err
+		}
+		return &RestoreResult{Summary: summary, Metadata: metadata, TotalBytes: totalBytes}, nil
+	})
+}
+
+// Forget starts a retention job, its result is a *restic.ForgetSummary
+func (m *Manager) Forget(ctx context.Context, option *storage.SnapshotsOption, policy *restic.ForgetPolicy) (string, error) {
+	var service = storage.NewForgetService(option)
+	return m.Submit(ctx, history.OperationForget, func(ctx context.Context, _ func(float64)) (interface{}, error) {
+		return service.ForgetContext(ctx, policy)
+	})
+}
+
+// Check starts a repository check job, its result is a *restic.CheckSummary
+func (m *Manager) Check(ctx context.Context, option *storage.SnapshotsOption, readDataSubset string) (string, error) {
+	var service = storage.NewCheckService(option)
+	return m.Submit(ctx, history.OperationCheck, func(ctx context.Context, _ func(float64)) (interface{}, error) {
+		return service.CheckContext(ctx, readDataSubset)
+	})
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/constants"
+)
+
+// TraceIdKey is the structured field of the trace id
+const TraceIdKey = "traceId"
+
+type loggerKey struct{}
+
+// NewContext returns a context that carries l with secrets masked, a nil l leaves ctx unchanged
+func NewContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, redacted(l))
+}
+
+// FromContext returns the logger of the context, or the global one,
+// with the trace id of the context as a structured field
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	var l = base
+	if ctx == nil {
+		return l
+	}
+	if v, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		l = v
+	}
+	if traceId, ok := ctx.Value(constants.TraceId).(string); ok && traceId != "" {
+		l = l.With(TraceIdKey, traceId)
+	}
+	return l
+}
@@ -6,59 +6,96 @@ import (
 	"os"
 	"os/user"
 	"path"
-	"time"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/redact"
 )
 
-var logger *zap.SugaredLogger
+// logger discards everything until InitLogger or SetLogger is called,
+// it skips one caller frame for the package level functions, base is the same logger without the skip
+var logger = zap.NewNop().Sugar()
+var base = zap.NewNop().Sugar()
 
 var FatalMessagePrefix = "[FATAL] "
 
-func InitLogger(consoleLogTruncate bool) {
-	var user, err = user.Current()
-	if err != nil {
-		panic(errors.New("get current user failed"))
-	}
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
 
-	var homeDir, jsonLogDir string
+	defaultLogFile = "backups.log"
+)
 
-	if err = godotenv.Load(constants.OlaresReleaseFile); err != nil {
-		homeDir = user.HomeDir
-		jsonLogDir = path.Join(homeDir, constants.DefaultBaseDir, constants.DefaultLogsDir)
-	} else {
-		envOlaresBaseDir := os.Getenv(constants.ENV_OLARES_BASE_DIR)
-		if envOlaresBaseDir == "" {
-			homeDir = path.Join(user.HomeDir, constants.DefaultBaseDir)
-		} else {
-			homeDir = envOlaresBaseDir
-		}
-		jsonLogDir = path.Join(homeDir, constants.DefaultLogsDir)
+// Options of a logger, the log file always gets every level in json and is rotated by size
+type Options struct {
+	Dir        string // log dir, {base dir}/logs by default
+	Level      string // console level: debug, info, warn or error
+	Format     string // console format: console or json
+	MaxSize    int    // megabytes of the log file before it is rotated
+	MaxBackups int    // rotated files to keep, zero keeps all
+	MaxAge     int    // days to keep rotated files, zero keeps all
+	Compress   bool   // gzip the rotated files
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Level:      "info",
+		Format:     FormatConsole,
+		MaxSize:    100,
+		MaxBackups: 10,
+		MaxAge:     30,
+		Compress:   true,
 	}
+}
 
-	found, err := isDirExist(jsonLogDir)
+// InitLogger sets the global logger with the default options
+func InitLogger(consoleLogTruncate bool) {
+	if err := Init(NewOptions()); err != nil {
+		fmt.Println("init logger error:", err)
+		os.Exit(1)
+	}
+}
+
+// Init sets the global logger, it is used by the package level functions and by services without a logger
+func Init(opts *Options) error {
+	l, err := New(opts)
 	if err != nil {
-		fmt.Println("log dir found error", err)
+		return err
 	}
-	if !found {
-		err := os.MkdirAll(jsonLogDir, 0755)
+	SetLogger(l)
+	return nil
+}
+
+// New creates a logger that writes to the console and to the rotated log file
+func New(opts *Options) (*zap.SugaredLogger, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	var logDir = opts.Dir
+	if logDir == "" {
+		dir, err := defaultLogDir()
 		if err != nil {
-			fmt.Println("create log dir error", err)
-			os.Exit(1)
+			return nil, err
 		}
+		logDir = dir
 	}
-
-	jsonLogFileName := path.Join(jsonLogDir, fmt.Sprintf("%s.log", time.Now().Format("2006-01-02_15-04-05")))
-	jsonLogFile, err := os.OpenFile(jsonLogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
-	if err != nil {
-		panic(err)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("create log dir error: %v", err)
 	}
 
+	if opts.Level != "" {
+		var l zapcore.Level
+		if err := l.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("log level %s is not supported", opts.Level)
+		}
+	}
+	var level = getLevel(opts.Level)
 	consolePriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl > zapcore.DebugLevel
+		return lvl >= level
 	})
 	jsonLogFilePriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 		return true
@@ -89,32 +126,66 @@ func InitLogger(consoleLogTruncate bool) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	consoleDebugging := zapcore.Lock(os.Stdout)
+	var consoleEncoder zapcore.Encoder
+	switch opts.Format {
+	case "", FormatConsole:
+		consoleEncoder = zapcore.NewConsoleEncoder(consoleEncoderConfig)
+	case FormatJSON:
+		consoleEncoder = zapcore.NewJSONEncoder(fileEncoder)
+	default:
+		return nil, fmt.Errorf("log format %s is not supported, use %s or %s", opts.Format, FormatConsole, FormatJSON)
+	}
 
-	core := zapcore.NewTee(
-		zapcore.NewCore(zapcore.NewConsoleEncoder(consoleEncoderConfig), consoleDebugging, consolePriority),
+	var jsonLogFile = &lumberjack.Logger{
+		Filename:   path.Join(logDir, defaultLogFile),
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   opts.Compress,
+	}
+
+	// every entry passes the redaction, secrets never reach the console or the log file
+	core := redact.Core(zapcore.NewTee(
+		zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), consolePriority),
 		zapcore.NewCore(zapcore.NewJSONEncoder(fileEncoder), zapcore.AddSync(jsonLogFile), jsonLogFilePriority),
-	)
-	logger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.FatalLevel)).Sugar()
+	))
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.FatalLevel)).Sugar(), nil
+}
+
+func defaultLogDir() (string, error) {
+	user, err := user.Current()
+	if err != nil {
+		return "", errors.New("get current user failed")
+	}
+
+	if err = godotenv.Load(constants.OlaresReleaseFile); err != nil {
+		return path.Join(user.HomeDir, constants.DefaultBaseDir, constants.DefaultLogsDir), nil
+	}
+	var homeDir = os.Getenv(constants.ENV_OLARES_BASE_DIR)
+	if homeDir == "" {
+		homeDir = path.Join(user.HomeDir, constants.DefaultBaseDir)
+	}
+	return path.Join(homeDir, constants.DefaultLogsDir), nil
 }
 
 func GetLogger() *zap.SugaredLogger {
-	return logger
+	return base
 }
 
+// SetLogger replaces the global logger, nil keeps the current one, secrets are masked in its output.
+// Services carry their own logger, see NewContext, so SetLogger is only needed for the package level functions
 func SetLogger(l *zap.SugaredLogger) {
-	logger = l
+	if l == nil {
+		return
+	}
+	l = redacted(l)
+	base = l
+	logger = l.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
 }
 
-func isDirExist(path string) (bool, error) {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false, nil
-	}
-	if err != nil {
-		return false, err
-	}
-	return info.IsDir(), nil
+// redacted wraps the core of l with the redaction, it is a no-op for an already wrapped core
+func redacted(l *zap.SugaredLogger) *zap.SugaredLogger {
+	return l.Desugar().WithOptions(zap.WrapCore(redact.Core)).Sugar()
 }
 
 func getLevel(level string) (l zapcore.Level) {
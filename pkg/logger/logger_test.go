@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"olares.com/backups-sdk/pkg/constants"
+)
+
+func TestFromContext(t *testing.T) {
+	coreA, logsA := observer.New(zapcore.DebugLevel)
+	coreB, logsB := observer.New(zapcore.DebugLevel)
+
+	var ctxA = NewContext(context.WithValue(context.Background(), constants.TraceId, "trace-a"), zap.New(coreA).Sugar())
+	var ctxB = NewContext(context.Background(), zap.New(coreB).Sugar())
+
+	FromContext(ctxA).Infof("backup %s", "a")
+	FromContext(ctxB).Infof("backup %s", "b")
+
+	assert.Equal(t, logsA.Len(), 1)
+	assert.Equal(t, logsA.All()[0].Message, "backup a")
+	assert.Equal(t, logsA.All()[0].ContextMap()[TraceIdKey], "trace-a")
+
+	assert.Equal(t, logsB.Len(), 1)
+	assert.Equal(t, logsB.All()[0].Message, "backup b")
+	_, ok := logsB.All()[0].ContextMap()[TraceIdKey]
+	assert.Equal(t, ok, false)
+
+	// a nil logger keeps the logger of the parent context
+	FromContext(NewContext(ctxA, nil)).Info("again")
+	assert.Equal(t, logsA.Len(), 2)
+}
+
+func TestNew(t *testing.T) {
+	var dir = t.TempDir()
+
+	l, err := New(&Options{Dir: dir, Level: "warn", Format: FormatJSON, MaxSize: 1})
+	assert.Equal(t, err, nil)
+	l.Debugf("written to the log file only")
+	_ = l.Sync()
+
+	data, err := os.ReadFile(path.Join(dir, defaultLogFile))
+	assert.Equal(t, err, nil)
+	assert.NotEqual(t, len(data), 0)
+
+	_, err = New(&Options{Dir: dir, Format: "xml"})
+	assert.NotEqual(t, err, nil)
+	_, err = New(&Options{Dir: dir, Level: "verbose"})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestNewLevel(t *testing.T) {
+	var dir = t.TempDir()
+	r, w, err := os.Pipe()
+	assert.Equal(t, err, nil)
+	var stdout = os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	l, err := New(&Options{Dir: dir, Level: "error"})
+	assert.Equal(t, err, nil)
+	l.Debugf("debug line")
+	l.Infof("info line")
+	l.Errorf("error line")
+	_ = l.Sync()
+	_ = w.Close()
+
+	console, err := io.ReadAll(r)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(string(console), "error line"), true)
+	assert.Equal(t, strings.Contains(string(console), "debug line"), false)
+	assert.Equal(t, strings.Contains(string(console), "info line"), false)
+
+	// the log file takes every level
+	data, err := os.ReadFile(path.Join(dir, defaultLogFile))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(string(data), "debug line"), true)
+}
+
+func TestNewRedacts(t *testing.T) {
+	var dir = t.TempDir()
+
+	l, err := New(&Options{Dir: dir, Level: "error"})
+	assert.Equal(t, err, nil)
+	l.Debugf("send backup data: userid=alice&token=%s", "access-token-secret")
+	l.Debugf("sts env vars: AWS_SECRET_ACCESS_KEY=%s", "aws-secret-value")
+	l.Infow("refresh", "data", "ak=AKIDEXAMPLE&sk=sts-secret-key&st=sts-session-token")
+	_ = l.Sync()
+
+	data, err := os.ReadFile(path.Join(dir, defaultLogFile))
+	assert.Equal(t, err, nil)
+	for _, secret := range []string{"access-token-secret", "aws-secret-value", "sts-secret-key", "sts-session-token"} {
+		assert.Equal(t, strings.Contains(string(data), secret), false)
+	}
+	assert.Equal(t, strings.Contains(string(data), "userid=alice"), true)
+}
+
+func TestNewContextRedacts(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	FromContext(NewContext(context.Background(), zap.New(core).Sugar())).Infof("data: token=%s", "injected-secret")
+
+	assert.Equal(t, logs.Len(), 1)
+	assert.Equal(t, strings.Contains(logs.All()[0].Message, "injected-secret"), false)
+}
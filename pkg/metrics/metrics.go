@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "olares_backups"
+
+	outcomeSuccess = "success"
+)
+
+// lastSuccessValues guards lastSuccess against going back in time
+var (
+	lastSuccessMu     sync.Mutex
+	lastSuccessValues = make(map[string]float64)
+)
+
+// Registry holds the backup metrics only, the process metrics of the default registry are not mixed in
+var Registry = prometheus.NewRegistry()
+
+var (
+	jobLabels = []string{"operation", "location", "repo"}
+
+	lastRun = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time the last job of the repo finished, whatever the outcome.",
+	}, jobLabels)
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time the last successful job of the repo finished.",
+	}, jobLabels)
+	jobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Finished jobs by outcome.",
+	}, append(jobLabels, "outcome"))
+	failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_failures_total",
+		Help:      "Failed jobs by error category.",
+	}, append(jobLabels, "category"))
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Duration of finished jobs.",
+		Buckets:   prometheus.ExponentialBuckets(1, 3, 10), // 1s to about 5.5h
+	}, jobLabels)
+	lastDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_duration_seconds",
+		Help:      "Duration of the last job of the repo.",
+	}, jobLabels)
+	processedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "processed_bytes_total",
+		Help:      "Bytes read by backups or written by restores.",
+	}, jobLabels)
+	addedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "added_bytes_total",
+		Help:      "Bytes added to the repository by backups.",
+	}, jobLabels)
+	files = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "files_total",
+		Help:      "Files of backups by state, new or changed.",
+	}, append(jobLabels, "state"))
+	repositorySize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "repository_size_bytes",
+		Help:      "Size of the repository reported by restic stats, by stats mode.",
+	}, []string{"repo", "mode"})
+)
+
+func init() {
+	Registry.MustRegister(lastRun, lastSuccess, jobs, failures, jobDuration, lastDuration,
+		processedBytes, addedBytes, files, repositorySize)
+}
+
+// Job is a finished backup, restore, check or forget
+type Job struct {
+	Operation    string
+	Location     string
+	Repo         string
+	Outcome      string // success, failed or canceled
+	Category     string // error category of a job that did not succeed
+	FinishedAt   time.Time
+	Duration     time.Duration
+	DryRun       bool
+	Bytes        uint64
+	BytesAdded   uint64
+	FilesNew     uint64
+	FilesChanged uint64
+}
+
+// ObserveJob records a finished job
+func ObserveJob(j *Job) {
+	if j == nil || j.FinishedAt.IsZero() {
+		return
+	}
+	var labels = prometheus.Labels{"operation": j.Operation, "location": j.Location, "repo": j.Repo}
+	var finished = float64(j.FinishedAt.Unix())
+
+	lastRun.With(labels).Set(finished)
+	jobs.MustCurryWith(labels).WithLabelValues(j.Outcome).Inc()
+	jobDuration.With(labels).Observe(j.Duration.Seconds())
+	lastDuration.With(labels).Set(j.Duration.Seconds())
+
+	if j.Outcome != outcomeSuccess {
+		failures.MustCurryWith(labels).WithLabelValues(j.Category).Inc()
+		return
+	}
+	// a dry run proves the repository is reachable but stores nothing
+	if j.DryRun {
+		return
+	}
+	setLastSuccess(labels, finished)
+	processedBytes.With(labels).Add(float64(j.Bytes))
+	addedBytes.With(labels).Add(float64(j.BytesAdded))
+	files.MustCurryWith(labels).WithLabelValues("new").Add(float64(j.FilesNew))
+	files.MustCurryWith(labels).WithLabelValues("changed").Add(float64(j.FilesChanged))
+}
+
+// RestoreLastSuccess sets the last success of a repo from an earlier run, a newer value is kept.
+// A one-shot run writing a textfile calls it so that a failed run does not drop the last success
+func RestoreLastSuccess(operation, location, repo string, t time.Time) {
+	setLastSuccess(prometheus.Labels{"operation": operation, "location": location, "repo": repo}, float64(t.Unix()))
+}
+
+func setLastSuccess(labels prometheus.Labels, v float64) {
+	lastSuccessMu.Lock()
+	defer lastSuccessMu.Unlock()
+
+	var key = labels["operation"] + "/" + labels["location"] + "/" + labels["repo"]
+	if prev, ok := lastSuccessValues[key]; ok && prev >= v {
+		return
+	}
+	lastSuccessValues[key] = v
+	lastSuccess.With(labels).Set(v)
+}
+
+// ObserveRepositorySize records the total size of a restic stats run
+func ObserveRepositorySize(repo, mode string, size uint64) {
+	repositorySize.WithLabelValues(repo, mode).Set(float64(size))
+}
+
+// Handler serves the metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes /metrics on addr until ctx is done
+func Serve(ctx context.Context, addr string) error {
+	var mux = http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	var server = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// WriteTextfile writes the metrics for the node_exporter textfile collector, the file is replaced atomically
+func WriteTextfile(file string) error {
+	return prometheus.WriteToTextfile(file, Registry)
+}
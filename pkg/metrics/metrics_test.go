@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveJob(t *testing.T) {
+	var finished = time.Unix(1700000000, 0)
+
+	ObserveJob(&Job{Operation: "backup", Location: "s3", Repo: "metrics-home", Outcome: "success", FinishedAt: finished,
+		Duration: 90 * time.Second, Bytes: 1000, BytesAdded: 100, FilesNew: 3, FilesChanged: 2})
+	ObserveJob(&Job{Operation: "backup", Location: "s3", Repo: "metrics-home", Outcome: "failed", Category: "network",
+		FinishedAt: finished.Add(time.Hour), Duration: time.Second})
+	ObserveJob(&Job{Operation: "backup", Location: "s3", Repo: "metrics-home", Outcome: "success", DryRun: true,
+		FinishedAt: finished.Add(2 * time.Hour), Bytes: 5000})
+	// an unfinished job is ignored
+	ObserveJob(&Job{Operation: "backup", Location: "s3", Repo: "metrics-home", Outcome: "success"})
+
+	assert.Equal(t, testutil.ToFloat64(lastSuccess.WithLabelValues("backup", "s3", "metrics-home")), float64(finished.Unix()))
+	assert.Equal(t, testutil.ToFloat64(lastRun.WithLabelValues("backup", "s3", "metrics-home")), float64(finished.Add(2*time.Hour).Unix()))
+	assert.Equal(t, testutil.ToFloat64(jobs.WithLabelValues("backup", "s3", "metrics-home", "success")), float64(2))
+	assert.Equal(t, testutil.ToFloat64(failures.WithLabelValues("backup", "s3", "metrics-home", "network")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(processedBytes.WithLabelValues("backup", "s3", "metrics-home")), float64(1000))
+	assert.Equal(t, testutil.ToFloat64(addedBytes.WithLabelValues("backup", "s3", "metrics-home")), float64(100))
+	assert.Equal(t, testutil.ToFloat64(files.WithLabelValues("backup", "s3", "metrics-home", "new")), float64(3))
+
+	// an older success from the history does not win over the one of this run
+	RestoreLastSuccess("backup", "s3", "metrics-home", finished.Add(-time.Hour))
+	assert.Equal(t, testutil.ToFloat64(lastSuccess.WithLabelValues("backup", "s3", "metrics-home")), float64(finished.Unix()))
+	RestoreLastSuccess("backup", "fs", "metrics-photos", finished)
+	assert.Equal(t, testutil.ToFloat64(lastSuccess.WithLabelValues("backup", "fs", "metrics-photos")), float64(finished.Unix()))
+}
+
+func TestWriteTextfile(t *testing.T) {
+	ObserveRepositorySize("metrics-textfile", "raw-data", 4096)
+
+	var file = path.Join(t.TempDir(), "backups.prom")
+	assert.Equal(t, WriteTextfile(file), nil)
+
+	data, err := os.ReadFile(file)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(string(data), `olares_backups_repository_size_bytes{mode="raw-data",repo="metrics-textfile"} 4096`), true)
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	var addr = l.Addr().String()
+	l.Close()
+
+	ObserveRepositorySize("metrics-serve", "raw-data", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan error)
+	go func() { done <- Serve(ctx, addr) }()
+
+	var body string
+	for i := 0; i < 50 && body == ""; i++ {
+		if resp, err := http.Get("http://" + addr + "/metrics"); err == nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body = string(data)
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	assert.Equal(t, strings.Contains(body, `repo="metrics-serve"`), true)
+
+	cancel()
+	assert.Equal(t, <-done, nil)
+}
@@ -12,11 +12,13 @@ type SpaceBackupOption struct {
 	RepoName        string   `json:"repo_name"`
 	Path            string   `json:"path"`
 	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
 	FilesPrefixPath string   `json:"files_prefix_path"`
 	Metadata        string   `json:"metadata"`
 	LimitUploadRate string   `json:"limit_upload_rate"`
+	StorageClass    string   `json:"storage_class"`
 	OlaresDid       string   `json:"olares_did"`
-	AccessToken     string   `json:"access_token"`
+	AccessToken     string   `json:"access_token" secret:"true"`
 	ClusterId       string   `json:"cluster_id"`
 	CloudName       string   `json:"cloud_name"`
 	RegionId        string   `json:"region_id"`
@@ -31,9 +33,11 @@ func (o *SpaceBackupOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
 	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
 	cmd.Flags().StringVarP(&o.LimitUploadRate, "limit-upload-rate", "", "", "Limits uploads to a maximum rate in KiB/s. (default: unlimited)")
+	cmd.Flags().StringVarP(&o.StorageClass, "storage-class", "", "", "Storage class for uploaded data, for example STANDARD_IA or INTELLIGENT_TIERING (default: bucket default)")
 	cmd.Flags().StringVarP(&o.OlaresDid, "olares-did", "", "", "Olares DID")
-	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token")
+	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 	cmd.Flags().StringVarP(&o.ClusterId, "cluster-id", "", "", "Space Cluster ID")
 	cmd.Flags().StringVarP(&o.CloudName, "cloud-name", "", "", "Space Cloud Name")
 	cmd.Flags().StringVarP(&o.RegionId, "region-id", "", "", "Space Region Id")
@@ -47,13 +51,19 @@ type AwsBackupOption struct {
 	RepoId          string
 	RepoName        string
 	Endpoint        string
-	AccessKey       string
-	SecretAccessKey string
+	AccessKey       string `secret:"true"`
+	SecretAccessKey string `secret:"true"`
+	SessionToken    string `secret:"true"`
+	Profile         string
 	Path            string
 	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
 	FilesPrefixPath string   `json:"files_prefix_path"`
 	Metadata        string   `json:"metadata"`
 	LimitUploadRate string
+	StorageClass    string
+	Immutable       bool
+	RetentionDays   int
 }
 
 func NewBackupAwsOption() *AwsBackupOption {
@@ -64,12 +74,18 @@ func (o *AwsBackupOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for S3, for example https://{bucket}.{region}.amazonaws.com/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SessionToken, "session-token", "", "", "Session Token for S3 temporary credentials, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.Profile, "aws-profile", "", "", "Profile name in the AWS shared credentials file, used when no access key is given")
 
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
 	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
 	cmd.Flags().StringVarP(&o.LimitUploadRate, "limit-upload-rate", "", "", "Limits uploads to a maximum rate in KiB/s. (default: unlimited)")
+	cmd.Flags().StringVarP(&o.StorageClass, "storage-class", "", "", "Storage class for uploaded data, for example STANDARD_IA or INTELLIGENT_TIERING (default: bucket default)")
+	cmd.Flags().BoolVarP(&o.Immutable, "immutable", "", false, "Lock backup data with S3 Object Lock in compliance mode, the bucket must have object lock enabled")
+	cmd.Flags().IntVarP(&o.RetentionDays, "retention-days", "", 0, "Days to keep backup data locked when --immutable is set, match it to the snapshot retention policy")
 }
 
 // ~ cos
@@ -79,13 +95,15 @@ type TencentCloudBackupOption struct {
 	RepoId          string
 	RepoName        string
 	Endpoint        string
-	AccessKey       string
-	SecretAccessKey string
+	AccessKey       string `secret:"true"`
+	SecretAccessKey string `secret:"true"`
 	Path            string
 	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
 	FilesPrefixPath string   `json:"files_prefix_path"`
 	Metadata        string   `json:"metadata"`
 	LimitUploadRate string
+	StorageClass    string
 }
 
 func NewBackupTencentCloudOption() *TencentCloudBackupOption {
@@ -96,12 +114,14 @@ func (o *TencentCloudBackupOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for Tencent COS, for example https://cos.{region}.myqcloud.com/{bucket}/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
 	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
 	cmd.Flags().StringVarP(&o.LimitUploadRate, "limit-upload-rate", "", "", "Limits uploads to a maximum rate in KiB/s. (default: unlimited)")
+	cmd.Flags().StringVarP(&o.StorageClass, "storage-class", "", "", "Storage class for uploaded data, for example STANDARD_IA or INTELLIGENT_TIERING (default: bucket default)")
 }
 
 // ~ filesystem
@@ -113,6 +133,7 @@ type FilesystemBackupOption struct {
 	Endpoint        string
 	Path            string
 	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
 	FilesPrefixPath string   `json:"files_prefix_path"`
 	Metadata        string   `json:"metadata"`
 }
@@ -126,4 +147,83 @@ func (o *FilesystemBackupOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "The endpoint of the filesystem is the local computer directory where the backup will be stored")
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
 	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
+}
+
+// ~ sftp
+var _ Option = &SftpBackupOption{}
+
+type SftpBackupOption struct {
+	RepoId          string
+	RepoName        string
+	Host            string
+	Port            int
+	User            string
+	KeyFile         string
+	KnownHosts      string
+	RemotePath      string
+	Path            string
+	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
+	FilesPrefixPath string   `json:"files_prefix_path"`
+	Metadata        string   `json:"metadata"`
+	LimitUploadRate string
+}
+
+func NewBackupSftpOption() *SftpBackupOption {
+	return &SftpBackupOption{}
+}
+
+func (o *SftpBackupOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+
+	cmd.Flags().StringVarP(&o.Host, "host", "", "", "SFTP server host")
+	cmd.Flags().IntVarP(&o.Port, "port", "", 22, "SFTP server port")
+	cmd.Flags().StringVarP(&o.User, "user", "", "", "SFTP user")
+	cmd.Flags().StringVarP(&o.KeyFile, "key-file", "", "", "Private key file for SSH authentication")
+	cmd.Flags().StringVarP(&o.KnownHosts, "known-hosts", "", "", "known_hosts file used to verify the server host key")
+	cmd.Flags().StringVarP(&o.RemotePath, "remote-path", "", "", "The directory on the SFTP server where the backup will be stored")
+
+	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
+	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
+	cmd.Flags().StringVarP(&o.LimitUploadRate, "limit-upload-rate", "", "", "Limits uploads to a maximum rate in KiB/s. (default: unlimited)")
+}
+
+// ~ rest
+var _ Option = &RestBackupOption{}
+
+type RestBackupOption struct {
+	RepoId          string
+	RepoName        string
+	Endpoint        string
+	Username        string
+	RestPassword    string `secret:"true"`
+	CACert          string
+	AppendOnly      bool
+	Path            string
+	Files           []string `json:"files"`
+	Excludes        []string `json:"excludes,omitempty"`
+	FilesPrefixPath string   `json:"files_prefix_path"`
+	Metadata        string   `json:"metadata"`
+	LimitUploadRate string
+}
+
+func NewBackupRestOption() *RestBackupOption {
+	return &RestBackupOption{}
+}
+
+func (o *RestBackupOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for the REST server, for example https://{host}:8000/{prefix}")
+	cmd.Flags().StringVarP(&o.Username, "username", "", "", "Username for the REST server basic auth")
+	cmd.Flags().StringVarP(&o.RestPassword, "rest-password", "", "", "Password for the REST server basic auth, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.CACert, "cacert", "", "", "CA certificate file used to verify the REST server")
+	cmd.Flags().BoolVarP(&o.AppendOnly, "append-only", "", false, "The REST server runs in append-only mode, data is never removed from the repository")
+
+	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be backed up")
+	cmd.Flags().StringSliceVarP(&o.Files, "files-from", "", []string{}, "Read the files to backup from file, can be specified multiple times")
+	cmd.Flags().StringSliceVarP(&o.Excludes, "exclude", "", []string{}, "Exclude a pattern from the backup, can be specified multiple times")
+	cmd.Flags().StringVarP(&o.LimitUploadRate, "limit-upload-rate", "", "", "Limits uploads to a maximum rate in KiB/s. (default: unlimited)")
 }
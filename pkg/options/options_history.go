@@ -0,0 +1,66 @@
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/history"
+)
+
+var _ Option = &HistoryOption{}
+
+type HistoryOption struct {
+	File      string
+	Operation string
+	Location  string
+	RepoName  string
+	Outcome   string
+	Since     string
+	Limit     int
+	Json      bool
+}
+
+func NewHistoryOption() *HistoryOption {
+	return &HistoryOption{}
+}
+
+func (o *HistoryOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.File, "history-file", "", "", "Job history file (default: {base dir}/history.jsonl)")
+	cmd.Flags().StringVarP(&o.Operation, "operation", "", "", "Only show jobs of an operation: backup, restore, check or forget")
+	cmd.Flags().StringVarP(&o.Location, "location", "", "", "Only show jobs of a location, for example space, s3 or fs")
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Only show jobs of a repo")
+	cmd.Flags().StringVarP(&o.Outcome, "outcome", "", "", "Only show jobs with an outcome: success, failed or canceled")
+	cmd.Flags().StringVarP(&o.Since, "since", "", "", "Only show jobs started after a time like 2006-01-02 or within an age like 36h or 7d")
+	cmd.Flags().IntVarP(&o.Limit, "limit", "", 20, "Show the newest jobs only, 0 shows all")
+	cmd.Flags().BoolVarP(&o.Json, "json", "", false, "Print the jobs as json")
+}
+
+func (o *HistoryOption) Filter() (*history.Filter, error) {
+	switch history.Operation(o.Operation) {
+	case "", history.OperationBackup, history.OperationRestore, history.OperationCheck, history.OperationForget:
+	default:
+		return nil, fmt.Errorf("operation %s is not supported", o.Operation)
+	}
+	switch history.Outcome(o.Outcome) {
+	case "", history.OutcomeSuccess, history.OutcomeFailed, history.OutcomeCanceled:
+	default:
+		return nil, fmt.Errorf("outcome %s is not supported", o.Outcome)
+	}
+	if o.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+	since, err := history.ParseSince(o.Since, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &history.Filter{
+		Operation: history.Operation(o.Operation),
+		Location:  o.Location,
+		Repo:      o.RepoName,
+		Outcome:   history.Outcome(o.Outcome),
+		Since:     since,
+		Limit:     o.Limit,
+	}, nil
+}
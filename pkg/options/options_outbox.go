@@ -0,0 +1,21 @@
+package options
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var _ Option = &OutboxOption{}
+
+type OutboxOption struct {
+	Dir  string
+	Json bool
+}
+
+func NewOutboxOption() *OutboxOption {
+	return &OutboxOption{}
+}
+
+func (o *OutboxOption) AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&o.Dir, "outbox-dir", "", "", "Outbox dir of the cloud records (default: {base dir}/outbox)")
+	cmd.PersistentFlags().BoolVarP(&o.Json, "json", "", false, "Print the result as json")
+}
@@ -6,7 +6,7 @@ var _ Option = &SpaceRegionOptions{}
 
 type SpaceRegionOptions struct {
 	OlaresDid      string `json:"olares_did"`
-	AccessToken    string `json:"access_token"`
+	AccessToken    string `json:"access_token" secret:"true"`
 	CloudApiMirror string `json:"cloud_api_mirror"`
 }
 
@@ -16,6 +16,6 @@ func NewRegionSpaceOption() *SpaceRegionOptions {
 
 func (s *SpaceRegionOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&s.OlaresDid, "olares-did", "", "", "Olares DID")
-	cmd.Flags().StringVarP(&s.AccessToken, "access-token", "", "", "Space Access Token")
+	cmd.Flags().StringVarP(&s.AccessToken, "access-token", "", "", "Space Access Token, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 	cmd.Flags().StringVarP(&s.CloudApiMirror, "cloud-api-mirror", "", "", "Cloud API mirror")
 }
@@ -15,7 +15,7 @@ type SpaceRestoreOption struct {
 	Path              string
 	LimitDownloadRate string
 	OlaresDid         string
-	AccessToken       string
+	AccessToken       string `secret:"true"`
 	ClusterId         string
 	CloudName         string
 	RegionId          string
@@ -34,7 +34,7 @@ func (o *SpaceRestoreOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
 	cmd.Flags().StringVarP(&o.LimitDownloadRate, "limit-download-rate", "", "", "Limits downloads to a maximum rate in KiB/s. (default: unlimited)")
 	cmd.Flags().StringVarP(&o.OlaresDid, "olares-did", "", "", "Olares DID")
-	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token")
+	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 	cmd.Flags().StringVarP(&o.ClusterId, "cluster-id", "", "", "Olares Cluster ID")
 	cmd.Flags().StringVarP(&o.CloudName, "cloud-name", "", "", "Space Cloud Name")
 	cmd.Flags().StringVarP(&o.RegionId, "region-id", "", "", "Space Region Id")
@@ -49,8 +49,10 @@ type AwsRestoreOption struct {
 	RepoName          string
 	SnapshotId        string
 	Endpoint          string
-	AccessKey         string
-	SecretAccessKey   string
+	AccessKey         string `secret:"true"`
+	SecretAccessKey   string `secret:"true"`
+	SessionToken      string `secret:"true"`
+	Profile           string
 	Path              string
 	LimitDownloadRate string
 }
@@ -64,8 +66,10 @@ func (o *AwsRestoreOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.SnapshotId, "snapshot-id", "", "", "Snapshot ID")
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for S3, for example https://{bucket}.{region}.amazonaws.com/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SessionToken, "session-token", "", "", "Session Token for S3 temporary credentials, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.Profile, "aws-profile", "", "", "Profile name in the AWS shared credentials file, used when no access key is given")
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
 	cmd.Flags().StringVarP(&o.LimitDownloadRate, "limit-download-rate", "", "", "Limits downloads to a maximum rate in KiB/s. (default: unlimited)")
 }
@@ -78,8 +82,8 @@ type TencentCloudRestoreOption struct {
 	RepoName          string
 	SnapshotId        string
 	Endpoint          string
-	AccessKey         string
-	SecretAccessKey   string
+	AccessKey         string `secret:"true"`
+	SecretAccessKey   string `secret:"true"`
 	Path              string
 	LimitDownloadRate string
 }
@@ -93,8 +97,8 @@ func (o *TencentCloudRestoreOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.SnapshotId, "snapshot-id", "", "", "Snapshot ID")
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for Tencent COS, for example https://cos.{region}.myqcloud.com/{bucket}/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
 	cmd.Flags().StringVarP(&o.LimitDownloadRate, "limit-download-rate", "", "", "Limits downloads to a maximum rate in KiB/s. (default: unlimited)")
 }
@@ -123,3 +127,71 @@ func (o *FilesystemRestoreOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
 	cmd.Flags().StringVarP(&o.OlaresId, "olares-id", "", "", "Olares ID")
 }
+
+// ~ sftp
+var _ Option = &SftpRestoreOption{}
+
+type SftpRestoreOption struct {
+	RepoId            string
+	RepoName          string
+	SnapshotId        string
+	Host              string
+	Port              int
+	User              string
+	KeyFile           string
+	KnownHosts        string
+	RemotePath        string
+	Path              string
+	LimitDownloadRate string
+}
+
+func NewRestoreSftpOption() *SftpRestoreOption {
+	return &SftpRestoreOption{}
+}
+
+func (o *SftpRestoreOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoId, "repo-id", "", "", "Backup repo id")
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+	cmd.Flags().StringVarP(&o.SnapshotId, "snapshot-id", "", "", "Snapshot ID")
+	cmd.Flags().StringVarP(&o.Host, "host", "", "", "SFTP server host")
+	cmd.Flags().IntVarP(&o.Port, "port", "", 22, "SFTP server port")
+	cmd.Flags().StringVarP(&o.User, "user", "", "", "SFTP user")
+	cmd.Flags().StringVarP(&o.KeyFile, "key-file", "", "", "Private key file for SSH authentication")
+	cmd.Flags().StringVarP(&o.KnownHosts, "known-hosts", "", "", "known_hosts file used to verify the server host key")
+	cmd.Flags().StringVarP(&o.RemotePath, "remote-path", "", "", "The directory on the SFTP server where the backup will be stored")
+	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
+	cmd.Flags().StringVarP(&o.LimitDownloadRate, "limit-download-rate", "", "", "Limits downloads to a maximum rate in KiB/s. (default: unlimited)")
+}
+
+// ~ rest
+var _ Option = &RestRestoreOption{}
+
+type RestRestoreOption struct {
+	RepoId            string
+	RepoName          string
+	SnapshotId        string
+	Endpoint          string
+	Username          string
+	RestPassword      string `secret:"true"`
+	CACert            string
+	AppendOnly        bool
+	Path              string
+	LimitDownloadRate string
+}
+
+func NewRestoreRestOption() *RestRestoreOption {
+	return &RestRestoreOption{}
+}
+
+func (o *RestRestoreOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoId, "repo-id", "", "", "Backup repo id")
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+	cmd.Flags().StringVarP(&o.SnapshotId, "snapshot-id", "", "", "Snapshot ID")
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for the REST server, for example https://{host}:8000/{prefix}")
+	cmd.Flags().StringVarP(&o.Username, "username", "", "", "Username for the REST server basic auth")
+	cmd.Flags().StringVarP(&o.RestPassword, "rest-password", "", "", "Password for the REST server basic auth, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.CACert, "cacert", "", "", "CA certificate file used to verify the REST server")
+	cmd.Flags().BoolVarP(&o.AppendOnly, "append-only", "", false, "The REST server runs in append-only mode, data is never removed from the repository")
+	cmd.Flags().StringVarP(&o.Path, "path", "", "", "The directory to be restore")
+	cmd.Flags().StringVarP(&o.LimitDownloadRate, "limit-download-rate", "", "", "Limits downloads to a maximum rate in KiB/s. (default: unlimited)")
+}
@@ -11,7 +11,7 @@ type SpaceSnapshotsOption struct {
 	RepoId         string
 	RepoName       string
 	OlaresDid      string
-	AccessToken    string
+	AccessToken    string `secret:"true"`
 	ClusterId      string
 	CloudName      string
 	RegionId       string
@@ -25,7 +25,7 @@ func NewSnapshotsSpaceOption() *SpaceSnapshotsOption {
 func (o *SpaceSnapshotsOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.OlaresDid, "olares-did", "", "", "Olares DID")
-	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token")
+	cmd.Flags().StringVarP(&o.AccessToken, "access-token", "", "", "Space Access Token, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 	cmd.Flags().StringVarP(&o.ClusterId, "cluster-id", "", "", "Space Cluster ID")
 	cmd.Flags().StringVarP(&o.CloudName, "cloud-name", "", "", "Space Cloud Name")
 	cmd.Flags().StringVarP(&o.RegionId, "region-id", "", "", "Space Region Id")
@@ -39,8 +39,10 @@ type AwsSnapshotsOption struct {
 	RepoId          string
 	RepoName        string
 	Endpoint        string
-	AccessKey       string
-	SecretAccessKey string
+	AccessKey       string `secret:"true"`
+	SecretAccessKey string `secret:"true"`
+	SessionToken    string `secret:"true"`
+	Profile         string
 }
 
 func NewSnapshotsAwsOption() *AwsSnapshotsOption {
@@ -50,8 +52,10 @@ func NewSnapshotsAwsOption() *AwsSnapshotsOption {
 func (o *AwsSnapshotsOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for S3, for example https://{bucket}.{region}.amazonaws.com/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for S3, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SessionToken, "session-token", "", "", "Session Token for S3 temporary credentials, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.Profile, "aws-profile", "", "", "Profile name in the AWS shared credentials file, used when no access key is given")
 }
 
 // ~ cos
@@ -61,8 +65,8 @@ type TencentCloudSnapshotsOption struct {
 	RepoId          string
 	RepoName        string
 	Endpoint        string
-	AccessKey       string
-	SecretAccessKey string
+	AccessKey       string `secret:"true"`
+	SecretAccessKey string `secret:"true"`
 }
 
 func NewSnapshotsTencentCloudOption() *TencentCloudSnapshotsOption {
@@ -72,8 +76,8 @@ func NewSnapshotsTencentCloudOption() *TencentCloudSnapshotsOption {
 func (o *TencentCloudSnapshotsOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for Tencent COS, for example https://cos.{region}.myqcloud.com/{bucket}/{prefix}")
-	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS")
-	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS")
+	cmd.Flags().StringVarP(&o.AccessKey, "access-key", "", "", "Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.SecretAccessKey, "secret-access-key", "", "", "Secret Access Key for Tencent COS, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
 }
 
 // ~ filesystem
@@ -93,3 +97,57 @@ func (o *FilesystemSnapshotsOption) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
 	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "The endpoint of the filesystem is the local computer directory where the backup will be stored")
 }
+
+// ~ sftp
+var _ Option = &SftpSnapshotsOption{}
+
+type SftpSnapshotsOption struct {
+	RepoId     string
+	RepoName   string
+	Host       string
+	Port       int
+	User       string
+	KeyFile    string
+	KnownHosts string
+	RemotePath string
+}
+
+func NewSnapshotsSftpOption() *SftpSnapshotsOption {
+	return &SftpSnapshotsOption{}
+}
+
+func (o *SftpSnapshotsOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+	cmd.Flags().StringVarP(&o.Host, "host", "", "", "SFTP server host")
+	cmd.Flags().IntVarP(&o.Port, "port", "", 22, "SFTP server port")
+	cmd.Flags().StringVarP(&o.User, "user", "", "", "SFTP user")
+	cmd.Flags().StringVarP(&o.KeyFile, "key-file", "", "", "Private key file for SSH authentication")
+	cmd.Flags().StringVarP(&o.KnownHosts, "known-hosts", "", "", "known_hosts file used to verify the server host key")
+	cmd.Flags().StringVarP(&o.RemotePath, "remote-path", "", "", "The directory on the SFTP server where the backup will be stored")
+}
+
+// ~ rest
+var _ Option = &RestSnapshotsOption{}
+
+type RestSnapshotsOption struct {
+	RepoId       string
+	RepoName     string
+	Endpoint     string
+	Username     string
+	RestPassword string `secret:"true"`
+	CACert       string
+	AppendOnly   bool
+}
+
+func NewSnapshotsRestOption() *RestSnapshotsOption {
+	return &RestSnapshotsOption{}
+}
+
+func (o *RestSnapshotsOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.RepoName, "repo-name", "", "", "Backup repo name")
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint for the REST server, for example https://{host}:8000/{prefix}")
+	cmd.Flags().StringVarP(&o.Username, "username", "", "", "Username for the REST server basic auth")
+	cmd.Flags().StringVarP(&o.RestPassword, "rest-password", "", "", "Password for the REST server basic auth, or a secret reference env:NAME, file:PATH or cmd:COMMAND")
+	cmd.Flags().StringVarP(&o.CACert, "cacert", "", "", "CA certificate file used to verify the REST server")
+	cmd.Flags().BoolVarP(&o.AppendOnly, "append-only", "", false, "The REST server runs in append-only mode, data is never removed from the repository")
+}
@@ -0,0 +1,135 @@
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// location specific keys of Repository.Params
+const (
+	ParamOlaresDid      = "olares-did"
+	ParamClusterId      = "cluster-id"
+	ParamCloudName      = "cloud-name"
+	ParamRegionId       = "region-id"
+	ParamCloudApiMirror = "cloud-api-mirror"
+	ParamRepoSuffix     = "repo-suffix"
+	ParamKnownHosts     = "known-hosts"
+	ParamCACert         = "cacert"
+	ParamAppendOnly     = "append-only"
+)
+
+// Repository describes where a backup repository lives, independent of the operation run against it.
+//
+// Location is the name of a registered backend (space, s3, cos, fs, sftp, rest or a custom one),
+// settings that only apply to one location go to Params, for example region-id for space
+// or known-hosts for sftp.
+type Repository struct {
+	Location    string            `json:"location"`
+	RepoName    string            `json:"repo_name"`
+	RepoId      string            `json:"repo_id,omitempty"`
+	Endpoint    string            `json:"endpoint,omitempty"`
+	Credentials *Credentials      `json:"credentials,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+}
+
+// Credentials of a repository, each location reads the fields it needs
+type Credentials struct {
+	AccessKey       string `json:"access_key,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	AccessToken     string `json:"access_token,omitempty"`
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	KeyFile         string `json:"key_file,omitempty"`
+}
+
+// MarshalJSON leaves the secrets out of a serialized descriptor, a secret reference or a redacted
+// value is kept, so a descriptor that is stored or logged does not carry the credentials
+func (c Credentials) MarshalJSON() ([]byte, error) {
+	type credentials Credentials
+	var res = credentials(c)
+	for _, v := range []*string{&res.AccessKey, &res.SecretAccessKey, &res.SessionToken, &res.AccessToken, &res.Password} {
+		if *v != redact.Mask && !utils.IsSecretRef(*v) {
+			*v = ""
+		}
+	}
+	return json.Marshal(res)
+}
+
+func (r *Repository) Validate() error {
+	if r.Location == "" {
+		return fmt.Errorf("repository location is required")
+	}
+	if r.RepoName == "" {
+		return fmt.Errorf("repository name is required")
+	}
+	return nil
+}
+
+// GetCredentials never returns nil, so that fields can be read without checks
+func (r *Repository) GetCredentials() *Credentials {
+	if r.Credentials == nil {
+		return &Credentials{}
+	}
+	return r.Credentials
+}
+
+func (r *Repository) Param(key string) string {
+	return r.Params[key]
+}
+
+func (r *Repository) BoolParam(key string) (bool, error) {
+	var v = r.Params[key]
+	if v == "" {
+		return false, nil
+	}
+	res, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("repository param %s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (r *Repository) IntParam(key string) (int, error) {
+	var v = r.Params[key]
+	if v == "" {
+		return 0, nil
+	}
+	res, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("repository param %s: %v", key, err)
+	}
+	return res, nil
+}
+
+// RepositoryBackupOption is a backup of the repository
+type RepositoryBackupOption struct {
+	Repository
+	Path            string   `json:"path"`
+	Files           []string `json:"files,omitempty"`
+	Excludes        []string `json:"excludes,omitempty"`
+	FilesPrefixPath string   `json:"files_prefix_path,omitempty"`
+	Metadata        string   `json:"metadata,omitempty"`
+	LimitUploadRate string   `json:"limit_upload_rate,omitempty"`
+	StorageClass    string   `json:"storage_class,omitempty"`
+	Immutable       bool     `json:"immutable,omitempty"`
+	RetentionDays   int      `json:"retention_days,omitempty"`
+}
+
+// RepositoryRestoreOption is a restore of a snapshot from the repository
+type RepositoryRestoreOption struct {
+	Repository
+	SnapshotId        string `json:"snapshot_id"`
+	Path              string `json:"path"`
+	LimitDownloadRate string `json:"limit_download_rate,omitempty"`
+}
+
+// RepositorySnapshotsOption lists snapshots or scans stats of the repository
+type RepositorySnapshotsOption struct {
+	Repository
+}
@@ -0,0 +1,53 @@
+package options
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// ResolveSecrets returns a copy of the option with the secret references of the
+// fields tagged `secret:"true"` resolved, see utils.ResolveSecret, the values are registered for redaction
+func ResolveSecrets(option Option) (Option, error) {
+	var v = reflect.ValueOf(option)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return option, nil
+	}
+
+	var res = reflect.New(v.Elem().Type())
+	res.Elem().Set(v.Elem())
+
+	var t = res.Elem().Type()
+	for i := 0; i < t.NumField(); i++ {
+		var field = res.Elem().Field(i)
+		if t.Field(i).Tag.Get("secret") != "true" || field.Kind() != reflect.String || !field.CanSet() {
+			continue
+		}
+		secret, err := utils.ResolveSecret(field.String())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.Field(i).Name, err)
+		}
+		redact.Register(secret)
+		field.SetString(secret)
+	}
+
+	return res.Interface().(Option), nil
+}
+
+// PasswordOption reads the repository password without a terminal
+type PasswordOption struct {
+	PasswordFile    string
+	PasswordCommand string
+}
+
+func NewPasswordOption() *PasswordOption {
+	return &PasswordOption{}
+}
+
+func (o *PasswordOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.PasswordFile, "password-file", "", "", "Read the repository password from a file (default: $RESTIC_PASSWORD_FILE)")
+	cmd.Flags().StringVarP(&o.PasswordCommand, "password-command", "", "", "Read the repository password from the output of a shell command (default: $RESTIC_PASSWORD_COMMAND)")
+}
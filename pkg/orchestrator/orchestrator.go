@@ -0,0 +1,277 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+const DefaultWorkers = 2
+
+// a running backup is restarted when its share grows by half at least, a restart scans the source again
+const rebalanceGain = 1.5
+
+// Options of a batch of backups
+type Options struct {
+	Workers        int            // backups running at the same time, DefaultWorkers when zero
+	LocationLimits map[string]int // backups of a location running at the same time, like {"space": 1}, no limit for a missing location
+	UploadBudget   int64          // total upload rate in KiB/s shared by the running backups, zero means unlimited
+	DryRun         bool
+
+	// Progress receives the percent done of the backup at index in the batch, it must not block
+	Progress func(index int, percentDone float64)
+}
+
+// Result of one backup of the batch
+type Result struct {
+	Index           int                   `json:"index"`
+	Location        string                `json:"location"`
+	Repo            string                `json:"repo"`
+	LimitUploadRate int64                 `json:"limit_upload_rate,omitempty"` // the last share of the budget in KiB/s, zero when unlimited
+	StartedAt       *time.Time            `json:"started_at,omitempty"`
+	Duration        float64               `json:"duration,omitempty"` // seconds
+	Summary         *restic.SummaryOutput `json:"summary,omitempty"`
+	StorageInfo     *model.StorageInfo    `json:"storage_info,omitempty"`
+	Error           string                `json:"error,omitempty"`
+	Err             error                 `json:"-"`
+}
+
+// Summary combines the results of the batch, the results are in the order of the batch
+type Summary struct {
+	Total          int       `json:"total"`
+	Succeeded      int       `json:"succeeded"`
+	Failed         int       `json:"failed"`
+	Canceled       int       `json:"canceled"`
+	BytesProcessed uint64    `json:"bytes_processed"`
+	BytesAdded     uint64    `json:"bytes_added"`
+	FilesNew       uint64    `json:"files_new"`
+	FilesChanged   uint64    `json:"files_changed"`
+	Duration       float64   `json:"duration"` // seconds
+	Results        []*Result `json:"results"`
+}
+
+// Err is the first error of the batch, nil when every backup succeeded
+func (s *Summary) Err() error {
+	for _, r := range s.Results {
+		if r.Err != nil {
+			return fmt.Errorf("backup %d of %s repo %s: %w", r.Index, r.Location, r.Repo, r.Err)
+		}
+	}
+	return nil
+}
+
+// runBackup is replaced by the tests
+var runBackup = func(ctx context.Context, option *storage.BackupOption, dryRun bool, progress func(float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
+	return storage.NewBackupService(option).BackupContext(ctx, dryRun, progress)
+}
+
+type job struct {
+	index    int
+	option   *storage.BackupOption
+	location string
+	limit    int64 // KiB/s the backup may upload itself, zero when unlimited
+	result   *Result
+	rate     *storage.RateLimit // the share of the budget, the backup restarts restic when it grows
+}
+
+// Run runs the backups with a pool of workers until all are finished or ctx is done.
+//
+// The upload budget is split when a backup starts: the budget not taken by running backups is shared
+// by the backups that can start now, so the rate of finished backups goes to the ones starting later.
+// Once none is pending, the budget freed by finished backups is shared by the running ones. restic reads
+// --limit-upload once at start, so a backup whose share grows by half restarts restic with the new rate,
+// the restart keeps the data already uploaded and the backup is still recorded once.
+// The options are not modified, the error is only returned for an invalid batch
+func Run(ctx context.Context, backups []*storage.BackupOption, opts *Options) (*Summary, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var workers = opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if opts.UploadBudget < 0 {
+		return nil, errors.New("upload budget must not be negative")
+	}
+	if opts.UploadBudget > 0 && opts.UploadBudget < int64(workers) {
+		return nil, fmt.Errorf("upload budget of %d KiB/s is lower than one KiB/s per worker", opts.UploadBudget)
+	}
+
+	for location, limit := range opts.LocationLimits {
+		if limit < 1 {
+			return nil, fmt.Errorf("limit of location %s must be at least 1", location)
+		}
+	}
+
+	var log = logger.FromContext(ctx)
+	var start = time.Now()
+	var pending []*job
+	var summary = &Summary{Total: len(backups), Results: make([]*Result, len(backups))}
+	for i, option := range backups {
+		if option == nil {
+			return nil, fmt.Errorf("backup %d is nil", i)
+		}
+		location, repo, err := option.Destination()
+		if err != nil {
+			return nil, fmt.Errorf("backup %d: %v", i, err)
+		}
+		var j = &job{index: i, option: option, location: location, result: &Result{Index: i, Location: location, Repo: repo}}
+		if option.LimitUploadRate != "" {
+			if j.limit, err = strconv.ParseInt(option.LimitUploadRate, 10, 64); err != nil || j.limit <= 0 {
+				return nil, fmt.Errorf("backup %d: invalid upload rate limit %q", i, option.LimitUploadRate)
+			}
+		}
+		summary.Results[i] = j.result
+		pending = append(pending, j)
+	}
+
+	var done = make(chan *job)
+	var running = make(map[*job]bool)
+	var perLocation = make(map[string]int)
+	var allocated int64
+
+	// startable is the index in pending of the next backup the location limits allow, -1 when none
+	var startable = func(counts map[string]int, from int) int {
+		for i := from; i < len(pending); i++ {
+			var limit, ok = opts.LocationLimits[pending[i].location]
+			if !ok || counts[pending[i].location] < limit {
+				return i
+			}
+		}
+		return -1
+	}
+	// slots counts the backups that can start now, they share the free budget
+	var slots = func() int {
+		var counts = make(map[string]int, len(perLocation))
+		for k, v := range perLocation {
+			counts[k] = v
+		}
+		var n = 0
+		for i := startable(counts, 0); i >= 0 && len(running)+n < workers; i = startable(counts, i+1) {
+			counts[pending[i].location]++
+			n++
+		}
+		return n
+	}
+
+	var launch = func(j *job) {
+		var rate int64
+		if opts.UploadBudget > 0 {
+			rate = (opts.UploadBudget - allocated) / int64(slots())
+			if j.limit > 0 && j.limit < rate {
+				rate = j.limit
+			}
+			allocated += rate
+		}
+		var now = time.Now()
+		j.result.LimitUploadRate, j.result.StartedAt = rate, &now
+		var option = *j.option
+		if rate > 0 {
+			j.rate = storage.NewRateLimit(rate)
+			option.UploadRateLimit = j.rate
+		}
+		running[j] = true
+		perLocation[j.location]++
+		log.Infof("backup %d of %s repo %s started, upload rate %d KiB/s", j.index, j.location, j.result.Repo, rate)
+
+		go func() {
+			var progress = func(percentDone float64) {
+				if opts.Progress != nil {
+					opts.Progress(j.index, percentDone)
+				}
+			}
+			j.result.Summary, j.result.StorageInfo, j.result.Err = runBackup(ctx, &option, opts.DryRun, progress)
+			done <- j
+		}()
+	}
+
+	// rebalance shares the free budget by the running backups below their own limit
+	var rebalance = func() {
+		var free = opts.UploadBudget - allocated
+		var grow []*job
+		for j := range running {
+			if j.rate != nil && (j.limit == 0 || j.result.LimitUploadRate < j.limit) {
+				grow = append(grow, j)
+			}
+		}
+		if opts.UploadBudget == 0 || len(pending) > 0 || len(grow) == 0 || free < int64(len(grow)) {
+			return
+		}
+		for _, j := range grow {
+			var rate = j.result.LimitUploadRate + free/int64(len(grow))
+			if j.limit > 0 && j.limit < rate {
+				rate = j.limit
+			}
+			if float64(rate) < float64(j.result.LimitUploadRate)*rebalanceGain {
+				continue
+			}
+			allocated += rate - j.result.LimitUploadRate
+			j.result.LimitUploadRate = rate
+			j.rate.Set(rate)
+			log.Infof("backup %d of %s repo %s upload rate raised to %d KiB/s", j.index, j.location, j.result.Repo, rate)
+		}
+	}
+
+	for len(pending) > 0 || len(running) > 0 {
+		for len(running) < workers && ctx.Err() == nil {
+			var i = startable(perLocation, 0)
+			if i < 0 {
+				break
+			}
+			var j = pending[i]
+			launch(j)
+			pending = append(pending[:i], pending[i+1:]...)
+		}
+		if ctx.Err() == nil {
+			rebalance()
+		}
+		if len(running) == 0 {
+			// ctx is done, the pending backups are not started
+			for _, j := range pending {
+				j.result.Err = ctx.Err()
+			}
+			pending = nil
+			break
+		}
+
+		var j = <-done
+		delete(running, j)
+		perLocation[j.location]--
+		allocated -= j.result.LimitUploadRate
+		j.result.Duration = time.Since(*j.result.StartedAt).Seconds()
+		if j.result.Err != nil {
+			log.Errorf("backup %d of %s repo %s error: %v", j.index, j.location, j.result.Repo, j.result.Err)
+		}
+	}
+
+	for _, r := range summary.Results {
+		switch {
+		case r.Err == nil:
+			summary.Succeeded++
+			if r.Summary != nil {
+				summary.BytesProcessed += r.Summary.TotalBytesProcessed
+				summary.BytesAdded += r.Summary.DataAdded
+				summary.FilesNew += uint64(r.Summary.FilesNew)
+				summary.FilesChanged += uint64(r.Summary.FilesChanged)
+			}
+		case errors.Is(r.Err, context.Canceled) || errors.Is(r.Err, context.DeadlineExceeded):
+			summary.Canceled++
+			r.Error = redact.String(r.Err.Error())
+		default:
+			summary.Failed++
+			r.Error = redact.String(r.Err.Error())
+		}
+	}
+	summary.Duration = time.Since(start).Seconds()
+	log.Infof("backups finished in %s, %d succeeded, %d failed, %d canceled", time.Since(start).Round(time.Second),
+		summary.Succeeded, summary.Failed, summary.Canceled)
+	return summary, nil
+}
@@ -0,0 +1,217 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+// fakeBackups replaces restic, it tracks the running backups and their upload rates
+type fakeBackups struct {
+	mu          sync.Mutex
+	running     map[string]int
+	maxRunning  map[string]int
+	total       int64
+	maxTotal    int64
+	unlimited   int
+	fail        map[string]bool
+	sleep       time.Duration
+	sleeps      map[string]time.Duration // the sleep of a repo, sleep when missing
+	rates       map[string][]int64       // the rate of every run of restic of a repo
+	calls       map[string]int           // the backups of a repo, restarts of restic are not counted
+	maxParallel int
+	parallel    int
+}
+
+func newFakeBackups(t *testing.T) *fakeBackups {
+	var f = &fakeBackups{running: map[string]int{}, maxRunning: map[string]int{}, fail: map[string]bool{}, sleep: 20 * time.Millisecond,
+		sleeps: map[string]time.Duration{}, rates: map[string][]int64{}, calls: map[string]int{}}
+	var old = runBackup
+	runBackup = f.backup
+	t.Cleanup(func() { runBackup = old })
+	return f
+}
+
+// backup restarts the run like BackupContext when the rate limit of the option changes
+func (f *fakeBackups) backup(ctx context.Context, option *storage.BackupOption, dryRun bool, progress func(float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
+	_, repo, _ := option.Destination()
+	f.mu.Lock()
+	f.calls[repo]++
+	f.mu.Unlock()
+	for {
+		var rate, _ = strconv.ParseInt(option.LimitUploadRate, 10, 64)
+		var changed <-chan struct{}
+		if option.UploadRateLimit != nil {
+			rate, changed = option.UploadRateLimit.Get()
+		}
+		summary, err := f.run(ctx, option, rate, changed, progress)
+		if !errors.Is(err, errRestarted) {
+			return summary, nil, err
+		}
+	}
+}
+
+var errRestarted = errors.New("restarted")
+
+func (f *fakeBackups) run(ctx context.Context, option *storage.BackupOption, rate int64, changed <-chan struct{}, progress func(float64)) (*restic.SummaryOutput, error) {
+	location, repo, _ := option.Destination()
+	f.mu.Lock()
+	f.running[location]++
+	f.maxRunning[location] = max(f.maxRunning[location], f.running[location])
+	f.parallel++
+	f.maxParallel = max(f.maxParallel, f.parallel)
+	f.total += rate
+	f.maxTotal = max(f.maxTotal, f.total)
+	if rate == 0 {
+		f.unlimited++
+	}
+	f.rates[repo] = append(f.rates[repo], rate)
+	var sleep, ok = f.sleeps[repo]
+	if !ok {
+		sleep = f.sleep
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running[location]--
+		f.parallel--
+		f.total -= rate
+		f.mu.Unlock()
+	}()
+
+	progress(0.5)
+	select {
+	case <-time.After(sleep):
+	case <-changed:
+		return nil, errRestarted
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.fail[repo] {
+		return nil, errors.New("repository is locked")
+	}
+	return &restic.SummaryOutput{TotalBytesProcessed: 100, DataAdded: 10, FilesNew: 1}, nil
+}
+
+func batch(locations ...string) []*storage.BackupOption {
+	var res []*storage.BackupOption
+	for i, location := range locations {
+		res = append(res, &storage.BackupOption{Repository: &options.RepositoryBackupOption{
+			Repository: options.Repository{Location: location, RepoName: location + strconv.Itoa(i)},
+			Path:       "/data",
+		}})
+	}
+	return res
+}
+
+func TestRun(t *testing.T) {
+	var f = newFakeBackups(t)
+	f.fail["s31"] = true
+
+	var progress sync.Map
+	summary, err := Run(context.Background(), batch("s3", "s3", "space", "space", "space", "cos"), &Options{
+		Workers:        3,
+		LocationLimits: map[string]int{"space": 1},
+		UploadBudget:   3000,
+		Progress:       func(index int, percentDone float64) { progress.Store(index, percentDone) },
+	})
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, summary.Total, 6)
+	assert.Equal(t, summary.Succeeded, 5)
+	assert.Equal(t, summary.Failed, 1)
+	assert.Equal(t, summary.BytesProcessed, uint64(500))
+	assert.Equal(t, summary.Results[1].Error, "repository is locked")
+	assert.NotEqual(t, summary.Err(), nil)
+	for i, r := range summary.Results {
+		assert.Equal(t, r.Index, i)
+		assert.NotEqual(t, r.LimitUploadRate, int64(0))
+		v, _ := progress.Load(i)
+		assert.Equal(t, v, 0.5)
+	}
+
+	assert.Equal(t, f.maxParallel, 3)
+	assert.Equal(t, f.maxRunning["space"], 1)
+	assert.Equal(t, f.maxTotal <= 3000, true)
+	assert.Equal(t, f.unlimited, 0)
+}
+
+func TestUploadBudget(t *testing.T) {
+	var f = newFakeBackups(t)
+	var backups = batch("s3", "s3", "s3")
+	backups[0].LimitUploadRate = "100"
+
+	summary, err := Run(context.Background(), backups, &Options{Workers: 2, UploadBudget: 1000})
+	assert.Equal(t, err, nil)
+	// the first two share the budget, the own limit of the first leaves more to the third
+	assert.Equal(t, summary.Results[0].LimitUploadRate, int64(100))
+	assert.Equal(t, f.rates["s31"][0], int64(900))
+	assert.Equal(t, f.maxTotal <= 1000, true)
+	// the options of the caller are not modified
+	assert.Equal(t, backups[1].LimitUploadRate, "")
+
+	summary, err = Run(context.Background(), batch("s3", "cos"), nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, summary.Succeeded, 2)
+	assert.Equal(t, f.unlimited, 2)
+}
+
+func TestRebalance(t *testing.T) {
+	var f = newFakeBackups(t)
+	f.sleeps["s30"] = 10 * time.Millisecond
+	f.sleeps["s31"] = 100 * time.Millisecond
+	f.sleeps["s32"] = 400 * time.Millisecond
+	var backups = batch("s3", "s3", "s3")
+	backups[2].LimitUploadRate = "300"
+
+	summary, err := Run(context.Background(), backups, &Options{Workers: 3, UploadBudget: 900})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, summary.Succeeded, 3)
+	// the budget of the first goes to the second when it finishes, the third keeps its own limit
+	assert.Equal(t, f.rates["s31"], []int64{300, 600})
+	assert.Equal(t, f.rates["s32"], []int64{300})
+	// the restart is inside the one backup of the repo
+	assert.Equal(t, f.calls, map[string]int{"s30": 1, "s31": 1, "s32": 1})
+	assert.Equal(t, summary.Results[1].LimitUploadRate, int64(600))
+	assert.Equal(t, f.maxTotal <= 900, true)
+}
+
+func TestCanceled(t *testing.T) {
+	var f = newFakeBackups(t)
+	f.sleep = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	summary, err := Run(ctx, batch("s3", "s3", "s3"), &Options{Workers: 1})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, summary.Canceled, 3)
+	assert.Equal(t, summary.Results[2].StartedAt == nil, true)
+}
+
+func TestInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		backups []*storage.BackupOption
+		opts    *Options
+	}{
+		{name: "nil backup", backups: []*storage.BackupOption{nil}},
+		{name: "unknown location", backups: batch("ftp")},
+		{name: "negative budget", backups: batch("s3"), opts: &Options{UploadBudget: -1}},
+		{name: "zero location limit", backups: batch("s3"), opts: &Options{LocationLimits: map[string]int{"s3": 0}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(context.Background(), tt.backups, tt.opts)
+			assert.NotEqual(t, err, nil)
+		})
+	}
+}
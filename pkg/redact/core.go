@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Core wraps a zap core so that messages and string fields are masked before they are written
+func Core(core zapcore.Core) zapcore.Core {
+	if _, ok := core.(*redactCore); ok {
+		return core
+	}
+	return &redactCore{Core: core}
+}
+
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+// Check asks the inner core which of its cores take the entry, a tee filters by the level of each one,
+// and writes to them through the redaction
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if checked := c.Core.Check(ent, nil); checked != nil {
+		return ce.AddCore(ent, &checkedCore{Core: c.Core, checked: checked})
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(redactEntry(ent), redactFields(fields))
+}
+
+// checkedCore writes one entry to the cores the inner core checked
+type checkedCore struct {
+	zapcore.Core
+	checked *zapcore.CheckedEntry
+}
+
+func (c *checkedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.checked.Entry = redactEntry(ent)
+	c.checked.Write(redactFields(fields)...)
+	return nil
+}
+
+func redactEntry(ent zapcore.Entry) zapcore.Entry {
+	ent.Message = String(ent.Message)
+	ent.Stack = String(ent.Stack)
+	return ent
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var res = make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = String(f.String)
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: String(err.Error())}
+			}
+		case zapcore.StringerType, zapcore.ReflectType, zapcore.ByteStringType:
+			var s string
+			if b, ok := f.Interface.([]byte); ok {
+				s = string(b)
+			} else {
+				s = fmt.Sprintf("%+v", f.Interface)
+			}
+			f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: String(s)}
+		}
+		res[i] = f
+	}
+	return res
+}
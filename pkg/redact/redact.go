@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces a secret
+const Mask = "******"
+
+// secrets shorter than minSecretLength are not registered, masking them would mangle unrelated text
+const minSecretLength = 4
+
+// secret keys of form data, query strings, env vars and json
+const secretKeys = `access[_-]?token|accesstoken|token|password|passwd|pwd|secret|secret[_-]?key|secret[_-]?access[_-]?key|` +
+	`session[_-]?token|security[_-]?token|x-amz-security-token|x-amz-signature|x-amz-credential|signature|` +
+	`aws_access_key_id|aws_secret_access_key|aws_session_token|restic_password|restic_rest_password|` +
+	`tmp[_-]?secret[_-]?id|tmp[_-]?secret[_-]?key|ak|sk|st`
+
+var patterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// key=value in form data, query strings and env vars
+	{re: regexp.MustCompile(`(?i)((?:^|[?&\s"',;(\[{])(?:` + secretKeys + `)=)[^&\s"',;)\]}]+`), repl: "${1}" + Mask},
+	// "key": "value" in json
+	{re: regexp.MustCompile(`(?i)("(?:` + secretKeys + `)"\s*:\s*")(?:[^"\\]|\\.)*(")`), repl: "${1}" + Mask + "${2}"},
+	// the password of user:password@host in urls
+	{re: regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+@`), repl: "${1}" + Mask + "@"},
+	// authorization headers
+	{re: regexp.MustCompile(`(?i)(authorization:?\s*(?:bearer|basic)\s+)[^\s"',]+`), repl: "${1}" + Mask},
+}
+
+var registry = struct {
+	sync.RWMutex
+	values map[string]struct{}
+	sorted []string
+}{values: make(map[string]struct{})}
+
+// Register adds secret values that are masked wherever they show up, for example a resolved password
+func Register(values ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	var changed bool
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength {
+			continue
+		}
+		if _, ok := registry.values[v]; ok {
+			continue
+		}
+		registry.values[v] = struct{}{}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	// longest first, so a secret that contains another one is masked as a whole
+	registry.sorted = registry.sorted[:0]
+	for v := range registry.values {
+		registry.sorted = append(registry.sorted, v)
+	}
+	sort.Slice(registry.sorted, func(i, j int) bool { return len(registry.sorted[i]) > len(registry.sorted[j]) })
+}
+
+// String masks the registered secrets and the well-known secret patterns of s
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+
+	registry.RLock()
+	for _, v := range registry.sorted {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, Mask)
+		}
+	}
+	registry.RUnlock()
+
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// Errorf is fmt.Errorf with the message masked, errors.Is and errors.As still see the wrapped error
+func Errorf(format string, args ...any) error {
+	return Error(fmt.Errorf(format, args...))
+}
+
+// Error masks the message of err
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	var r *redactedError
+	if errors.As(err, &r) && r == err {
+		return err
+	}
+	return &redactedError{msg: String(err.Error()), err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+
+	"olares.com/backups-sdk/pkg/redact"
 )
 
 type ResticEnvs struct {
@@ -12,6 +15,8 @@ type ResticEnvs struct {
 	AWS_SECRET_ACCESS_KEY string `env:"AWS_SECRET_ACCESS_KEY" json:"aws_secret_access_key,omitempty"`
 	AWS_SESSION_TOKEN     string `env:"AWS_SESSION_TOKEN" json:"aws_session_token,omitempty"`
 	RESTIC_REPOSITORY     string `env:"RESTIC_REPOSITORY" json:"restic_repository,omitempty"`
+	RESTIC_REST_USERNAME  string `env:"RESTIC_REST_USERNAME" json:"restic_rest_username,omitempty"`
+	RESTIC_REST_PASSWORD  string `env:"RESTIC_REST_PASSWORD" json:"-"`
 	RESTIC_PASSWORD       string `env:"RESTIC_PASSWORD" json:"-"`
 }
 
@@ -45,8 +50,15 @@ func (r *ResticEnvs) Slice() []string {
 	return m
 }
 
+// String is the json of the envs with the credentials masked, it is safe to log
 func (r *ResticEnvs) String() string {
-	res, _ := json.Marshal(r)
+	var c = *r
+	for _, v := range []*string{&c.AWS_SECRET_ACCESS_KEY, &c.AWS_SESSION_TOKEN} {
+		if *v != "" {
+			*v = redact.Mask
+		}
+	}
+	res, _ := json.Marshal(&c)
 	return string(res)
 }
 
@@ -204,3 +216,73 @@ type InitSummaryOutput struct {
 	Id          string `json:"id"`
 	Repository  string `json:"repository"`
 }
+
+// ForgetPolicy is the retention policy of restic forget, snapshots matching any keep rule are kept
+type ForgetPolicy struct {
+	KeepLast    int    `json:"keep_last,omitempty"`
+	KeepHourly  int    `json:"keep_hourly,omitempty"`
+	KeepDaily   int    `json:"keep_daily,omitempty"`
+	KeepWeekly  int    `json:"keep_weekly,omitempty"`
+	KeepMonthly int    `json:"keep_monthly,omitempty"`
+	KeepYearly  int    `json:"keep_yearly,omitempty"`
+	KeepWithin  string `json:"keep_within,omitempty"` // a restic duration like 30d or 1y2m
+	Prune       bool   `json:"prune,omitempty"`       // also remove the data no snapshot references
+}
+
+// Args are the keep flags of the policy, snapshots are not grouped since a repository holds one backup source
+func (p *ForgetPolicy) Args() []string {
+	var args []string
+	for _, k := range []struct {
+		flag  string
+		value int
+	}{
+		{"--keep-last", p.KeepLast}, {"--keep-hourly", p.KeepHourly}, {"--keep-daily", p.KeepDaily},
+		{"--keep-weekly", p.KeepWeekly}, {"--keep-monthly", p.KeepMonthly}, {"--keep-yearly", p.KeepYearly},
+	} {
+		if k.value > 0 {
+			args = append(args, k.flag, strconv.Itoa(k.value))
+		}
+	}
+	if p.KeepWithin != "" {
+		args = append(args, "--keep-within", p.KeepWithin)
+	}
+	if len(args) > 0 {
+		args = append(args, "--group-by", "")
+	}
+	return args
+}
+
+type ForgetGroup struct {
+	Tags   []string    `json:"tags"`
+	Host   string      `json:"host"`
+	Paths  []string    `json:"paths"`
+	Keep   []*Snapshot `json:"keep"`
+	Remove []*Snapshot `json:"remove"`
+}
+
+type ForgetSummary struct {
+	Kept    int            `json:"kept"`
+	Removed int            `json:"removed"`
+	Pruned  bool           `json:"pruned"`
+	DryRun  bool           `json:"dry_run,omitempty"`
+	Groups  []*ForgetGroup `json:"groups,omitempty"`
+}
+
+type CheckSummary struct {
+	ReadDataSubset string `json:"read_data_subset,omitempty"`
+	Output         string `json:"output"`
+}
+
+// LsNode is a file or directory of a snapshot
+type LsNode struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"` // file, dir or symlink
+	Path  string `json:"path"`
+	Uid   uint32 `json:"uid"`
+	Gid   uint32 `json:"gid"`
+	Size  uint64 `json:"size,omitempty"`
+	Mode  uint32 `json:"mode,omitempty"`
+	Mtime string `json:"mtime"`
+	Atime string `json:"atime,omitempty"`
+	Ctime string `json:"ctime,omitempty"`
+}
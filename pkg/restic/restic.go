@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,10 +19,16 @@ import (
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/shirou/gopsutil/v4/disk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
 	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/metrics"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/tracing"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
@@ -62,6 +69,8 @@ const (
 	ERROR_MESSAGE_NO_SPACE_LEFT_ON_DEVICE_MESSAGE    RESTIC_ERROR_MESSAGE = "Insufficient space on the target disk."
 	ERROR_MESSAGE_ACCESS_DENIED                      RESTIC_ERROR_MESSAGE = "Access Denied"
 	ERROR_MESSAGE_ACCESS_DENIED_MESSAGE              RESTIC_ERROR_MESSAGE = "Access denied. Please provide the correct access key."
+	ERROR_MESSAGE_REPOSITORY_IMMUTABLE               RESTIC_ERROR_MESSAGE = "repository objects are under object lock retention, prune is not allowed"
+	ERROR_MESSAGE_REPOSITORY_APPEND_ONLY             RESTIC_ERROR_MESSAGE = "repository is append-only, prune and unlock are not allowed"
 )
 
 const (
@@ -82,6 +91,29 @@ func (e RESTIC_ERROR_MESSAGE) ToLower() string {
 	return strings.ToLower(string(e))
 }
 
+// expiredTokenMessages are the errors of a run whose temporary credentials expired
+var expiredTokenMessages = []string{
+	ERROR_MESSAGE_TOKEN_EXPIRED.Error(),
+	ERROR_MESSAGE_COS_TOKEN_EXPIRED.Error(),
+	"ExpiredToken",
+	"The security token included in the request is expired",
+	"RequestExpired",
+}
+
+// IsTokenExpired reports whether err is the failure of a run whose temporary credentials expired
+func IsTokenExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+	var msg = strings.ToLower(err.Error())
+	for _, m := range expiredTokenMessages {
+		if strings.Contains(msg, strings.ToLower(m)) {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	tolerance = 1e-9
 
@@ -105,17 +137,26 @@ type ResticOptions struct {
 	SnapshotId        string
 	Path              string
 	Files             []string
+	Excludes          []string
 	FilesPrefixPath   string
 	Metadata          string
 	LimitDownloadRate string
 	LimitUploadRate   string
+	StorageClass      string
+	ImmutableUntil    time.Time // prune is refused until the object lock retention expires
+	LockedSnapshots   []string  // snapshots under object lock retention, forget keeps them
+	SftpCommand       string
+	CACert            string
+	AppendOnly        bool // the server refuses deletes, nothing may be removed from the repository
 	DryRun            bool
+	SkipSpaceCheck    bool // the pre-flight free space checks of restores and local backups are skipped
 	LocalEndpoint     string
 
 	Operator                 string
 	BackupType               string
 	BackupAppTypeName        string
 	BackupFileTypeSourcePath string
+	BackupSetId              string
 	RepoEnvs                 *ResticEnvs
 }
 
@@ -153,8 +194,10 @@ type Restic struct {
 	dir    string
 	args   []string
 	opt    *ResticOptions
+	log    *zap.SugaredLogger
 }
 
+// NewRestic runs restic with the logger of ctx, see logger.NewContext
 func NewRestic(ctx context.Context, opt *ResticOptions) (*Restic, error) {
 	var commandPath, err = utils.Lookup("restic")
 	if err != nil {
@@ -166,15 +209,20 @@ func NewRestic(ctx context.Context, opt *ResticOptions) (*Restic, error) {
 		cancel: cancel,
 		dir:    commandPath,
 		opt:    opt,
+		log:    logger.FromContext(ctx),
 	}, nil
 }
 
-func (r *Restic) Init() (string, error) {
+func (r *Restic) Init() (_ string, err error) {
+	var span = r.startSpan("init")
+	defer r.endSpan(span, &err)
+
 	r.addCommand([]string{"init", "-v=3", PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS}).addExtended()
 
 	cmd := exec.CommandContext(r.ctx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
 	cmd.Env = append(cmd.Env, r.opt.RepoEnvs.Slice()...)
-	logger.Infof("[Cmd] %s", cmd.String())
+	r.log.Infof("[Cmd] %s", cmd.String())
 	var outerr RESTIC_ERROR_MESSAGE
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -190,6 +238,15 @@ func (r *Restic) Init() (string, error) {
 }
 
 func (r *Restic) Rollback() error {
+	if r.opt.AppendOnly {
+		r.log.Warnf("repo %s is append-only, skip prune", r.opt.RepoName)
+		return ERROR_MESSAGE_REPOSITORY_APPEND_ONLY
+	}
+	if r.opt.ImmutableUntil.After(time.Now()) {
+		r.log.Warnf("repo %s is locked until %s, skip prune", r.opt.RepoName, r.opt.ImmutableUntil.Format(time.RFC3339))
+		return ERROR_MESSAGE_REPOSITORY_IMMUTABLE
+	}
+
 	backoff := wait.Backoff{
 		Duration: 2 * time.Second,
 		Factor:   2,
@@ -216,26 +273,268 @@ func (r *Restic) Rollback() error {
 	return nil
 }
 
-func (r *Restic) prune() (string, error) {
+func (r *Restic) prune() (_ string, err error) {
+	var span = r.startSpan("prune")
+	defer r.endSpan(span, &err)
+
 	var getCtx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
 	r.addCommand([]string{"prune", PARAM_INSECURE_TLS}).addExtended()
 
 	cmd := exec.CommandContext(getCtx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
 	cmd.Env = append(cmd.Env, r.opt.RepoEnvs.Slice()...)
-	logger.Infof("[Cmd] %s", cmd.String())
+	r.log.Infof("[Cmd] %s", cmd.String())
 	output, _ := cmd.CombinedOutput()
-	logger.Debugf("[restic] prune result: %s", string(output))
+	r.log.Debugf("[restic] prune result: %s", string(output))
 
 	return string(output), nil
 }
 
+// Forget removes the snapshots the policy does not keep, with policy.Prune the unreferenced data is removed too
+func (r *Restic) Forget(policy *ForgetPolicy) (_ *ForgetSummary, err error) {
+	var span = r.startSpan("forget")
+	defer r.endSpan(span, &err)
+
+	if r.opt.AppendOnly {
+		return nil, ERROR_MESSAGE_REPOSITORY_APPEND_ONLY
+	}
+	var keep = policy.Args()
+	if len(keep) == 0 {
+		return nil, fmt.Errorf("retention policy of repo %s has no keep rule", r.opt.RepoName)
+	}
+
+	// prune cannot remove locked data, a later run prunes what the forgotten snapshots leave once the retention expires
+	var prune = policy.Prune
+	if prune && r.opt.ImmutableUntil.After(time.Now()) {
+		r.log.Warnf("repo %s is locked until %s, skip prune", r.opt.RepoName, r.opt.ImmutableUntil.Format(time.RFC3339))
+		prune = false
+	}
+
+	var cmds = append([]string{"forget"}, keep...)
+	if len(r.opt.LockedSnapshots) > 0 {
+		return r.forgetUnlocked(cmds, prune)
+	}
+	if prune {
+		cmds = append(cmds, "--prune")
+	}
+	if r.opt.DryRun {
+		cmds = append(cmds, "--dry-run")
+	}
+	groups, err := r.forget(cmds)
+	if err != nil {
+		return nil, err
+	}
+	return newForgetSummary(groups, prune && !r.opt.DryRun, r.opt.DryRun), nil
+}
+
+// forgetUnlocked applies the policy with a dry run and forgets the snapshots it removes by id, except
+// the locked ones, restic cannot remove their objects before the retention expires
+func (r *Restic) forgetUnlocked(cmds []string, prune bool) (*ForgetSummary, error) {
+	groups, err := r.forget(append(cmds, "--dry-run"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = []string{"forget"}
+	for _, g := range groups {
+		var remove []*Snapshot
+		for _, s := range g.Remove {
+			if slices.Contains(r.opt.LockedSnapshots, s.Id) {
+				g.Keep = append(g.Keep, s)
+				continue
+			}
+			remove = append(remove, s)
+			ids = append(ids, s.Id)
+		}
+		g.Remove = remove
+	}
+	if len(ids) == 1 || r.opt.DryRun {
+		return newForgetSummary(groups, false, r.opt.DryRun), nil
+	}
+	if prune {
+		ids = append(ids, "--prune")
+	}
+	if _, err = r.forget(ids); err != nil {
+		return nil, err
+	}
+	return newForgetSummary(groups, prune, false), nil
+}
+
+func newForgetSummary(groups []*ForgetGroup, pruned bool, dryRun bool) *ForgetSummary {
+	var summary = &ForgetSummary{Pruned: pruned, DryRun: dryRun, Groups: groups}
+	for _, g := range groups {
+		summary.Kept += len(g.Keep)
+		summary.Removed += len(g.Remove)
+	}
+	return summary
+}
+
+// forget runs a forget command and returns the groups of its json output, none when it prints no groups
+func (r *Restic) forget(cmds []string) ([]*ForgetGroup, error) {
+	r.addCommand(append(cmds, PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS)).addExtended().addRequestTimeout()
+
+	cmd := exec.CommandContext(r.ctx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
+	cmd.Env = append(os.Environ(), r.opt.RepoEnvs.Slice()...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	r.log.Infof("[Cmd] %s", cmd.String())
+	output, err := cmd.Output()
+	r.log.Debugf("[restic] forget %s result: %s %s", r.opt.RepoName, string(output), stderr.String())
+	if err != nil {
+		if errorMsg, _ := r.formatErrorMessage(r.trimError(stderr.String())); errorMsg.Error() != "" {
+			return nil, errorMsg
+		}
+		return nil, err
+	}
+
+	// the prune messages follow the json line of the forget groups
+	var groups []*ForgetGroup
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &groups); err != nil {
+			return nil, fmt.Errorf("parse forget result of repo %s error: %v", r.opt.RepoName, err)
+		}
+		break
+	}
+	return groups, nil
+}
+
+// Check verifies the structure of the repository, readDataSubset like 10% or 1/5 also reads that part of the data
+func (r *Restic) Check(readDataSubset string) (_ *CheckSummary, err error) {
+	var span = r.startSpan("check")
+	defer r.endSpan(span, &err)
+
+	var cmds = []string{"check"}
+	if readDataSubset != "" {
+		cmds = append(cmds, "--read-data-subset", readDataSubset)
+	}
+	r.addCommand(append(cmds, PARAM_INSECURE_TLS)).addExtended().addRequestTimeout()
+
+	cmd := exec.CommandContext(r.ctx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
+	cmd.Env = append(os.Environ(), r.opt.RepoEnvs.Slice()...)
+	r.log.Infof("[Cmd] %s", cmd.String())
+	output, err := cmd.CombinedOutput()
+	var summary = &CheckSummary{ReadDataSubset: readDataSubset, Output: strings.TrimSpace(string(output))}
+	r.log.Debugf("[restic] check %s result: %s", r.opt.RepoName, summary.Output)
+	if err == nil {
+		return summary, nil
+	}
+	if r.ctx.Err() != nil {
+		return nil, r.ctx.Err()
+	}
+
+	if strings.Contains(summary.Output, "repository contains errors") {
+		return summary, fmt.Errorf("check repo %s: %s", r.opt.RepoName, ERROR_MESSAGE_REPOSITORY_BE_DAMAGED_MESSAGE)
+	}
+	if errorMsg, _ := r.formatErrorMessage(r.trimError(summary.Output)); errorMsg.Error() != "" {
+		return summary, errorMsg
+	}
+	return summary, err
+}
+
+// Ls lists the files of the snapshot, under path when it is not empty, recursive also lists the subdirectories of path
+func (r *Restic) Ls(snapshotId string, path string, recursive bool) (_ []*LsNode, err error) {
+	var span = r.startSpan("ls")
+	defer r.endSpan(span, &err)
+
+	var cmds = []string{"ls", PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS}
+	if recursive {
+		cmds = append(cmds, "--recursive")
+	}
+	cmds = append(cmds, snapshotId)
+	if path != "" {
+		cmds = append(cmds, path)
+	}
+	r.addCommand(cmds).addExtended().addRequestTimeout()
+
+	cmd := exec.CommandContext(r.ctx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
+	cmd.Env = append(os.Environ(), r.opt.RepoEnvs.Slice()...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe error: %v", err)
+	}
+	r.log.Infof("[Cmd] %s", cmd.String())
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cmd start error: %v", err)
+	}
+
+	// the first line describes the snapshot, every following one is a node
+	var nodes []*LsNode
+	var scanner = bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			StructType  string `json:"struct_type"`
+			MessageType string `json:"message_type"`
+			LsNode
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.StructType == "node" || line.MessageType == "node" {
+			var node = line.LsNode
+			nodes = append(nodes, &node)
+		}
+	}
+	var scanErr = scanner.Err()
+	if err := cmd.Wait(); err != nil {
+		if r.ctx.Err() != nil {
+			return nil, r.ctx.Err()
+		}
+		if errorMsg, _ := r.formatErrorMessage(r.trimError(stderr.String())); errorMsg.Error() != "" {
+			return nil, errorMsg
+		}
+		return nil, err
+	}
+	if scanErr != nil {
+		return nil, fmt.Errorf("read ls result of repo %s error: %v", r.opt.RepoName, scanErr)
+	}
+	return nodes, nil
+}
+
 func (r *Restic) StatsMode(mode string) (*StatsContainer, error) {
+	stats, err := r.stats(mode, "")
+	if err != nil {
+		return nil, err
+	}
+	metrics.ObserveRepositorySize(r.opt.RepoName, mode, stats.TotalSize)
+	return stats, nil
+}
+
+// RestoreSize is the size of the files a restore of the snapshot writes, of its subfolder when it is set
+func (r *Restic) RestoreSize(snapshotId string, subfolder string) (uint64, error) {
+	var snapshot = snapshotId
+	if subfolder != "" {
+		snapshot = fmt.Sprintf("%s:%s", snapshotId, subfolder)
+	}
+	stats, err := r.stats("restore-size", snapshot)
+	if err != nil {
+		return 0, err
+	}
+	return stats.TotalSize, nil
+}
+
+// stats runs restic stats in the mode over every snapshot, or over the one that is given
+func (r *Restic) stats(mode string, snapshot string) (_ *StatsContainer, err error) {
+	var span = r.startSpan("stats")
+	defer r.endSpan(span, &err)
+
 	var getCtx, cancel = context.WithCancel(r.ctx)
 	defer cancel()
 
 	r.addCommand([]string{"stats", "--mode", mode, PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS}).addExtended().addRequestTimeout()
+	if snapshot != "" {
+		r.addSnapshotId(snapshot)
+	}
 
 	opts := utils.CommandOptions{
 		Path: r.dir,
@@ -260,7 +559,7 @@ func (r *Restic) StatsMode(mode string) (*StatsContainer, error) {
 				}
 
 				var msg = string(res)
-				logger.Debugf("[restic] stats %s message: %s", r.opt.RepoName, msg)
+				r.log.Debugf("[restic] stats %s message: %s", r.opt.RepoName, msg)
 				if err := json.Unmarshal(res, &stats); err != nil {
 					errorMsg = RESTIC_ERROR_MESSAGE(string(msg))
 					c.Cancel()
@@ -272,7 +571,7 @@ func (r *Restic) StatsMode(mode string) (*StatsContainer, error) {
 		}
 	}()
 
-	_, err := c.Run()
+	_, err = c.Run()
 	if err != nil {
 		return nil, err
 	}
@@ -283,11 +582,13 @@ func (r *Restic) StatsMode(mode string) (*StatsContainer, error) {
 	if stats == nil {
 		return nil, fmt.Errorf("stats %s not found", r.opt.RepoName)
 	}
-
 	return stats, nil
 }
 
-func (r *Restic) Stats() (*StatsContainer, error) {
+func (r *Restic) Stats() (_ *StatsContainer, err error) {
+	var span = r.startSpan("stats")
+	defer r.endSpan(span, &err)
+
 	var getCtx, cancel = context.WithCancel(r.ctx)
 	defer cancel()
 
@@ -316,7 +617,7 @@ func (r *Restic) Stats() (*StatsContainer, error) {
 				}
 
 				var msg = string(res)
-				logger.Debugf("[restic] stats %s message: %s", r.opt.RepoName, msg)
+				r.log.Debugf("[restic] stats %s message: %s", r.opt.RepoName, msg)
 				if err := json.Unmarshal(res, &stats); err != nil {
 					errorMsg = RESTIC_ERROR_MESSAGE(string(msg))
 					c.Cancel()
@@ -328,7 +629,7 @@ func (r *Restic) Stats() (*StatsContainer, error) {
 		}
 	}()
 
-	_, err := c.Run()
+	_, err = c.Run()
 	if err != nil {
 		return nil, err
 	}
@@ -340,6 +641,7 @@ func (r *Restic) Stats() (*StatsContainer, error) {
 		return nil, fmt.Errorf("stats %s not found", r.opt.RepoName)
 	}
 
+	metrics.ObserveRepositorySize(r.opt.RepoName, "raw-data", stats.TotalSize)
 	return stats, nil
 }
 
@@ -347,9 +649,10 @@ func (r *Restic) Tag(snapshotId string, tags []string) error {
 	r.addCommand([]string{"tag"}).resetTags(tags).addSnapshotId(snapshotId)
 
 	cmd := exec.CommandContext(context.Background(), r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
 	cmd.Env = append(cmd.Env, r.opt.RepoEnvs.Slice()...)
 
-	logger.Infof("[Cmd] %s", cmd.String())
+	r.log.Infof("[Cmd] %s", cmd.String())
 	_, err := cmd.CombinedOutput()
 	if err != nil {
 		return err
@@ -357,8 +660,11 @@ func (r *Restic) Tag(snapshotId string, tags []string) error {
 	return nil
 }
 
-func (r *Restic) Backup(folder string, files []string, filePathPrefix string, tags []string, traceId string, dryRun bool, progressChan chan float64) (*SummaryOutput, error) {
-	var filesPath, err = r.formatBackupFiles(files)
+func (r *Restic) Backup(folder string, files []string, filePathPrefix string, tags []string, traceId string, dryRun bool, progressChan chan float64) (_ *SummaryOutput, err error) {
+	var span = r.startSpan("backup")
+	defer r.endSpan(span, &err)
+
+	filesPath, err := r.formatBackupFiles(files)
 	if err != nil {
 		return nil, fmt.Errorf("invalid backup file list path, error: %v", err.Error())
 	}
@@ -377,6 +683,9 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 			cmds = append(cmds, folder)
 		}
 	}
+	for _, exclude := range r.opt.Excludes {
+		cmds = append(cmds, "--exclude", exclude)
+	}
 	if dryRun {
 		cmds = append(cmds, "-n")
 	}
@@ -401,21 +710,30 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 	var summary *SummaryOutput
 	var errorMsg RESTIC_ERROR_MESSAGE
 	var continued bool
+	var done = make(chan struct{})
+	// a canceled backup may have no reader of the progress anymore
+	var sendProgress = func(percentDone float64) {
+		select {
+		case progressChan <- percentDone:
+		case <-r.ctx.Done():
+		}
+	}
 
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(8 * time.Second)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-r.ctx.Done():
-				logger.Infof("[restic] backup canceled, traceId: %s", traceId)
+				r.log.Infof("[restic] backup canceled, traceId: %s", traceId)
 				errorMsg = ERROR_MESSAGE_BACKUP_CANCELED
 				return
 			case <-ticker.C:
 				if r.opt.LocalEndpoint != "" {
 					if checkErr := r.checkDiskSpace(r.opt.LocalEndpoint); checkErr != nil {
-						logger.Errorf("[restic] backup canceled, msg: %v", checkErr)
+						r.log.Errorf("[restic] backup canceled, msg: %v", checkErr)
 						errorMsg = RESTIC_ERROR_MESSAGE(checkErr.Error())
 						c.Cancel()
 						return
@@ -431,7 +749,7 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 				status := messagePool.Get()
 				if err := json.Unmarshal(res, status); err != nil {
 					var msg = string(res)
-					logger.Errorf("[restic] backup %s error message: %s, traceId: %s", r.opt.RepoName, msg, traceId)
+					r.log.Errorf("[restic] backup %s error message: %s, traceId: %s", r.opt.RepoName, msg, traceId)
 					messagePool.Put(status)
 
 					errorMsg, continued = r.formatErrorMessage(msg)
@@ -445,24 +763,24 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 				case "status":
 					switch {
 					case math.Abs(status.PercentDone-0.0) < tolerance:
-						logger.Infof(PRINT_START_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
-						progressChan <- status.PercentDone
+						r.log.Infof(PRINT_START_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
+						sendProgress(status.PercentDone)
 					case math.Abs(status.PercentDone-1.0) < tolerance:
 						if !finished {
-							logger.Infof(PRINT_FINISH_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
+							r.log.Infof(PRINT_FINISH_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
 							finished = true
-							progressChan <- status.PercentDone
+							sendProgress(status.PercentDone)
 						}
 					default:
 						if prevPercent != 0 && prevPercent != status.PercentDone {
-							logger.Infof(PRINT_PROGRESS_MESSAGE,
+							r.log.Infof(PRINT_PROGRESS_MESSAGE,
 								status.GetPercentDone(),
 								status.FilesDone,
 								status.TotalFiles,
 								utils.FormatBytes(status.BytesDone),
 								utils.FormatBytes(status.TotalBytes),
 								r.fileNameTidy(status.CurrentFiles, filePathPrefix))
-							progressChan <- status.PercentDone
+							sendProgress(status.PercentDone)
 						}
 						prevPercent = status.PercentDone
 					}
@@ -482,7 +800,7 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 					return
 				case "summary":
 					if err := json.Unmarshal(res, &summary); err != nil {
-						logger.Errorf("[restic] backup %s error summary unmarshal message: %s, traceId: %s", r.opt.RepoName, string(res), traceId)
+						r.log.Errorf("[restic] backup %s error summary unmarshal message: %s, traceId: %s", r.opt.RepoName, string(res), traceId)
 						messagePool.Put(status)
 						errorMsg = RESTIC_ERROR_MESSAGE(err.Error())
 						c.Cancel()
@@ -497,6 +815,8 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 	}()
 
 	_, err = c.Run()
+	// the output is closed, wait for the reader to set the summary or the error
+	<-done
 	if err != nil {
 		return nil, err
 	}
@@ -507,6 +827,11 @@ func (r *Restic) Backup(folder string, files []string, filePathPrefix string, ta
 }
 
 func (r *Restic) Repair() error {
+	if r.opt.AppendOnly {
+		r.log.Infof("repo %s is append-only, skip repairing index", r.opt.RepoName)
+		return nil
+	}
+
 	backoff := wait.Backoff{
 		Duration: 2 * time.Second,
 		Factor:   2,
@@ -521,7 +846,7 @@ func (r *Restic) Repair() error {
 	}, func() error {
 		res, err := r.repairIndex()
 		if err != nil {
-			logger.Errorf("[restic] repair %s error: %s", r.opt.RepoName, err)
+			r.log.Errorf("[restic] repair %s error: %s", r.opt.RepoName, err)
 			return err
 		}
 
@@ -543,7 +868,10 @@ func (r *Restic) Repair() error {
 	return nil
 }
 
-func (r *Restic) repairIndex() (string, error) {
+func (r *Restic) repairIndex() (_ string, err error) {
+	var span = r.startSpan("repair index")
+	defer r.endSpan(span, &err)
+
 	r.addCommand([]string{"repair", "index", PARAM_INSECURE_TLS}).addExtended()
 
 	opts := utils.CommandOptions{
@@ -566,7 +894,7 @@ func (r *Restic) repairIndex() (string, error) {
 				if res == nil || len(res) == 0 {
 					continue
 				}
-				logger.Debugf("[restic] repair %s message: %s", r.opt.RepoName, string(res))
+				r.log.Debugf("[restic] repair %s message: %s", r.opt.RepoName, string(res))
 				sb.WriteString(string(res) + "\n")
 			case <-r.ctx.Done():
 				return
@@ -574,14 +902,21 @@ func (r *Restic) repairIndex() (string, error) {
 		}
 	}()
 
-	_, err := c.Run()
+	_, err = c.Run()
 	if err != nil {
 		return "", err
 	}
 	return sb.String(), nil
 }
 
-func (r *Restic) Unlock() (string, error) {
+func (r *Restic) Unlock() (_ string, err error) {
+	var span = r.startSpan("unlock")
+	defer r.endSpan(span, &err)
+
+	if r.opt.AppendOnly {
+		return "", ERROR_MESSAGE_REPOSITORY_APPEND_ONLY
+	}
+
 	var getCtx, cancel = context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -605,7 +940,7 @@ func (r *Restic) Unlock() (string, error) {
 				if res == nil || len(res) == 0 {
 					continue
 				}
-				logger.Debugf("[restic] unlock %s message: %s", r.opt.RepoName, string(res))
+				r.log.Debugf("[restic] unlock %s message: %s", r.opt.RepoName, string(res))
 				sb.WriteString(string(res) + "\n")
 			case <-r.ctx.Done():
 				return
@@ -613,7 +948,7 @@ func (r *Restic) Unlock() (string, error) {
 		}
 	}()
 
-	_, err := c.Run()
+	_, err = c.Run()
 	if err != nil {
 		return "", err
 	}
@@ -645,13 +980,17 @@ func (l SnapshotList) PrintTable() {
 	table.Render()
 }
 
-func (r *Restic) GetSnapshot(snapshotId string) (*Snapshot, error) {
+func (r *Restic) GetSnapshot(snapshotId string) (_ *Snapshot, err error) {
+	var span = r.startSpan("snapshot")
+	defer r.endSpan(span, &err)
+
 	var getCtx, cancel = context.WithCancel(r.ctx)
 	defer cancel()
 
 	r.addCommand([]string{"snapshots", PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS, snapshotId}).addExtended().addRequestTimeout()
 
 	cmd := exec.CommandContext(getCtx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
 	cmd.Env = append(os.Environ(), cmd.Env...)
 	for k, v := range r.opt.RepoEnvs.Kv() {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
@@ -666,7 +1005,7 @@ func (r *Restic) GetSnapshot(snapshotId string) (*Snapshot, error) {
 		return nil, fmt.Errorf("stderr pipe error: %v", err)
 	}
 
-	logger.Infof("[Cmd] %s", cmd.String())
+	r.log.Infof("[Cmd] %s", cmd.String())
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("cmd start error: %v", err)
@@ -688,7 +1027,7 @@ func (r *Restic) GetSnapshot(snapshotId string) (*Snapshot, error) {
 			}
 			if e != nil {
 				if !errors.Is(e, io.EOF) {
-					logger.Errorf("[Cmd][stderr] read error: %v", e)
+					r.log.Errorf("[Cmd][stderr] read error: %v", e)
 					errorMsg, _ = r.formatErrorMessage(e.Error())
 				}
 				break
@@ -744,13 +1083,17 @@ func (r *Restic) GetSnapshot(snapshotId string) (*Snapshot, error) {
 	return snaps.First(), nil
 }
 
-func (r *Restic) GetSnapshots(tags []string) (*SnapshotList, error) {
+func (r *Restic) GetSnapshots(tags []string) (_ *SnapshotList, err error) {
+	var span = r.startSpan("snapshots")
+	defer r.endSpan(span, &err)
+
 	var restoreCtx, cancel = context.WithCancel(r.ctx)
 	defer cancel()
 
 	r.addCommand([]string{"snapshots", PARAM_JSON_OUTPUT, PARAM_INSECURE_TLS}).addTags(tags).addExtended().addRequestTimeout()
 
 	cmd := exec.CommandContext(restoreCtx, r.dir, r.args...)
+	utils.SetProcessGroup(cmd)
 	cmd.Env = append(os.Environ(), cmd.Env...)
 	for k, v := range r.opt.RepoEnvs.Kv() {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
@@ -765,7 +1108,7 @@ func (r *Restic) GetSnapshots(tags []string) (*SnapshotList, error) {
 		return nil, fmt.Errorf("stderr pipe error: %v", err)
 	}
 
-	logger.Infof("[Cmd] %s", cmd.String())
+	r.log.Infof("[Cmd] %s", cmd.String())
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("cmd start error: %v", err)
@@ -787,7 +1130,7 @@ func (r *Restic) GetSnapshots(tags []string) (*SnapshotList, error) {
 			}
 			if e != nil {
 				if !errors.Is(e, io.EOF) {
-					logger.Errorf("[Cmd][stderr] read error: %v", e)
+					r.log.Errorf("[Cmd][stderr] read error: %v", e)
 					errorMsg, _ = r.formatErrorMessage(e.Error())
 				}
 				break
@@ -843,7 +1186,10 @@ func (r *Restic) GetSnapshots(tags []string) (*SnapshotList, error) {
 	return snaps, nil
 }
 
-func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder string, target string, progressChan chan float64) (*RestoreSummaryOutput, error) {
+func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder string, target string, progressChan chan float64) (_ *RestoreSummaryOutput, err error) {
+	var span = r.startSpan("restore")
+	defer r.endSpan(span, &err)
+
 	if subfolder != "" {
 		subfolder = fmt.Sprintf("%s:%s", snapshotId, subfolder)
 	} else {
@@ -875,12 +1221,12 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 		for {
 			select {
 			case <-r.ctx.Done():
-				logger.Infof("[restic] restore canceled")
+				r.log.Infof("[restic] restore canceled")
 				errorMsg = ERROR_MESSAGE_RESTORE_CANCELED
 				return
 			case <-ticker.C:
 				if checkErr := r.checkDiskSpace(target); checkErr != nil {
-					logger.Errorf("[restic] restore canceled, msg: %v", checkErr)
+					r.log.Errorf("[restic] restore canceled, msg: %v", checkErr)
 					errorMsg = RESTIC_ERROR_MESSAGE(checkErr.Error())
 					c.Cancel()
 					return
@@ -896,7 +1242,7 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 				status := restoreMessagePool.Get()
 				if err := json.Unmarshal(res, status); err != nil {
 					var msg = string(res)
-					logger.Debugf("[restic] restore %s error message: %s", r.opt.RepoName, msg)
+					r.log.Debugf("[restic] restore %s error message: %s", r.opt.RepoName, msg)
 					restoreMessagePool.Put(status)
 
 					errorMsg, continued = r.formatErrorMessage(msg)
@@ -912,19 +1258,19 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 					switch {
 					case math.Abs(status.PercentDone-0.0) < tolerance:
 						if !started {
-							logger.Infof(PRINT_RESTORE_START_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
+							r.log.Infof(PRINT_RESTORE_START_MESSAGE, status.TotalFiles, utils.FormatBytes(status.TotalBytes))
 							started = true
 							progressChan <- status.GetPercentDone(phase, total)
 						}
 					case math.Abs(status.PercentDone-1.0) < tolerance:
 						if !finished {
-							logger.Infof(PRINT_RESTORE_FINISH_MESSAGE, snapshotId, status.TotalFiles, status.FilesRestored, utils.FormatBytes(status.TotalBytes), utils.FormatBytes(status.BytesRestored))
+							r.log.Infof(PRINT_RESTORE_FINISH_MESSAGE, snapshotId, status.TotalFiles, status.FilesRestored, utils.FormatBytes(status.TotalBytes), utils.FormatBytes(status.BytesRestored))
 							finished = true
 							progressChan <- status.GetPercentDone(phase, total)
 						}
 					default:
 						if prevPercent != status.PercentDone {
-							logger.Infof(PRINT_RESTORE_PROGRESS_MESSAGE,
+							r.log.Infof(PRINT_RESTORE_PROGRESS_MESSAGE,
 								fmt.Sprintf("%.2f%%", status.GetPercentDone(phase, total)*100),
 								status.FilesRestored,
 								status.TotalFiles,
@@ -943,7 +1289,7 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 						c.Cancel()
 						return
 					}
-					logger.Infof(PRINT_RESTORE_ITEM, rvu.Item, utils.FormatBytes(rvu.Size))
+					r.log.Infof(PRINT_RESTORE_ITEM, rvu.Item, utils.FormatBytes(rvu.Size))
 				case "error":
 					errObj := new(ErrorUpdate)
 					if err := json.Unmarshal(res, &errObj); err != nil {
@@ -956,7 +1302,7 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 					return
 				case "summary":
 					if err := json.Unmarshal(res, &summary); err != nil {
-						logger.Debugf("[restic] restore %s error summary unmarshal message: %s", r.opt.RepoName, string(res))
+						r.log.Debugf("[restic] restore %s error summary unmarshal message: %s", r.opt.RepoName, string(res))
 						restoreMessagePool.Put(status)
 						errorMsg = RESTIC_ERROR_MESSAGE(err.Error())
 						c.Cancel()
@@ -971,7 +1317,7 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 		}
 	}()
 
-	_, err := c.Run()
+	_, err = c.Run()
 	if err != nil {
 		return nil, err
 	}
@@ -982,6 +1328,20 @@ func (r *Restic) Restore(phase int, total int, snapshotId string, subfolder stri
 	return summary, nil
 }
 
+// startSpan starts the span of a restic command as a child of the span of the service call
+func (r *Restic) startSpan(command string) trace.Span {
+	_, span := tracing.Start(r.ctx, "restic "+command,
+		attribute.String("restic.command", command),
+		attribute.String("backups.repo", r.opt.RepoName))
+	return span
+}
+
+// endSpan adds the command line, without the env vars that hold the credentials, and ends the span
+func (r *Restic) endSpan(span trace.Span, err *error) {
+	span.SetAttributes(attribute.String("restic.args", redact.String(strings.Join(r.args, " "))))
+	tracing.End(span, err)
+}
+
 func (r *Restic) fileNameTidy(f []string, prefix string) []string {
 	if f == nil || len(f) == 0 {
 		return f
@@ -1034,6 +1394,22 @@ func (r *Restic) addExtended() *Restic {
 	if cloudName == constants.CloudTencentName {
 		r.args = append(r.args, "-o", "s3.bucket-lookup=dns", "-o", fmt.Sprintf("s3.region=%s", r.opt.RegionId))
 	}
+	if r.opt.StorageClass != "" {
+		r.args = append(r.args, "-o", fmt.Sprintf("s3.storage-class=%s", r.opt.StorageClass))
+	}
+	if r.opt.SftpCommand != "" {
+		r.args = append(r.args, "-o", fmt.Sprintf("sftp.command=%s", r.opt.SftpCommand))
+	}
+	if r.opt.CACert != "" {
+		// the server certificate is verified against the custom CA instead of being skipped
+		var args = r.args[:0]
+		for _, arg := range r.args {
+			if arg != PARAM_INSECURE_TLS {
+				args = append(args, arg)
+			}
+		}
+		r.args = append(args, "--cacert", r.opt.CACert)
+	}
 	return r
 }
 
@@ -1114,11 +1490,11 @@ func (r *Restic) formatErrorMessage(msg string) (RESTIC_ERROR_MESSAGE, bool) {
 func (r *Restic) checkDiskSpace(path string) error {
 	usage, err := disk.Usage(path)
 	if err != nil {
-		logger.Errorf("[restic] check disk free space error: %v", err)
+		r.log.Errorf("[restic] check disk free space error: %v", err)
 		return err
 	}
 
-	logger.Debugf("[restic] check disk free space: %s, path: %s, limit: %d", usage.String(), path, constants.FreeSpaceLimit)
+	r.log.Debugf("[restic] check disk free space: %s, path: %s, limit: %d", usage.String(), path, constants.FreeSpaceLimit)
 
 	if usage.Free < constants.FreeSpaceLimit {
 		return errors.New(ERROR_MESSAGE_NO_SPACE_LEFT_ON_DEVICE_MESSAGE.Error())
@@ -0,0 +1,118 @@
+package restic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/zap"
+)
+
+func TestAddExtended(t *testing.T) {
+	var tests = []struct {
+		name string
+		opt  *ResticOptions
+		args []string
+	}{
+		{
+			name: "default storage class",
+			opt:  &ResticOptions{},
+			args: []string{"backup"},
+		},
+		{
+			name: "s3 storage class",
+			opt:  &ResticOptions{StorageClass: "STANDARD_IA"},
+			args: []string{"backup", "-o", "s3.storage-class=STANDARD_IA"},
+		},
+		{
+			name: "cos storage class",
+			opt:  &ResticOptions{CloudName: "tencentcloud", RegionId: "ap-tokyo", StorageClass: "ARCHIVE"},
+			args: []string{"backup", "-o", "s3.bucket-lookup=dns", "-o", "s3.region=ap-tokyo", "-o", "s3.storage-class=ARCHIVE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Restic{opt: tt.opt}
+			r.addCommand([]string{"backup"}).addExtended()
+			assert.Equal(t, r.args, tt.args)
+		})
+	}
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	var tests = []struct {
+		err     error
+		expired bool
+	}{
+		{err: nil},
+		{err: errors.New("repository is locked")},
+		{err: ERROR_MESSAGE_TOKEN_EXPIRED, expired: true},
+		{err: errors.New("Save(<data/1234>) returned error: ExpiredToken: The security token included in the request is expired"), expired: true},
+		{err: errors.New("requestexpired: request has expired"), expired: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, IsTokenExpired(tt.err), tt.expired)
+	}
+}
+
+func TestForgetLocked(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake restic is a shell script")
+	}
+	var tests = []struct {
+		name    string
+		locked  []string
+		until   time.Time
+		calls   []string
+		removed int
+		pruned  bool
+	}{
+		{
+			name:    "unlocked",
+			calls:   []string{"forget --keep-last 1 --group-by  --prune"},
+			removed: 2,
+			pruned:  true,
+		},
+		{
+			name:    "locked snapshot is kept and the data is not pruned",
+			locked:  []string{"b"},
+			until:   time.Now().Add(time.Hour),
+			calls:   []string{"forget --keep-last 1 --group-by  --dry-run", "forget c"},
+			removed: 1,
+		},
+		{
+			name:   "every removed snapshot is locked",
+			locked: []string{"b", "c"},
+			until:  time.Now().Add(time.Hour),
+			calls:  []string{"forget --keep-last 1 --group-by  --dry-run"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir = t.TempDir()
+			var calls = filepath.Join(dir, "calls")
+			var script = fmt.Sprintf("#!/bin/sh\necho \"$@\" | sed 's/ --json.*//' >> %s\n"+
+				"case \"$*\" in\n*--keep-last*) echo '[{\"keep\":[{\"id\":\"a\"}],\"remove\":[{\"id\":\"b\"},{\"id\":\"c\"}]}]';;\nesac\n", calls)
+			assert.Equal(t, os.WriteFile(filepath.Join(dir, "restic"), []byte(script), 0755), nil)
+
+			var r = &Restic{ctx: context.Background(), dir: filepath.Join(dir, "restic"), log: zap.NewNop().Sugar(),
+				opt: &ResticOptions{RepoName: "home", ImmutableUntil: tt.until, LockedSnapshots: tt.locked, RepoEnvs: &ResticEnvs{}}}
+			summary, err := r.Forget(&ForgetPolicy{KeepLast: 1, Prune: true})
+			assert.Equal(t, err, nil)
+			assert.Equal(t, summary.Removed, tt.removed)
+			assert.Equal(t, summary.Kept, 3-tt.removed)
+			assert.Equal(t, summary.Pruned, tt.pruned)
+
+			data, err := os.ReadFile(calls)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, strings.Split(strings.TrimSpace(string(data)), "\n"), tt.calls)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/storage/notification"
+)
+
+// Job is the schedule of a repository, its backup, retention and check become separate tasks.
+// Without RetentionSchedule the retention policy is applied after every successful backup
+type Job struct {
+	Name            string
+	Repository      options.Repository
+	Password        string
+	PasswordFile    string
+	PasswordCommand string
+	SecretRefs      bool // resolves env:, file: and cmd: references in the password and the credentials
+
+	Backup          string   // cron of the backup, empty means no backup task
+	Path            string   // the directory to back up
+	Files           []string // files that list the paths to back up, instead of Path
+	Excludes        []string
+	LimitUploadRate string
+	UploadSchedule  bandwidth.Schedule
+
+	Retention         *restic.ForgetPolicy
+	RetentionSchedule string
+
+	Check          string // cron of the check, empty means no check task
+	ReadDataSubset string
+
+	Jitter   time.Duration
+	CatchUp  bool
+	Timeout  time.Duration // bounds every run, zero means no limit
+	History  *history.Store
+	Notifier *notification.Notifier
+	Logger   *zap.SugaredLogger
+}
+
+// Tasks returns the tasks of the job, named {job}/backup, {job}/retention and {job}/check
+func (j *Job) Tasks() ([]*Task, error) {
+	if err := j.Repository.Validate(); err != nil {
+		return nil, fmt.Errorf("job %s: %v", j.Name, err)
+	}
+	if j.Password == "" && j.PasswordFile == "" && j.PasswordCommand == "" {
+		return nil, fmt.Errorf("job %s: password, password file or password command is required, tasks cannot prompt for it", j.Name)
+	}
+	if j.Backup == "" && j.RetentionSchedule == "" && j.Check == "" {
+		return nil, fmt.Errorf("job %s has no schedule", j.Name)
+	}
+	if j.RetentionSchedule != "" && j.Retention == nil {
+		return nil, fmt.Errorf("job %s: retention schedule without retention policy", j.Name)
+	}
+
+	var repo = j.Repository.Location + "/" + j.Repository.RepoName
+	if j.Repository.RepoId != "" {
+		repo += "-" + j.Repository.RepoId
+	}
+	var task = func(op, schedule string, run func(ctx context.Context) error) *Task {
+		return &Task{Name: j.Name + "/" + op, Schedule: schedule, Repo: repo, Jitter: j.Jitter, CatchUp: j.CatchUp, Run: run}
+	}
+
+	var tasks []*Task
+	if j.Backup != "" {
+		tasks = append(tasks, task("backup", j.Backup, j.backup))
+	}
+	if j.RetentionSchedule != "" {
+		tasks = append(tasks, task("retention", j.RetentionSchedule, j.forget))
+	}
+	if j.Check != "" {
+		tasks = append(tasks, task("check", j.Check, j.check))
+	}
+	return tasks, nil
+}
+
+func (j *Job) backup(ctx context.Context) error {
+	var option = &storage.BackupOption{
+		Password:                 j.Password,
+		PasswordFile:             j.PasswordFile,
+		PasswordCommand:          j.PasswordCommand,
+		SecretRefs:               j.SecretRefs,
+		Operator:                 constants.StorageOperatorCli,
+		BackupType:               constants.BackupTypeFile,
+		BackupFileTypeSourcePath: j.Path,
+		Timeout:                  j.Timeout,
+		Logger:                   j.Logger,
+		History:                  j.History,
+		Notifier:                 j.Notifier,
+		UploadSchedule:           j.UploadSchedule,
+		Repository: &options.RepositoryBackupOption{
+			Repository:      j.Repository,
+			Path:            j.Path,
+			Files:           j.Files,
+			Excludes:        j.Excludes,
+			LimitUploadRate: j.LimitUploadRate,
+		},
+	}
+	if len(j.Files) > 0 {
+		// a files list backs up the paths it names, they are restored in place like app data
+		option.BackupType, option.BackupFileTypeSourcePath = constants.BackupTypeApp, ""
+	}
+	if _, _, err := storage.NewBackupService(option).BackupContext(ctx, false, func(float64) {}); err != nil {
+		return err
+	}
+	if j.Retention != nil && j.RetentionSchedule == "" {
+		return j.forget(ctx)
+	}
+	return nil
+}
+
+func (j *Job) forget(ctx context.Context) error {
+	_, err := storage.NewForgetService(j.snapshotsOption()).ForgetContext(ctx, j.Retention)
+	return err
+}
+
+func (j *Job) check(ctx context.Context) error {
+	_, err := storage.NewCheckService(j.snapshotsOption()).CheckContext(ctx, j.ReadDataSubset)
+	return err
+}
+
+func (j *Job) snapshotsOption() *storage.SnapshotsOption {
+	return &storage.SnapshotsOption{
+		Password:        j.Password,
+		PasswordFile:    j.PasswordFile,
+		PasswordCommand: j.PasswordCommand,
+		SecretRefs:      j.SecretRefs,
+		Operator:        constants.StorageOperatorCli,
+		Timeout:         j.Timeout,
+		Logger:          j.Logger,
+		History:         j.History,
+		Notifier:        j.Notifier,
+		Repository:      &options.RepositorySnapshotsOption{Repository: j.Repository},
+	}
+}
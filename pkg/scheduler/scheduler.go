@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
+)
+
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSchedule parses a cron expression of 5 fields, 6 fields start with seconds, or a descriptor like @daily or @every 6h
+func ParseSchedule(expr string) (cron.Schedule, error) {
+	s, err := parser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+	}
+	return s, nil
+}
+
+// Task is work that runs on a cron schedule
+type Task struct {
+	Name     string
+	Schedule string                          // see ParseSchedule, in the local time zone
+	Repo     string                          // tasks of the same repo never run at the same time, empty means no exclusion
+	Jitter   time.Duration                   // a random delay up to Jitter before each run, catch-up runs included
+	CatchUp  bool                            // run once at start when a run was missed while the scheduler was down
+	Run      func(ctx context.Context) error // ctx is canceled when the scheduler stops
+}
+
+// TaskStatus is the state of a task for status queries
+type TaskStatus struct {
+	Name      string    `json:"name"`
+	Repo      string    `json:"repo,omitempty"`
+	Schedule  string    `json:"schedule"`
+	Running   bool      `json:"running"`
+	Next      time.Time `json:"next"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type task struct {
+	*Task
+	schedule cron.Schedule
+
+	mu      sync.Mutex
+	running bool
+	next    time.Time
+}
+
+// Scheduler runs each task on its schedule, a run that is late because the previous one is
+// still going, or waits for its repo, is coalesced with the following ones
+type Scheduler struct {
+	state *State
+	tasks []*task
+	names map[string]bool
+
+	mu    sync.Mutex
+	repos map[string]chan struct{}
+}
+
+// New returns a scheduler that remembers the runs in state, nil keeps them in memory
+func New(state *State) *Scheduler {
+	if state == nil {
+		state = NewMemoryState()
+	}
+	return &Scheduler{state: state, names: make(map[string]bool), repos: make(map[string]chan struct{})}
+}
+
+// Add adds a task, it must be called before Run
+func (s *Scheduler) Add(t *Task) error {
+	if t == nil || t.Name == "" || t.Run == nil {
+		return fmt.Errorf("task name and run are required")
+	}
+	if s.names[t.Name] {
+		return fmt.Errorf("task %s is added twice", t.Name)
+	}
+	if t.Jitter < 0 {
+		return fmt.Errorf("task %s: jitter must not be negative", t.Name)
+	}
+	schedule, err := ParseSchedule(t.Schedule)
+	if err != nil {
+		return fmt.Errorf("task %s: %v", t.Name, err)
+	}
+	s.names[t.Name] = true
+	s.tasks = append(s.tasks, &task{Task: t, schedule: schedule, next: schedule.Next(time.Now())})
+	return nil
+}
+
+// Run runs the tasks until ctx is done, then waits for the running ones to return
+func (s *Scheduler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func(t *task) {
+			defer wg.Done()
+			s.loop(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Status returns the tasks sorted by name
+func (s *Scheduler) Status() []*TaskStatus {
+	var res = make([]*TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		var state, _ = s.state.Get(t.Name)
+		t.mu.Lock()
+		res = append(res, &TaskStatus{Name: t.Name, Repo: t.Repo, Schedule: t.Task.Schedule, Running: t.running,
+			Next: t.next, LastRun: state.LastRun, LastError: state.LastError})
+		t.mu.Unlock()
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	var log = logger.FromContext(ctx)
+
+	var now = time.Now()
+	state, ok := s.state.Get(t.Name)
+	if !ok {
+		// the first start is the reference to tell a later downtime
+		if err := s.state.Set(t.Name, TaskState{LastRun: now}); err != nil {
+			log.Warnf("save task %s state error: %v", t.Name, err)
+		}
+	} else if t.CatchUp && missed(t.schedule, state.LastRun, now) {
+		log.Infof("task %s missed its run after %s, catching up", t.Name, state.LastRun.Format(time.RFC3339))
+		t.setNext(now)
+		if !sleep(ctx, jitter(t.Jitter)) {
+			return
+		}
+		s.run(ctx, t)
+	}
+
+	for {
+		var next = t.schedule.Next(time.Now())
+		t.setNext(next)
+		if !sleep(ctx, time.Until(next)+jitter(t.Jitter)) {
+			return
+		}
+		s.run(ctx, t)
+	}
+}
+
+// run waits for the repo of the task and runs it once
+func (s *Scheduler) run(ctx context.Context, t *task) {
+	var log = logger.FromContext(ctx)
+	var repo = s.repo(t.Repo)
+	if repo != nil {
+		select {
+		case repo <- struct{}{}:
+			defer func() { <-repo }()
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	t.setRunning(true)
+	defer t.setRunning(false)
+
+	var start = time.Now()
+	log.Infof("task %s started", t.Name)
+	var err = t.Run(ctx)
+	var state = TaskState{LastRun: start, Duration: time.Since(start).Seconds()}
+	if err != nil {
+		state.LastError = redact.String(err.Error())
+		log.Errorf("task %s error: %s", t.Name, state.LastError)
+	} else {
+		log.Infof("task %s finished in %s", t.Name, time.Since(start).Round(time.Second))
+	}
+	if err := s.state.Set(t.Name, state); err != nil {
+		log.Warnf("save task %s state error: %v", t.Name, err)
+	}
+}
+
+func (s *Scheduler) repo(name string) chan struct{} {
+	if name == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.repos[name]; !ok {
+		s.repos[name] = make(chan struct{}, 1)
+	}
+	return s.repos[name]
+}
+
+func (t *task) setNext(next time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next = next
+}
+
+func (t *task) setRunning(running bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running = running
+}
+
+// missed reports whether a run was due between the last run and now
+func missed(schedule cron.Schedule, last, now time.Time) bool {
+	if last.IsZero() {
+		return false
+	}
+	var next = schedule.Next(last)
+	return !next.IsZero() && next.Before(now)
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(max)))
+}
+
+// sleep waits for d, it reports false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	var timer = time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
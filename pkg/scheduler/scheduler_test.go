@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestParseSchedule(t *testing.T) {
+	var tests = []struct {
+		expr string
+		ok   bool
+	}{
+		{expr: "0 2 * * *", ok: true},
+		{expr: "*/30 * * * * *", ok: true},
+		{expr: "@daily", ok: true},
+		{expr: "@every 6h", ok: true},
+		{expr: "0 25 * * *"},
+		{expr: "daily"},
+		{expr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseSchedule(tt.expr)
+			assert.Equal(t, err == nil, tt.ok)
+		})
+	}
+}
+
+func TestMissed(t *testing.T) {
+	schedule, err := ParseSchedule("0 2 * * *")
+	assert.Equal(t, err, nil)
+	var day = time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+
+	var tests = []struct {
+		name string
+		last time.Time
+		now  time.Time
+		want bool
+	}{
+		{name: "never ran", now: day},
+		{name: "ran today", last: day.Add(2 * time.Hour), now: day.Add(12 * time.Hour)},
+		{name: "down over night", last: day.Add(2 * time.Hour), now: day.Add(30 * time.Hour), want: true},
+		{name: "down before the run", last: day.Add(-22 * time.Hour), now: day.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, missed(schedule, tt.last, tt.now), tt.want)
+		})
+	}
+}
+
+func TestRepoExclusion(t *testing.T) {
+	var running, maxRunning, runs int32
+	var run = func(ctx context.Context) error {
+		var n = atomic.AddInt32(&running, 1)
+		for {
+			var m = atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(300 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+
+	var s = New(nil)
+	assert.Equal(t, s.Add(&Task{Name: "a", Schedule: "* * * * * *", Repo: "fs/home", Run: run}), nil)
+	assert.Equal(t, s.Add(&Task{Name: "b", Schedule: "* * * * * *", Repo: "fs/home", Run: run}), nil)
+	assert.NotEqual(t, s.Add(&Task{Name: "b", Schedule: "* * * * * *", Run: run}), nil)
+	assert.NotEqual(t, s.Add(&Task{Name: "c", Schedule: "never", Run: run}), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, s.Run(ctx), context.DeadlineExceeded)
+	assert.Equal(t, atomic.LoadInt32(&maxRunning), int32(1))
+	assert.Equal(t, atomic.LoadInt32(&runs) >= 2, true)
+}
+
+func TestCatchUp(t *testing.T) {
+	var file = path.Join(t.TempDir(), "scheduler.json")
+	state, err := LoadState(file)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, state.Set("nightly", TaskState{LastRun: time.Now().Add(-49 * time.Hour)}), nil)
+
+	// the state is read back from the file
+	state, err = LoadState(file)
+	assert.Equal(t, err, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var once sync.Once
+	var s = New(state)
+	assert.Equal(t, s.Add(&Task{Name: "nightly", Schedule: "@daily", CatchUp: true, Run: func(ctx context.Context) error {
+		once.Do(cancel)
+		return errors.New("repo is offline")
+	}}), nil)
+	assert.Equal(t, s.Add(&Task{Name: "new", Schedule: "@daily", CatchUp: true, Run: func(ctx context.Context) error {
+		t.Error("a task without state must not catch up")
+		return nil
+	}}), nil)
+
+	assert.Equal(t, s.Run(ctx), context.Canceled)
+	var status = s.Status()
+	assert.Equal(t, len(status), 2)
+	assert.Equal(t, status[1].Name, "nightly")
+	assert.Equal(t, status[1].LastError, "repo is offline")
+
+	state, err = LoadState(file)
+	assert.Equal(t, err, nil)
+	nightly, ok := state.Get("nightly")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, time.Since(nightly.LastRun) < time.Minute, true)
+	_, ok = state.Get("new")
+	assert.Equal(t, ok, true)
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// TaskState is what the scheduler remembers of a task across restarts
+type TaskState struct {
+	LastRun   time.Time `json:"last_run"`             // start of the last run, or the first start of the scheduler
+	LastError string    `json:"last_error,omitempty"` // redacted error of the last run
+	Duration  float64   `json:"duration,omitempty"`   // seconds of the last run
+}
+
+// State persists the task states in a json file, so missed runs are caught up after downtime
+type State struct {
+	file  string
+	mu    sync.Mutex
+	tasks map[string]*TaskState
+}
+
+func DefaultStatePath() string {
+	return path.Join(utils.GetBaseDir(), constants.DefaultSchedulerState)
+}
+
+// LoadState reads the state file, a missing file is an empty state
+func LoadState(file string) (*State, error) {
+	var s = &State{file: file, tasks: make(map[string]*TaskState)}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read scheduler state error: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.tasks); err != nil {
+		return nil, fmt.Errorf("parse scheduler state %s error: %v", file, err)
+	}
+	if s.tasks == nil {
+		s.tasks = make(map[string]*TaskState)
+	}
+	return s, nil
+}
+
+// NewMemoryState keeps the state in memory only, nothing is caught up after a restart
+func NewMemoryState() *State {
+	return &State{tasks: make(map[string]*TaskState)}
+}
+
+func (s *State) Get(name string) (TaskState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok {
+		return TaskState{}, false
+	}
+	return *t, true
+}
+
+// Set stores the state of the task and writes the file
+func (s *State) Set(name string, t TaskState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[name] = &t
+	return s.save()
+}
+
+func (s *State) save() error {
+	if s.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(s.file), 0755); err != nil {
+		return fmt.Errorf("create scheduler state dir error: %v", err)
+	}
+	var tmp = s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write scheduler state error: %v", err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("write scheduler state error: %v", err)
+	}
+	return nil
+}
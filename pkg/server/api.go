@@ -0,0 +1,381 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/jobs"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// BackupRequest starts a backup job, password is the repository password itself
+type BackupRequest struct {
+	options.RepositoryBackupOption
+	Password string `json:"password"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+	Force    bool   `json:"force,omitempty"` // skips the free space check of a local repository
+}
+
+// RestoreRequest starts a restore job, it fails early when the target has too little free space unless force is set
+type RestoreRequest struct {
+	options.RepositoryRestoreOption
+	Password string `json:"password"`
+	Force    bool   `json:"force,omitempty"`
+}
+
+// SnapshotsRequest lists the snapshots of the repository, or the one of snapshot_id, it also scans the stats
+type SnapshotsRequest struct {
+	options.RepositorySnapshotsOption
+	Password   string `json:"password"`
+	SnapshotId string `json:"snapshot_id,omitempty"`
+}
+
+// LsRequest lists the files of a snapshot under path, the root when empty
+type LsRequest struct {
+	options.RepositorySnapshotsOption
+	Password   string `json:"password"`
+	SnapshotId string `json:"snapshot_id"`
+	Path       string `json:"path,omitempty"`
+	Recursive  bool   `json:"recursive,omitempty"`
+}
+
+// ForgetRequest starts a job that applies the retention policy
+type ForgetRequest struct {
+	options.RepositorySnapshotsOption
+	Password  string              `json:"password"`
+	Retention restic.ForgetPolicy `json:"retention"`
+}
+
+// CheckRequest starts a job that checks the repository
+type CheckRequest struct {
+	options.RepositorySnapshotsOption
+	Password       string `json:"password"`
+	ReadDataSubset string `json:"read_data_subset,omitempty"`
+}
+
+func (s *Server) apiService() *restful.WebService {
+	var ws = new(restful.WebService)
+	ws.Path(BasePath).Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+
+	var operations = []string{"operations"}
+	var repository = []string{"repository"}
+	var jobTags = []string{"jobs"}
+
+	ws.Route(ws.POST("/backups").To(s.backup).
+		Doc("Start a backup").Operation("backup").Metadata(metaTags, operations).
+		Reads(BackupRequest{}).
+		Returns(http.StatusAccepted, "The backup job", jobs.Job{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}))
+	ws.Route(ws.POST("/restores").To(s.restore).
+		Doc("Start a restore of a snapshot").Operation("restore").Metadata(metaTags, operations).
+		Reads(RestoreRequest{}).
+		Returns(http.StatusAccepted, "The restore job", jobs.Job{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}))
+	ws.Route(ws.POST("/forget").To(s.forget).
+		Doc("Start applying a retention policy").Operation("forget").Metadata(metaTags, operations).
+		Reads(ForgetRequest{}).
+		Returns(http.StatusAccepted, "The forget job", jobs.Job{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}))
+	ws.Route(ws.POST("/check").To(s.check).
+		Doc("Start a repository check").Operation("check").Metadata(metaTags, operations).
+		Reads(CheckRequest{}).
+		Returns(http.StatusAccepted, "The check job", jobs.Job{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}))
+
+	ws.Route(ws.POST("/snapshots").To(s.snapshots).
+		Doc("List the snapshots of a repository").Operation("snapshots").Metadata(metaTags, repository).
+		Reads(SnapshotsRequest{}).
+		Returns(http.StatusOK, "The snapshots", restic.SnapshotList{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}).
+		Returns(http.StatusInternalServerError, "The repository could not be read", Error{}))
+	ws.Route(ws.POST("/stats").To(s.stats).
+		Doc("Scan the stats of a repository").Operation("stats").Metadata(metaTags, repository).
+		Reads(SnapshotsRequest{}).
+		Returns(http.StatusOK, "The stats", restic.StatsContainer{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}).
+		Returns(http.StatusInternalServerError, "The repository could not be read", Error{}))
+	ws.Route(ws.POST("/ls").To(s.ls).
+		Doc("List the files of a snapshot").Operation("ls").Metadata(metaTags, repository).
+		Reads(LsRequest{}).
+		Returns(http.StatusOK, "The files", []*restic.LsNode{}).
+		Returns(http.StatusBadRequest, "Invalid request", Error{}).
+		Returns(http.StatusInternalServerError, "The repository could not be read", Error{}))
+
+	ws.Route(ws.GET("/jobs").To(s.listJobs).
+		Doc("List the running, queued and recent jobs, the newest first").Operation("listJobs").Metadata(metaTags, jobTags).
+		Param(ws.QueryParameter("state", "Comma separated states to filter by").PossibleValues(states())).
+		Returns(http.StatusOK, "The jobs", []*jobs.Job{}))
+	ws.Route(ws.GET("/jobs/{id}").To(s.getJob).
+		Doc("Get the status and the last progress of a job").Operation("getJob").Metadata(metaTags, jobTags).
+		Param(ws.PathParameter("id", "Job id")).
+		Returns(http.StatusOK, "The job", jobs.Job{}).
+		Returns(http.StatusNotFound, "Unknown job", Error{}))
+	ws.Route(ws.DELETE("/jobs/{id}").To(s.cancelJob).
+		Doc("Cancel a queued or running job").Operation("cancelJob").Metadata(metaTags, jobTags).
+		Notes("The restic processes of the job are terminated, the job is canceled once its events end.").
+		Param(ws.PathParameter("id", "Job id")).
+		Returns(http.StatusAccepted, "The job being canceled", jobs.Job{}).
+		Returns(http.StatusNotFound, "Unknown job", Error{}).
+		Returns(http.StatusConflict, "The job is already finished", Error{}))
+	ws.Route(ws.GET("/jobs/{id}/events").To(s.jobEvents).
+		Doc("Stream the changes of a job as server-sent events").Operation("jobEvents").Metadata(metaTags, jobTags).
+		Notes("Each event carries the job as JSON, a job event on every change and a done event when it is finished, then the stream ends.").
+		Produces("text/event-stream").
+		Param(ws.PathParameter("id", "Job id")).
+		Returns(http.StatusOK, "The events", jobs.Job{}).
+		Returns(http.StatusNotFound, "Unknown job", Error{}))
+
+	return ws
+}
+
+func (s *Server) publicService() *restful.WebService {
+	var ws = new(restful.WebService)
+	ws.Path("/").Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("/openapi.json").To(func(req *restful.Request, resp *restful.Response) {
+		_ = resp.WriteAsJson(s.document)
+	}).Doc("The OpenAPI document of the API").Operation("openapi").Metadata(metaTags, []string{"meta"}).
+		Returns(http.StatusOK, "The document", nil))
+	ws.Route(ws.GET("/healthz").To(func(req *restful.Request, resp *restful.Response) {
+		resp.WriteHeader(http.StatusNoContent)
+	}).Doc("Liveness probe").Operation("healthz").Metadata(metaTags, []string{"meta"}).
+		Returns(http.StatusNoContent, "The server is up", nil))
+	return ws
+}
+
+func states() []string {
+	return []string{string(jobs.StateQueued), string(jobs.StateRunning), string(jobs.StateSucceeded), string(jobs.StateFailed), string(jobs.StateCanceled)}
+}
+
+// request is a body that targets a repository
+type request interface {
+	repository() (*options.Repository, string)
+}
+
+func (r *BackupRequest) repository() (*options.Repository, string)  { return &r.Repository, r.Password }
+func (r *RestoreRequest) repository() (*options.Repository, string) { return &r.Repository, r.Password }
+func (r *SnapshotsRequest) repository() (*options.Repository, string) {
+	return &r.Repository, r.Password
+}
+func (r *LsRequest) repository() (*options.Repository, string)     { return &r.Repository, r.Password }
+func (r *ForgetRequest) repository() (*options.Repository, string) { return &r.Repository, r.Password }
+func (r *CheckRequest) repository() (*options.Repository, string)  { return &r.Repository, r.Password }
+
+// readRequest decodes the body strictly, so that a misspelled field is an error rather than a default
+func readRequest(req *restful.Request, body request) error {
+	var dec = json.NewDecoder(req.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(body); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	var repo, password = body.repository()
+	if err := repo.Validate(); err != nil {
+		return err
+	}
+	if _, ok := storage.LookupBackend(repo.Location); !ok {
+		return fmt.Errorf("location %s is not registered", repo.Location)
+	}
+	if password == "" {
+		return errors.New("repository password is required")
+	}
+	return rejectSecretRefs(repo, password)
+}
+
+// rejectSecretRefs refuses env:, file: and cmd: secret references, a token holder must not
+// read the environment or files of the server, nor run commands on it.
+func rejectSecretRefs(repo *options.Repository, password string) error {
+	var values = []string{password}
+	var credentials = reflect.ValueOf(repo.GetCredentials()).Elem()
+	for i := 0; i < credentials.NumField(); i++ {
+		if credentials.Field(i).Kind() == reflect.String {
+			values = append(values, credentials.Field(i).String())
+		}
+	}
+	for _, v := range values {
+		if utils.IsSecretRef(v) {
+			return errors.New("secret references are not accepted by the api, send the secret itself")
+		}
+	}
+	return nil
+}
+
+func (s *Server) snapshotsOption(repo *options.RepositorySnapshotsOption, password, snapshotId string) *storage.SnapshotsOption {
+	return &storage.SnapshotsOption{
+		Password:   password,
+		Operator:   constants.StorageOperatorApp,
+		SnapshotId: snapshotId,
+		Logger:     s.options.Logger,
+		History:    s.options.History,
+		Notifier:   s.options.Notifier,
+		Repository: repo,
+	}
+}
+
+func (s *Server) backup(req *restful.Request, resp *restful.Response) {
+	var body BackupRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	if body.Path == "" && len(body.Files) == 0 {
+		badRequest(resp, "path or files are required")
+		return
+	}
+	id, err := s.jobs.Backup(s.context(req), &storage.BackupOption{
+		Password:   body.Password,
+		Operator:   constants.StorageOperatorApp,
+		Logger:     s.options.Logger,
+		History:    s.options.History,
+		Notifier:   s.options.Notifier,
+		Force:      body.Force,
+		Repository: &body.RepositoryBackupOption,
+	}, body.DryRun)
+	s.writeJob(resp, id, err)
+}
+
+func (s *Server) restore(req *restful.Request, resp *restful.Response) {
+	var body RestoreRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	if body.SnapshotId == "" || body.Path == "" {
+		badRequest(resp, "snapshot_id and path are required")
+		return
+	}
+	id, err := s.jobs.Restore(s.context(req), &storage.RestoreOption{
+		Password:   body.Password,
+		Operator:   constants.StorageOperatorApp,
+		Logger:     s.options.Logger,
+		History:    s.options.History,
+		Notifier:   s.options.Notifier,
+		Force:      body.Force,
+		Repository: &body.RepositoryRestoreOption,
+	})
+	s.writeJob(resp, id, err)
+}
+
+func (s *Server) forget(req *restful.Request, resp *restful.Response) {
+	var body ForgetRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	if len(body.Retention.Args()) == 0 {
+		badRequest(resp, "retention has no keep rule")
+		return
+	}
+	id, err := s.jobs.Forget(s.context(req), s.snapshotsOption(&body.RepositorySnapshotsOption, body.Password, ""), &body.Retention)
+	s.writeJob(resp, id, err)
+}
+
+func (s *Server) check(req *restful.Request, resp *restful.Response) {
+	var body CheckRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	id, err := s.jobs.Check(s.context(req), s.snapshotsOption(&body.RepositorySnapshotsOption, body.Password, ""), body.ReadDataSubset)
+	s.writeJob(resp, id, err)
+}
+
+func (s *Server) writeJob(resp *restful.Response, id string, err error) {
+	if err != nil {
+		writeJobError(resp, err)
+		return
+	}
+	j, err := s.jobs.Get(id)
+	if err != nil {
+		writeJobError(resp, err)
+		return
+	}
+	_ = resp.WriteHeaderAndJson(http.StatusAccepted, j, restful.MIME_JSON)
+}
+
+func (s *Server) snapshots(req *restful.Request, resp *restful.Response) {
+	var body SnapshotsRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	var option = s.snapshotsOption(&body.RepositorySnapshotsOption, body.Password, body.SnapshotId)
+	result, err := storage.NewSnapshotsService(option).SnapshotsContext(s.context(req))
+	if err != nil {
+		writeError(resp, http.StatusInternalServerError, err)
+		return
+	}
+	_ = resp.WriteAsJson(result)
+}
+
+func (s *Server) stats(req *restful.Request, resp *restful.Response) {
+	var body SnapshotsRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	var option = s.snapshotsOption(&body.RepositorySnapshotsOption, body.Password, body.SnapshotId)
+	result, err := storage.NewStatsService(option).StatsContext(s.context(req))
+	if err != nil {
+		writeError(resp, http.StatusInternalServerError, err)
+		return
+	}
+	_ = resp.WriteAsJson(result)
+}
+
+func (s *Server) ls(req *restful.Request, resp *restful.Response) {
+	var body LsRequest
+	if err := readRequest(req, &body); err != nil {
+		badRequest(resp, "%v", err)
+		return
+	}
+	if body.SnapshotId == "" {
+		badRequest(resp, "snapshot_id is required")
+		return
+	}
+	var option = s.snapshotsOption(&body.RepositorySnapshotsOption, body.Password, body.SnapshotId)
+	result, err := storage.NewLsService(option).LsContext(s.context(req), body.Path, body.Recursive)
+	if err != nil {
+		writeError(resp, http.StatusInternalServerError, err)
+		return
+	}
+	if result == nil {
+		result = []*restic.LsNode{}
+	}
+	_ = resp.WriteAsJson(result)
+}
+
+func (s *Server) listJobs(req *restful.Request, resp *restful.Response) {
+	var filter []jobs.State
+	if v := req.QueryParameter("state"); v != "" {
+		for _, state := range strings.Split(v, ",") {
+			filter = append(filter, jobs.State(strings.TrimSpace(state)))
+		}
+	}
+	_ = resp.WriteAsJson(s.jobs.List(filter...))
+}
+
+func (s *Server) getJob(req *restful.Request, resp *restful.Response) {
+	j, err := s.jobs.Get(req.PathParameter("id"))
+	if err != nil {
+		writeJobError(resp, err)
+		return
+	}
+	_ = resp.WriteAsJson(j)
+}
+
+func (s *Server) cancelJob(req *restful.Request, resp *restful.Response) {
+	var id = req.PathParameter("id")
+	if err := s.jobs.Cancel(id); err != nil {
+		writeJobError(resp, err)
+		return
+	}
+	s.writeJob(resp, id, nil)
+}
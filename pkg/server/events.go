@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// keepaliveInterval keeps proxies from closing a quiet event stream
+const keepaliveInterval = 15 * time.Second
+
+// jobEvents streams the job as server-sent events until it is finished or the client goes away
+func (s *Server) jobEvents(req *restful.Request, resp *restful.Response) {
+	updates, unwatch, err := s.jobs.Watch(req.PathParameter("id"))
+	if err != nil {
+		writeJobError(resp, err)
+		return
+	}
+	defer unwatch()
+
+	flusher, ok := resp.ResponseWriter.(http.Flusher)
+	if !ok {
+		writeError(resp, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var keepalive = time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	var ctx = req.Request.Context()
+	for seq := 1; ; seq++ {
+		select {
+		case j, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(j)
+			if err != nil {
+				return
+			}
+			var event = "job"
+			if j.State.Finished() {
+				event = "done"
+			}
+			if _, err := fmt.Fprintf(resp, "id: %d\nevent: %s\ndata: %s\n\n", seq, event, data); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(resp, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+const securityScheme = "bearerAuth"
+
+// Document is an OpenAPI 3.0 document, it is generated from the routes of the server
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Security   []map[string][]string            `json:"security"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Operation struct {
+	OperationId string                 `json:"operationId,omitempty"`
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []*Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]*Response   `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"` // an empty list makes the operation public
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// newDocument describes the routes of the web services, the routes of public ones need no token
+func newDocument(version string, secured []*restful.WebService, public []*restful.WebService) *Document {
+	var d = &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Olares backups API",
+			Description: "Backups, restores and maintenance of restic repositories, see the backups serve command.",
+			Version:     version,
+		},
+		Security: []map[string][]string{{securityScheme: {}}},
+		Paths:    make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{securityScheme: {Type: "http", Scheme: "bearer"}},
+		},
+	}
+	for _, ws := range secured {
+		d.addRoutes(ws, false)
+	}
+	for _, ws := range public {
+		d.addRoutes(ws, true)
+	}
+	return d
+}
+
+func (d *Document) addRoutes(ws *restful.WebService, public bool) {
+	for _, r := range ws.Routes() {
+		var op = &Operation{
+			OperationId: r.Operation,
+			Summary:     r.Doc,
+			Description: r.Notes,
+			Responses:   make(map[string]*Response),
+		}
+		if tags, ok := r.Metadata[metaTags].([]string); ok {
+			op.Tags = tags
+		}
+		if public {
+			op.Security = &[]map[string][]string{}
+		}
+		for _, p := range r.ParameterDocs {
+			var data = p.Data()
+			var in string
+			switch data.Kind {
+			case restful.PathParameterKind:
+				in = "path"
+			case restful.QueryParameterKind:
+				in = "query"
+			case restful.HeaderParameterKind:
+				in = "header"
+			default:
+				continue
+			}
+			var schema = &Schema{Type: data.DataType, Enum: data.PossibleValues}
+			if schema.Type == "" {
+				schema.Type = "string"
+			}
+			op.Parameters = append(op.Parameters, &Parameter{Name: data.Name, In: in, Description: data.Description,
+				Required: data.Required || in == "path", Schema: schema})
+		}
+		if r.ReadSample != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+				mediaType(r.Consumes): {Schema: d.schema(reflect.TypeOf(r.ReadSample))},
+			}}
+		}
+		for code, e := range r.ResponseErrors {
+			var res = &Response{Description: e.Message}
+			if e.Model != nil {
+				res.Content = map[string]*MediaType{mediaType(r.Produces): {Schema: d.schema(reflect.TypeOf(e.Model))}}
+			}
+			op.Responses[strconv.Itoa(code)] = res
+		}
+
+		if d.Paths[r.Path] == nil {
+			d.Paths[r.Path] = make(map[string]*Operation)
+		}
+		d.Paths[r.Path][strings.ToLower(r.Method)] = op
+	}
+}
+
+func mediaType(types []string) string {
+	if len(types) == 0 {
+		return restful.MIME_JSON
+	}
+	return types[0]
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema describes t like encoding/json encodes it, named structs go to the components
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		var name = path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// registered before the fields, so that recursive types end
+			d.Components.Schemas[name] = &Schema{}
+			d.Components.Schemas[name] = d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// interfaces hold any value
+	return &Schema{}
+}
+
+func (d *Document) object(t reflect.Type) *Schema {
+	var s = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.fields(t, s)
+	return s
+}
+
+// fields adds the json fields of t to s, embedded structs without a json name are inlined
+func (d *Document) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		var tag = f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		var name = strings.Split(tag, ",")[0]
+		var ft = f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			d.fields(ft, s)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schema(f.Type)
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/jobs"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/storage/notification"
+)
+
+const (
+	Version  = "v1"
+	BasePath = "/api/" + Version
+
+	metaTags = "backups.tags"
+)
+
+// Options of the API server
+type Options struct {
+	Tokens   []string               // bearer tokens accepted by the API, at least one is required
+	Jobs     *jobs.Manager          // runs backups, restores, forget and check, a manager with the default options when nil
+	History  *history.Store         // finished jobs are recorded here, nothing is recorded when nil
+	Notifier *notification.Notifier // finished jobs are sent to the matching rules, nothing is sent when nil
+	Logger   *zap.SugaredLogger     // logger of the server and its jobs, the global logger when nil
+}
+
+// Server exposes the services of the SDK as a REST API, long operations run as jobs of the job manager
+type Server struct {
+	options   *Options
+	jobs      *jobs.Manager
+	container *restful.Container
+	document  *Document
+}
+
+// Error is the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+func New(options *Options) (*Server, error) {
+	if options == nil || len(options.Tokens) == 0 {
+		return nil, errors.New("at least one api token is required")
+	}
+	for _, token := range options.Tokens {
+		if strings.TrimSpace(token) == "" {
+			return nil, errors.New("api tokens must not be empty")
+		}
+	}
+
+	return newServer(options), nil
+}
+
+// Spec returns the OpenAPI document of the API without a server
+func Spec() *Document {
+	return newServer(&Options{}).document
+}
+
+func newServer(options *Options) *Server {
+	var s = &Server{options: options, jobs: options.Jobs}
+	if s.jobs == nil {
+		s.jobs = jobs.NewManager(nil)
+	}
+
+	var api = s.apiService()
+	api.Filter(s.authenticate)
+	var public = s.publicService()
+
+	s.container = restful.NewContainer()
+	s.container.ServiceErrorHandler(func(err restful.ServiceError, req *restful.Request, resp *restful.Response) {
+		writeError(resp, err.Code, errors.New(err.Message))
+	})
+	s.container.Add(api)
+	s.container.Add(public)
+	s.document = newDocument(Version, []*restful.WebService{api}, []*restful.WebService{public})
+	return s
+}
+
+// Handler serves the API
+func (s *Server) Handler() http.Handler {
+	return s.container
+}
+
+// OpenAPI returns the OpenAPI document of the API
+func (s *Server) OpenAPI() *Document {
+	return s.document
+}
+
+// Jobs returns the job manager of the server
+func (s *Server) Jobs() *jobs.Manager {
+	return s.jobs
+}
+
+// ListenAndServe serves the API on addr until ctx is done, with TLS when certFile and keyFile are set.
+// The running jobs are canceled on shutdown
+func (s *Server) ListenAndServe(ctx context.Context, addr string, certFile, keyFile string) error {
+	var server = &http.Server{Addr: addr, Handler: s.container, ReadHeaderTimeout: 10 * time.Second}
+	var log = logger.FromContext(logger.NewContext(ctx, s.options.Logger))
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.jobs.Shutdown(shutdownCtx); err != nil {
+			log.Warnf("wait for jobs error: %v", err)
+		}
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("api listening on %s", addr)
+	var err error
+	if certFile != "" || keyFile != "" {
+		err = server.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) authenticate(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	var token, ok = strings.CutPrefix(req.HeaderParameter("Authorization"), "Bearer ")
+	if ok {
+		for _, t := range s.options.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				chain.ProcessFilter(req, resp)
+				return
+			}
+		}
+	}
+	resp.AddHeader("WWW-Authenticate", `Bearer realm="backups"`)
+	writeError(resp, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+}
+
+// context carries the logger of the server, the request context ends when the client goes away
+func (s *Server) context(req *restful.Request) context.Context {
+	return logger.NewContext(req.Request.Context(), s.options.Logger)
+}
+
+func writeError(resp *restful.Response, code int, err error) {
+	_ = resp.WriteHeaderAndJson(code, &Error{Error: err.Error()}, restful.MIME_JSON)
+}
+
+// writeJobError maps the errors of the job manager to status codes
+func writeJobError(resp *restful.Response, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		writeError(resp, http.StatusNotFound, err)
+	case errors.Is(err, jobs.ErrJobFinished):
+		writeError(resp, http.StatusConflict, err)
+	case errors.Is(err, jobs.ErrShutdown):
+		writeError(resp, http.StatusServiceUnavailable, err)
+	default:
+		writeError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+func badRequest(resp *restful.Response, format string, a ...interface{}) {
+	writeError(resp, http.StatusBadRequest, fmt.Errorf(format, a...))
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/jobs"
+)
+
+var update = flag.Bool("update", false, "update the OpenAPI document under api/")
+
+const testToken = "testtoken"
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	s, err := New(&Options{Tokens: []string{testToken}})
+	assert.Equal(t, err, nil)
+	var ts = httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path, token, body string) *http.Response {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	assert.Equal(t, err, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Equal(t, err, nil)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&Options{})
+	assert.NotEqual(t, err, nil)
+	_, err = New(&Options{Tokens: []string{" "}})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestAuthenticate(t *testing.T) {
+	var _, ts = newTestServer(t)
+	var tests = []struct {
+		name  string
+		path  string
+		token string
+		code  int
+	}{
+		{name: "no token", path: "/api/v1/jobs", code: http.StatusUnauthorized},
+		{name: "wrong token", path: "/api/v1/jobs", token: "wrong", code: http.StatusUnauthorized},
+		{name: "token", path: "/api/v1/jobs", token: testToken, code: http.StatusOK},
+		{name: "public", path: "/healthz", code: http.StatusNoContent},
+		{name: "openapi", path: "/openapi.json", code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, do(t, ts, http.MethodGet, tt.path, tt.token, "").StatusCode, tt.code)
+		})
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	var _, ts = newTestServer(t)
+	var tests = []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "invalid json", path: "/api/v1/backups", body: "{"},
+		{name: "unknown field", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","password":"p","path":"/data","bucket":"b"}`},
+		{name: "missing repo name", path: "/api/v1/backups", body: `{"location":"fs","password":"p","path":"/data"}`},
+		{name: "unknown location", path: "/api/v1/backups", body: `{"location":"ftp","repo_name":"a","password":"p","path":"/data"}`},
+		{name: "missing password", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","path":"/data"}`},
+		{name: "command password", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","password":"cmd:id","path":"/data"}`},
+		{name: "command credentials", path: "/api/v1/snapshots", body: `{"location":"s3","repo_name":"a","password":"p","credentials":{"secret_access_key":"cmd:id"}}`},
+		{name: "env password", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","password":"env:HOME","path":"/data"}`},
+		{name: "env credentials", path: "/api/v1/snapshots", body: `{"location":"s3","repo_name":"a","password":"p","credentials":{"access_key":"env:AWS_ACCESS_KEY_ID"}}`},
+		{name: "file password", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","password":"file:/etc/shadow","path":"/data"}`},
+		{name: "file credentials", path: "/api/v1/snapshots", body: `{"location":"space","repo_name":"a","password":"p","credentials":{"access_token":"file:/etc/hostname"}}`},
+		{name: "missing path", path: "/api/v1/backups", body: `{"location":"fs","repo_name":"a","password":"p"}`},
+		{name: "missing snapshot", path: "/api/v1/restores", body: `{"location":"fs","repo_name":"a","password":"p","path":"/restore"}`},
+		{name: "empty retention", path: "/api/v1/forget", body: `{"location":"fs","repo_name":"a","password":"p","retention":{}}`},
+		{name: "ls without snapshot", path: "/api/v1/ls", body: `{"location":"fs","repo_name":"a","password":"p"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp = do(t, ts, http.MethodPost, tt.path, testToken, tt.body)
+			assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+			var e Error
+			assert.Equal(t, json.NewDecoder(resp.Body).Decode(&e), nil)
+			assert.NotEqual(t, e.Error, "")
+		})
+	}
+}
+
+func TestJobs(t *testing.T) {
+	var s, ts = newTestServer(t)
+	id, err := s.Jobs().Submit(context.Background(), history.OperationBackup, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		progress(0.5)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, err, nil)
+
+	var resp = do(t, ts, http.MethodGet, "/api/v1/jobs?state=running,queued", testToken, "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	var list []*jobs.Job
+	assert.Equal(t, json.NewDecoder(resp.Body).Decode(&list), nil)
+	assert.Equal(t, len(list), 1)
+	assert.Equal(t, list[0].Id, id)
+
+	assert.Equal(t, do(t, ts, http.MethodGet, "/api/v1/jobs/"+id, testToken, "").StatusCode, http.StatusOK)
+	assert.Equal(t, do(t, ts, http.MethodGet, "/api/v1/jobs/missing", testToken, "").StatusCode, http.StatusNotFound)
+
+	assert.Equal(t, do(t, ts, http.MethodDelete, "/api/v1/jobs/"+id, testToken, "").StatusCode, http.StatusAccepted)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	j, err := s.Jobs().Wait(ctx, id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, j.State, jobs.StateCanceled)
+	assert.Equal(t, do(t, ts, http.MethodDelete, "/api/v1/jobs/"+id, testToken, "").StatusCode, http.StatusConflict)
+}
+
+func TestJobEvents(t *testing.T) {
+	var s, ts = newTestServer(t)
+	var release = make(chan struct{})
+	id, err := s.Jobs().Submit(context.Background(), history.OperationRestore, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		<-release
+		progress(1)
+		return nil, nil
+	})
+	assert.Equal(t, err, nil)
+
+	var resp = do(t, ts, http.MethodGet, "/api/v1/jobs/"+id+"/events", testToken, "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, resp.Header.Get("Content-Type"), "text/event-stream")
+	close(release)
+
+	var events []string
+	var last jobs.Job
+	var scanner = bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			assert.Equal(t, json.Unmarshal([]byte(data), &last), nil)
+		}
+	}
+	assert.Equal(t, events[len(events)-1], "done")
+	assert.Equal(t, last.State, jobs.StateSucceeded)
+}
+
+func TestOpenAPI(t *testing.T) {
+	data, err := json.MarshalIndent(Spec(), "", "  ")
+	assert.Equal(t, err, nil)
+	data = append(data, '\n')
+
+	var file = "../../api/openapi.json"
+	if *update {
+		assert.Equal(t, os.WriteFile(file, data, 0644), nil)
+	}
+	shipped, err := os.ReadFile(file)
+	assert.Equal(t, err, nil)
+	// regenerate with go test ./pkg/server -run TestOpenAPI -update
+	assert.Equal(t, string(shipped), string(data))
+}
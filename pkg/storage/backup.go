@@ -2,40 +2,96 @@ package storage
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"errors"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/history"
 	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/restic"
-	"olares.com/backups-sdk/pkg/storage/cos"
-	"olares.com/backups-sdk/pkg/storage/filesystem"
 	"olares.com/backups-sdk/pkg/storage/model"
-	"olares.com/backups-sdk/pkg/storage/s3"
-	"olares.com/backups-sdk/pkg/storage/space"
+	"olares.com/backups-sdk/pkg/storage/notification"
+	"olares.com/backups-sdk/pkg/tracing"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
 type BackupOption struct {
 	Basedir                  string
 	Password                 string
+	PasswordFile             string
+	PasswordCommand          string
 	Operator                 string
-	BackupType               string // file / app
-	BackupAppTypeName        string // if app
-	BackupFileTypeSourcePath string // if file
-	Ctx                      context.Context
-	Logger                   *zap.SugaredLogger
+	BackupType               string                 // file / app
+	BackupAppTypeName        string                 // if app
+	BackupFileTypeSourcePath string                 // if file
+	BackupSetId              string                 // tags the snapshot with backup-set=<id>, set by the fan-out to correlate its snapshots
+	Ctx                      context.Context        // Deprecated: pass the context to BackupContext
+	Timeout                  time.Duration          // bounds the whole backup, zero means no limit
+	Logger                   *zap.SugaredLogger     // logger of the service, the global logger when nil
+	History                  *history.Store         // the finished backup is recorded here, nothing is recorded when nil
+	Notifier                 *notification.Notifier // the finished backup is sent to the matching rules, nothing is sent when nil
+	LimitUploadRate          string                 // caps the upload rate of the location option in KiB/s, the lower limit applies
+	UploadSchedule           bandwidth.Schedule     // caps the upload rate by time of day, restic is restarted when the rate changes
+	UploadRateLimit          *RateLimit             // caps the upload rate while it may change, restic is restarted when it does
+	Force                    bool                   // starts even when the pre-flight check finds too little free space for a local repository
+	SecretRefs               bool                   // resolves env:, file: and cmd: references in the password and the credentials, they are used as they are when false
 	Space                    *options.SpaceBackupOption
 	Aws                      *options.AwsBackupOption
 	TencentCloud             *options.TencentCloudBackupOption
 	Filesystem               *options.FilesystemBackupOption
+	Sftp                     *options.SftpBackupOption
+	Rest                     *options.RestBackupOption
+
+	// Repository is the serializable descriptor of the repository and the operation
+	Repository *options.RepositoryBackupOption
+
+	// Location selects a registered backend by name, LocationOption is its backup option
+	Location       string
+	LocationOption options.Option
+
+	// Targets are more locations FanOutContext backs up the same source to
+	Targets    []*BackupTarget
+	Policy     FanOutPolicy // when the fan-out fails, FailAny when empty
+	Sequential bool         // the fan-out backs up one target after another instead of in parallel
+}
+
+func (o *BackupOption) location() (string, options.Option, error) {
+	switch {
+	case o.Space != nil:
+		return LocationSpace, o.Space, nil
+	case o.Aws != nil:
+		return LocationAws, o.Aws, nil
+	case o.TencentCloud != nil:
+		return LocationTencentCloud, o.TencentCloud, nil
+	case o.Filesystem != nil:
+		return LocationFilesystem, o.Filesystem, nil
+	case o.Sftp != nil:
+		return LocationSftp, o.Sftp, nil
+	case o.Rest != nil:
+		return LocationRest, o.Rest, nil
+	case o.Repository != nil:
+		return repositoryOption(&o.Repository.Repository, o.Repository)
+	}
+	return o.Location, o.LocationOption, nil
+}
+
+// Destination resolves the location and the repository name the backup goes to
+func (o *BackupOption) Destination() (location string, repo string, err error) {
+	name, option, err := o.location()
+	if err != nil {
+		return "", "", err
+	}
+	return name, optionField(option, "RepoName"), nil
 }
 
 type BackupService struct {
 	baseDir  string
 	password string
 	option   *BackupOption
+	resolved bool // password is resolved already, set by the fan-out
 }
 
 func NewBackupService(option *BackupOption) *BackupService {
@@ -47,101 +103,99 @@ func NewBackupService(option *BackupOption) *BackupService {
 	return backupService
 }
 
+// Deprecated: use BackupContext, Backup runs with BackupOption.Ctx
 func (b *BackupService) Backup(dryRun bool, progressCallback func(percentDone float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
-	var password = b.password
-	var err error
-	if password == "" {
-		password, err = utils.InputPasswordWithConfirm(true)
-		if err != nil {
-			panic(err)
-		}
+	return b.BackupContext(contextOrBackground(b.option.Ctx), dryRun, progressCallback)
+}
+
+// BackupContext runs the backup until it finishes or ctx is done, a trace id is added to ctx when it has none
+func (b *BackupService) BackupContext(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (summaryOutput *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	if ctx == nil {
+		return nil, nil, ErrNilContext
+	}
+	if len(b.option.Targets) > 0 {
+		return nil, nil, errors.New("the backup has targets, run FanOutContext")
 	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), b.option.Logger), b.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "backup")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
 
-	var service Location
-	if b.option.Space != nil {
-		service = &space.Space{
-			RepoId:                   b.option.Space.RepoId,
-			RepoName:                 b.option.Space.RepoName,
-			OlaresDid:                b.option.Space.OlaresDid,
-			AccessToken:              b.option.Space.AccessToken,
-			ClusterId:                b.option.Space.ClusterId,
-			CloudName:                strings.ToLower(b.option.Space.CloudName),
-			RegionId:                 strings.ToLower(b.option.Space.RegionId),
-			Path:                     b.option.Space.Path,
-			Files:                    b.option.Space.Files,
-			FilesPrefixPath:          b.option.Space.FilesPrefixPath,
-			Metadata:                 b.option.Space.Metadata,
-			CloudApiMirror:           b.option.Space.CloudApiMirror,
-			LimitUploadRate:          b.option.Space.LimitUploadRate,
-			Password:                 password,
-			StsToken:                 &space.StsToken{},
-			Operator:                 b.option.Operator,
-			BackupType:               b.option.BackupType,
-			BackupAppTypeName:        b.option.BackupAppTypeName,
-			BackupFileTypeSourcePath: b.option.BackupFileTypeSourcePath,
+	var record = history.Start(history.OperationBackup, "", "", utils.GetTraceId(ctx))
+	record.DryRun = dryRun
+	defer func() {
+		if summaryOutput != nil {
+			record.SnapshotId = summaryOutput.SnapshotID
+			record.Bytes = summaryOutput.TotalBytesProcessed
+			record.Files = uint64(summaryOutput.TotalFilesProcessed)
+			record.BytesAdded = summaryOutput.DataAdded
+			record.FilesNew = uint64(summaryOutput.FilesNew)
+			record.FilesChanged = uint64(summaryOutput.FilesChanged)
 		}
-	} else if b.option.Aws != nil {
-		service = &s3.Aws{
-			RepoId:                   b.option.Aws.RepoId,
-			RepoName:                 b.option.Aws.RepoName,
-			Endpoint:                 b.option.Aws.Endpoint,
-			AccessKey:                b.option.Aws.AccessKey,
-			SecretAccessKey:          b.option.Aws.SecretAccessKey,
-			Path:                     b.option.Aws.Path,
-			Files:                    b.option.Aws.Files,
-			FilesPrefixPath:          b.option.Aws.FilesPrefixPath,
-			Metadata:                 b.option.Aws.Metadata,
-			LimitUploadRate:          b.option.Aws.LimitUploadRate,
-			Password:                 password,
-			BaseHandler:              &BaseHandler{},
-			Operator:                 b.option.Operator,
-			BackupType:               b.option.BackupType,
-			BackupAppTypeName:        b.option.BackupAppTypeName,
-			BackupFileTypeSourcePath: b.option.BackupFileTypeSourcePath,
-		}
-	} else if b.option.TencentCloud != nil {
-		service = &cos.TencentCloud{
-			RepoId:                   b.option.TencentCloud.RepoId,
-			RepoName:                 b.option.TencentCloud.RepoName,
-			Endpoint:                 b.option.TencentCloud.Endpoint,
-			AccessKey:                b.option.TencentCloud.AccessKey,
-			SecretAccessKey:          b.option.TencentCloud.SecretAccessKey,
-			Path:                     b.option.TencentCloud.Path,
-			Files:                    b.option.TencentCloud.Files,
-			FilesPrefixPath:          b.option.TencentCloud.FilesPrefixPath,
-			Metadata:                 b.option.TencentCloud.Metadata,
-			LimitUploadRate:          b.option.TencentCloud.LimitUploadRate,
-			Password:                 password,
-			BaseHandler:              &BaseHandler{},
-			Operator:                 b.option.Operator,
-			BackupType:               b.option.BackupType,
-			BackupAppTypeName:        b.option.BackupAppTypeName,
-			BackupFileTypeSourcePath: b.option.BackupFileTypeSourcePath,
-		}
-	} else if b.option.Filesystem != nil {
-		service = &filesystem.Filesystem{
-			RepoId:                   b.option.Filesystem.RepoId,
-			RepoName:                 b.option.Filesystem.RepoName,
-			Endpoint:                 b.option.Filesystem.Endpoint,
-			Path:                     b.option.Filesystem.Path,
-			Files:                    b.option.Filesystem.Files,
-			FilesPrefixPath:          b.option.Filesystem.FilesPrefixPath,
-			Metadata:                 b.option.Filesystem.Metadata,
-			Password:                 password,
-			BaseHandler:              &BaseHandler{},
-			Operator:                 b.option.Operator,
-			BackupType:               b.option.BackupType,
-			BackupAppTypeName:        b.option.BackupAppTypeName,
-			BackupFileTypeSourcePath: b.option.BackupFileTypeSourcePath,
+		record.Storage = storageInfo
+		recordJob(ctx, b.option.History, b.option.Notifier, record, err)
+	}()
+
+	var password = b.password
+	if !b.resolved {
+		if password, err = resolvePassword(b.password, b.option.PasswordFile, b.option.PasswordCommand, b.option.SecretRefs, true); err != nil {
+			log.Errorf("get repository password error: %v", err)
+			return nil, nil, err
 		}
-	} else {
-		logger.Fatalf("There is no suitable recovery method.")
 	}
 
-	summaryOutput, storageInfo, err := service.Backup(b.option.Ctx, dryRun, progressCallback)
+	name, option, err := b.option.location()
+	if err != nil {
+		log.Errorf("resolve location error: %v", err)
+		return nil, nil, err
+	}
+	record.Location, record.Repo, record.RepoId = name, optionField(option, "RepoName"), optionField(option, "RepoId")
+	if b.option.LimitUploadRate != "" {
+		if option, err = capRate(option, "LimitUploadRate", b.option.LimitUploadRate); err != nil {
+			log.Errorf("limit upload rate error: %v", err)
+			return nil, nil, err
+		}
+	}
+	spanLocation(span, name, option)
 
+	// only the first run checks the free space, a restart after a rate change would estimate again
+	var skipSpaceCheck = b.option.Force
+	err = runLimited(ctx, b.option.UploadRateLimit, func(ctx context.Context, limit int64) error {
+		var limited, err = option, error(nil)
+		if limit > 0 {
+			if limited, err = capRate(option, "LimitUploadRate", strconv.FormatInt(limit, 10)); err != nil {
+				return err
+			}
+		}
+		return runScheduled(ctx, rateSchedule(limited, "LimitUploadRate", b.option.UploadSchedule), func(ctx context.Context, rate int64) error {
+			var option, err = limited, error(nil)
+			if rate > 0 {
+				if option, err = capRate(option, "LimitUploadRate", strconv.FormatInt(rate, 10)); err != nil {
+					return err
+				}
+			}
+			service, err := newLocation(name, option, &LocationParams{
+				Password:                 password,
+				Operator:                 b.option.Operator,
+				BackupType:               b.option.BackupType,
+				BackupAppTypeName:        b.option.BackupAppTypeName,
+				BackupFileTypeSourcePath: b.option.BackupFileTypeSourcePath,
+				BackupSetId:              b.option.BackupSetId,
+				SkipSpaceCheck:           skipSpaceCheck,
+				SecretRefs:               b.option.SecretRefs,
+			})
+			if err != nil {
+				log.Errorf("new location error: %v", err)
+				return err
+			}
+			skipSpaceCheck = true
+			summaryOutput, storageInfo, err = service.Backup(ctx, dryRun, progressCallback)
+			return err
+		})
+	})
 	if err != nil {
-		fmt.Printf("Backup error: %v\n", err)
+		log.Errorf("Backup error: %v, traceId: %s", err, utils.GetTraceId(ctx))
 	}
 	return summaryOutput, storageInfo, err
 }
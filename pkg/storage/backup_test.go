@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/options"
+)
+
+func TestDestination(t *testing.T) {
+	location, repo, err := (&BackupOption{Sftp: &options.SftpBackupOption{RepoName: "nas"}}).Destination()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, location, LocationSftp)
+	assert.Equal(t, repo, "nas")
+
+	location, repo, err = (&BackupOption{Repository: &options.RepositoryBackupOption{Repository: options.Repository{Location: "fs", RepoName: "photos"}}}).Destination()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, location, LocationFilesystem)
+	assert.Equal(t, repo, "photos")
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/options"
+)
+
+// errRateChanged ends a run of restic at a window boundary of the bandwidth schedule
+var errRateChanged = errors.New("bandwidth window changed")
+
+// errRateLimitChanged ends a run of restic to restart it with the new rate of its RateLimit
+var errRateLimitChanged = errors.New("upload rate limit changed")
+
+// clock is replaced by the tests
+var clock = time.Now
+
+// capRate returns a copy of the option with its rate field, LimitUploadRate or LimitDownloadRate, capped
+// in KiB/s, options without the field, like the local filesystem, are returned as they are
+func capRate(option options.Option, field string, limit string) (options.Option, error) {
+	rate, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %q", limit)
+	}
+	if !hasRate(option, field) {
+		return option, nil
+	}
+
+	var v = reflect.ValueOf(option)
+	var res = reflect.New(v.Elem().Type())
+	res.Elem().Set(v.Elem())
+	var f = res.Elem().FieldByName(field)
+	if current, err := strconv.ParseInt(f.String(), 10, 64); err == nil && current > 0 && current <= rate {
+		return option, nil
+	}
+	f.SetString(strconv.FormatInt(rate, 10))
+	return res.Interface().(options.Option), nil
+}
+
+// rateSchedule is the schedule when the option has the rate field, restarting restic for a location
+// that does not limit its rate, like the local filesystem, would only repeat work
+func rateSchedule(option options.Option, field string, schedule bandwidth.Schedule) bandwidth.Schedule {
+	if !hasRate(option, field) {
+		return nil
+	}
+	return schedule
+}
+
+func hasRate(option options.Option, field string) bool {
+	var v = reflect.ValueOf(option)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	var f = v.Elem().FieldByName(field)
+	return f.IsValid() && f.Kind() == reflect.String
+}
+
+// runScheduled calls run with the rate of the schedule, zero when it does not limit the rate.
+// restic reads its limit once, so run is canceled when the rate changes and called again with the
+// new rate. restic deduplicates, the new run skips the data the canceled one saved to the repository
+func runScheduled(ctx context.Context, schedule bandwidth.Schedule, run func(ctx context.Context, rate int64) error) error {
+	var log = logger.FromContext(ctx)
+	for {
+		var now = clock()
+		rate, next := schedule.At(now)
+		if next.IsZero() {
+			return run(ctx, rate)
+		}
+
+		runCtx, cancel := context.WithTimeoutCause(ctx, next.Sub(now), errRateChanged)
+		log.Infof("bandwidth schedule rate %d KiB/s until %s", rate, next.Format(time.RFC3339))
+		var err = run(runCtx, rate)
+		var changed = err != nil && ctx.Err() == nil && errors.Is(context.Cause(runCtx), errRateChanged)
+		cancel()
+		if !changed {
+			return err
+		}
+		log.Infof("bandwidth window changed, restarting restic: %v", err)
+	}
+}
+
+// RateLimit is a rate in KiB/s that may change while a backup runs, the backup restarts restic with the new rate.
+// A batch of backups sharing an upload budget raises the limit of the running ones when others finish
+type RateLimit struct {
+	mu      sync.Mutex
+	rate    int64
+	changed chan struct{}
+}
+
+func NewRateLimit(rate int64) *RateLimit {
+	return &RateLimit{rate: rate, changed: make(chan struct{})}
+}
+
+// Set changes the rate, a run with the previous rate is restarted
+func (l *RateLimit) Set(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate == l.rate {
+		return
+	}
+	l.rate = rate
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Get returns the rate and a channel that is closed when the rate changes
+func (l *RateLimit) Get() (int64, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.changed
+}
+
+// runLimited calls run with the rate of limit, zero when limit is nil, and restarts it when the rate changes.
+// Like with runScheduled the canceled run keeps the data it saved, the restart skips it
+func runLimited(ctx context.Context, limit *RateLimit, run func(ctx context.Context, rate int64) error) error {
+	if limit == nil {
+		return run(ctx, 0)
+	}
+	var log = logger.FromContext(ctx)
+	for {
+		rate, changed := limit.Get()
+		runCtx, cancel := context.WithCancelCause(ctx)
+		go func() {
+			select {
+			case <-changed:
+				cancel(errRateLimitChanged)
+			case <-runCtx.Done():
+			}
+		}()
+		var err = run(runCtx, rate)
+		var restart = err != nil && ctx.Err() == nil && errors.Is(context.Cause(runCtx), errRateLimitChanged)
+		cancel(nil)
+		if !restart {
+			return err
+		}
+		next, _ := limit.Get()
+		log.Infof("upload rate limit changed to %d KiB/s, restarting restic: %v", next, err)
+	}
+}
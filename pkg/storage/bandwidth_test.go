@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+func TestCapRate(t *testing.T) {
+	var tests = []struct {
+		name    string
+		current string
+		limit   string
+		want    string
+	}{
+		{name: "unlimited", current: "", limit: "512", want: "512"},
+		{name: "higher", current: "2048", limit: "512", want: "512"},
+		{name: "lower", current: "256", limit: "512", want: "256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o = &options.AwsBackupOption{RepoName: "home", LimitUploadRate: tt.current}
+			res, err := capRate(o, "LimitUploadRate", tt.limit)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, res.(*options.AwsBackupOption).LimitUploadRate, tt.want)
+			assert.Equal(t, res.(*options.AwsBackupOption).RepoName, "home")
+			assert.Equal(t, o.LimitUploadRate, tt.current)
+		})
+	}
+
+	var fs = &options.FilesystemBackupOption{RepoName: "home"}
+	res, err := capRate(fs, "LimitUploadRate", "512")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res, options.Option(fs))
+
+	_, err = capRate(fs, "LimitUploadRate", "fast")
+	assert.NotEqual(t, err, nil)
+
+	var restore = &options.AwsRestoreOption{LimitDownloadRate: "1024"}
+	res, err = capRate(restore, "LimitDownloadRate", "256")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, res.(*options.AwsRestoreOption).LimitDownloadRate, "256")
+}
+
+func TestRateSchedule(t *testing.T) {
+	schedule, err := bandwidth.ParseSchedule([]string{"* 09:00-18:00 1024"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rateSchedule(&options.AwsBackupOption{}, "LimitUploadRate", schedule), schedule)
+	assert.Equal(t, rateSchedule(&options.FilesystemBackupOption{}, "LimitUploadRate", schedule), bandwidth.Schedule(nil))
+}
+
+func TestRunScheduled(t *testing.T) {
+	schedule, err := bandwidth.ParseSchedule([]string{"* 09:00-18:00 1024"})
+	assert.Equal(t, err, nil)
+
+	// the first run starts just before the window, the second one in it
+	var times = []time.Time{
+		time.Date(2026, 10, 19, 8, 59, 59, 950_000_000, time.Local),
+		time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local),
+	}
+	var old = clock
+	clock = func() time.Time {
+		var t = times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return t
+	}
+	defer func() { clock = old }()
+
+	var rates []int64
+	err = runScheduled(context.Background(), schedule, func(ctx context.Context, rate int64) error {
+		rates = append(rates, rate)
+		if rate == 0 {
+			<-ctx.Done()
+			return errors.New("signal: terminated")
+		}
+		return nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rates, []int64{0, 1024})
+
+	// a failure that is not a window change is returned
+	times = []time.Time{time.Date(2026, 10, 19, 8, 0, 0, 0, time.Local)}
+	err = runScheduled(context.Background(), schedule, func(ctx context.Context, rate int64) error {
+		return errors.New("repository is locked")
+	})
+	assert.Equal(t, err.Error(), "repository is locked")
+
+	// so is the cancellation of the caller
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = runScheduled(ctx, schedule, func(ctx context.Context, rate int64) error {
+		return ctx.Err()
+	})
+	assert.Equal(t, err, context.Canceled)
+}
+
+// scheduledOption is the option of the scheduled-test backend, it limits both rates
+type scheduledOption struct {
+	RepoName          string
+	LimitUploadRate   string
+	LimitDownloadRate string
+}
+
+func (o *scheduledOption) AddFlags(cmd *cobra.Command) {}
+
+// scheduledLocation records whether each run checks the free space, the unlimited run waits for the window change
+type scheduledLocation struct {
+	Location
+	option *scheduledOption
+	params *LocationParams
+	checks *[]bool
+}
+
+func (l *scheduledLocation) run(ctx context.Context) error {
+	*l.checks = append(*l.checks, !l.params.SkipSpaceCheck)
+	if l.option.LimitUploadRate == "" && l.option.LimitDownloadRate == "" {
+		<-ctx.Done()
+		return errors.New("signal: terminated")
+	}
+	return nil
+}
+
+func (l *scheduledLocation) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
+	return &restic.SummaryOutput{}, &model.StorageInfo{}, l.run(ctx)
+}
+
+func (l *scheduledLocation) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	return nil, "", 0, l.run(ctx)
+}
+
+func TestScheduledSpaceCheck(t *testing.T) {
+	var checks []bool
+	_ = Register(&Backend{
+		Name:      "scheduled-test",
+		NewOption: func(op Operation) options.Option { return nil },
+		NewLocation: func(option options.Option, params *LocationParams) (Location, error) {
+			return &scheduledLocation{option: option.(*scheduledOption), params: params, checks: &checks}, nil
+		},
+	})
+	schedule, err := bandwidth.ParseSchedule([]string{"* 09:00-18:00 1024"})
+	assert.Equal(t, err, nil)
+
+	var times []time.Time
+	var old = clock
+	clock = func() time.Time {
+		var t = times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return t
+	}
+	defer func() { clock = old }()
+	var restart = func() {
+		times = []time.Time{
+			time.Date(2026, 10, 19, 8, 59, 59, 950_000_000, time.Local),
+			time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local),
+		}
+	}
+
+	var tests = []struct {
+		name  string
+		force bool
+		run   func(force bool) error
+		want  []bool
+	}{
+		{
+			name: "backup",
+			run: func(force bool) error {
+				_, _, err := NewBackupService(&BackupOption{Password: "secret", Location: "scheduled-test", LocationOption: &scheduledOption{RepoName: "home"},
+					UploadSchedule: schedule, Force: force}).BackupContext(context.Background(), false, nil)
+				return err
+			},
+			want: []bool{true, false},
+		},
+		{
+			name: "restore",
+			run: func(force bool) error {
+				_, _, _, err := NewRestoreService(&RestoreOption{Password: "secret", Location: "scheduled-test", LocationOption: &scheduledOption{RepoName: "home"},
+					DownloadSchedule: schedule, Force: force}).RestoreContext(context.Background(), nil)
+				return err
+			},
+			want: []bool{true, false},
+		},
+		{
+			name:  "forced restore",
+			force: true,
+			run: func(force bool) error {
+				_, _, _, err := NewRestoreService(&RestoreOption{Password: "secret", Location: "scheduled-test", LocationOption: &scheduledOption{RepoName: "home"},
+					DownloadSchedule: schedule, Force: force}).RestoreContext(context.Background(), nil)
+				return err
+			},
+			want: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks = nil
+			restart()
+			assert.Equal(t, tt.run(tt.force), nil)
+			// the restart in the window does not check again
+			assert.Equal(t, checks, tt.want)
+		})
+	}
+}
+
+func TestScheduledRestartKeepsData(t *testing.T) {
+	var calls = fakeRestic(t, "exec sleep 10")
+	schedule, err := bandwidth.ParseSchedule([]string{"* 09:00-18:00 1024"})
+	assert.Equal(t, err, nil)
+
+	var times = []time.Time{
+		time.Date(2026, 10, 19, 8, 59, 59, 900_000_000, time.Local),
+		time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local),
+	}
+	var old = clock
+	clock = func() time.Time {
+		var t = times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return t
+	}
+	defer func() { clock = old }()
+
+	// the run canceled at the window boundary does not prune what it uploaded
+	var h = &BaseHandler{opts: &restic.ResticOptions{RepoName: "home", RepoEnvs: &restic.ResticEnvs{}}}
+	var runs int
+	err = runScheduled(context.Background(), schedule, func(ctx context.Context, rate int64) error {
+		if runs++; runs > 1 {
+			return nil
+		}
+		_, err := h.Backup(ctx, false, func(percentDone float64) {})
+		return err
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, runs, 2)
+
+	data, err := os.ReadFile(calls)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Fields(string(data)), []string{"init", "backup"})
+}
+
+// limitedLocation records the rate of each run, the first run waits for its restart
+type limitedLocation struct {
+	Location
+	option  *scheduledOption
+	rates   *[]string
+	started chan struct{}
+}
+
+func (l *limitedLocation) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
+	*l.rates = append(*l.rates, l.option.LimitUploadRate)
+	if len(*l.rates) == 1 {
+		close(l.started)
+		<-ctx.Done()
+		return nil, nil, errors.New("signal: terminated")
+	}
+	return &restic.SummaryOutput{}, &model.StorageInfo{}, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	var rates []string
+	var started = make(chan struct{})
+	_ = Register(&Backend{
+		Name:      "limited-test",
+		NewOption: func(op Operation) options.Option { return nil },
+		NewLocation: func(option options.Option, params *LocationParams) (Location, error) {
+			return &limitedLocation{option: option.(*scheduledOption), rates: &rates, started: started}, nil
+		},
+	})
+	var store = history.NewStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	var limit = NewRateLimit(300)
+	go func() {
+		<-started
+		limit.Set(600)
+	}()
+
+	// the raised limit restarts restic inside the one backup, its own limit still applies
+	_, _, err := NewBackupService(&BackupOption{Password: "secret", Location: "limited-test", LocationOption: &scheduledOption{RepoName: "home", LimitUploadRate: "500"},
+		UploadRateLimit: limit, History: store}).BackupContext(context.Background(), false, nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rates, []string{"300", "500"})
+
+	records, err := store.List(nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0].Outcome, history.OutcomeSuccess)
+}
@@ -13,4 +13,7 @@ type Interface interface {
 	Snapshots(ctx context.Context) (*restic.SnapshotList, error)
 	GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error)
 	Stats(ctx context.Context) (*restic.StatsContainer, error)
+	Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error)
+	Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error)
+	Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error)
 }
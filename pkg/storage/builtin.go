@@ -0,0 +1,523 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/cos"
+	"olares.com/backups-sdk/pkg/storage/filesystem"
+	"olares.com/backups-sdk/pkg/storage/rest"
+	"olares.com/backups-sdk/pkg/storage/s3"
+	"olares.com/backups-sdk/pkg/storage/sftp"
+	"olares.com/backups-sdk/pkg/storage/space"
+	"olares.com/backups-sdk/pkg/storage/util"
+)
+
+const (
+	LocationSpace        = "space"
+	LocationAws          = "s3"
+	LocationTencentCloud = "cos"
+	LocationFilesystem   = "fs"
+	LocationSftp         = "sftp"
+	LocationRest         = "rest"
+)
+
+var (
+	_ Maintainer = &space.Space{}
+	_ Maintainer = &s3.Aws{}
+	_ Maintainer = &cos.TencentCloud{}
+	_ Maintainer = &filesystem.Filesystem{}
+	_ Maintainer = &sftp.Sftp{}
+	_ Maintainer = &rest.Rest{}
+
+	_ Lister = &space.Space{}
+	_ Lister = &s3.Aws{}
+	_ Lister = &cos.TencentCloud{}
+	_ Lister = &filesystem.Filesystem{}
+	_ Lister = &sftp.Sftp{}
+	_ Lister = &rest.Rest{}
+)
+
+func init() {
+	MustRegister(&Backend{
+		Name:        LocationSpace,
+		Description: "Space",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupSpaceOption()
+			case OperationRestore:
+				return options.NewRestoreSpaceOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsSpaceOption()
+			}
+			return nil
+		},
+		NewLocation:    newSpaceLocation,
+		FromRepository: spaceFromRepository,
+	})
+
+	MustRegister(&Backend{
+		Name:        LocationAws,
+		Description: "Amazon S3 or S3-compatible storage",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupAwsOption()
+			case OperationRestore:
+				return options.NewRestoreAwsOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsAwsOption()
+			}
+			return nil
+		},
+		NewLocation:    newAwsLocation,
+		FromRepository: awsFromRepository,
+	})
+
+	MustRegister(&Backend{
+		Name:        LocationTencentCloud,
+		Description: "Tencent Cloud Object Storage (COS)",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupTencentCloudOption()
+			case OperationRestore:
+				return options.NewRestoreTencentCloudOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsTencentCloudOption()
+			}
+			return nil
+		},
+		NewLocation:    newTencentCloudLocation,
+		FromRepository: tencentCloudFromRepository,
+	})
+
+	MustRegister(&Backend{
+		Name:        LocationFilesystem,
+		Description: "the local filesystem or disk",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupFilesystemOption()
+			case OperationRestore:
+				return options.NewRestoreFilesystemOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsFilesystemOption()
+			}
+			return nil
+		},
+		NewLocation:    newFilesystemLocation,
+		FromRepository: filesystemFromRepository,
+	})
+
+	MustRegister(&Backend{
+		Name:        LocationSftp,
+		Description: "a remote server over SFTP",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupSftpOption()
+			case OperationRestore:
+				return options.NewRestoreSftpOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsSftpOption()
+			}
+			return nil
+		},
+		NewLocation:    newSftpLocation,
+		FromRepository: sftpFromRepository,
+	})
+
+	MustRegister(&Backend{
+		Name:        LocationRest,
+		Description: "a restic REST server",
+		NewOption: func(op Operation) options.Option {
+			switch op {
+			case OperationBackup:
+				return options.NewBackupRestOption()
+			case OperationRestore:
+				return options.NewRestoreRestOption()
+			case OperationSnapshots, OperationStats, OperationForget, OperationCheck, OperationLs:
+				return options.NewSnapshotsRestOption()
+			}
+			return nil
+		},
+		NewLocation:    newRestLocation,
+		FromRepository: restFromRepository,
+	})
+}
+
+func unsupportedOption(name string, option options.Option) error {
+	return fmt.Errorf("location %s does not support option %T", name, option)
+}
+
+func newSpaceLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.SpaceBackupOption:
+		var classes = util.S3StorageClasses
+		if strings.EqualFold(o.CloudName, constants.CloudTencentName) {
+			classes = util.CosStorageClasses
+		}
+		if err := util.ValidateStorageClass(o.StorageClass, classes); err != nil {
+			return nil, err
+		}
+		return &space.Space{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			OlaresDid:                o.OlaresDid,
+			AccessToken:              o.AccessToken,
+			ClusterId:                o.ClusterId,
+			CloudName:                strings.ToLower(o.CloudName),
+			RegionId:                 strings.ToLower(o.RegionId),
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			CloudApiMirror:           o.CloudApiMirror,
+			LimitUploadRate:          o.LimitUploadRate,
+			StorageClass:             strings.ToUpper(o.StorageClass),
+			Password:                 params.Password,
+			StsToken:                 &space.StsToken{},
+			Operator:                 params.Operator,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.SpaceRestoreOption:
+		return &space.Space{
+			RepoId:   o.RepoId,
+			RepoName: o.RepoName,
+			// When restoring from BackupURL on a new machine, it is necessary to extract the Suffix from the Prefix of the backup in the BackupURL
+			RepoSuffix:        o.RepoSuffix,
+			SnapshotId:        o.SnapshotId,
+			Path:              o.Path,
+			OlaresDid:         o.OlaresDid,
+			AccessToken:       o.AccessToken,
+			ClusterId:         o.ClusterId,
+			CloudName:         strings.ToLower(o.CloudName),
+			RegionId:          strings.ToLower(o.RegionId),
+			CloudApiMirror:    o.CloudApiMirror,
+			Password:          params.Password,
+			LimitDownloadRate: o.LimitDownloadRate,
+			StsToken:          &space.StsToken{},
+			Operator:          params.Operator,
+			SkipSpaceCheck:    params.SkipSpaceCheck,
+			BackupType:        params.BackupType,
+		}, nil
+	case *options.SpaceSnapshotsOption:
+		return &space.Space{
+			RepoId:         o.RepoId,
+			RepoName:       o.RepoName,
+			OlaresDid:      o.OlaresDid,
+			AccessToken:    o.AccessToken,
+			ClusterId:      o.ClusterId,
+			CloudName:      strings.ToLower(o.CloudName),
+			RegionId:       strings.ToLower(o.RegionId),
+			CloudApiMirror: o.CloudApiMirror,
+			Password:       params.Password,
+			StsToken:       &space.StsToken{},
+			Operator:       params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationSpace, option)
+}
+
+func newAwsLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.AwsBackupOption:
+		if err := util.ValidateStorageClass(o.StorageClass, util.S3StorageClasses); err != nil {
+			return nil, err
+		}
+		return &s3.Aws{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			Endpoint:                 o.Endpoint,
+			AccessKey:                o.AccessKey,
+			SecretAccessKey:          o.SecretAccessKey,
+			SessionToken:             o.SessionToken,
+			Profile:                  o.Profile,
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			LimitUploadRate:          o.LimitUploadRate,
+			StorageClass:             strings.ToUpper(o.StorageClass),
+			Immutable:                o.Immutable,
+			RetentionDays:            o.RetentionDays,
+			Password:                 params.Password,
+			BaseHandler:              &BaseHandler{},
+			Operator:                 params.Operator,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.AwsRestoreOption:
+		return &s3.Aws{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			AccessKey:         o.AccessKey,
+			SecretAccessKey:   o.SecretAccessKey,
+			SessionToken:      o.SessionToken,
+			Profile:           o.Profile,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+			Password:          params.Password,
+			BaseHandler:       &BaseHandler{},
+			Operator:          params.Operator,
+			SkipSpaceCheck:    params.SkipSpaceCheck,
+			BackupType:        params.BackupType,
+		}, nil
+	case *options.AwsSnapshotsOption:
+		return &s3.Aws{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			AccessKey:       o.AccessKey,
+			SecretAccessKey: o.SecretAccessKey,
+			SessionToken:    o.SessionToken,
+			Profile:         o.Profile,
+			Password:        params.Password,
+			BaseHandler:     &BaseHandler{},
+			Operator:        params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationAws, option)
+}
+
+func newTencentCloudLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.TencentCloudBackupOption:
+		if err := util.ValidateStorageClass(o.StorageClass, util.CosStorageClasses); err != nil {
+			return nil, err
+		}
+		return &cos.TencentCloud{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			Endpoint:                 o.Endpoint,
+			AccessKey:                o.AccessKey,
+			SecretAccessKey:          o.SecretAccessKey,
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			LimitUploadRate:          o.LimitUploadRate,
+			StorageClass:             strings.ToUpper(o.StorageClass),
+			Password:                 params.Password,
+			BaseHandler:              &BaseHandler{},
+			Operator:                 params.Operator,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.TencentCloudRestoreOption:
+		return &cos.TencentCloud{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			AccessKey:         o.AccessKey,
+			SecretAccessKey:   o.SecretAccessKey,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+			Password:          params.Password,
+			BaseHandler:       &BaseHandler{},
+			Operator:          params.Operator,
+			SkipSpaceCheck:    params.SkipSpaceCheck,
+			BackupType:        params.BackupType,
+		}, nil
+	case *options.TencentCloudSnapshotsOption:
+		return &cos.TencentCloud{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			CloudName:       constants.CloudTencentName,
+			AccessKey:       o.AccessKey,
+			SecretAccessKey: o.SecretAccessKey,
+			Password:        params.Password,
+			BaseHandler:     &BaseHandler{},
+			Operator:        params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationTencentCloud, option)
+}
+
+func newFilesystemLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.FilesystemBackupOption:
+		return &filesystem.Filesystem{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			Endpoint:                 o.Endpoint,
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			Password:                 params.Password,
+			BaseHandler:              &BaseHandler{},
+			Operator:                 params.Operator,
+			SkipSpaceCheck:           params.SkipSpaceCheck,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.FilesystemRestoreOption:
+		return &filesystem.Filesystem{
+			RepoId:         o.RepoId,
+			RepoName:       o.RepoName,
+			SnapshotId:     o.SnapshotId,
+			Endpoint:       o.Endpoint,
+			Path:           o.Path,
+			Password:       params.Password,
+			BaseHandler:    &BaseHandler{},
+			Operator:       params.Operator,
+			SkipSpaceCheck: params.SkipSpaceCheck,
+			BackupType:     params.BackupType,
+		}, nil
+	case *options.FilesystemSnapshotsOption:
+		return &filesystem.Filesystem{
+			RepoId:      o.RepoId,
+			RepoName:    o.RepoName,
+			Endpoint:    o.Endpoint,
+			Password:    params.Password,
+			BaseHandler: &BaseHandler{},
+			Operator:    params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationFilesystem, option)
+}
+
+func newSftpLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.SftpBackupOption:
+		return &sftp.Sftp{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			Host:                     o.Host,
+			Port:                     o.Port,
+			User:                     o.User,
+			KeyFile:                  o.KeyFile,
+			KnownHosts:               o.KnownHosts,
+			RemotePath:               o.RemotePath,
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			LimitUploadRate:          o.LimitUploadRate,
+			Password:                 params.Password,
+			BaseHandler:              &BaseHandler{},
+			Operator:                 params.Operator,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.SftpRestoreOption:
+		return &sftp.Sftp{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Host:              o.Host,
+			Port:              o.Port,
+			User:              o.User,
+			KeyFile:           o.KeyFile,
+			KnownHosts:        o.KnownHosts,
+			RemotePath:        o.RemotePath,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+			Password:          params.Password,
+			BaseHandler:       &BaseHandler{},
+			Operator:          params.Operator,
+			SkipSpaceCheck:    params.SkipSpaceCheck,
+			BackupType:        params.BackupType,
+		}, nil
+	case *options.SftpSnapshotsOption:
+		return &sftp.Sftp{
+			RepoId:      o.RepoId,
+			RepoName:    o.RepoName,
+			Host:        o.Host,
+			Port:        o.Port,
+			User:        o.User,
+			KeyFile:     o.KeyFile,
+			KnownHosts:  o.KnownHosts,
+			RemotePath:  o.RemotePath,
+			Password:    params.Password,
+			BaseHandler: &BaseHandler{},
+			Operator:    params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationSftp, option)
+}
+
+func newRestLocation(option options.Option, params *LocationParams) (Location, error) {
+	switch o := option.(type) {
+	case *options.RestBackupOption:
+		return &rest.Rest{
+			RepoId:                   o.RepoId,
+			RepoName:                 o.RepoName,
+			Endpoint:                 o.Endpoint,
+			Username:                 o.Username,
+			RestPassword:             o.RestPassword,
+			CACert:                   o.CACert,
+			AppendOnly:               o.AppendOnly,
+			Path:                     o.Path,
+			Files:                    o.Files,
+			Excludes:                 o.Excludes,
+			FilesPrefixPath:          o.FilesPrefixPath,
+			Metadata:                 o.Metadata,
+			LimitUploadRate:          o.LimitUploadRate,
+			Password:                 params.Password,
+			BaseHandler:              &BaseHandler{},
+			Operator:                 params.Operator,
+			BackupType:               params.BackupType,
+			BackupAppTypeName:        params.BackupAppTypeName,
+			BackupFileTypeSourcePath: params.BackupFileTypeSourcePath,
+			BackupSetId:              params.BackupSetId,
+		}, nil
+	case *options.RestRestoreOption:
+		return &rest.Rest{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			Username:          o.Username,
+			RestPassword:      o.RestPassword,
+			CACert:            o.CACert,
+			AppendOnly:        o.AppendOnly,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+			Password:          params.Password,
+			BaseHandler:       &BaseHandler{},
+			Operator:          params.Operator,
+			SkipSpaceCheck:    params.SkipSpaceCheck,
+			BackupType:        params.BackupType,
+		}, nil
+	case *options.RestSnapshotsOption:
+		return &rest.Rest{
+			RepoId:       o.RepoId,
+			RepoName:     o.RepoName,
+			Endpoint:     o.Endpoint,
+			Username:     o.Username,
+			RestPassword: o.RestPassword,
+			CACert:       o.CACert,
+			AppendOnly:   o.AppendOnly,
+			Password:     params.Password,
+			BaseHandler:  &BaseHandler{},
+			Operator:     params.Operator,
+		}, nil
+	}
+	return nil, unsupportedOption(LocationRest, option)
+}
@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"olares.com/backups-sdk/pkg/options"
+)
+
+func unsupportedRepositoryOption(name string, option interface{}) error {
+	return fmt.Errorf("location %s does not support repository option %T", name, option)
+}
+
+func spaceFromRepository(option interface{}) (options.Option, error) {
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		return &options.SpaceBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			OlaresDid:       o.Param(options.ParamOlaresDid),
+			AccessToken:     o.GetCredentials().AccessToken,
+			ClusterId:       o.Param(options.ParamClusterId),
+			CloudName:       o.Param(options.ParamCloudName),
+			RegionId:        o.Param(options.ParamRegionId),
+			CloudApiMirror:  o.Param(options.ParamCloudApiMirror),
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+			LimitUploadRate: o.LimitUploadRate,
+			StorageClass:    o.StorageClass,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		return &options.SpaceRestoreOption{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			RepoSuffix:        o.Param(options.ParamRepoSuffix),
+			SnapshotId:        o.SnapshotId,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+			OlaresDid:         o.Param(options.ParamOlaresDid),
+			AccessToken:       o.GetCredentials().AccessToken,
+			ClusterId:         o.Param(options.ParamClusterId),
+			CloudName:         o.Param(options.ParamCloudName),
+			RegionId:          o.Param(options.ParamRegionId),
+			CloudApiMirror:    o.Param(options.ParamCloudApiMirror),
+		}, nil
+	case *options.RepositorySnapshotsOption:
+		return &options.SpaceSnapshotsOption{
+			RepoId:         o.RepoId,
+			RepoName:       o.RepoName,
+			OlaresDid:      o.Param(options.ParamOlaresDid),
+			AccessToken:    o.GetCredentials().AccessToken,
+			ClusterId:      o.Param(options.ParamClusterId),
+			CloudName:      o.Param(options.ParamCloudName),
+			RegionId:       o.Param(options.ParamRegionId),
+			CloudApiMirror: o.Param(options.ParamCloudApiMirror),
+		}, nil
+	}
+	return nil, unsupportedRepositoryOption(LocationSpace, option)
+}
+
+func awsFromRepository(option interface{}) (options.Option, error) {
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		var creds = o.GetCredentials()
+		return &options.AwsBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			AccessKey:       creds.AccessKey,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Profile:         creds.Profile,
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+			LimitUploadRate: o.LimitUploadRate,
+			StorageClass:    o.StorageClass,
+			Immutable:       o.Immutable,
+			RetentionDays:   o.RetentionDays,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		var creds = o.GetCredentials()
+		return &options.AwsRestoreOption{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			AccessKey:         creds.AccessKey,
+			SecretAccessKey:   creds.SecretAccessKey,
+			SessionToken:      creds.SessionToken,
+			Profile:           creds.Profile,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+		}, nil
+	case *options.RepositorySnapshotsOption:
+		var creds = o.GetCredentials()
+		return &options.AwsSnapshotsOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			AccessKey:       creds.AccessKey,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Profile:         creds.Profile,
+		}, nil
+	}
+	return nil, unsupportedRepositoryOption(LocationAws, option)
+}
+
+func tencentCloudFromRepository(option interface{}) (options.Option, error) {
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		var creds = o.GetCredentials()
+		return &options.TencentCloudBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			AccessKey:       creds.AccessKey,
+			SecretAccessKey: creds.SecretAccessKey,
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+			LimitUploadRate: o.LimitUploadRate,
+			StorageClass:    o.StorageClass,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		var creds = o.GetCredentials()
+		return &options.TencentCloudRestoreOption{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			AccessKey:         creds.AccessKey,
+			SecretAccessKey:   creds.SecretAccessKey,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+		}, nil
+	case *options.RepositorySnapshotsOption:
+		var creds = o.GetCredentials()
+		return &options.TencentCloudSnapshotsOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			AccessKey:       creds.AccessKey,
+			SecretAccessKey: creds.SecretAccessKey,
+		}, nil
+	}
+	return nil, unsupportedRepositoryOption(LocationTencentCloud, option)
+}
+
+func filesystemFromRepository(option interface{}) (options.Option, error) {
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		return &options.FilesystemBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		return &options.FilesystemRestoreOption{
+			RepoId:     o.RepoId,
+			RepoName:   o.RepoName,
+			SnapshotId: o.SnapshotId,
+			Endpoint:   o.Endpoint,
+			Path:       o.Path,
+		}, nil
+	case *options.RepositorySnapshotsOption:
+		return &options.FilesystemSnapshotsOption{
+			RepoId:   o.RepoId,
+			RepoName: o.RepoName,
+			Endpoint: o.Endpoint,
+		}, nil
+	}
+	return nil, unsupportedRepositoryOption(LocationFilesystem, option)
+}
+
+// sftpEndpoint splits sftp://{user}@{host}:{port}/{remote-path}
+type sftpEndpoint struct {
+	host       string
+	port       int
+	user       string
+	remotePath string
+}
+
+func parseSftpEndpoint(endpoint string) (*sftpEndpoint, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("sftp endpoint must look like sftp://user@host:port/path, got %s", endpoint)
+	}
+
+	var ep = &sftpEndpoint{
+		host:       u.Hostname(),
+		remotePath: u.Path,
+	}
+	if u.User != nil {
+		ep.user = u.User.Username()
+	}
+	if p := u.Port(); p != "" {
+		if ep.port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("sftp endpoint port %s is invalid", p)
+		}
+	}
+	return ep, nil
+}
+
+func sftpFromRepository(option interface{}) (options.Option, error) {
+	var repo *options.Repository
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		repo = &o.Repository
+	case *options.RepositoryRestoreOption:
+		repo = &o.Repository
+	case *options.RepositorySnapshotsOption:
+		repo = &o.Repository
+	default:
+		return nil, unsupportedRepositoryOption(LocationSftp, option)
+	}
+
+	ep, err := parseSftpEndpoint(repo.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var keyFile = repo.GetCredentials().KeyFile
+	var knownHosts = repo.Param(options.ParamKnownHosts)
+
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		return &options.SftpBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Host:            ep.host,
+			Port:            ep.port,
+			User:            ep.user,
+			KeyFile:         keyFile,
+			KnownHosts:      knownHosts,
+			RemotePath:      ep.remotePath,
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+			LimitUploadRate: o.LimitUploadRate,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		return &options.SftpRestoreOption{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Host:              ep.host,
+			Port:              ep.port,
+			User:              ep.user,
+			KeyFile:           keyFile,
+			KnownHosts:        knownHosts,
+			RemotePath:        ep.remotePath,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+		}, nil
+	}
+	return &options.SftpSnapshotsOption{
+		RepoId:     repo.RepoId,
+		RepoName:   repo.RepoName,
+		Host:       ep.host,
+		Port:       ep.port,
+		User:       ep.user,
+		KeyFile:    keyFile,
+		KnownHosts: knownHosts,
+		RemotePath: ep.remotePath,
+	}, nil
+}
+
+func restFromRepository(option interface{}) (options.Option, error) {
+	var repo *options.Repository
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		repo = &o.Repository
+	case *options.RepositoryRestoreOption:
+		repo = &o.Repository
+	case *options.RepositorySnapshotsOption:
+		repo = &o.Repository
+	default:
+		return nil, unsupportedRepositoryOption(LocationRest, option)
+	}
+
+	appendOnly, err := repo.BoolParam(options.ParamAppendOnly)
+	if err != nil {
+		return nil, err
+	}
+	var creds = repo.GetCredentials()
+	var cacert = repo.Param(options.ParamCACert)
+
+	switch o := option.(type) {
+	case *options.RepositoryBackupOption:
+		return &options.RestBackupOption{
+			RepoId:          o.RepoId,
+			RepoName:        o.RepoName,
+			Endpoint:        o.Endpoint,
+			Username:        creds.Username,
+			RestPassword:    creds.Password,
+			CACert:          cacert,
+			AppendOnly:      appendOnly,
+			Path:            o.Path,
+			Files:           o.Files,
+			Excludes:        o.Excludes,
+			FilesPrefixPath: o.FilesPrefixPath,
+			Metadata:        o.Metadata,
+			LimitUploadRate: o.LimitUploadRate,
+		}, nil
+	case *options.RepositoryRestoreOption:
+		return &options.RestRestoreOption{
+			RepoId:            o.RepoId,
+			RepoName:          o.RepoName,
+			SnapshotId:        o.SnapshotId,
+			Endpoint:          o.Endpoint,
+			Username:          creds.Username,
+			RestPassword:      creds.Password,
+			CACert:            cacert,
+			AppendOnly:        appendOnly,
+			Path:              o.Path,
+			LimitDownloadRate: o.LimitDownloadRate,
+		}, nil
+	}
+	return &options.RestSnapshotsOption{
+		RepoId:       repo.RepoId,
+		RepoName:     repo.RepoName,
+		Endpoint:     repo.Endpoint,
+		Username:     creds.Username,
+		RestPassword: creds.Password,
+		CACert:       cacert,
+		AppendOnly:   appendOnly,
+	}, nil
+}
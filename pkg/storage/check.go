@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/tracing"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type CheckService struct {
+	password string
+	option   *SnapshotsOption
+}
+
+func NewCheckService(option *SnapshotsOption) *CheckService {
+	return &CheckService{
+		password: option.Password,
+		option:   option,
+	}
+}
+
+// CheckContext verifies the repository, readDataSubset like 10% or 1/5 also reads that part of the data.
+// The summary holds the restic output, it is returned with the error when the repository has errors
+func (s *CheckService) CheckContext(ctx context.Context, readDataSubset string) (summary *restic.CheckSummary, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), s.option.Logger), s.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "check")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
+
+	var record = history.Start(history.OperationCheck, "", "", utils.GetTraceId(ctx))
+	defer func() {
+		recordJob(ctx, s.option.History, s.option.Notifier, record, err)
+	}()
+
+	service, err := maintainer(ctx, s.password, s.option, record, span, "check")
+	if err != nil {
+		return nil, err
+	}
+
+	if summary, err = service.Check(ctx, readDataSubset); err != nil {
+		log.Errorf("Check error: %v", err)
+		return summary, err
+	}
+	return summary, nil
+}
+
+// maintainer builds the location of a forget or check and fills the location of its record
+func maintainer(ctx context.Context, password string, option *SnapshotsOption, record *history.Record, span trace.Span, operation string) (Maintainer, error) {
+	var log = logger.FromContext(ctx)
+
+	password, err := resolvePassword(password, option.PasswordFile, option.PasswordCommand, option.SecretRefs, false)
+	if err != nil {
+		log.Errorf("get repository password error: %v", err)
+		return nil, err
+	}
+
+	name, locationOption, err := option.location()
+	if err != nil {
+		log.Errorf("resolve location error: %v", err)
+		return nil, err
+	}
+	record.Location, record.Repo = name, optionField(locationOption, "RepoName")
+	spanLocation(span, name, locationOption)
+	service, err := newLocation(name, locationOption, &LocationParams{
+		Password:   password,
+		Operator:   option.Operator,
+		SecretRefs: option.SecretRefs,
+	})
+	if err != nil {
+		log.Errorf("new location error: %v", err)
+		return nil, err
+	}
+
+	m, ok := service.(Maintainer)
+	if !ok {
+		return nil, fmt.Errorf("location %s does not support %s", name, operation)
+	}
+	return m, nil
+}
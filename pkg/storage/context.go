@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// timeouts of the deprecated methods that take no context
+const (
+	defaultQueryTimeout = 30 * time.Second
+	defaultLocksTimeout = 5 * time.Minute
+)
+
+var ErrNilContext = errors.New("context is required")
+
+// withTimeout bounds ctx by timeout, a zero timeout leaves the deadline to ctx
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// defaultTimeout is the timeout of a deprecated method, the option timeout wins when it is set
+func defaultTimeout(timeout, def time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return def
+}
+
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/options"
+)
+
+func TestContextMethods(t *testing.T) {
+	var nilCtx context.Context
+
+	_, _, err := NewBackupService(&BackupOption{Password: "secret"}).BackupContext(nilCtx, false, nil)
+	assert.Equal(t, err, ErrNilContext)
+	_, err = NewSnapshotsService(&SnapshotsOption{Password: "secret"}).SnapshotsContext(nilCtx)
+	assert.Equal(t, err, ErrNilContext)
+
+	// a missing location is an error, the process keeps running
+	_, _, err = NewBackupService(&BackupOption{Password: "secret"}).BackupContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+	_, _, _, err = NewRestoreService(&RestoreOption{Password: "secret"}).RestoreContext(context.Background(), nil)
+	assert.NotEqual(t, err, nil)
+	_, err = NewStatsService(&SnapshotsOption{Password: "secret"}).StatsContext(context.Background())
+	assert.NotEqual(t, err, nil)
+	_, err = NewLocksService(&SnapshotsOption{Password: "secret"}).LocksContext(context.Background())
+	assert.NotEqual(t, err, nil)
+	_, err = NewRegionService(&RegionOption{}).RegionsContext(context.Background())
+	assert.NotEqual(t, err, nil)
+
+	// the deprecated methods return the same errors
+	_, _, err = NewBackupService(&BackupOption{Password: "secret", Location: LocationFilesystem}).Backup(false, nil)
+	assert.NotEqual(t, err, nil)
+	_, err = NewSnapshotsService(&SnapshotsOption{Password: "secret", Filesystem: &options.FilesystemSnapshotsOption{}}).Snapshots()
+	assert.NotEqual(t, err, nil)
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 0)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.Equal(t, ok, false)
+
+	ctx, cancel = withTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.Equal(t, ok, true)
+
+	assert.Equal(t, defaultTimeout(0, defaultQueryTimeout), defaultQueryTimeout)
+	assert.Equal(t, defaultTimeout(time.Second, defaultQueryTimeout), time.Second)
+}
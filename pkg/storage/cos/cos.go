@@ -27,8 +27,10 @@ type TencentCloud struct {
 	RegionId                 string
 	LimitUploadRate          string
 	LimitDownloadRate        string
+	StorageClass             string
 	Path                     string
 	Files                    []string
+	Excludes                 []string
 	FilesPrefixPath          string
 	Metadata                 string
 	BaseHandler              base.Interface
@@ -36,9 +38,12 @@ type TencentCloud struct {
 	BackupType               string
 	BackupAppTypeName        string
 	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
 }
 
 func (c *TencentCloud) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err = c.FormatRepository()
 	if err != nil {
 		return
@@ -52,17 +57,20 @@ func (c *TencentCloud) Backup(ctx context.Context, dryRun bool, progressCallback
 		RegionId:                 c.RegionId,
 		Path:                     c.Path,
 		Files:                    c.Files,
+		Excludes:                 c.Excludes,
 		FilesPrefixPath:          c.FilesPrefixPath,
 		Metadata:                 c.Metadata,
 		LimitUploadRate:          c.LimitUploadRate,
+		StorageClass:             c.StorageClass,
 		Operator:                 c.Operator,
 		BackupType:               c.BackupType,
 		BackupAppTypeName:        c.BackupAppTypeName,
 		BackupFileTypeSourcePath: c.BackupFileTypeSourcePath,
+		BackupSetId:              c.BackupSetId,
 		RepoEnvs:                 envs,
 	}
 
-	logger.Debugf("cos backup env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("cos backup env vars: %s", envs.String())
 
 	c.BaseHandler.SetOptions(opts)
 
@@ -71,6 +79,7 @@ func (c *TencentCloud) Backup(ctx context.Context, dryRun bool, progressCallback
 }
 
 func (c *TencentCloud) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := c.FormatRepository()
 	if err != nil {
 		return nil, "", 0, err
@@ -86,15 +95,17 @@ func (c *TencentCloud) Restore(ctx context.Context, progressCallback func(percen
 		RepoEnvs:          envs,
 		Path:              c.Path,
 		LimitDownloadRate: c.LimitDownloadRate,
+		SkipSpaceCheck:    c.SkipSpaceCheck,
 	}
 
-	logger.Debugf("cos restore env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("cos restore env vars: %s", envs.String())
 
 	c.BaseHandler.SetOptions(opts)
 	return c.BaseHandler.Restore(ctx, progressCallback)
 }
 
 func (c *TencentCloud) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := c.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -109,13 +120,14 @@ func (c *TencentCloud) Snapshots(ctx context.Context) (*restic.SnapshotList, err
 		RepoEnvs:  envs,
 	}
 
-	logger.Debugf("cos snapshots env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("cos snapshots env vars: %s", envs.String())
 
 	c.BaseHandler.SetOptions(opts)
 	return c.BaseHandler.Snapshots(ctx)
 }
 
 func (c *TencentCloud) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := c.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -130,13 +142,14 @@ func (c *TencentCloud) GetSnapshot(ctx context.Context, snapshotId string) (*res
 		RepoEnvs:  envs,
 	}
 
-	logger.Debugf("cos snapshots env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("cos snapshots env vars: %s", envs.String())
 
 	c.BaseHandler.SetOptions(opts)
 	return c.BaseHandler.GetSnapshot(ctx, snapshotId)
 }
 
 func (c *TencentCloud) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := c.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -151,12 +164,78 @@ func (c *TencentCloud) Stats(ctx context.Context) (*restic.StatsContainer, error
 		RepoEnvs:  envs,
 	}
 
-	logger.Debugf("cos stats env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("cos stats env vars: %s", envs.String())
 
 	c.BaseHandler.SetOptions(opts)
 	return c.BaseHandler.Stats(ctx)
 }
 
+func (c *TencentCloud) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := c.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = c.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    c.RepoId,
+		RepoName:  c.RepoName,
+		CloudName: c.CloudName,
+		RegionId:  c.RegionId,
+		RepoEnvs:  envs,
+	}
+
+	log.Debugf("cos forget env vars: %s", envs.String())
+
+	c.BaseHandler.SetOptions(opts)
+	return c.BaseHandler.Forget(ctx, policy)
+}
+
+func (c *TencentCloud) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := c.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = c.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    c.RepoId,
+		RepoName:  c.RepoName,
+		CloudName: c.CloudName,
+		RegionId:  c.RegionId,
+		RepoEnvs:  envs,
+	}
+
+	log.Debugf("cos check env vars: %s", envs.String())
+
+	c.BaseHandler.SetOptions(opts)
+	return c.BaseHandler.Check(ctx, readDataSubset)
+}
+
+func (c *TencentCloud) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := c.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = c.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    c.RepoId,
+		RepoName:  c.RepoName,
+		CloudName: c.CloudName,
+		RegionId:  c.RegionId,
+		RepoEnvs:  envs,
+	}
+
+	log.Debugf("cos ls env vars: %s", envs.String())
+
+	c.BaseHandler.SetOptions(opts)
+	return c.BaseHandler.Ls(ctx, snapshotId, path, recursive)
+}
+
 func (c *TencentCloud) Regions() ([]map[string]string, error) {
 	return nil, nil
 }
@@ -231,12 +310,13 @@ func (c *TencentCloud) FormatRepository() (storageInfo *model.StorageInfo, err e
 	c.RegionId = region
 
 	storageInfo = &model.StorageInfo{
-		Location:  constants.CloudTencentName,
-		Url:       repository,
-		CloudName: constants.CloudTencentName,
-		RegionId:  region,
-		Bucket:    bucket,
-		Prefix:    prefix,
+		Location:     constants.CloudTencentName,
+		Url:          repository,
+		CloudName:    constants.CloudTencentName,
+		RegionId:     region,
+		Bucket:       bucket,
+		Prefix:       prefix,
+		StorageClass: c.StorageClass,
 	}
 
 	return
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage/model"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// FanOutPolicy decides when a backup to several targets fails
+type FanOutPolicy string
+
+const (
+	FailAny FanOutPolicy = "fail-any" // one failed target fails the backup, the default
+	FailAll FanOutPolicy = "fail-all" // the backup fails only when every target failed
+)
+
+// BackupTarget is one more location of a fan-out backup, set one of the location fields like in BackupOption.
+// A target without a password, password file or password command shares the password of the backup option,
+// a Repository without Path and Files backs up its source
+type BackupTarget struct {
+	Password        string
+	PasswordFile    string
+	PasswordCommand string
+
+	Space        *options.SpaceBackupOption
+	Aws          *options.AwsBackupOption
+	TencentCloud *options.TencentCloudBackupOption
+	Filesystem   *options.FilesystemBackupOption
+	Sftp         *options.SftpBackupOption
+	Rest         *options.RestBackupOption
+	Repository   *options.RepositoryBackupOption
+
+	Location       string
+	LocationOption options.Option
+}
+
+// TargetSummary is the result of the backup to one target
+type TargetSummary struct {
+	Location    string                `json:"location"`
+	Repo        string                `json:"repo"`
+	SnapshotId  string                `json:"snapshot_id,omitempty"`
+	Summary     *restic.SummaryOutput `json:"summary,omitempty"`
+	StorageInfo *model.StorageInfo    `json:"storage_info,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	Err         error                 `json:"-"`
+}
+
+// FanOutSummary is the result of a fan-out backup, the targets are in the order they were given
+type FanOutSummary struct {
+	BackupSetId string           `json:"backup_set_id"`
+	Succeeded   int              `json:"succeeded"`
+	Failed      int              `json:"failed"`
+	Targets     []*TargetSummary `json:"targets"`
+}
+
+// targets are the backup option of every target, the location of the option itself comes first when it has one
+func (b *BackupService) targets(backupSetId string) ([]*BackupOption, error) {
+	var primary = *b.option
+	primary.Targets, primary.BackupSetId = nil, backupSetId
+
+	var res []*BackupOption
+	name, option, err := primary.location()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" || option != nil {
+		res = append(res, &primary)
+	}
+	for i, t := range b.option.Targets {
+		if t == nil {
+			return nil, fmt.Errorf("target %d is nil", i)
+		}
+		var o = primary
+		o.Space, o.Aws, o.TencentCloud, o.Filesystem, o.Sftp, o.Rest = t.Space, t.Aws, t.TencentCloud, t.Filesystem, t.Sftp, t.Rest
+		o.Location, o.LocationOption, o.Repository = t.Location, t.LocationOption, t.Repository
+		if t.Password != "" || t.PasswordFile != "" || t.PasswordCommand != "" {
+			o.Password, o.PasswordFile, o.PasswordCommand = t.Password, t.PasswordFile, t.PasswordCommand
+		}
+		if t.Repository != nil && t.Repository.Path == "" && len(t.Repository.Files) == 0 && option != nil {
+			var repo = *t.Repository
+			repo.Path, repo.Files = optionField(option, "Path"), optionStrings(option, "Files")
+			if len(repo.Excludes) == 0 {
+				repo.Excludes = optionStrings(option, "Excludes")
+			}
+			o.Repository = &repo
+		}
+		res = append(res, &o)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("the backup has no target")
+	}
+	return res, nil
+}
+
+func optionStrings(option options.Option, name string) []string {
+	var v = reflect.ValueOf(option)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var f = v.Elem().FieldByName(name)
+	if !f.IsValid() || f.Type() != reflect.TypeOf([]string(nil)) {
+		return nil
+	}
+	return f.Interface().([]string)
+}
+
+// FanOutContext backs up the same source to the location of the option and to its Targets, one after
+// another when Sequential is set and in parallel otherwise. Every snapshot is tagged backup-set=<id> with
+// BackupSetId, a new id when it is empty. A failed target does not stop the others, Policy decides whether
+// the returned error reports it. Each target is recorded in the history like a single backup
+func (b *BackupService) FanOutContext(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (*FanOutSummary, error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	ctx = logger.NewContext(utils.WithTraceId(ctx), b.option.Logger)
+	var log = logger.FromContext(ctx)
+
+	switch b.option.Policy {
+	case "", FailAny, FailAll:
+	default:
+		return nil, fmt.Errorf("unknown fan-out policy %q", b.option.Policy)
+	}
+	var backupSetId = b.option.BackupSetId
+	if backupSetId == "" {
+		backupSetId = utils.NewUUID()
+	}
+	if strings.ContainsAny(backupSetId, ", \t\n") {
+		return nil, fmt.Errorf("invalid backup set id %q", backupSetId)
+	}
+	targets, err := b.targets(backupSetId)
+	if err != nil {
+		return nil, err
+	}
+	// resolved before the targets start and once per source, the parallel targets would prompt on the same terminal
+	type passwordSource struct{ password, file, command string }
+	var passwords = make([]string, len(targets))
+	var resolved = make(map[passwordSource]string)
+	for i, o := range targets {
+		var source = passwordSource{o.Password, o.PasswordFile, o.PasswordCommand}
+		if _, ok := resolved[source]; !ok {
+			password, err := resolvePassword(source.password, source.file, source.command, b.option.SecretRefs, true)
+			if err != nil {
+				log.Errorf("get repository password error: %v", err)
+				return nil, err
+			}
+			resolved[source] = password
+		}
+		passwords[i] = resolved[source]
+	}
+
+	var summary = &FanOutSummary{BackupSetId: backupSetId, Targets: make([]*TargetSummary, len(targets))}
+	var mu sync.Mutex
+	var percents = make([]float64, len(targets))
+	var run = func(i int) {
+		var t = &TargetSummary{}
+		t.Location, t.Repo, _ = targets[i].Destination()
+		var progress = func(percentDone float64) {
+			mu.Lock()
+			defer mu.Unlock()
+			percents[i] = percentDone
+			var total float64
+			for _, p := range percents {
+				total += p
+			}
+			if progressCallback != nil {
+				progressCallback(total / float64(len(percents)))
+			}
+		}
+		var service = &BackupService{password: passwords[i], option: targets[i], resolved: true}
+		t.Summary, t.StorageInfo, t.Err = service.BackupContext(ctx, dryRun, progress)
+		if t.Summary != nil {
+			t.SnapshotId = t.Summary.SnapshotID
+		}
+		if t.Err != nil {
+			t.Error = redact.String(t.Err.Error())
+		}
+		summary.Targets[i] = t
+	}
+
+	if b.option.Sequential {
+		for i := range targets {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range targets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	var failed []string
+	for _, t := range summary.Targets {
+		if t.Err != nil {
+			summary.Failed++
+			failed = append(failed, fmt.Sprintf("%s repo %s: %v", t.Location, t.Repo, t.Err))
+			continue
+		}
+		summary.Succeeded++
+		log.Infof("backup set %s, %s repo %s snapshot %s", backupSetId, t.Location, t.Repo, t.SnapshotId)
+	}
+	if summary.Failed > 0 {
+		log.Errorf("backup set %s, %d of %d targets failed", backupSetId, summary.Failed, len(targets))
+	}
+	if summary.Failed > 0 && (b.option.Policy != FailAll || summary.Succeeded == 0) {
+		return summary, fmt.Errorf("backup set %s: %s", backupSetId, strings.Join(failed, "; "))
+	}
+	return summary, nil
+}
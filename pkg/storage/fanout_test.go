@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+// fanOutOption is the option of the fanout-test backend, a repo named "broken" fails
+type fanOutOption struct {
+	RepoName string
+	Path     string
+	Files    []string
+}
+
+func (o *fanOutOption) AddFlags(cmd *cobra.Command) {}
+
+type fanOutLocation struct {
+	Location
+	repo   string
+	params *LocationParams
+}
+
+var fanOutBackups sync.Map // repo name -> *LocationParams
+
+func (l *fanOutLocation) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (*restic.SummaryOutput, *model.StorageInfo, error) {
+	fanOutBackups.Store(l.repo, l.params)
+	progressCallback(1)
+	if l.repo == "broken" {
+		return nil, nil, errors.New("repository is locked")
+	}
+	return &restic.SummaryOutput{SnapshotID: "snap-" + l.repo}, &model.StorageInfo{}, nil
+}
+
+var registerFanOut sync.Once
+
+func fanOutTarget(repo string) *BackupTarget {
+	registerFanOut.Do(func() {
+		// the backend offers no options, so that it is not listed with the backup commands
+		_ = Register(&Backend{
+			Name:      "fanout-test",
+			NewOption: func(op Operation) options.Option { return nil },
+			NewLocation: func(option options.Option, params *LocationParams) (Location, error) {
+				return &fanOutLocation{repo: option.(*fanOutOption).RepoName, params: params}, nil
+			},
+		})
+	})
+	return &BackupTarget{Location: "fanout-test", LocationOption: &fanOutOption{RepoName: repo, Path: "/data"}}
+}
+
+func TestFanOut(t *testing.T) {
+	var tests = []struct {
+		name       string
+		repos      []string
+		policy     FanOutPolicy
+		sequential bool
+		failed     int
+		err        bool
+	}{
+		{name: "parallel", repos: []string{"space", "disk"}, failed: 0},
+		{name: "sequential", repos: []string{"space", "disk"}, sequential: true, failed: 0},
+		{name: "fail any", repos: []string{"space", "broken"}, failed: 1, err: true},
+		{name: "fail all with a success", repos: []string{"space", "broken"}, policy: FailAll, failed: 1},
+		{name: "fail all", repos: []string{"broken"}, policy: FailAll, failed: 1, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primary = fanOutTarget(tt.repos[0])
+			var option = &BackupOption{Password: "secret", Location: primary.Location, LocationOption: primary.LocationOption,
+				Policy: tt.policy, Sequential: tt.sequential}
+			for _, repo := range tt.repos[1:] {
+				option.Targets = append(option.Targets, fanOutTarget(repo))
+			}
+
+			var mu sync.Mutex
+			var last float64
+			summary, err := NewBackupService(option).FanOutContext(context.Background(), false, func(percentDone float64) {
+				mu.Lock()
+				last = percentDone
+				mu.Unlock()
+			})
+			assert.Equal(t, err != nil, tt.err)
+			assert.Equal(t, summary.Failed, tt.failed)
+			assert.Equal(t, summary.Succeeded, len(tt.repos)-tt.failed)
+			assert.Equal(t, last, 1.0)
+			assert.NotEqual(t, summary.BackupSetId, "")
+			for i, repo := range tt.repos {
+				var target = summary.Targets[i]
+				assert.Equal(t, target.Repo, repo)
+				if repo == "broken" {
+					assert.Equal(t, target.Error, "repository is locked")
+					continue
+				}
+				assert.Equal(t, target.SnapshotId, "snap-"+repo)
+				params, _ := fanOutBackups.Load(repo)
+				assert.Equal(t, params.(*LocationParams).BackupSetId, summary.BackupSetId)
+			}
+		})
+	}
+}
+
+func TestFanOutTargets(t *testing.T) {
+	var fs = &options.FilesystemBackupOption{RepoName: "home", Path: "/data", Excludes: []string{"*.tmp"}}
+	var option = &BackupOption{Filesystem: fs, Targets: []*BackupTarget{
+		{Repository: &options.RepositoryBackupOption{Repository: options.Repository{Location: LocationSftp, RepoName: "home", Endpoint: "backup@nas"}}},
+		{Repository: &options.RepositoryBackupOption{Repository: options.Repository{Location: LocationAws, RepoName: "home"}, Path: "/other"}},
+	}}
+	targets, err := NewBackupService(option).targets("set")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(targets), 3)
+	assert.Equal(t, targets[0].Filesystem, fs)
+	assert.Equal(t, targets[1].Filesystem, (*options.FilesystemBackupOption)(nil))
+	assert.Equal(t, targets[1].Repository.Path, "/data")
+	assert.Equal(t, targets[1].Repository.Excludes, []string{"*.tmp"})
+	assert.Equal(t, targets[2].Repository.Path, "/other")
+	assert.Equal(t, option.Targets[0].Repository.Path, "")
+	for _, target := range targets {
+		assert.Equal(t, target.BackupSetId, "set")
+		assert.Equal(t, len(target.Targets), 0)
+	}
+
+	_, _, err = NewBackupService(option).BackupContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+	_, err = NewBackupService(&BackupOption{}).FanOutContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+	_, err = NewBackupService(&BackupOption{Filesystem: fs, Policy: "fail-some"}).FanOutContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+	_, err = NewBackupService(&BackupOption{Filesystem: fs, BackupSetId: "a,b"}).FanOutContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+}
+
+func TestFanOutPassword(t *testing.T) {
+	// the command counts its runs
+	var calls = filepath.Join(t.TempDir(), "calls")
+	var option = &BackupOption{PasswordCommand: fmt.Sprintf("echo run >> %s; echo fanout-secret", calls)}
+	var primary = fanOutTarget("password-a")
+	option.Location, option.LocationOption = primary.Location, primary.LocationOption
+	// a target with its own password file does not share the password
+	var file = filepath.Join(t.TempDir(), "password")
+	assert.Equal(t, os.WriteFile(file, []byte("target-secret\n"), 0600), nil)
+	var own = fanOutTarget("password-d")
+	own.PasswordFile = file
+	option.Targets = []*BackupTarget{fanOutTarget("password-b"), fanOutTarget("password-c"), own}
+
+	_, err := NewBackupService(option).FanOutContext(context.Background(), false, nil)
+	assert.Equal(t, err, nil)
+	data, err := os.ReadFile(calls)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Count(string(data), "run"), 1)
+	for repo, password := range map[string]string{"password-a": "fanout-secret", "password-b": "fanout-secret", "password-c": "fanout-secret", "password-d": "target-secret"} {
+		params, _ := fanOutBackups.Load(repo)
+		assert.Equal(t, params.(*LocationParams).Password, password)
+	}
+}
@@ -25,6 +25,7 @@ type Filesystem struct {
 	Password                 string
 	Path                     string
 	Files                    []string
+	Excludes                 []string
 	FilesPrefixPath          string
 	Metadata                 string
 	BaseHandler              base.Interface
@@ -32,9 +33,12 @@ type Filesystem struct {
 	BackupType               string
 	BackupAppTypeName        string
 	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
 }
 
 func (f *Filesystem) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err = f.FormatRepository()
 	if err != nil {
 		return
@@ -46,22 +50,25 @@ func (f *Filesystem) Backup(ctx context.Context, dryRun bool, progressCallback f
 		RepoName:                 f.RepoName,
 		Path:                     f.Path,
 		Files:                    f.Files,
+		Excludes:                 f.Excludes,
 		FilesPrefixPath:          f.FilesPrefixPath,
 		Metadata:                 f.Metadata,
 		Operator:                 f.Operator,
 		BackupType:               f.BackupType,
 		BackupAppTypeName:        f.BackupAppTypeName,
 		BackupFileTypeSourcePath: f.BackupFileTypeSourcePath,
+		BackupSetId:              f.BackupSetId,
 		LocalEndpoint:            f.Endpoint,
+		SkipSpaceCheck:           f.SkipSpaceCheck,
 		RepoEnvs:                 envs,
 	}
 
-	logger.Debugf("fs backup env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("fs backup env vars: %s", envs.String())
 
 	f.BaseHandler.SetOptions(opts)
 	backupSummary, err = f.BaseHandler.Backup(ctx, dryRun, progressCallback)
 	if err = utils.Chmod(storageInfo.Url); err != nil {
-		logger.Warnf("fs backup chmod error: %v, path: %s", err, storageInfo.Url)
+		log.Warnf("fs backup chmod error: %v, path: %s", err, storageInfo.Url)
 	}
 
 	if err != nil {
@@ -71,9 +78,9 @@ func (f *Filesystem) Backup(ctx context.Context, dryRun bool, progressCallback f
 		} else if files != nil && len(files) > 0 {
 			for _, fn := range files {
 				if deleterr := utils.DeleteFile(fn); deleterr != nil {
-					logger.Errorf("fs backup delete tmp file error: %v", deleterr)
+					log.Errorf("fs backup delete tmp file error: %v", deleterr)
 				} else {
-					logger.Debugf("fs backup delete tmp file successful: %s", fn)
+					log.Debugf("fs backup delete tmp file successful: %s", fn)
 				}
 			}
 		}
@@ -83,26 +90,29 @@ func (f *Filesystem) Backup(ctx context.Context, dryRun bool, progressCallback f
 }
 
 func (f *Filesystem) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := f.FormatRepository()
 	if err != nil {
 		return nil, "", 0, err
 	}
 	var envs = f.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
-		RepoId:     f.RepoId,
-		RepoName:   f.RepoName,
-		SnapshotId: f.SnapshotId,
-		RepoEnvs:   envs,
-		Path:       f.Path,
+		RepoId:         f.RepoId,
+		RepoName:       f.RepoName,
+		SnapshotId:     f.SnapshotId,
+		RepoEnvs:       envs,
+		Path:           f.Path,
+		SkipSpaceCheck: f.SkipSpaceCheck,
 	}
 
-	logger.Debugf("fs restore env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("fs restore env vars: %s", envs.String())
 
 	f.BaseHandler.SetOptions(opts)
 	return f.BaseHandler.Restore(ctx, progressCallback)
 }
 
 func (f *Filesystem) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := f.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -115,13 +125,14 @@ func (f *Filesystem) Snapshots(ctx context.Context) (*restic.SnapshotList, error
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("fs snapshots env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("fs snapshots env vars: %s", envs.String())
 
 	f.BaseHandler.SetOptions(opts)
 	return f.BaseHandler.Snapshots(ctx)
 }
 
 func (f *Filesystem) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := f.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -134,13 +145,14 @@ func (f *Filesystem) GetSnapshot(ctx context.Context, snapshotId string) (*resti
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("fs snapshot env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("fs snapshot env vars: %s", envs.String())
 
 	f.BaseHandler.SetOptions(opts)
 	return f.BaseHandler.GetSnapshot(ctx, snapshotId)
 }
 
 func (f *Filesystem) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := f.FormatRepository()
 	if err != nil {
 		return nil, err
@@ -153,12 +165,72 @@ func (f *Filesystem) Stats(ctx context.Context) (*restic.StatsContainer, error)
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("fs stats env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("fs stats env vars: %s", envs.String())
 
 	f.BaseHandler.SetOptions(opts)
 	return f.BaseHandler.Stats(ctx)
 }
 
+func (f *Filesystem) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := f.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = f.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:   f.RepoId,
+		RepoName: f.RepoName,
+		RepoEnvs: envs,
+	}
+
+	log.Debugf("fs forget env vars: %s", envs.String())
+
+	f.BaseHandler.SetOptions(opts)
+	return f.BaseHandler.Forget(ctx, policy)
+}
+
+func (f *Filesystem) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := f.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = f.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:   f.RepoId,
+		RepoName: f.RepoName,
+		RepoEnvs: envs,
+	}
+
+	log.Debugf("fs check env vars: %s", envs.String())
+
+	f.BaseHandler.SetOptions(opts)
+	return f.BaseHandler.Check(ctx, readDataSubset)
+}
+
+func (f *Filesystem) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := f.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = f.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:   f.RepoId,
+		RepoName: f.RepoName,
+		RepoEnvs: envs,
+	}
+
+	log.Debugf("fs ls env vars: %s", envs.String())
+
+	f.BaseHandler.SetOptions(opts)
+	return f.BaseHandler.Ls(ctx, snapshotId, path, recursive)
+}
+
 func (f *Filesystem) Regions() ([]map[string]string, error) {
 	return nil, nil
 }
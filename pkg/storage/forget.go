@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/tracing"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type ForgetService struct {
+	password string
+	option   *SnapshotsOption
+}
+
+func NewForgetService(option *SnapshotsOption) *ForgetService {
+	return &ForgetService{
+		password: option.Password,
+		option:   option,
+	}
+}
+
+// ForgetContext applies the retention policy to the repository, the location must be a Maintainer
+func (s *ForgetService) ForgetContext(ctx context.Context, policy *restic.ForgetPolicy) (summary *restic.ForgetSummary, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("retention policy is required")
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), s.option.Logger), s.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "forget")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
+
+	var record = history.Start(history.OperationForget, "", "", utils.GetTraceId(ctx))
+	defer func() {
+		recordJob(ctx, s.option.History, s.option.Notifier, record, err)
+	}()
+
+	service, err := maintainer(ctx, s.password, s.option, record, span, "forget")
+	if err != nil {
+		return nil, err
+	}
+
+	if summary, err = service.Forget(ctx, policy); err != nil {
+		log.Errorf("Forget error: %v", err)
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/metrics"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/notification"
+)
+
+// recordJob finishes the record with err, observes its metrics, appends it to store and notifies it,
+// a nil store records no history. A history or a notification that fails never fails the job
+func recordJob(ctx context.Context, store *history.Store, notifier *notification.Notifier, record *history.Record, err error) {
+	record.Finish(err)
+	metrics.ObserveJob(&metrics.Job{
+		Operation:    string(record.Operation),
+		Location:     record.Location,
+		Repo:         record.Repo,
+		Outcome:      string(record.Outcome),
+		Category:     string(record.ErrorCategory),
+		FinishedAt:   record.FinishedAt,
+		Duration:     record.FinishedAt.Sub(record.StartedAt),
+		DryRun:       record.DryRun,
+		Bytes:        record.Bytes,
+		BytesAdded:   record.BytesAdded,
+		FilesNew:     record.FilesNew,
+		FilesChanged: record.FilesChanged,
+	})
+	if store != nil {
+		if e := store.Append(record); e != nil {
+			logger.FromContext(ctx).Warnf("record %s history error: %v", record.Operation, e)
+		}
+	}
+	if e := notifier.Notify(ctx, record); e != nil {
+		logger.FromContext(ctx).Warnf("notify %s error: %v", record.Operation, e)
+	}
+}
+
+// optionField reads a string field of a location option, every builtin option has RepoName
+func optionField(option options.Option, name string) string {
+	var v = reflect.ValueOf(option)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	var f = v.Elem().FieldByName(name)
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/options"
+)
+
+func TestRecordJob(t *testing.T) {
+	var store = history.NewStore(path.Join(t.TempDir(), "history.jsonl"))
+
+	// the password command fails before restic runs, the failure is still recorded
+	_, _, err := NewBackupService(&BackupOption{PasswordCommand: "exit 1", History: store}).BackupContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err = NewRestoreService(&RestoreOption{
+		Password:   "secret",
+		Filesystem: &options.FilesystemRestoreOption{RepoName: "home", SnapshotId: "abcd1234"},
+		History:    store,
+	}).RestoreContext(ctx, nil)
+	assert.NotEqual(t, err, nil)
+
+	records, err := store.List(nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(records), 2)
+
+	backup, err := store.Last(&history.Filter{Operation: history.OperationBackup})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, backup.Outcome, history.OutcomeFailed)
+	assert.NotEqual(t, backup.TraceId, "")
+
+	restore, err := store.Last(&history.Filter{Operation: history.OperationRestore})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, restore.Location, LocationFilesystem)
+	assert.Equal(t, restore.Repo, "home")
+	assert.Equal(t, restore.SnapshotId, "abcd1234")
+	assert.Equal(t, restore.Outcome == history.OutcomeSuccess, false)
+}
+
+func TestOptionField(t *testing.T) {
+	assert.Equal(t, optionField(&options.AwsBackupOption{RepoName: "home"}, "RepoName"), "home")
+	assert.Equal(t, optionField(&options.AwsBackupOption{}, "Missing"), "")
+	assert.Equal(t, optionField(nil, "RepoName"), "")
+}
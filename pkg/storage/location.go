@@ -26,6 +26,18 @@ type Location interface {
 	FormatRepository() (storageInfo *model.StorageInfo, err error)
 }
 
+// Maintainer is a Location that applies retention policies and checks its repository,
+// the builtin locations implement it, a registered one may not
+type Maintainer interface {
+	Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error)
+	Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error)
+}
+
+// Lister is a Location that lists the files of its snapshots, the builtin locations implement it
+type Lister interface {
+	Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error)
+}
+
 var _ base.Interface = &BaseHandler{}
 
 type BaseHandler struct {
@@ -38,7 +50,8 @@ func (d *BaseHandler) SetOptions(opts *restic.ResticOptions) {
 }
 
 func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, err error) {
-	var traceId = ctx.Value(constants.TraceId).(string)
+	var log = logger.FromContext(ctx)
+	var traceId = utils.GetTraceId(ctx)
 	var repoName = d.opts.RepoName
 	var tags = d.getTags()
 
@@ -52,14 +65,14 @@ func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback
 
 	// backupType = constants.FullyBackup
 
-	logger.Infof("initializing repo %s, traceId: %s", repoName, traceId)
+	log.Infof("initializing repo %s, traceId: %s", repoName, traceId)
 	initResult, err = r.Init()
 
 	if err != nil {
 		if err.Error() == restic.MESSAGE_REPOSITORY_ALREADY_INITIALIZED {
 			initialized = true
 		} else {
-			logger.Errorf("initializing repo %s, traceId: %s, error: %v", repoName, traceId, err)
+			log.Errorf("initializing repo %s, traceId: %s, error: %v", repoName, traceId, err)
 			return
 		}
 	}
@@ -72,16 +85,16 @@ func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback
 	// }
 
 	if initialized {
-		logger.Infof("repo %s already initialized, traceId: %s, repairing index", repoName, traceId)
+		log.Infof("repo %s already initialized, traceId: %s, repairing index", repoName, traceId)
 		if err = r.Repair(); err != nil {
-			logger.Errorf("repo %s repair error: %v", repoName, err)
+			log.Errorf("repo %s repair error: %v", repoName, err)
 			return
 		}
 	} else {
-		logger.Infof("repo %s initialized, traceId: %s\n\n%s", repoName, traceId, initResult)
+		log.Infof("repo %s initialized, traceId: %s\n\n%s", repoName, traceId, initResult)
 	}
 
-	logger.Infof("preparing to start repo %s backup, traceId: %s", repoName, traceId)
+	log.Infof("preparing to start repo %s backup, traceId: %s", repoName, traceId)
 
 	var progressChan = make(chan float64, 100)
 	defer close(progressChan)
@@ -99,11 +112,28 @@ func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback
 		}
 	}()
 
+	if d.opts.LocalEndpoint != "" && !dryRun && !d.opts.SkipSpaceCheck {
+		if err = d.checkBackupSpace(r, tags, traceId); err != nil {
+			log.Errorf("repo %s pre-flight space check error: %v, traceId: %s", repoName, err, traceId)
+			return
+		}
+	}
+
 	backupSummary, err = r.Backup(d.opts.Path, d.opts.Files, "", tags, traceId, dryRun, progressChan)
 	if err != nil {
 		err = errors.WithStack(err)
+		// a canceled run is restarted or given up, the packs it uploaded are kept for the next run to reuse
+		if ctx.Err() != nil {
+			log.Infof("repo %s backup canceled: %v, skip rollback, traceId: %s", repoName, context.Cause(ctx), traceId)
+			return
+		}
+		// so are the packs of a run whose credentials expired, it is retried and a prune would fail too
+		if restic.IsTokenExpired(err) {
+			log.Infof("repo %s credentials expired, skip rollback, traceId: %s", repoName, traceId)
+			return
+		}
 		if e := r.Rollback(); e != nil {
-			logger.Errorf("rollbackup error: %v, traceId: %s", e, traceId)
+			log.Errorf("rollbackup error: %v, traceId: %s", e, traceId)
 		}
 		// if e := r.Rollback(); e != nil {
 		// 	err = errors.Wrap(err, e.Error())
@@ -120,7 +150,7 @@ func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback
 	// if backupType == constants.FullyBackup {
 	// 	var snapshots *restic.SnapshotList
 	// 	shortId := backupSummary.SnapshotID[:8]
-	// 	logger.Infof("reset tag, name: %s, snapshot: %s, type: %s", repoName, shortId, backupType)
+	// 	log.Infof("reset tag, name: %s, snapshot: %s, type: %s", repoName, shortId, backupType)
 	// 	snapshots, err = r.GetSnapshots(nil)
 	// 	if err == nil && snapshots != nil && snapshots.Len() > 0 {
 	// 		firstBackup := snapshots.First()
@@ -132,18 +162,43 @@ func (d *BaseHandler) Backup(ctx context.Context, dryRun bool, progressCallback
 	// 			fmt.Sprintf("type=%s", currentBackupType),
 	// 		}
 	// 		if err = r.Tag(backupSummary.SnapshotID, resetTags); err != nil {
-	// 			logger.Errorf("set tag %s error :%v", shortId, err)
+	// 			log.Errorf("set tag %s error :%v", shortId, err)
 	// 			return
 	// 		}
 	// 	}
 	// }
 
-	logger.Infof("Backup successful, result: %s, traceId: %s", utils.ToJSON(backupSummary), traceId)
+	log.Infof("Backup successful, result: %s, traceId: %s", utils.ToJSON(backupSummary), traceId)
 
 	return
 }
 
+// checkBackupSpace checks that the growth of a local repository fits on its filesystem. The size of the
+// source is an upper bound that only needs a walk, the slower dry run estimating the data the backup adds
+// before compression is only run when that bound does not fit
+func (d *BaseHandler) checkBackupSpace(r *restic.Restic, tags []string, traceId string) error {
+	if size, err := util.SourceSize(d.opts.Path, d.opts.Files); err == nil {
+		if util.CheckFreeSpace(map[string]uint64{d.opts.LocalEndpoint: size}) == nil {
+			return nil
+		}
+	}
+
+	var progressChan = make(chan float64, 100)
+	go func() {
+		for range progressChan {
+		}
+	}()
+	defer close(progressChan)
+
+	estimate, err := r.Backup(d.opts.Path, d.opts.Files, "", tags, traceId, true, progressChan)
+	if err != nil {
+		return fmt.Errorf("estimate backup size error: %v", err)
+	}
+	return util.CheckFreeSpace(map[string]uint64{d.opts.LocalEndpoint: estimate.DataAdded})
+}
+
 func (h *BaseHandler) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
 	var snapshotId = h.opts.SnapshotId
 	var restoreTargetPath = h.opts.Path
 	var restoreSummarys = make(map[string]*restic.RestoreSummaryOutput)
@@ -151,7 +206,7 @@ func (h *BaseHandler) Restore(ctx context.Context, progressCallback func(percent
 	var totalBytes, totalBytesTmp uint64
 	var err error
 
-	logger.Debugf("restore env vars: %s, snapshotId: %s", utils.Base64encode([]byte(h.opts.RepoEnvs.String())), snapshotId)
+	log.Debugf("restore env vars: %s, snapshotId: %s", h.opts.RepoEnvs.String(), snapshotId)
 
 	var re *restic.Restic
 	re, err = restic.NewRestic(ctx, h.opts)
@@ -164,19 +219,30 @@ func (h *BaseHandler) Restore(ctx context.Context, progressCallback func(percent
 		return nil, metadata, totalBytes, err
 	}
 
-	logger.Infof("restore spanshot: %s, paths: %d, tags: %v, summary %s", snapshotSummary.Id, len(snapshotSummary.Paths), snapshotSummary.Tags, utils.ToJSON(snapshotSummary.Summary))
+	log.Infof("restore spanshot: %s, paths: %d, tags: %v, summary %s", snapshotSummary.Id, len(snapshotSummary.Paths), snapshotSummary.Tags, utils.ToJSON(snapshotSummary.Summary))
 
 	var uploadPaths []string
 	var backupMetadata = util.GetMetadata(snapshotSummary.Tags)
 	var backupType = util.GetBackupType(snapshotSummary.Tags)
 
-	logger.Infof("restore spanshot: %s, backupType: %s, paths: %d, tags: %v, summary %s", snapshotSummary.Id, backupType, len(snapshotSummary.Paths), snapshotSummary.Tags, utils.ToJSON(snapshotSummary.Summary))
+	log.Infof("restore spanshot: %s, backupType: %s, paths: %d, tags: %v, summary %s", snapshotSummary.Id, backupType, len(snapshotSummary.Paths), snapshotSummary.Tags, utils.ToJSON(snapshotSummary.Summary))
+
+	if storageClass := util.GetStorageClass(snapshotSummary.Tags); util.IsArchiveStorageClass(storageClass) {
+		log.Warnf("restore spanshot: %s, data was uploaded with archive storage class %s, objects must be rehydrated before restore", snapshotSummary.Id, storageClass)
+	}
 
 	uploadPaths, _ = util.GetFilesPrefixPath(snapshotSummary.Tags)
 	if uploadPaths == nil || len(uploadPaths) == 0 {
 		uploadPaths = append(uploadPaths, snapshotSummary.Paths[0])
 	}
 
+	if !h.opts.SkipSpaceCheck {
+		if err = util.CheckRestoreSpace(re, snapshotId, backupType, restoreTargetPath, uploadPaths); err != nil {
+			log.Errorf("restore %s snapshot %s, pre-flight space check error: %v", h.opts.RepoName, snapshotId, err)
+			return nil, metadata, totalBytes, err
+		}
+	}
+
 	var progressChan = make(chan float64, 100)
 	defer close(progressChan)
 	go func() {
@@ -202,7 +268,7 @@ func (h *BaseHandler) Restore(ctx context.Context, progressCallback func(percent
 		}
 		rs, err = re.Restore(phase, len(uploadPaths), snapshotId, backupTrimPath, targetPath, progressChan)
 		if err != nil {
-			logger.Errorf("restore %s snapshot %s, backupType: %s, subfolder: %s, error: %v", h.opts.RepoName, h.opts.SnapshotId, backupType, uploadPath, err)
+			log.Errorf("restore %s snapshot %s, backupType: %s, subfolder: %s, error: %v", h.opts.RepoName, h.opts.SnapshotId, backupType, uploadPath, err)
 			break
 		}
 		if rs != nil {
@@ -220,17 +286,18 @@ func (h *BaseHandler) Restore(ctx context.Context, progressCallback func(percent
 
 	// restoreSummary, err = re.Restore(snapshotId, uploadPath, path, progressChan)
 	// if err != nil {
-	// 	logger.Errorf("restore %s snapshot %s error: %v", h.opts.RepoName, h.opts.SnapshotId, err)
+	// 	log.Errorf("restore %s snapshot %s error: %v", h.opts.RepoName, h.opts.SnapshotId, err)
 	// 	return
 	// }
 
-	logger.Infof("Restore successful, name: %s, result: %s", h.opts.RepoName, utils.ToJSON(restoreSummarys))
+	log.Infof("Restore successful, name: %s, result: %s", h.opts.RepoName, utils.ToJSON(restoreSummarys))
 
 	return restoreSummarys, metadata, totalBytes, nil
 }
 
 func (h *BaseHandler) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
-	logger.Debugf("snapshot env vars: %s", utils.Base64encode([]byte(h.opts.RepoEnvs.String())))
+	var log = logger.FromContext(ctx)
+	log.Debugf("snapshot env vars: %s", h.opts.RepoEnvs.String())
 
 	r, err := restic.NewRestic(ctx, h.opts)
 	if err != nil {
@@ -249,7 +316,8 @@ func (h *BaseHandler) GetSnapshot(ctx context.Context, snapshotId string) (*rest
 }
 
 func (h *BaseHandler) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
-	logger.Debugf("snapshots env vars: %s", utils.Base64encode([]byte(h.opts.RepoEnvs.String())))
+	var log = logger.FromContext(ctx)
+	log.Debugf("snapshots env vars: %s", h.opts.RepoEnvs.String())
 
 	r, err := restic.NewRestic(ctx, h.opts)
 	if err != nil {
@@ -268,7 +336,8 @@ func (h *BaseHandler) Snapshots(ctx context.Context) (*restic.SnapshotList, erro
 }
 
 func (h *BaseHandler) Stats(ctx context.Context) (*restic.StatsContainer, error) {
-	logger.Debugf("stats env vars: %s", utils.Base64encode([]byte(h.opts.RepoEnvs.String())))
+	var log = logger.FromContext(ctx)
+	log.Debugf("stats env vars: %s", h.opts.RepoEnvs.String())
 
 	r, err := restic.NewRestic(ctx, h.opts)
 	if err != nil {
@@ -282,6 +351,61 @@ func (h *BaseHandler) Stats(ctx context.Context) (*restic.StatsContainer, error)
 	return stats, nil
 }
 
+func (h *BaseHandler) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	log.Debugf("forget env vars: %s", h.opts.RepoEnvs.String())
+
+	r, err := restic.NewRestic(ctx, h.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := r.Forget(policy)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Forget successful, name: %s, kept: %d, removed: %d, pruned: %v", h.opts.RepoName, summary.Kept, summary.Removed, summary.Pruned)
+	return summary, nil
+}
+
+func (h *BaseHandler) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	log.Debugf("check env vars: %s", h.opts.RepoEnvs.String())
+
+	r, err := restic.NewRestic(ctx, h.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// restic keeps the metadata in the default storage class, reading the data touches the archived packs
+	if readDataSubset != "" {
+		if snapshots, err := r.GetSnapshots(nil); err == nil && snapshots != nil {
+			if storageClass := util.ArchiveStorageClass(*snapshots); storageClass != "" {
+				log.Warnf("check %s, data was uploaded with archive storage class %s, reading it fails until the objects are rehydrated", h.opts.RepoName, storageClass)
+			}
+		}
+	}
+
+	summary, err := r.Check(readDataSubset)
+	if err != nil {
+		return summary, err
+	}
+	log.Infof("Check successful, name: %s", h.opts.RepoName)
+	return summary, nil
+}
+
+func (h *BaseHandler) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	log.Debugf("ls env vars: %s", h.opts.RepoEnvs.String())
+
+	r, err := restic.NewRestic(ctx, h.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Ls(snapshotId, path, recursive)
+}
+
 func (h *BaseHandler) getTags() []string {
 	var tags = []string{
 		fmt.Sprintf("repo-name=%s", utils.Base64encode([]byte(h.opts.RepoName))),
@@ -312,5 +436,13 @@ func (h *BaseHandler) getTags() []string {
 		tags = append(tags, fmt.Sprintf("metadata=%s", utils.Base64encode([]byte(h.opts.Metadata))))
 	}
 
+	if h.opts.StorageClass != "" {
+		tags = append(tags, fmt.Sprintf("storage-class=%s", h.opts.StorageClass))
+	}
+
+	if h.opts.BackupSetId != "" {
+		tags = append(tags, fmt.Sprintf("backup-set=%s", h.opts.BackupSetId))
+	}
+
 	return tags
 }
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/restic"
+)
+
+// fakeRestic puts a restic on PATH that logs its commands to the returned file, a backup runs the backup script
+func fakeRestic(t *testing.T, backup string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake restic is a shell script")
+	}
+	var dir = t.TempDir()
+	var calls = filepath.Join(dir, "calls")
+	var script = fmt.Sprintf("#!/bin/sh\necho \"$1\" >> %s\nif [ \"$1\" = backup ]; then %s; fi\n", calls, backup)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "restic"), []byte(script), 0755), nil)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+func TestBackupRollback(t *testing.T) {
+	var tests = []struct {
+		name   string
+		backup string
+		calls  []string
+	}{
+		{name: "failed", backup: "echo 'Fatal: unable to save snapshot'; exit 1", calls: []string{"init", "backup", "prune"}},
+		{name: "token expired", backup: "echo 'Fatal: The provided token has expired'; exit 1", calls: []string{"init", "backup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls = fakeRestic(t, tt.backup)
+			var h = &BaseHandler{opts: &restic.ResticOptions{RepoName: "home", RepoEnvs: &restic.ResticEnvs{}}}
+			_, err := h.Backup(context.Background(), false, func(percentDone float64) {})
+			assert.NotEqual(t, err, nil)
+
+			data, err := os.ReadFile(calls)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, strings.Fields(string(data)), tt.calls)
+		})
+	}
+}
+
+func TestBackupSpaceCheck(t *testing.T) {
+	var calls = fakeRestic(t, `echo '{"message_type":"summary","snapshot_id":"0123456789abcdef"}'`)
+	var source = t.TempDir()
+	assert.Equal(t, os.WriteFile(filepath.Join(source, "a"), []byte("data"), 0644), nil)
+
+	// a source that fits is backed up without the dry run estimating its growth
+	var h = &BaseHandler{opts: &restic.ResticOptions{RepoName: "home", Path: source, LocalEndpoint: t.TempDir(), RepoEnvs: &restic.ResticEnvs{}}}
+	_, err := h.Backup(context.Background(), false, func(percentDone float64) {})
+	assert.Equal(t, err, nil)
+
+	data, err := os.ReadFile(calls)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Fields(string(data)), []string{"init", "backup", "stats"})
+}
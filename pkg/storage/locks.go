@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/s3"
+	"olares.com/backups-sdk/pkg/tracing"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type LocksService struct {
+	password string
+	option   *SnapshotsOption
+}
+
+func NewLocksService(option *SnapshotsOption) *LocksService {
+	return &LocksService{
+		password: option.Password,
+		option:   option,
+	}
+}
+
+// Deprecated: use LocksContext
+func (s *LocksService) Locks() (s3.SnapshotLocks, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout(s.option.Timeout, defaultLocksTimeout))
+	defer cancel()
+
+	return s.LocksContext(ctx)
+}
+
+// LocksContext reports the object lock expiry of each snapshot, only S3 repositories support object lock
+func (s *LocksService) LocksContext(ctx context.Context) (locks s3.SnapshotLocks, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), s.option.Logger), s.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "locks")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
+
+	password, err := resolvePassword(s.password, s.option.PasswordFile, s.option.PasswordCommand, s.option.SecretRefs, false)
+	if err != nil {
+		log.Errorf("get repository password error: %v", err)
+		return nil, err
+	}
+
+	if s.option.Aws == nil {
+		return nil, errors.New("Object lock is only supported by S3 locations.")
+	}
+
+	var aws = s.option.Aws
+	if s.option.SecretRefs {
+		option, err := options.ResolveSecrets(aws)
+		if err != nil {
+			log.Errorf("resolve secrets error: %v", err)
+			return nil, err
+		}
+		aws = option.(*options.AwsSnapshotsOption)
+	}
+	spanLocation(span, LocationAws, aws)
+
+	var service = &s3.Aws{
+		RepoId:          aws.RepoId,
+		RepoName:        aws.RepoName,
+		Endpoint:        aws.Endpoint,
+		AccessKey:       aws.AccessKey,
+		SecretAccessKey: aws.SecretAccessKey,
+		SessionToken:    aws.SessionToken,
+		Profile:         aws.Profile,
+		Password:        password,
+		BaseHandler:     &BaseHandler{},
+	}
+
+	locks, err = service.Locks(ctx)
+	if err != nil {
+		log.Errorf("get snapshot locks error: %v", err)
+		return nil, err
+	}
+
+	if s.option.Operator == constants.StorageOperatorCli {
+		locks.PrintTable()
+	}
+
+	return locks, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/tracing"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type LsService struct {
+	password string
+	option   *SnapshotsOption
+}
+
+func NewLsService(option *SnapshotsOption) *LsService {
+	return &LsService{
+		password: option.Password,
+		option:   option,
+	}
+}
+
+// LsContext lists the files of SnapshotsOption.SnapshotId under path, the root when empty,
+// recursive also lists the subdirectories. The location must be a Lister
+func (s *LsService) LsContext(ctx context.Context, path string, recursive bool) (nodes []*restic.LsNode, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	if s.option.SnapshotId == "" {
+		return nil, fmt.Errorf("snapshot id is required")
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), s.option.Logger), s.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "ls")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
+
+	password, err := resolvePassword(s.password, s.option.PasswordFile, s.option.PasswordCommand, s.option.SecretRefs, false)
+	if err != nil {
+		log.Errorf("get repository password error: %v", err)
+		return nil, err
+	}
+
+	name, option, err := s.option.location()
+	if err != nil {
+		log.Errorf("resolve location error: %v", err)
+		return nil, err
+	}
+	spanLocation(span, name, option)
+	service, err := newLocation(name, option, &LocationParams{
+		Password:   password,
+		Operator:   s.option.Operator,
+		SecretRefs: s.option.SecretRefs,
+	})
+	if err != nil {
+		log.Errorf("new location error: %v", err)
+		return nil, err
+	}
+
+	lister, ok := service.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("location %s does not support ls", name)
+	}
+	if nodes, err = lister.Ls(ctx, s.option.SnapshotId, path, recursive); err != nil {
+		log.Errorf("Ls error: %v", err)
+		return nil, err
+	}
+	return nodes, nil
+}
@@ -1,10 +1,13 @@
 package model
 
 type StorageInfo struct {
-	Location  string `json:"location"`
-	Url       string `json:"url"`
-	CloudName string `json:"cloud_name"`
-	RegionId  string `json:"region_id"`
-	Bucket    string `json:"bucket"`
-	Prefix    string `json:"prefix"`
+	Location     string `json:"location"`
+	Url          string `json:"url"`
+	CloudName    string `json:"cloud_name"`
+	RegionId     string `json:"region_id"`
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix"`
+	Partition    string `json:"partition,omitempty"`
+	Endpoint     string `json:"endpoint,omitempty"`
+	StorageClass string `json:"storage_class,omitempty"`
 }
@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"context"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// Cloud saves successful backups as snapshot records of the Olares cloud, other events are skipped.
+// With an outbox the record is queued first and survives an unreachable cloud
+type Cloud struct {
+	ChannelName string
+	CloudApiUrl string // constants.DefaultCloudApiUrl when empty
+	UserId      string // the olares did
+	Token       string
+	Outbox      *Outbox
+}
+
+func (c *Cloud) Name() string {
+	return c.ChannelName
+}
+
+func (c *Cloud) Send(ctx context.Context, subject string, e *Event) error {
+	if e.Type != EventBackupSuccess {
+		return nil
+	}
+	var snapshot = &Snapshot{
+		UserId:       c.UserId,
+		Token:        c.Token,
+		BackupId:     e.RepoId,
+		SnapshotId:   e.SnapshotId,
+		Size:         e.Bytes,
+		Uint:         "byte",
+		SnapshotTime: e.FinishedAt.UnixMilli(),
+		Status:       constants.BackupComplete,
+		CloudName:    e.Location,
+		Message:      e.Summary,
+	}
+	if e.Storage != nil {
+		snapshot.Url, snapshot.RegionId, snapshot.Bucket, snapshot.Prefix = e.Storage.Url, e.Storage.RegionId, e.Storage.Bucket, e.Storage.Prefix
+		snapshot.CloudName = utils.DefaultValue(e.Location, e.Storage.CloudName)
+	}
+	if c.Outbox == nil {
+		return SendNewSnapshotContext(ctx, utils.DefaultValue(constants.DefaultCloudApiUrl, c.CloudApiUrl), snapshot)
+	}
+
+	if _, err := c.Outbox.EnqueueSnapshot(c.CloudApiUrl, snapshot); err != nil {
+		return err
+	}
+	// the record is safe on disk, a failed delivery is retried by the next flush
+	_, err := c.Outbox.Flush(ctx, false)
+	return err
+}
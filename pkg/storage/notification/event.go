@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"olares.com/backups-sdk/pkg/history"
+)
+
+// EventType is what a rule subscribes to
+type EventType string
+
+const (
+	EventBackupSuccess  EventType = "backup_success"
+	EventBackupFailure  EventType = "backup_failure"
+	EventRestoreSuccess EventType = "restore_success"
+	EventRestoreFailure EventType = "restore_failure"
+	EventCheckSuccess   EventType = "check_success"
+	EventCheckFailure   EventType = "check_failure"
+	EventRetention      EventType = "retention"
+)
+
+var eventTypes = []EventType{EventBackupSuccess, EventBackupFailure, EventRestoreSuccess, EventRestoreFailure,
+	EventCheckSuccess, EventCheckFailure, EventRetention}
+
+// Event is a finished job, it is the json payload of webhooks and the data of templates
+type Event struct {
+	Type EventType `json:"type"`
+	Host string    `json:"host,omitempty"`
+	history.Record
+	Summary string `json:"summary,omitempty"`
+}
+
+// EventTypeOf maps a finished job to its event, a canceled job is a failure
+func EventTypeOf(r *history.Record) EventType {
+	var success = r.Outcome == history.OutcomeSuccess
+	switch r.Operation {
+	case history.OperationBackup:
+		if success {
+			return EventBackupSuccess
+		}
+		return EventBackupFailure
+	case history.OperationRestore:
+		if success {
+			return EventRestoreSuccess
+		}
+		return EventRestoreFailure
+	case history.OperationCheck:
+		if success {
+			return EventCheckSuccess
+		}
+		return EventCheckFailure
+	case history.OperationForget:
+		return EventRetention
+	}
+	return ""
+}
+
+func validEventType(t EventType) bool {
+	for _, v := range eventTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
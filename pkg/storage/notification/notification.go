@@ -7,6 +7,7 @@ import (
 	"github.com/emicklei/go-restful/v3"
 	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
@@ -22,6 +23,7 @@ type Backup struct {
 
 type Snapshot struct {
 	UserId       string
+	Token        string
 	BackupId     string
 	SnapshotId   string
 	Size         uint64
@@ -42,16 +44,21 @@ type Response struct {
 	Message string `json:"message"`
 }
 
+// Deprecated: use SendNewBackupContext, or Outbox to keep the record when the cloud is unreachable
 func SendNewBackup(cloudApiUrl string, backup *Backup) error {
+	return SendNewBackupContext(context.Background(), cloudApiUrl, backup)
+}
+
+func SendNewBackupContext(ctx context.Context, cloudApiUrl string, backup *Backup) error {
 	var url = fmt.Sprintf("%s%s", cloudApiUrl, constants.SendBackupUrl)
 	var headers = make(map[string]string)
 	headers[restful.HEADER_ContentType] = "application/x-www-form-urlencoded"
 	var data = fmt.Sprintf("userid=%s&token=%s&backupId=%s&name=%s&backupPath=%s&backupLocation=%s&status=%s",
 		backup.UserId, backup.Token, backup.BackupId, backup.Name, backup.BackupPath, backup.BackupLocation, backup.Status)
 
-	logger.Infof("send backup data: %s", data)
+	logger.FromContext(ctx).Infof("send backup data: %s", redact.String(data))
 
-	result, err := utils.Post[Response](context.Background(), url, headers, data)
+	result, err := utils.Post[Response](ctx, url, headers, data)
 	if err != nil {
 		return err
 	}
@@ -62,20 +69,26 @@ func SendNewBackup(cloudApiUrl string, backup *Backup) error {
 	return nil
 }
 
+// Deprecated: use SendNewSnapshotContext, or Outbox to keep the record when the cloud is unreachable
 func SendNewSnapshot(cloudApiUrl string, snapshot *Snapshot) error {
+	return SendNewSnapshotContext(context.Background(), cloudApiUrl, snapshot)
+}
+
+func SendNewSnapshotContext(ctx context.Context, cloudApiUrl string, snapshot *Snapshot) error {
 	var url = fmt.Sprintf("%s%s", cloudApiUrl, constants.SendSnapshotUrl)
 	var headers = make(map[string]string)
 	headers[restful.HEADER_ContentType] = "application/x-www-form-urlencoded"
 
-	var data = fmt.Sprintf("userid=%s&backupId=%s&snapshotId=%s&size=%d&unit=%s&snapshotTime=%d&status=%s&type=%s&url=%s&cloud=%s&region=%s&bucket=%s&prefix=%s&message=%s", snapshot.UserId, snapshot.BackupId,
+	var data = fmt.Sprintf("userid=%s&token=%s&backupId=%s&snapshotId=%s&size=%d&unit=%s&snapshotTime=%d&status=%s&type=%s&url=%s&cloud=%s&region=%s&bucket=%s&prefix=%s&message=%s", snapshot.UserId, snapshot.Token, snapshot.BackupId,
 		snapshot.SnapshotId, snapshot.Size, snapshot.Uint,
 		snapshot.SnapshotTime, snapshot.Status, snapshot.Type,
 		snapshot.Url, snapshot.CloudName, snapshot.RegionId,
 		snapshot.Bucket, snapshot.Prefix, snapshot.Message)
 
-	logger.Infof("send snapshot data: %s", data)
+	redact.Register(snapshot.Token)
+	logger.FromContext(ctx).Infof("send snapshot data: %s", redact.String(data))
 
-	result, err := utils.Post[Response](context.Background(), url, headers, data)
+	result, err := utils.Post[Response](ctx, url, headers, data)
 	if err != nil {
 		return err
 	}
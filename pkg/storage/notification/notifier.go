@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+const (
+	DefaultSubject  = `[backups] {{.Operation}} of {{.Repo}} {{.Outcome}}`
+	DefaultTemplate = `{{.Operation}} of {{.Repo}} ({{.Location}}) on {{.Host}}: {{.Outcome}}
+{{- if .Error}}
+error ({{.ErrorCategory}}): {{.Error}}
+{{- end}}
+{{- if .SnapshotId}}
+snapshot: {{.SnapshotId}}
+{{- end}}
+started: {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}, duration: {{seconds .Duration}}
+processed: {{bytes .Bytes}}, added: {{bytes .BytesAdded}}, files: {{.Files}}
+trace id: {{.TraceId}}`
+)
+
+var templateFuncs = template.FuncMap{
+	"bytes": utils.FormatBytes,
+	"seconds": func(s float64) string {
+		return (time.Duration(s * float64(time.Second))).Round(time.Second).String()
+	},
+}
+
+// Channel delivers a notification, subject is empty for channels without one
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, subject string, e *Event) error
+}
+
+// Rule sends the events it lists to its channels, the templates render the subject and the summary
+type Rule struct {
+	Events   []EventType
+	Channels []string
+	Subject  string // text/template of Event, DefaultSubject when empty
+	Template string // text/template of Event, DefaultTemplate when empty
+}
+
+type rule struct {
+	events   map[EventType]bool
+	channels []Channel
+	subject  *template.Template
+	summary  *template.Template
+}
+
+// Notifier routes the finished jobs to the channels of the matching rules
+type Notifier struct {
+	rules   []*rule
+	timeout time.Duration
+}
+
+// NewNotifier checks that every rule names known events and channels and that its templates parse
+func NewNotifier(channels []Channel, rules []*Rule) (*Notifier, error) {
+	var byName = make(map[string]Channel, len(channels))
+	for _, c := range channels {
+		if _, ok := byName[c.Name()]; ok {
+			return nil, fmt.Errorf("channel %s is defined twice", c.Name())
+		}
+		byName[c.Name()] = c
+	}
+
+	var n = &Notifier{timeout: 30 * time.Second}
+	for i, r := range rules {
+		if len(r.Events) == 0 || len(r.Channels) == 0 {
+			return nil, fmt.Errorf("rule %d: events and channels are required", i)
+		}
+		var res = &rule{events: make(map[EventType]bool)}
+		for _, e := range r.Events {
+			if !validEventType(e) {
+				return nil, fmt.Errorf("rule %d: event %s is not supported", i, e)
+			}
+			res.events[e] = true
+		}
+		for _, name := range r.Channels {
+			c, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("rule %d: channel %s is not defined", i, name)
+			}
+			res.channels = append(res.channels, c)
+		}
+
+		var err error
+		if res.subject, err = parseTemplate("subject", r.Subject, DefaultSubject); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		if res.summary, err = parseTemplate("template", r.Template, DefaultTemplate); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		n.rules = append(n.rules, res)
+	}
+	return n, nil
+}
+
+func parseTemplate(name, text, def string) (*template.Template, error) {
+	if text == "" {
+		text = def
+	}
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s error: %v", name, err)
+	}
+	return t, nil
+}
+
+// Notify sends the finished job to every channel of every matching rule, it goes on when a channel fails.
+// The job context may already be canceled, so the sends run on their own timeout
+func (n *Notifier) Notify(ctx context.Context, r *history.Record) error {
+	if n == nil || r == nil {
+		return nil
+	}
+	var e = &Event{Type: EventTypeOf(r), Record: *r}
+	e.Host, _ = os.Hostname()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), n.timeout)
+	defer cancel()
+
+	var errs []error
+	for _, rule := range n.rules {
+		if !rule.events[e.Type] {
+			continue
+		}
+		var event = *e
+		summary, err := render(rule.summary, &event)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		event.Summary = summary
+		subject, err := render(rule.subject, &event)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, c := range rule.channels {
+			if err := c.Send(ctx, subject, &event); err != nil {
+				errs = append(errs, fmt.Errorf("notify %s error: %v", c.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func render(t *template.Template, e *Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, e); err != nil {
+		return "", fmt.Errorf("render %s error: %v", t.Name(), err)
+	}
+	return buf.String(), nil
+}
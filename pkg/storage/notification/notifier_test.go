@@ -0,0 +1,206 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/history"
+	"olares.com/backups-sdk/pkg/storage/model"
+)
+
+func testRecord(op history.Operation, err error) *history.Record {
+	var r = history.Start(op, "s3", "home", "trace")
+	r.SnapshotId = "abcdef"
+	r.Bytes = 2048
+	r.Finish(err)
+	return r
+}
+
+func TestWebhook(t *testing.T) {
+	var got []*Event
+	var mu sync.Mutex
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("s3cret", r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var e = &Event{}
+		if err := json.Unmarshal(body, e); err != nil || r.Header.Get(HeaderEvent) != string(e.Type) || r.Header.Get("X-Team") != "ops" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier([]Channel{&Webhook{ChannelName: "ops", Url: server.URL, Secret: "s3cret", Headers: map[string]string{"X-Team": "ops"}}},
+		[]*Rule{{Events: []EventType{EventBackupFailure}, Channels: []string{"ops"}, Template: "{{.Repo}} {{.Outcome}}: {{.Error}}"}})
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, n.Notify(context.Background(), testRecord(history.OperationBackup, nil)), nil)
+	assert.Equal(t, n.Notify(context.Background(), testRecord(history.OperationBackup, errors.New("boom"))), nil)
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0].Type, EventBackupFailure)
+	assert.Equal(t, got[0].Repo, "home")
+	assert.Equal(t, got[0].Summary, "home failed: boom")
+
+	var bad = &Webhook{ChannelName: "bad", Url: server.URL, Secret: "wrong"}
+	assert.NotEqual(t, bad.Send(context.Background(), "", &Event{Type: EventBackupFailure}), nil)
+}
+
+func TestSmtp(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	defer l.Close()
+
+	var messages = make(chan string, 1)
+	go serveSmtp(l, messages)
+
+	var port = l.Addr().(*net.TCPAddr).Port
+	n, err := NewNotifier([]Channel{&Smtp{ChannelName: "admin", Host: "127.0.0.1", Port: port, From: "backups@example.com", To: []string{"admin@example.com"}}},
+		[]*Rule{{Events: []EventType{EventRestoreSuccess}, Channels: []string{"admin"}}})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n.Notify(context.Background(), testRecord(history.OperationRestore, nil)), nil)
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, strings.Contains(msg, "Subject: [backups] restore of home success\r\n"), true)
+		assert.Equal(t, strings.Contains(msg, "To: admin@example.com\r\n"), true)
+		assert.Equal(t, strings.Contains(msg, "snapshot: abcdef\r\n"), true)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+// serveSmtp accepts one session of the commands smtp.SendMail issues without auth and tls
+func serveSmtp(l net.Listener, messages chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var r = bufio.NewReader(conn)
+	var reply = func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+	reply("220 localhost ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			messages <- data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	var channels = []Channel{&Webhook{ChannelName: "ops"}}
+	var tests = []struct {
+		name  string
+		rules []*Rule
+		ok    bool
+	}{
+		{name: "valid", rules: []*Rule{{Events: []EventType{EventCheckFailure, EventRetention}, Channels: []string{"ops"}}}, ok: true},
+		{name: "unknown event", rules: []*Rule{{Events: []EventType{"backup_started"}, Channels: []string{"ops"}}}},
+		{name: "unknown channel", rules: []*Rule{{Events: []EventType{EventBackupSuccess}, Channels: []string{"mail"}}}},
+		{name: "no events", rules: []*Rule{{Channels: []string{"ops"}}}},
+		{name: "bad template", rules: []*Rule{{Events: []EventType{EventBackupSuccess}, Channels: []string{"ops"}, Template: "{{.Repo"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNotifier(channels, tt.rules)
+			assert.Equal(t, err == nil, tt.ok)
+		})
+	}
+
+	_, err := NewNotifier([]Channel{&Webhook{ChannelName: "ops"}, &Smtp{ChannelName: "ops"}}, nil)
+	assert.NotEqual(t, err, nil)
+}
+
+func TestEventTypeOf(t *testing.T) {
+	var tests = []struct {
+		op   history.Operation
+		err  error
+		want EventType
+	}{
+		{op: history.OperationBackup, want: EventBackupSuccess},
+		{op: history.OperationBackup, err: context.Canceled, want: EventBackupFailure},
+		{op: history.OperationRestore, err: errors.New("x"), want: EventRestoreFailure},
+		{op: history.OperationCheck, err: errors.New("x"), want: EventCheckFailure},
+		{op: history.OperationForget, want: EventRetention},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, EventTypeOf(testRecord(tt.op, tt.err)), tt.want)
+	}
+}
+
+func TestCloud(t *testing.T) {
+	var got []string
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.URL.Path+"?"+string(body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":200}`))
+	}))
+	defer server.Close()
+
+	var c = &Cloud{ChannelName: "olares", CloudApiUrl: server.URL, UserId: "did:key:alice", Token: "access-token-0123456789"}
+	var r = testRecord(history.OperationBackup, nil)
+	r.RepoId = "00000000-0000-0000-0000-000000000000"
+	r.Storage = &model.StorageInfo{Url: "s3:https://s3.us-east-1.amazonaws.com/bucket/folder/olares-backups/home-00000000-0000-0000-0000-000000000000",
+		CloudName: "aws", RegionId: "us-east-1", Bucket: "bucket", Prefix: "folder"}
+	assert.Equal(t, c.Send(context.Background(), "", &Event{Type: EventBackupFailure, Record: *r}), nil)
+	assert.Equal(t, c.Send(context.Background(), "", &Event{Type: EventBackupSuccess, Record: *r}), nil)
+	assert.Equal(t, len(got), 1)
+
+	form, err := url.ParseQuery(strings.TrimPrefix(got[0], "/v1/resource/snapshot/save?"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, form.Get("userid"), "did:key:alice")
+	assert.Equal(t, form.Get("token"), "access-token-0123456789")
+	assert.Equal(t, form.Get("backupId"), r.RepoId)
+	assert.Equal(t, form.Get("snapshotId"), "abcdef")
+	assert.Equal(t, form.Get("url"), r.Storage.Url)
+	assert.Equal(t, form.Get("cloud"), "aws")
+	assert.Equal(t, form.Get("region"), "us-east-1")
+	assert.Equal(t, form.Get("bucket"), "bucket")
+	assert.Equal(t, form.Get("prefix"), "folder")
+}
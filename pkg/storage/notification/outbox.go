@@ -0,0 +1,319 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type MessageKind string
+
+const (
+	MessageBackup   MessageKind = "backup"
+	MessageSnapshot MessageKind = "snapshot"
+)
+
+type MessageStatus string
+
+const (
+	MessagePending MessageStatus = "pending" // waits for its next attempt
+	MessageFailed  MessageStatus = "failed"  // gave up after MaxAttempts, only a forced flush retries it
+)
+
+var (
+	ErrMessageNotFound = errors.New("outbox message not found")
+
+	messageIdRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// Message is a cloud record waiting for delivery, its id is the kind and the snapshot or backup id,
+// so a record is queued once however often it is enqueued
+type Message struct {
+	Id            string        `json:"id"`
+	Kind          MessageKind   `json:"kind"`
+	CloudApiUrl   string        `json:"cloud_api_url"`
+	Backup        *Backup       `json:"backup,omitempty"`
+	Snapshot      *Snapshot     `json:"snapshot,omitempty"`
+	Status        MessageStatus `json:"status"`
+	Attempts      int           `json:"attempts"`
+	CreatedAt     time.Time     `json:"created_at"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+	LastError     string        `json:"last_error,omitempty"`
+}
+
+// FlushResult counts what a flush did with the messages it attempted
+type FlushResult struct {
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Failed    int `json:"failed"`
+}
+
+// Outbox keeps cloud records on disk until the cloud accepts them, one json file per message.
+// A delivered message is removed, a failing one is retried with exponential backoff
+type Outbox struct {
+	Dir         string
+	MaxAttempts int                                         // 10 when zero
+	MinBackoff  time.Duration                               // 30 seconds when zero, doubled after every attempt
+	MaxBackoff  time.Duration                               // 1 hour when zero
+	Send        func(ctx context.Context, m *Message) error // Deliver when nil
+
+	mu sync.Mutex
+}
+
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{Dir: dir}
+}
+
+func DefaultOutboxDir() string {
+	return path.Join(utils.GetBaseDir(), constants.DefaultOutbox)
+}
+
+// Deliver posts the message to the cloud api
+func Deliver(ctx context.Context, m *Message) error {
+	var cloudApiUrl = utils.DefaultValue(constants.DefaultCloudApiUrl, m.CloudApiUrl)
+	switch {
+	case m.Kind == MessageBackup && m.Backup != nil:
+		return SendNewBackupContext(ctx, cloudApiUrl, m.Backup)
+	case m.Kind == MessageSnapshot && m.Snapshot != nil:
+		return SendNewSnapshotContext(ctx, cloudApiUrl, m.Snapshot)
+	}
+	return fmt.Errorf("outbox message %s has no %s record", m.Id, m.Kind)
+}
+
+func (o *Outbox) EnqueueBackup(cloudApiUrl string, backup *Backup) (bool, error) {
+	return o.Enqueue(&Message{Id: string(MessageBackup) + "-" + backup.BackupId, Kind: MessageBackup, CloudApiUrl: cloudApiUrl, Backup: backup})
+}
+
+func (o *Outbox) EnqueueSnapshot(cloudApiUrl string, snapshot *Snapshot) (bool, error) {
+	return o.Enqueue(&Message{Id: string(MessageSnapshot) + "-" + snapshot.SnapshotId, Kind: MessageSnapshot, CloudApiUrl: cloudApiUrl, Snapshot: snapshot})
+}
+
+// Enqueue writes the message to disk, it reports false when a message with the same id is already queued
+func (o *Outbox) Enqueue(m *Message) (bool, error) {
+	if !messageIdRegexp.MatchString(m.Id) || strings.HasSuffix(m.Id, "-") {
+		return false, fmt.Errorf("outbox message id %q is invalid", m.Id)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := os.Stat(o.file(m.Id)); err == nil {
+		return false, nil
+	}
+	var now = time.Now().UTC()
+	var res = *m
+	res.Status, res.Attempts, res.LastError = MessagePending, 0, ""
+	res.CreatedAt, res.NextAttemptAt = now, now
+	if err := o.write(&res); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns the queued messages, oldest first, a missing dir is an empty outbox
+func (o *Outbox) List() ([]*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.list()
+}
+
+func (o *Outbox) Get(id string) (*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.read(id)
+}
+
+// Discard removes the messages without delivering them
+func (o *Outbox) Discard(ids ...string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, id := range ids {
+		if _, err := o.read(id); err != nil {
+			return err
+		}
+		if err := os.Remove(o.file(id)); err != nil {
+			return fmt.Errorf("discard outbox message %s error: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// Flush attempts the pending messages whose next attempt is due, force attempts every message,
+// failed ones included. Delivery errors are kept on the messages, only outbox errors are returned
+func (o *Outbox) Flush(ctx context.Context, force bool) (*FlushResult, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	messages, err := o.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var res = &FlushResult{}
+	var maxAttempts = o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	var send = o.Send
+	if send == nil {
+		send = Deliver
+	}
+	for _, m := range messages {
+		if !force && (m.Status != MessagePending || time.Now().Before(m.NextAttemptAt)) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		err := send(ctx, m)
+		if err == nil {
+			if err := os.Remove(o.file(m.Id)); err != nil {
+				return res, fmt.Errorf("remove outbox message %s error: %v", m.Id, err)
+			}
+			res.Delivered++
+			continue
+		}
+
+		m.Attempts++
+		m.LastError = redact.String(err.Error())
+		m.NextAttemptAt = time.Now().UTC().Add(o.backoff(m.Attempts))
+		if m.Attempts >= maxAttempts {
+			m.Status = MessageFailed
+			res.Failed++
+		} else {
+			m.Status = MessagePending
+			res.Retrying++
+		}
+		logger.FromContext(ctx).Warnf("deliver outbox message %s attempt %d error: %s", m.Id, m.Attempts, m.LastError)
+		if err := o.write(m); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Run flushes the outbox every interval until ctx is done
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := o.Flush(ctx, false); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).Errorf("flush outbox error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	var d, max = o.MinBackoff, o.MaxBackoff
+	if d <= 0 {
+		d = 30 * time.Second
+	}
+	if max <= 0 {
+		max = time.Hour
+	}
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (o *Outbox) file(id string) string {
+	return path.Join(o.Dir, id+".json")
+}
+
+func (o *Outbox) list() ([]*Message, error) {
+	entries, err := os.ReadDir(o.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read outbox error: %v", err)
+	}
+
+	var res []*Message
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		m, err := o.read(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			// a malformed message must not block the others
+			continue
+		}
+		res = append(res, m)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+func (o *Outbox) read(id string) (*Message, error) {
+	if !messageIdRegexp.MatchString(id) {
+		return nil, ErrMessageNotFound
+	}
+	data, err := os.ReadFile(o.file(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("read outbox message %s error: %v", id, err)
+	}
+	var m = &Message{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse outbox message %s error: %v", id, err)
+	}
+	return m, nil
+}
+
+// write replaces the message file atomically, the file may hold a token so only the owner can read it
+func (o *Outbox) write(m *Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return fmt.Errorf("create outbox dir error: %v", err)
+	}
+	f, err := os.CreateTemp(o.Dir, "."+m.Id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write outbox message %s error: %v", m.Id, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), o.file(m.Id))
+	}
+	if err != nil {
+		return fmt.Errorf("write outbox message %s error: %v", m.Id, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/constants"
+)
+
+func TestOutbox(t *testing.T) {
+	var down = true
+	var sent []string
+	var o = NewOutbox(t.TempDir())
+	o.MaxAttempts = 2
+	o.Send = func(ctx context.Context, m *Message) error {
+		if down {
+			return errors.New("cloud is down")
+		}
+		sent = append(sent, m.Id)
+		return nil
+	}
+
+	ok, err := o.EnqueueSnapshot("", &Snapshot{SnapshotId: "abc"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ok, true)
+	ok, err = o.EnqueueSnapshot("", &Snapshot{SnapshotId: "abc"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ok, false)
+	_, err = o.EnqueueSnapshot("", &Snapshot{SnapshotId: "../x"})
+	assert.NotEqual(t, err, nil)
+	_, err = o.EnqueueSnapshot("", &Snapshot{})
+	assert.NotEqual(t, err, nil)
+
+	res, err := o.Flush(context.Background(), false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{Retrying: 1})
+
+	// the next attempt is not due yet
+	res, err = o.Flush(context.Background(), false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{})
+
+	res, err = o.Flush(context.Background(), true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{Failed: 1})
+
+	// a reopened outbox sees the failed message
+	messages, err := NewOutbox(o.Dir).List()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(messages), 1)
+	assert.Equal(t, messages[0].Id, "snapshot-abc")
+	assert.Equal(t, messages[0].Status, MessageFailed)
+	assert.Equal(t, messages[0].Attempts, 2)
+	assert.Equal(t, messages[0].LastError, "cloud is down")
+
+	down = false
+	res, err = o.Flush(context.Background(), false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{})
+	res, err = o.Flush(context.Background(), true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{Delivered: 1})
+	assert.Equal(t, sent, []string{"snapshot-abc"})
+
+	messages, err = o.List()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(messages), 0)
+}
+
+func TestOutboxDiscard(t *testing.T) {
+	var o = NewOutbox(t.TempDir())
+	_, err := o.EnqueueBackup("", &Backup{BackupId: "b1"})
+	assert.Equal(t, err, nil)
+	_, err = o.EnqueueSnapshot("", &Snapshot{SnapshotId: "s1"})
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, o.Discard("backup-b1"), nil)
+	assert.Equal(t, o.Discard("backup-b1"), ErrMessageNotFound)
+
+	m, err := o.Get("snapshot-s1")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, m.Kind, MessageSnapshot)
+	assert.Equal(t, m.Status, MessagePending)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	var o = &Outbox{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	var tests = []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 5 * time.Second},
+		{attempts: 40, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, o.backoff(tt.attempts), tt.want)
+	}
+}
+
+func TestCloudOutbox(t *testing.T) {
+	var calls int
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 || r.URL.Path != constants.SendSnapshotUrl {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":200}`))
+	}))
+	defer server.Close()
+
+	var o = NewOutbox(t.TempDir())
+	var c = &Cloud{ChannelName: "cloud", CloudApiUrl: server.URL, UserId: "did", Outbox: o}
+	var e = &Event{Type: EventBackupSuccess}
+	e.SnapshotId = "abc"
+
+	// the record is kept when the cloud is unavailable
+	assert.Equal(t, c.Send(context.Background(), "", e), nil)
+	messages, err := o.List()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(messages), 1)
+
+	res, err := o.Flush(context.Background(), true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *res, FlushResult{Delivered: 1})
+	assert.Equal(t, calls, 2)
+}
@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Smtp mails the summary of the event, the server is asked for STARTTLS when it offers it,
+// credentials are only sent over tls or to localhost, see smtp.PlainAuth
+type Smtp struct {
+	ChannelName string
+	Host        string
+	Port        int // 587 when zero
+	Username    string
+	Password    string
+	From        string
+	To          []string
+}
+
+func (s *Smtp) Name() string {
+	return s.ChannelName
+}
+
+func (s *Smtp) Send(ctx context.Context, subject string, e *Event) error {
+	if len(s.To) == 0 {
+		return fmt.Errorf("smtp recipients are required")
+	}
+	var port = s.Port
+	if port == 0 {
+		port = 587
+	}
+	var addr = net.JoinHostPort(s.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, s.From, s.To, s.message(subject, e.Summary))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Smtp) message(subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
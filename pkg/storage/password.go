@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// inputPassword prompts the terminal, replaced in tests
+var inputPassword = utils.InputPasswordWithConfirm
+
+var ErrPasswordRequired = errors.New("repository password is required, set a password file, a password command or RESTIC_PASSWORD when running without a terminal")
+
+// resolvePassword returns the repository password from the first source that is set:
+// the password itself (a secret reference when secretRefs is set), the password file, the password command,
+// then RESTIC_PASSWORD, RESTIC_PASSWORD_FILE and RESTIC_PASSWORD_COMMAND,
+// the terminal is only prompted when none of them is set, the password is registered for redaction
+func resolvePassword(password, passwordFile, passwordCommand string, secretRefs, confirm bool) (string, error) {
+	res, err := lookupPassword(password, passwordFile, passwordCommand, secretRefs, confirm)
+	if err != nil {
+		return "", err
+	}
+	redact.Register(res)
+	return res, nil
+}
+
+func lookupPassword(password, passwordFile, passwordCommand string, secretRefs, confirm bool) (string, error) {
+	switch {
+	case password != "" && secretRefs:
+		return utils.ResolveSecret(password)
+	case password != "":
+		return password, nil
+	case passwordFile != "":
+		return utils.ReadSecretFile(passwordFile)
+	case passwordCommand != "":
+		return utils.RunSecretCommand(passwordCommand)
+	}
+
+	if v := os.Getenv(constants.ENV_RESTIC_PASSWORD); v != "" {
+		return v, nil
+	}
+	if v := os.Getenv(constants.ENV_RESTIC_PASSWORD_FILE); v != "" {
+		return utils.ReadSecretFile(v)
+	}
+	if v := os.Getenv(constants.ENV_RESTIC_PASSWORD_COMMAND); v != "" {
+		return utils.RunSecretCommand(v)
+	}
+
+	password, err := inputPassword(confirm)
+	if err != nil {
+		if errors.Is(err, utils.ErrNoTerminal) {
+			return "", ErrPasswordRequired
+		}
+		return "", fmt.Errorf("read password error: %v", err)
+	}
+	return password, nil
+}
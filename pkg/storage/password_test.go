@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/s3"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+func TestResolvePassword(t *testing.T) {
+	var file = path.Join(t.TempDir(), "password")
+	assert.Equal(t, os.WriteFile(file, []byte("from-file\n"), 0600), nil)
+
+	for _, env := range []string{constants.ENV_RESTIC_PASSWORD, constants.ENV_RESTIC_PASSWORD_FILE, constants.ENV_RESTIC_PASSWORD_COMMAND} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("TEST_BACKUPS_PASSWORD", "from-env")
+
+	var tests = []struct {
+		name     string
+		password string
+		file     string
+		command  string
+		refs     bool
+		want     string
+	}{
+		{name: "password", password: "plain", file: file, want: "plain"},
+		{name: "password reference", password: "env:TEST_BACKUPS_PASSWORD", refs: true, want: "from-env"},
+		{name: "literal password", password: "env:TEST_BACKUPS_PASSWORD", want: "env:TEST_BACKUPS_PASSWORD"},
+		{name: "password file", file: file, command: "echo ignored", want: "from-file"},
+		{name: "password command", command: "echo from-command", want: "from-command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := resolvePassword(tt.password, tt.file, tt.command, tt.refs, false)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, password, tt.want)
+		})
+	}
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv(constants.ENV_RESTIC_PASSWORD_FILE, file)
+		password, err := resolvePassword("", "", "", false, false)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, password, "from-file")
+	})
+
+	t.Run("failed sources", func(t *testing.T) {
+		_, err := resolvePassword("env:TEST_BACKUPS_MISSING", "", "", true, false)
+		assert.NotEqual(t, err, nil)
+		_, err = resolvePassword("", path.Join(t.TempDir(), "missing"), "", false, false)
+		assert.NotEqual(t, err, nil)
+		_, err = resolvePassword("", "", "exit 1", false, false)
+		assert.NotEqual(t, err, nil)
+	})
+}
+
+func TestResolvePasswordWithoutTerminal(t *testing.T) {
+	for _, env := range []string{constants.ENV_RESTIC_PASSWORD, constants.ENV_RESTIC_PASSWORD_FILE, constants.ENV_RESTIC_PASSWORD_COMMAND} {
+		t.Setenv(env, "")
+	}
+
+	var saved = inputPassword
+	inputPassword = func(confirm bool) (string, error) { return "", utils.ErrNoTerminal }
+	defer func() { inputPassword = saved }()
+
+	_, err := resolvePassword("", "", "", false, true)
+	assert.Equal(t, err, ErrPasswordRequired)
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("TEST_BACKUPS_SECRET_KEY", "secret-from-env")
+
+	var o = &options.AwsBackupOption{
+		RepoName:        "repo",
+		AccessKey:       "AKIAEXAMPLE",
+		SecretAccessKey: "env:TEST_BACKUPS_SECRET_KEY",
+		SessionToken:    "cmd:echo token",
+	}
+	res, err := options.ResolveSecrets(o)
+	assert.Equal(t, err, nil)
+
+	var resolved = res.(*options.AwsBackupOption)
+	assert.Equal(t, resolved.RepoName, "repo")
+	assert.Equal(t, resolved.AccessKey, "AKIAEXAMPLE")
+	assert.Equal(t, resolved.SecretAccessKey, "secret-from-env")
+	assert.Equal(t, resolved.SessionToken, "token")
+
+	// the caller's option keeps the references
+	assert.Equal(t, o.SecretAccessKey, "env:TEST_BACKUPS_SECRET_KEY")
+
+	_, err = options.ResolveSecrets(&options.SpaceBackupOption{AccessToken: "file:/nonexistent/token"})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestNewLocationSecretRefs(t *testing.T) {
+	t.Setenv("TEST_BACKUPS_SECRET_KEY", "secret-from-env")
+
+	var tests = []struct {
+		name string
+		refs bool
+		want string
+	}{
+		{name: "literal", want: "env:TEST_BACKUPS_SECRET_KEY"},
+		{name: "reference", refs: true, want: "secret-from-env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o = &options.AwsBackupOption{RepoName: "repo", SecretAccessKey: "env:TEST_BACKUPS_SECRET_KEY"}
+			location, err := newLocation(LocationAws, o, &LocationParams{Password: "p", SecretRefs: tt.refs})
+			assert.Equal(t, err, nil)
+			assert.Equal(t, location.(*s3.Aws).SecretAccessKey, tt.want)
+		})
+	}
+}
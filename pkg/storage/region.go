@@ -2,16 +2,29 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/storage/space"
+	"olares.com/backups-sdk/pkg/tracing"
+	"olares.com/backups-sdk/pkg/utils"
 )
 
 type RegionOption struct {
-	Ctx    context.Context
-	Logger *zap.SugaredLogger
-	Space  *options.SpaceRegionOptions
+	Ctx     context.Context    // Deprecated: pass the context to RegionsContext
+	Timeout time.Duration      // bounds the query, zero means no limit, Regions defaults to 30 seconds
+	Logger  *zap.SugaredLogger // logger of the service, the global logger when nil
+	Space   *options.SpaceRegionOptions
+
+	// SecretRefs resolves an env:, file: or cmd: reference in the access token, it is used as it is when false
+	SecretRefs bool
+
+	// Repository is used when Space is not set, regions are only provided by space
+	Repository *options.Repository
 }
 
 type RegionService struct {
@@ -26,15 +39,58 @@ func NewRegionService(option *RegionOption) *RegionService {
 	return regionService
 }
 
+// Deprecated: use RegionsContext
 func (r *RegionService) Regions() ([]map[string]string, error) {
-	var service Location
+	var ctx, timeout = context.Background(), defaultQueryTimeout
+	if r.option != nil {
+		ctx, timeout = contextOrBackground(r.option.Ctx), defaultTimeout(r.option.Timeout, defaultQueryTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return r.RegionsContext(ctx)
+}
+
+func (r *RegionService) RegionsContext(ctx context.Context) (regions []map[string]string, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
 	if r.option != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeout(logger.NewContext(ctx, r.option.Logger), r.option.Timeout)
+		defer cancel()
+	}
+	ctx, span := startSpan(utils.WithTraceId(ctx), "regions")
+	defer tracing.End(span, &err)
+	span.SetAttributes(attribute.String("backups.location", LocationSpace))
+
+	var service *space.Space
+	if r.option != nil && r.option.Space != nil {
 		service = &space.Space{
 			OlaresDid:      r.option.Space.OlaresDid,
 			AccessToken:    r.option.Space.AccessToken,
 			CloudApiMirror: r.option.Space.CloudApiMirror,
 		}
+	} else if r.option != nil && r.option.Repository != nil {
+		if r.option.Repository.Location != LocationSpace {
+			return nil, fmt.Errorf("location %s does not provide regions", r.option.Repository.Location)
+		}
+		service = &space.Space{
+			OlaresDid:      r.option.Repository.Param(options.ParamOlaresDid),
+			AccessToken:    r.option.Repository.GetCredentials().AccessToken,
+			CloudApiMirror: r.option.Repository.Param(options.ParamCloudApiMirror),
+		}
+	} else {
+		return nil, fmt.Errorf("space region option is required")
+	}
+
+	if r.option.SecretRefs {
+		accessToken, err := utils.ResolveSecret(service.AccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("access token: %v", err)
+		}
+		service.AccessToken = accessToken
 	}
 
-	return service.Regions()
+	return service.RegionsContext(ctx)
 }
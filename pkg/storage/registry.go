@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"olares.com/backups-sdk/pkg/options"
+)
+
+type Operation string
+
+const (
+	OperationBackup    Operation = "backup"
+	OperationRestore   Operation = "restore"
+	OperationSnapshots Operation = "snapshots"
+	OperationStats     Operation = "stats"
+	OperationForget    Operation = "forget"
+	OperationCheck     Operation = "check"
+	OperationLs        Operation = "ls"
+)
+
+// LocationParams are the location independent parameters of a request
+type LocationParams struct {
+	Password                 string
+	Operator                 string
+	BackupType               string
+	BackupAppTypeName        string
+	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
+	SecretRefs               bool // the secret references of the option are resolved before the location is built
+}
+
+// Backend describes a storage location that can be registered.
+//
+// NewOption returns the option schema of an operation, its AddFlags builds the cli flags,
+// nil means the operation is not supported by the backend.
+// NewLocation receives one of the options returned by NewOption and builds the Location.
+// FromRepository maps a repository descriptor, one of *options.RepositoryBackupOption,
+// *options.RepositoryRestoreOption or *options.RepositorySnapshotsOption, to the option
+// NewLocation expects, it is optional for backends that are only used through their own options.
+type Backend struct {
+	Name           string
+	Description    string
+	NewOption      func(op Operation) options.Option
+	NewLocation    func(option options.Option, params *LocationParams) (Location, error)
+	FromRepository func(option interface{}) (options.Option, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	names    []string
+	backends map[string]*Backend
+}{backends: make(map[string]*Backend)}
+
+// Register adds a backend to the registry, names must be unique
+func Register(backend *Backend) error {
+	if backend == nil || backend.Name == "" {
+		return fmt.Errorf("backend name is required")
+	}
+	if backend.NewOption == nil || backend.NewLocation == nil {
+		return fmt.Errorf("backend %s must provide NewOption and NewLocation", backend.Name)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.backends[backend.Name]; ok {
+		return fmt.Errorf("backend %s already registered", backend.Name)
+	}
+	registry.names = append(registry.names, backend.Name)
+	registry.backends[backend.Name] = backend
+	return nil
+}
+
+func MustRegister(backend *Backend) {
+	if err := Register(backend); err != nil {
+		panic(err)
+	}
+}
+
+func LookupBackend(name string) (*Backend, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	backend, ok := registry.backends[name]
+	return backend, ok
+}
+
+// Backends returns the registered backends in registration order
+func Backends() []*Backend {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var backends = make([]*Backend, 0, len(registry.names))
+	for _, name := range registry.names {
+		backends = append(backends, registry.backends[name])
+	}
+	return backends
+}
+
+// BackendsFor returns the backends that support the operation
+func BackendsFor(op Operation) []*Backend {
+	var backends []*Backend
+	for _, backend := range Backends() {
+		if backend.NewOption(op) != nil {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// repositoryOption resolves a repository descriptor to the option of its backend
+func repositoryOption(repo *options.Repository, option interface{}) (string, options.Option, error) {
+	if err := repo.Validate(); err != nil {
+		return "", nil, err
+	}
+	backend, ok := LookupBackend(repo.Location)
+	if !ok {
+		return "", nil, fmt.Errorf("location %s is not registered", repo.Location)
+	}
+	if backend.FromRepository == nil {
+		return "", nil, fmt.Errorf("location %s does not support repository descriptors", repo.Location)
+	}
+	o, err := backend.FromRepository(option)
+	if err != nil {
+		return "", nil, err
+	}
+	return repo.Location, o, nil
+}
+
+func newLocation(name string, option options.Option, params *LocationParams) (Location, error) {
+	if name == "" || option == nil {
+		return nil, fmt.Errorf("There is no suitable recovery method.")
+	}
+	backend, ok := LookupBackend(name)
+	if !ok {
+		return nil, fmt.Errorf("location %s is not registered", name)
+	}
+	if params.SecretRefs {
+		resolved, err := options.ResolveSecrets(option)
+		if err != nil {
+			return nil, fmt.Errorf("location %s: %v", name, err)
+		}
+		option = resolved
+	}
+	return backend.NewLocation(option, params)
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/spf13/cobra"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/storage/filesystem"
+)
+
+type customBackupOption struct {
+	Endpoint string
+}
+
+func (o *customBackupOption) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Endpoint, "endpoint", "", "", "Endpoint")
+}
+
+func TestRegisterBackend(t *testing.T) {
+	var backend = &Backend{
+		Name:        "custom-test",
+		Description: "a custom backend",
+		NewOption: func(op Operation) options.Option {
+			if op == OperationBackup {
+				return &customBackupOption{}
+			}
+			return nil
+		},
+		NewLocation: func(option options.Option, params *LocationParams) (Location, error) {
+			o, ok := option.(*customBackupOption)
+			if !ok {
+				return nil, unsupportedOption("custom-test", option)
+			}
+			return &filesystem.Filesystem{Endpoint: o.Endpoint, Password: params.Password}, nil
+		},
+	}
+
+	assert.Equal(t, Register(backend), nil)
+	assert.NotEqual(t, Register(backend), nil)
+	assert.NotEqual(t, Register(&Backend{Name: "incomplete"}), nil)
+
+	var names []string
+	for _, b := range BackendsFor(OperationBackup) {
+		names = append(names, b.Name)
+	}
+	assert.Equal(t, names, []string{LocationSpace, LocationAws, LocationTencentCloud, LocationFilesystem, LocationSftp, LocationRest, "custom-test"})
+
+	for _, b := range BackendsFor(OperationRestore) {
+		assert.NotEqual(t, b.Name, "custom-test")
+	}
+
+	location, err := newLocation("custom-test", &customBackupOption{Endpoint: "/backups"}, &LocationParams{Password: "secret"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, location.(*filesystem.Filesystem).Endpoint, "/backups")
+
+	_, err = newLocation("custom-test", options.NewRestoreFilesystemOption(), &LocationParams{})
+	assert.NotEqual(t, err, nil)
+
+	_, err = newLocation("missing", &customBackupOption{}, &LocationParams{})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestBackupOptionLocation(t *testing.T) {
+	var aws = options.NewBackupAwsOption()
+	name, option, err := (&BackupOption{Aws: aws, Location: LocationFilesystem}).location()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, name, LocationAws)
+	assert.Equal(t, option, aws)
+
+	var fs = options.NewBackupFilesystemOption()
+	name, option, err = (&BackupOption{Location: LocationFilesystem, LocationOption: fs}).location()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, name, LocationFilesystem)
+	assert.Equal(t, option, fs)
+}
+
+func TestRepositoryDescriptor(t *testing.T) {
+	var data = `{
+		"location": "s3",
+		"repo_name": "photos",
+		"repo_id": "00000000-0000-0000-0000-000000000000",
+		"endpoint": "https://s3.us-east-1.amazonaws.com/mytest-bucket",
+		"credentials": {"access_key": "AK", "secret_access_key": "SK"},
+		"path": "/data/photos",
+		"storage_class": "standard_ia"
+	}`
+	var repo options.RepositoryBackupOption
+	assert.Equal(t, json.Unmarshal([]byte(data), &repo), nil)
+
+	name, option, err := (&BackupOption{Repository: &repo}).location()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, name, LocationAws)
+	var aws = option.(*options.AwsBackupOption)
+	assert.Equal(t, aws.RepoName, "photos")
+	assert.Equal(t, aws.AccessKey, "AK")
+	assert.Equal(t, aws.Path, "/data/photos")
+	assert.Equal(t, aws.StorageClass, "standard_ia")
+
+	// the same repository restored through sftp and rest descriptors
+	name, option, err = (&RestoreOption{Repository: &options.RepositoryRestoreOption{
+		Repository: options.Repository{
+			Location:    LocationSftp,
+			RepoName:    "photos",
+			Endpoint:    "sftp://backup@nas.local:2222/volume1/backups",
+			Credentials: &options.Credentials{KeyFile: "/root/.ssh/id_ed25519"},
+			Params:      map[string]string{options.ParamKnownHosts: "/root/.ssh/known_hosts"},
+		},
+		SnapshotId: "abcd1234",
+	}}).location()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, name, LocationSftp)
+	var sftp = option.(*options.SftpRestoreOption)
+	assert.Equal(t, sftp.Host, "nas.local")
+	assert.Equal(t, sftp.Port, 2222)
+	assert.Equal(t, sftp.User, "backup")
+	assert.Equal(t, sftp.RemotePath, "/volume1/backups")
+	assert.Equal(t, sftp.KnownHosts, "/root/.ssh/known_hosts")
+	assert.Equal(t, sftp.SnapshotId, "abcd1234")
+
+	_, option, err = (&SnapshotsOption{Repository: &options.RepositorySnapshotsOption{
+		Repository: options.Repository{
+			Location: LocationRest,
+			RepoName: "photos",
+			Endpoint: "https://backup.office.lan:8000",
+			Params:   map[string]string{options.ParamAppendOnly: "true"},
+		},
+	}}).location()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, option.(*options.RestSnapshotsOption).AppendOnly, true)
+
+	_, _, err = (&SnapshotsOption{Repository: &options.RepositorySnapshotsOption{
+		Repository: options.Repository{Location: "missing", RepoName: "photos"},
+	}}).location()
+	assert.NotEqual(t, err, nil)
+
+	_, _, err = (&SnapshotsOption{Repository: &options.RepositorySnapshotsOption{
+		Repository: options.Repository{Location: LocationAws},
+	}}).location()
+	assert.NotEqual(t, err, nil)
+}
+
+func TestRepositoryDescriptorJSON(t *testing.T) {
+	var repo = options.Repository{
+		Location: LocationAws,
+		RepoName: "photos",
+		Credentials: &options.Credentials{
+			AccessKey:       "AK",
+			SecretAccessKey: "env:AWS_SECRET_ACCESS_KEY",
+			SessionToken:    "token",
+			Profile:         "backup",
+		},
+	}
+	data, err := json.Marshal(&options.RepositoryBackupOption{Repository: repo, Path: "/data/photos"})
+	assert.Equal(t, err, nil)
+
+	// the literal secrets are left out, the reference and the profile are kept
+	var res options.RepositoryBackupOption
+	assert.Equal(t, json.Unmarshal(data, &res), nil)
+	assert.Equal(t, *res.Credentials, options.Credentials{SecretAccessKey: "env:AWS_SECRET_ACCESS_KEY", Profile: "backup"})
+	assert.Equal(t, res.Path, "/data/photos")
+
+	// the descriptor itself keeps them
+	assert.Equal(t, repo.Credentials.AccessKey, "AK")
+}
@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage/base"
+	"olares.com/backups-sdk/pkg/storage/model"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+type Rest struct {
+	RepoId                   string
+	RepoName                 string
+	SnapshotId               string
+	Endpoint                 string
+	Username                 string
+	RestPassword             string
+	CACert                   string
+	AppendOnly               bool
+	Password                 string
+	LimitUploadRate          string
+	LimitDownloadRate        string
+	Path                     string
+	Files                    []string
+	Excludes                 []string
+	FilesPrefixPath          string
+	Metadata                 string
+	BaseHandler              base.Interface
+	Operator                 string
+	BackupType               string
+	BackupAppTypeName        string
+	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
+}
+
+func (r *Rest) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err = r.FormatRepository()
+	if err != nil {
+		return
+	}
+
+	var envs = r.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:                   r.RepoId,
+		RepoName:                 r.RepoName,
+		Path:                     r.Path,
+		Files:                    r.Files,
+		Excludes:                 r.Excludes,
+		FilesPrefixPath:          r.FilesPrefixPath,
+		Metadata:                 r.Metadata,
+		LimitUploadRate:          r.LimitUploadRate,
+		CACert:                   r.CACert,
+		AppendOnly:               r.AppendOnly,
+		Operator:                 r.Operator,
+		BackupType:               r.BackupType,
+		BackupAppTypeName:        r.BackupAppTypeName,
+		BackupFileTypeSourcePath: r.BackupFileTypeSourcePath,
+		BackupSetId:              r.BackupSetId,
+		RepoEnvs:                 envs,
+	}
+
+	log.Debugf("rest backup env vars: %s", envs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	backupSummary, err = r.BaseHandler.Backup(ctx, dryRun, progressCallback)
+
+	return backupSummary, storageInfo, err
+}
+
+func (r *Rest) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := r.FormatRepository()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var envs = r.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:            r.RepoId,
+		RepoName:          r.RepoName,
+		SnapshotId:        r.SnapshotId,
+		RepoEnvs:          envs,
+		Path:              r.Path,
+		LimitDownloadRate: r.LimitDownloadRate,
+		CACert:            r.CACert,
+		AppendOnly:        r.AppendOnly,
+		SkipSpaceCheck:    r.SkipSpaceCheck,
+	}
+
+	log.Debugf("rest restore env vars: %s", envs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Restore(ctx, progressCallback)
+}
+
+func (r *Rest) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest snapshots env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Snapshots(ctx)
+}
+
+func (r *Rest) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest snapshot env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.GetSnapshot(ctx, snapshotId)
+}
+
+func (r *Rest) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest stats env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Stats(ctx)
+}
+
+func (r *Rest) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest forget env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Forget(ctx, policy)
+}
+
+func (r *Rest) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest check env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Check(ctx, readDataSubset)
+}
+
+func (r *Rest) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := r.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("rest ls env vars: %s", opts.RepoEnvs.String())
+
+	r.BaseHandler.SetOptions(opts)
+	return r.BaseHandler.Ls(ctx, snapshotId, path, recursive)
+}
+
+func (r *Rest) Regions() ([]map[string]string, error) {
+	return nil, nil
+}
+
+func (r *Rest) GetEnv(repository string) *restic.ResticEnvs {
+	var envs = &restic.ResticEnvs{
+		RESTIC_REPOSITORY:    repository,
+		RESTIC_PASSWORD:      r.Password,
+		RESTIC_REST_USERNAME: r.Username,
+		RESTIC_REST_PASSWORD: r.RestPassword,
+	}
+	return envs
+}
+
+// rest:http(s)://{host}:{port}/{prefix}/olares-backups/{name}-{id}
+//
+// basic auth credentials are passed through env, never inside the repository url
+func (r *Rest) FormatRepository() (storageInfo *model.StorageInfo, err error) {
+	if r.Endpoint == "" {
+		return nil, errors.New("rest endpoint is required")
+	}
+
+	u, err := url.Parse(r.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("rest endpoint scheme %q is not supported, use http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("rest endpoint host is required")
+	}
+	if u.User != nil {
+		return nil, errors.New("rest endpoint must not contain credentials, use the username and password options")
+	}
+
+	var prefix = strings.Trim(u.Path, "/")
+	var repo = utils.JoinName(utils.EncodeURLPart(r.RepoName), r.RepoId)
+	var repoUrl = fmt.Sprintf("rest:%s://%s/%s/%s", u.Scheme, u.Host, constants.OlaresStorageDefaultPrefix, repo)
+	if prefix != "" {
+		repoUrl = fmt.Sprintf("rest:%s://%s/%s/%s/%s", u.Scheme, u.Host, prefix, constants.OlaresStorageDefaultPrefix, repo)
+	}
+
+	storageInfo = &model.StorageInfo{
+		Location:  "rest",
+		Url:       repoUrl,
+		CloudName: constants.CloudRestName,
+		RegionId:  "",
+		Bucket:    "",
+		Prefix:    prefix,
+		Endpoint:  u.Host,
+	}
+
+	return storageInfo, nil
+}
+
+func (r *Rest) queryOptions() (*restic.ResticOptions, error) {
+	storageInfo, err := r.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	return &restic.ResticOptions{
+		RepoId:     r.RepoId,
+		RepoName:   r.RepoName,
+		RepoEnvs:   r.GetEnv(storageInfo.Url),
+		CACert:     r.CACert,
+		AppendOnly: r.AppendOnly,
+	}, nil
+}
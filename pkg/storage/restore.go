@@ -2,30 +2,67 @@ package storage
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/bandwidth"
+	"olares.com/backups-sdk/pkg/history"
 	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/restic"
-	"olares.com/backups-sdk/pkg/storage/cos"
-	"olares.com/backups-sdk/pkg/storage/filesystem"
-	"olares.com/backups-sdk/pkg/storage/s3"
-	"olares.com/backups-sdk/pkg/storage/space"
+	"olares.com/backups-sdk/pkg/storage/notification"
+	"olares.com/backups-sdk/pkg/tracing"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
 type RestoreOption struct {
-	Password     string
-	Operator     string `json:"operator"`
-	BackupType   string `json:"backup_type"` // file / app
-	Ctx          context.Context
-	Logger       *zap.SugaredLogger
-	Space        *options.SpaceRestoreOption        `json:"space,omitempty"`
-	Aws          *options.AwsRestoreOption          `json:"aws,omitempty"`
-	TencentCloud *options.TencentCloudRestoreOption `json:"tencentcloud,omitempty"`
-	Filesystem   *options.FilesystemRestoreOption   `json:"filesystem,omitempty"`
+	Password         string
+	PasswordFile     string
+	PasswordCommand  string
+	Operator         string                             `json:"operator"`
+	BackupType       string                             `json:"backup_type"` // file / app
+	Ctx              context.Context                    // Deprecated: pass the context to RestoreContext
+	Timeout          time.Duration                      // bounds the whole restore, zero means no limit
+	Logger           *zap.SugaredLogger                 // logger of the service, the global logger when nil
+	History          *history.Store                     `json:"-"`                           // the finished restore is recorded here, nothing is recorded when nil
+	Notifier         *notification.Notifier             `json:"-"`                           // the finished restore is sent to the matching rules, nothing is sent when nil
+	DownloadSchedule bandwidth.Schedule                 `json:"download_schedule,omitempty"` // caps the download rate by time of day, restic is restarted when the rate changes
+	Force            bool                               `json:"force,omitempty"`             // starts even when the pre-flight check finds too little free space
+	SecretRefs       bool                               `json:"-"`                           // resolves env:, file: and cmd: references in the password and the credentials, they are used as they are when false
+	Space            *options.SpaceRestoreOption        `json:"space,omitempty"`
+	Aws              *options.AwsRestoreOption          `json:"aws,omitempty"`
+	TencentCloud     *options.TencentCloudRestoreOption `json:"tencentcloud,omitempty"`
+	Filesystem       *options.FilesystemRestoreOption   `json:"filesystem,omitempty"`
+	Sftp             *options.SftpRestoreOption         `json:"sftp,omitempty"`
+	Rest             *options.RestRestoreOption         `json:"rest,omitempty"`
+
+	// Repository is the serializable descriptor of the repository and the operation
+	Repository *options.RepositoryRestoreOption `json:"repository,omitempty"`
+
+	// Location selects a registered backend by name, LocationOption is its restore option
+	Location       string         `json:"location,omitempty"`
+	LocationOption options.Option `json:"location_option,omitempty"`
+}
+
+func (o *RestoreOption) location() (string, options.Option, error) {
+	switch {
+	case o.Space != nil:
+		return LocationSpace, o.Space, nil
+	case o.Aws != nil:
+		return LocationAws, o.Aws, nil
+	case o.TencentCloud != nil:
+		return LocationTencentCloud, o.TencentCloud, nil
+	case o.Filesystem != nil:
+		return LocationFilesystem, o.Filesystem, nil
+	case o.Sftp != nil:
+		return LocationSftp, o.Sftp, nil
+	case o.Rest != nil:
+		return LocationRest, o.Rest, nil
+	case o.Repository != nil:
+		return repositoryOption(&o.Repository.Repository, o.Repository)
+	}
+	return o.Location, o.LocationOption, nil
 }
 
 type RestoreService struct {
@@ -42,88 +79,74 @@ func NewRestoreService(option *RestoreOption) *RestoreService {
 	return restoreService
 }
 
+// Deprecated: use RestoreContext, Restore runs with RestoreOption.Ctx
 func (r *RestoreService) Restore(progressCallback func(percentDone float64)) (restoreSummary map[string]*restic.RestoreSummaryOutput, metadata string, totalBytes uint64, err error) {
-	var password = r.password
-	if password == "" {
-		password, err = utils.InputPasswordWithConfirm(false)
-		if err != nil {
-			panic(err)
-		}
-	}
+	return r.RestoreContext(contextOrBackground(r.option.Ctx), progressCallback)
+}
 
-	var service Location
+// RestoreContext runs the restore until it finishes or ctx is done
+func (r *RestoreService) RestoreContext(ctx context.Context, progressCallback func(percentDone float64)) (restoreSummary map[string]*restic.RestoreSummaryOutput, metadata string, totalBytes uint64, err error) {
+	if ctx == nil {
+		return nil, "", 0, ErrNilContext
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), r.option.Logger), r.option.Timeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "restore")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
 
-	if r.option.Space != nil {
-		service = &space.Space{
-			RepoId:   r.option.Space.RepoId,
-			RepoName: r.option.Space.RepoName,
-			// When restoring from BackupURL on a new machine, it is necessary to extract the Suffix from the Prefix of the backup in the BackupURL
-			RepoSuffix:        r.option.Space.RepoSuffix,
-			SnapshotId:        r.option.Space.SnapshotId,
-			Path:              r.option.Space.Path,
-			OlaresDid:         r.option.Space.OlaresDid,
-			AccessToken:       r.option.Space.AccessToken,
-			ClusterId:         r.option.Space.ClusterId,
-			CloudName:         strings.ToLower(r.option.Space.CloudName),
-			RegionId:          strings.ToLower(r.option.Space.RegionId),
-			CloudApiMirror:    r.option.Space.CloudApiMirror,
-			Password:          password,
-			LimitDownloadRate: r.option.Space.LimitDownloadRate,
-			StsToken:          &space.StsToken{},
-			Operator:          r.option.Operator,
-			BackupType:        r.option.BackupType,
-		}
-	} else if r.option.Aws != nil {
-		service = &s3.Aws{
-			RepoId:            r.option.Aws.RepoId,
-			RepoName:          r.option.Aws.RepoName,
-			SnapshotId:        r.option.Aws.SnapshotId,
-			Endpoint:          r.option.Aws.Endpoint,
-			AccessKey:         r.option.Aws.AccessKey,
-			SecretAccessKey:   r.option.Aws.SecretAccessKey,
-			Path:              r.option.Aws.Path,
-			LimitDownloadRate: r.option.Aws.LimitDownloadRate,
-			Password:          password,
-			BaseHandler:       &BaseHandler{},
-			Operator:          r.option.Operator,
-			BackupType:        r.option.BackupType,
-		}
-	} else if r.option.TencentCloud != nil {
-		service = &cos.TencentCloud{
-			RepoId:            r.option.TencentCloud.RepoId,
-			RepoName:          r.option.TencentCloud.RepoName,
-			SnapshotId:        r.option.TencentCloud.SnapshotId,
-			Endpoint:          r.option.TencentCloud.Endpoint,
-			AccessKey:         r.option.TencentCloud.AccessKey,
-			SecretAccessKey:   r.option.TencentCloud.SecretAccessKey,
-			Path:              r.option.TencentCloud.Path,
-			LimitDownloadRate: r.option.TencentCloud.LimitDownloadRate,
-			Password:          password,
-			BaseHandler:       &BaseHandler{},
-			Operator:          r.option.Operator,
-			BackupType:        r.option.BackupType,
+	var record = history.Start(history.OperationRestore, "", "", utils.GetTraceId(ctx))
+	defer func() {
+		record.Bytes = totalBytes
+		for _, summary := range restoreSummary {
+			if summary != nil {
+				record.Files += summary.FilesRestored
+			}
 		}
+		recordJob(ctx, r.option.History, r.option.Notifier, record, err)
+	}()
 
-	} else if r.option.Filesystem != nil {
-		service = &filesystem.Filesystem{
-			RepoId:      r.option.Filesystem.RepoId,
-			RepoName:    r.option.Filesystem.RepoName,
-			SnapshotId:  r.option.Filesystem.SnapshotId,
-			Endpoint:    r.option.Filesystem.Endpoint,
-			Path:        r.option.Filesystem.Path,
-			Password:    password,
-			BaseHandler: &BaseHandler{},
-			Operator:    r.option.Operator,
-			BackupType:  r.option.BackupType,
-		}
-	} else {
-		logger.Fatalf("There is no suitable recovery method.")
+	password, err := resolvePassword(r.password, r.option.PasswordFile, r.option.PasswordCommand, r.option.SecretRefs, false)
+	if err != nil {
+		log.Errorf("get repository password error: %v", err)
+		return nil, "", 0, err
 	}
 
-	restoreOutput, metadata, totalBytes, err := service.Restore(r.option.Ctx, progressCallback)
+	name, option, err := r.option.location()
+	if err != nil {
+		log.Errorf("resolve location error: %v", err)
+		return nil, "", 0, err
+	}
+	record.Location, record.Repo = name, optionField(option, "RepoName")
+	spanLocation(span, name, option)
+	record.SnapshotId = optionField(option, "SnapshotId")
+	// only the first run checks the free space, after a window change the files restored so far use it
+	var skipSpaceCheck = r.option.Force
+	err = runScheduled(ctx, rateSchedule(option, "LimitDownloadRate", r.option.DownloadSchedule), func(ctx context.Context, rate int64) error {
+		var option, err = option, error(nil)
+		if rate > 0 {
+			if option, err = capRate(option, "LimitDownloadRate", strconv.FormatInt(rate, 10)); err != nil {
+				return err
+			}
+		}
+		service, err := newLocation(name, option, &LocationParams{
+			Password:       password,
+			Operator:       r.option.Operator,
+			BackupType:     r.option.BackupType,
+			SkipSpaceCheck: skipSpaceCheck,
+			SecretRefs:     r.option.SecretRefs,
+		})
+		if err != nil {
+			log.Errorf("new location error: %v", err)
+			return err
+		}
+		skipSpaceCheck = true
+		restoreSummary, metadata, totalBytes, err = service.Restore(ctx, progressCallback)
+		return err
+	})
 	if err != nil {
-		fmt.Printf("Restore error: %v", err)
+		log.Errorf("Restore error: %v, traceId: %s", err, utils.GetTraceId(ctx))
 	}
 
-	return restoreOutput, metadata, totalBytes, err
+	return restoreSummary, metadata, totalBytes, err
 }
@@ -0,0 +1,447 @@
+package s3
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+const (
+	CredentialsSourceStatic      = "static"
+	CredentialsSourceEnv         = "env"
+	CredentialsSourceWebIdentity = "web-identity"
+	CredentialsSourceProfile     = "profile"
+	CredentialsSourceEcs         = "ecs"
+	CredentialsSourceEc2         = "ec2"
+
+	defaultEc2MetadataEndpoint = "http://169.254.169.254"
+	defaultEcsMetadataEndpoint = "http://169.254.170.2"
+
+	// refresh temporary credentials ahead of their expiration
+	credentialsExpiryWindow = 5 * time.Minute
+)
+
+var ErrNoCredentials = errors.New("no valid aws credentials found, tried static keys, environment, web identity, shared credentials file, ecs and ec2 metadata")
+
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	Source          string
+}
+
+// CanExpire reports whether the credentials are temporary and can be refreshed
+func (c *Credentials) CanExpire() bool {
+	return !c.Expiration.IsZero()
+}
+
+func (c *Credentials) Expired() bool {
+	return c.CanExpire() && time.Now().Add(credentialsExpiryWindow).After(c.Expiration)
+}
+
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (*Credentials, error)
+}
+
+// CredentialsChain resolves credentials in the standard aws order:
+// static keys, environment, web identity token file, shared credentials profile, ecs container and ec2 instance metadata
+type CredentialsChain struct {
+	AccessKey       string
+	SecretAccessKey string
+	SessionToken    string
+	Profile         string
+	Region          string
+}
+
+func (c *CredentialsChain) providers() []CredentialsProvider {
+	var static = &staticProvider{accessKey: c.AccessKey, secretAccessKey: c.SecretAccessKey, sessionToken: c.SessionToken}
+	var profile = &profileProvider{profile: c.Profile}
+
+	// an explicitly selected profile takes precedence over the environment
+	if c.Profile != "" {
+		return []CredentialsProvider{static, profile, &envProvider{}, &webIdentityProvider{region: c.Region}, &ecsProvider{}, &ec2Provider{}}
+	}
+	return []CredentialsProvider{static, &envProvider{}, &webIdentityProvider{region: c.Region}, profile, &ecsProvider{}, &ec2Provider{}}
+}
+
+func (c *CredentialsChain) Retrieve(ctx context.Context) (*Credentials, error) {
+	var log = logger.FromContext(ctx)
+	var errs []error
+	for _, p := range c.providers() {
+		creds, err := p.Retrieve(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if creds == nil {
+			continue
+		}
+		log.Infof("aws credentials resolved from %s", creds.Source)
+		return creds, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNoCredentials, errors.Join(errs...))
+	}
+	return nil, ErrNoCredentials
+}
+
+// ~ static
+type staticProvider struct {
+	accessKey       string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func (p *staticProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	if p.accessKey == "" && p.secretAccessKey == "" {
+		return nil, nil
+	}
+	if p.accessKey == "" || p.secretAccessKey == "" {
+		return nil, errors.New("static credentials require both access key and secret access key")
+	}
+	return &Credentials{
+		AccessKeyId:     p.accessKey,
+		SecretAccessKey: p.secretAccessKey,
+		SessionToken:    p.sessionToken,
+		Source:          CredentialsSourceStatic,
+	}, nil
+}
+
+// ~ env
+type envProvider struct{}
+
+func (p *envProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	var ak = os.Getenv("AWS_ACCESS_KEY_ID")
+	var sk = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if ak == "" || sk == "" {
+		return nil, nil
+	}
+	return &Credentials{
+		AccessKeyId:     ak,
+		SecretAccessKey: sk,
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Source:          CredentialsSourceEnv,
+	}, nil
+}
+
+// ~ web identity
+type webIdentityProvider struct {
+	region string
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	XMLName xml.Name `xml:"AssumeRoleWithWebIdentityResponse"`
+	Result  struct {
+		Credentials struct {
+			AccessKeyId     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+			Expiration      string `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+func (p *webIdentityProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	var tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	var roleArn = os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleArn == "" {
+		return nil, nil
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read web identity token file error: %v", err)
+	}
+
+	var sessionName = utils.DefaultValue(fmt.Sprintf("olares-backups-%d", time.Now().Unix()), os.Getenv("AWS_ROLE_SESSION_NAME"))
+
+	resp, err := resty.New().SetTimeout(30 * time.Second).R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"Action":           "AssumeRoleWithWebIdentity",
+			"Version":          "2011-06-15",
+			"RoleArn":          roleArn,
+			"RoleSessionName":  sessionName,
+			"WebIdentityToken": strings.TrimSpace(string(token)),
+		}).
+		Get(p.stsEndpoint())
+	if err != nil {
+		return nil, fmt.Errorf("assume role with web identity error: %v", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("assume role with web identity failed, status code: %d", resp.StatusCode())
+	}
+
+	var result assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("assume role with web identity unmarshal error: %v", err)
+	}
+
+	var c = result.Result.Credentials
+	expiration, err := time.Parse(time.RFC3339, c.Expiration)
+	if err != nil {
+		return nil, fmt.Errorf("assume role with web identity expiration invalid: %v", err)
+	}
+
+	return &Credentials{
+		AccessKeyId:     c.AccessKeyId,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expiration:      expiration,
+		Source:          CredentialsSourceWebIdentity,
+	}, nil
+}
+
+func (p *webIdentityProvider) stsEndpoint() string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_STS"); endpoint != "" {
+		return endpoint
+	}
+	var region = utils.DefaultValue(os.Getenv("AWS_DEFAULT_REGION"), os.Getenv("AWS_REGION"))
+	region = utils.DefaultValue(p.region, region)
+	switch {
+	case region == "":
+		return fmt.Sprintf("https://sts.%s", constants.StorageS3Domain)
+	case strings.HasPrefix(region, "cn-"):
+		return fmt.Sprintf("https://sts.%s.%s", region, constants.StorageS3ChinaDomain)
+	default:
+		return fmt.Sprintf("https://sts.%s.%s", region, constants.StorageS3Domain)
+	}
+}
+
+// ~ shared credentials file
+type profileProvider struct {
+	profile string
+}
+
+func (p *profileProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	var profile = utils.DefaultValue(utils.DefaultValue("default", os.Getenv("AWS_PROFILE")), p.profile)
+
+	var files = []struct {
+		env     string
+		name    string
+		section string
+	}{
+		{env: "AWS_SHARED_CREDENTIALS_FILE", name: "credentials", section: profile},
+		{env: "AWS_CONFIG_FILE", name: "config", section: configSection(profile)},
+	}
+
+	for _, f := range files {
+		name, err := sharedFile(f.env, f.name)
+		if err != nil {
+			return nil, err
+		}
+		values, err := readIniSection(name, f.section)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil || values["aws_access_key_id"] == "" || values["aws_secret_access_key"] == "" {
+			continue
+		}
+		return &Credentials{
+			AccessKeyId:     values["aws_access_key_id"],
+			SecretAccessKey: values["aws_secret_access_key"],
+			SessionToken:    values["aws_session_token"],
+			Source:          CredentialsSourceProfile,
+		}, nil
+	}
+
+	if p.profile != "" {
+		return nil, fmt.Errorf("aws profile %s not found", p.profile)
+	}
+	return nil, nil
+}
+
+// sharedFile is the file of the environment variable env, ~/.aws/name when it is not set
+func sharedFile(env, name string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("aws shared %s file: %v", name, err)
+	}
+	return path.Join(home, ".aws", name), nil
+}
+
+func configSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+func readIniSection(name, section string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open %s error: %v", name, err)
+	}
+	defer f.Close()
+
+	var values map[string]string
+	var current string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if current == section {
+				values = make(map[string]string)
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			values[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// ~ ecs and ec2 metadata
+type metadataCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+func (m *metadataCredentials) credentials(source string) (*Credentials, error) {
+	if m.AccessKeyId == "" || m.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s metadata credentials are empty", source)
+	}
+	var creds = &Credentials{
+		AccessKeyId:     m.AccessKeyId,
+		SecretAccessKey: m.SecretAccessKey,
+		SessionToken:    m.Token,
+		Source:          source,
+	}
+	if m.Expiration != "" {
+		expiration, err := time.Parse(time.RFC3339, m.Expiration)
+		if err != nil {
+			return nil, fmt.Errorf("%s metadata credentials expiration invalid: %v", source, err)
+		}
+		creds.Expiration = expiration
+	}
+	return creds, nil
+}
+
+type ecsProvider struct{}
+
+func (p *ecsProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+	var endpoint string
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		endpoint = defaultEcsMetadataEndpoint + uri
+	} else if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		endpoint = uri
+	} else {
+		return nil, nil
+	}
+
+	var token = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		b, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ecs authorization token file error: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	req := resty.New().SetTimeout(5 * time.Second).R().SetContext(ctx)
+	if token != "" {
+		req.SetHeader("Authorization", token)
+	}
+
+	var result metadataCredentials
+	resp, err := req.SetResult(&result).Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("ecs metadata request error: %v", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("ecs metadata request failed, status code: %d", resp.StatusCode())
+	}
+
+	return result.credentials(CredentialsSourceEcs)
+}
+
+type ec2Provider struct{}
+
+func (p *ec2Provider) Retrieve(ctx context.Context) (*Credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, nil
+	}
+
+	var endpoint = strings.TrimRight(utils.DefaultValue(defaultEc2MetadataEndpoint, os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")), "/")
+	var client = resty.New().SetTimeout(2 * time.Second)
+
+	// imdsv2 session token, fall back to imdsv1 when the token api is unavailable
+	var token string
+	resp, err := client.R().SetContext(ctx).
+		SetHeader("X-aws-ec2-metadata-token-ttl-seconds", "21600").
+		Put(endpoint + "/latest/api/token")
+	if err != nil {
+		return nil, fmt.Errorf("ec2 metadata unavailable: %v", err)
+	}
+	if resp.StatusCode() == http.StatusOK {
+		token = resp.String()
+	}
+
+	var get = func(p string) (*resty.Response, error) {
+		req := client.R().SetContext(ctx)
+		if token != "" {
+			req.SetHeader("X-aws-ec2-metadata-token", token)
+		}
+		resp, err := req.Get(endpoint + p)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("ec2 metadata %s failed, status code: %d", p, resp.StatusCode())
+		}
+		return resp, nil
+	}
+
+	resp, err = get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	var role = strings.TrimSpace(strings.SplitN(resp.String(), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("ec2 instance has no iam role attached")
+	}
+
+	resp, err = get("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return nil, err
+	}
+
+	var result metadataCredentials
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("ec2 metadata credentials unmarshal error: %v", err)
+	}
+
+	return result.credentials(CredentialsSourceEc2)
+}
@@ -0,0 +1,292 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+)
+
+func setupCredentialsEnv(t *testing.T) string {
+	logger.SetLogger(zap.NewNop().Sugar())
+
+	var dir = t.TempDir()
+	for _, k := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME", "AWS_ENDPOINT_URL_STS",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+	} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", path.Join(dir, "config"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	return dir
+}
+
+func TestCredentialsChainOrder(t *testing.T) {
+	var dir = setupCredentialsEnv(t)
+
+	var credentialsFile = `
+[default]
+aws_access_key_id = DEFAULTAK
+aws_secret_access_key = DEFAULTSK
+
+[backup]
+aws_access_key_id = PROFILEAK
+aws_secret_access_key = PROFILESK
+aws_session_token = PROFILEST
+`
+	assert.Equal(t, os.WriteFile(path.Join(dir, "credentials"), []byte(credentialsFile), 0600), nil)
+
+	// static keys win
+	creds, err := (&CredentialsChain{AccessKey: "AK", SecretAccessKey: "SK", SessionToken: "ST"}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceStatic)
+	assert.Equal(t, creds.SessionToken, "ST")
+
+	// default profile when nothing else is set
+	creds, err = (&CredentialsChain{}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceProfile)
+	assert.Equal(t, creds.AccessKeyId, "DEFAULTAK")
+
+	// environment before the default profile
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVAK")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "ENVSK")
+	creds, err = (&CredentialsChain{}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceEnv)
+	assert.Equal(t, creds.AccessKeyId, "ENVAK")
+
+	// an explicit profile before the environment
+	creds, err = (&CredentialsChain{Profile: "backup"}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceProfile)
+	assert.Equal(t, creds.AccessKeyId, "PROFILEAK")
+	assert.Equal(t, creds.SessionToken, "PROFILEST")
+	assert.Equal(t, creds.CanExpire(), false)
+}
+
+func TestCredentialsChainConfigProfile(t *testing.T) {
+	var dir = setupCredentialsEnv(t)
+
+	var configFile = `
+[profile backup]
+region = us-east-1
+aws_access_key_id = CONFIGAK
+aws_secret_access_key = CONFIGSK
+`
+	assert.Equal(t, os.WriteFile(path.Join(dir, "config"), []byte(configFile), 0600), nil)
+
+	creds, err := (&CredentialsChain{Profile: "backup"}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.AccessKeyId, "CONFIGAK")
+
+	_, err = (&CredentialsChain{Profile: "missing"}).Retrieve(context.Background())
+	assert.NotEqual(t, err, nil)
+}
+
+func TestCredentialsChainEcs(t *testing.T) {
+	setupCredentialsEnv(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "ecs-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"AccessKeyId":"ECSAK","SecretAccessKey":"ECSSK","Token":"ECSST","Expiration":"2099-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/v2/credentials")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "ecs-token")
+
+	creds, err := (&CredentialsChain{}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceEcs)
+	assert.Equal(t, creds.SessionToken, "ECSST")
+	assert.Equal(t, creds.CanExpire(), true)
+	assert.Equal(t, creds.Expired(), false)
+}
+
+func TestCredentialsChainNoCredentials(t *testing.T) {
+	setupCredentialsEnv(t)
+
+	_, err := (&CredentialsChain{}).Retrieve(context.Background())
+	assert.NotEqual(t, err, nil)
+}
+
+func TestCredentialsChainWebIdentity(t *testing.T) {
+	var dir = setupCredentialsEnv(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query = r.URL.Query()
+		if query.Get("Action") != "AssumeRoleWithWebIdentity" || query.Get("WebIdentityToken") != "oidc-token" ||
+			query.Get("RoleArn") != "arn:aws:iam::123456789012:role/backups" || query.Get("RoleSessionName") != "nightly" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>WEBAK</AccessKeyId><SecretAccessKey>WEBSK</SecretAccessKey><SessionToken>WEBST</SessionToken>
+<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	assert.Equal(t, os.WriteFile(path.Join(dir, "token"), []byte("oidc-token\n"), 0600), nil)
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", path.Join(dir, "token"))
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/backups")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "nightly")
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	creds, err := (&CredentialsChain{}).Retrieve(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, creds.Source, CredentialsSourceWebIdentity)
+	assert.Equal(t, creds.AccessKeyId, "WEBAK")
+	assert.Equal(t, creds.SessionToken, "WEBST")
+	assert.Equal(t, creds.CanExpire(), true)
+
+	// a rejected token is an error, not a fall through to the next provider
+	t.Setenv("AWS_ROLE_SESSION_NAME", "other")
+	_, err = (&CredentialsChain{}).Retrieve(context.Background())
+	assert.NotEqual(t, err, nil)
+}
+
+func TestCredentialsChainEc2(t *testing.T) {
+	setupCredentialsEnv(t)
+
+	var tests = []struct {
+		name  string
+		imds2 bool
+	}{
+		{name: "imdsv2", imds2: true},
+		{name: "imdsv1", imds2: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/latest/api/token" {
+					if !tt.imds2 {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.Write([]byte("imds-token"))
+					return
+				}
+				if tt.imds2 && r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch r.URL.Path {
+				case "/latest/meta-data/iam/security-credentials/":
+					w.Write([]byte("backups-role\n"))
+				case "/latest/meta-data/iam/security-credentials/backups-role":
+					w.Write([]byte(`{"AccessKeyId":"EC2AK","SecretAccessKey":"EC2SK","Token":"EC2ST","Expiration":"2099-01-01T00:00:00Z"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			t.Setenv("AWS_EC2_METADATA_DISABLED", "false")
+			t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+
+			creds, err := (&CredentialsChain{}).Retrieve(context.Background())
+			assert.Equal(t, err, nil)
+			assert.Equal(t, creds.Source, CredentialsSourceEc2)
+			assert.Equal(t, creds.AccessKeyId, "EC2AK")
+			assert.Equal(t, creds.SessionToken, "EC2ST")
+			assert.Equal(t, creds.CanExpire(), true)
+		})
+	}
+}
+
+func TestCredentialsChainNoHome(t *testing.T) {
+	setupCredentialsEnv(t)
+	t.Setenv("HOME", "")
+
+	// the files of the environment do not need the home directory
+	_, err := (&CredentialsChain{}).Retrieve(context.Background())
+	assert.Equal(t, errors.Is(err, ErrNoCredentials), true)
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "")
+	_, err = (&CredentialsChain{}).Retrieve(context.Background())
+	assert.NotEqual(t, err, nil)
+	assert.Equal(t, strings.Contains(err.Error(), "aws shared credentials file"), true)
+}
+
+func TestRunRefreshed(t *testing.T) {
+	setupCredentialsEnv(t)
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"AccessKeyId":"ECSAK%d","SecretAccessKey":"ECSSK","Token":"ECSST","Expiration":"2099-01-01T00:00:00Z"}`, calls)
+	}))
+	defer server.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL)
+
+	var tests = []struct {
+		name  string
+		creds *Credentials
+		wait  bool // the run with the old credentials waits to be stopped
+		errs  []error
+		keys  []string
+		err   bool
+	}{
+		{
+			// the run is stopped ahead of the expiration and runs again with refreshed credentials
+			name:  "refresh ahead of expiry",
+			creds: &Credentials{AccessKeyId: "OLD", Expiration: time.Now().Add(credentialsExpiryWindow + 50*time.Millisecond)},
+			wait:  true,
+			errs:  []error{nil},
+			keys:  []string{"OLD", "ECSAK1"},
+		},
+		{
+			name:  "refresh after an expired token",
+			creds: &Credentials{AccessKeyId: "OLD", Expiration: time.Now().Add(time.Hour)},
+			errs:  []error{errors.New("ExpiredToken: The security token included in the request is expired"), nil},
+			keys:  []string{"OLD", "ECSAK1"},
+		},
+		{
+			name:  "static credentials are not refreshed",
+			creds: &Credentials{AccessKeyId: "STATIC"},
+			errs:  []error{errors.New(restic.ERROR_MESSAGE_TOKEN_EXPIRED.Error())},
+			keys:  []string{"STATIC"},
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			var s = &Aws{credentials: tt.creds}
+			var keys []string
+			var err = s.runRefreshed(context.Background(), "", "backup", func(ctx context.Context) error {
+				keys = append(keys, s.credentials.AccessKeyId)
+				if tt.wait && s.credentials.AccessKeyId == "OLD" {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				var err = tt.errs[0]
+				tt.errs = tt.errs[1:]
+				return err
+			})
+			assert.Equal(t, err != nil, tt.err)
+			assert.Equal(t, keys, tt.keys)
+		})
+	}
+}
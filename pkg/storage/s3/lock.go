@@ -0,0 +1,204 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	miniocreds "github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/olekukonko/tablewriter"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+var ErrObjectLockDisabled = errors.New("object lock is not enabled on the bucket, create the bucket with object lock enabled to use immutable backups")
+
+// SnapshotLock is the retention of a snapshot object in the repository
+type SnapshotLock struct {
+	SnapshotId  string     `json:"snapshot_id"`
+	Mode        string     `json:"mode,omitempty"`
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+}
+
+func (l *SnapshotLock) Locked() bool {
+	return l.RetainUntil != nil && l.RetainUntil.After(time.Now())
+}
+
+type SnapshotLocks []*SnapshotLock
+
+func (l SnapshotLocks) PrintTable() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Mode", "Retain Until", "Locked"})
+
+	for _, s := range l {
+		var retainUntil = "-"
+		if s.RetainUntil != nil {
+			retainUntil = s.RetainUntil.Local().Format(time.RFC3339)
+		}
+		table.Append([]string{s.SnapshotId, utils.DefaultValue("-", s.Mode), retainUntil, strconv.FormatBool(s.Locked())})
+	}
+	table.Render()
+}
+
+// objectLock applies S3 Object Lock retention to the objects of a restic repository.
+// Objects are locked in COMPLIANCE mode, so they cannot be deleted or overwritten
+// before the retention expires, not even with the credentials used for the backup.
+type objectLock struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func (s *Aws) newObjectLock() (*objectLock, error) {
+	ep, err := s3format(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv("")
+	var secure = !ep.compatible || ep.scheme == "https"
+	client, err := minio.New(ep.host(), &minio.Options{
+		Creds:        miniocreds.NewStaticV4(envs.AWS_ACCESS_KEY_ID, envs.AWS_SECRET_ACCESS_KEY, envs.AWS_SESSION_TOKEN),
+		Secure:       secure,
+		Region:       ep.region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectLock{
+		client: client,
+		bucket: ep.bucket,
+		prefix: ep.objectPrefix(s.RepoName, s.RepoId),
+	}, nil
+}
+
+// check verifies that object lock is enabled on the bucket
+func (o *objectLock) check(ctx context.Context) error {
+	enabled, _, _, _, err := o.client.GetObjectLockConfig(ctx, o.bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "ObjectLockConfigurationNotFoundError" {
+			return ErrObjectLockDisabled
+		}
+		return fmt.Errorf("get bucket %s object lock config error: %v", o.bucket, err)
+	}
+	if enabled != "Enabled" {
+		return ErrObjectLockDisabled
+	}
+	return nil
+}
+
+// clockSkew is the margin between the local clock and the last modified time of the bucket
+const clockSkew = 5 * time.Minute
+
+// extend sets the retention of the repository objects to at least until: the config, the keys, the packs
+// and index files, which the new snapshot may reference however old they are, and the snapshot files written
+// since the backup started. Older snapshot files keep their own retention, so they can be forgotten once it
+// expires. Lock files are skipped so that restic can still remove stale locks
+func (o *objectLock) extend(ctx context.Context, since time.Time, until time.Time) (int, error) {
+	var log = logger.FromContext(ctx)
+	var mode = minio.Compliance
+	var locked int
+	var locksPrefix = o.prefix + "/locks/"
+	var snapshotsPrefix = o.prefix + "/snapshots/"
+
+	for obj := range o.client.ListObjects(ctx, o.bucket, minio.ListObjectsOptions{Prefix: o.prefix + "/", Recursive: true}) {
+		if obj.Err != nil {
+			return locked, fmt.Errorf("list repository objects error: %v", obj.Err)
+		}
+		if strings.HasPrefix(obj.Key, locksPrefix) || strings.HasPrefix(obj.Key, snapshotsPrefix) && obj.LastModified.Before(since.Add(-clockSkew)) {
+			continue
+		}
+
+		_, current, err := o.client.GetObjectRetention(ctx, o.bucket, obj.Key, "")
+		if err == nil && current != nil && !current.Before(until) {
+			continue
+		}
+
+		if err = o.client.PutObjectRetention(ctx, o.bucket, obj.Key, minio.PutObjectRetentionOptions{
+			Mode:            &mode,
+			RetainUntilDate: &until,
+		}); err != nil {
+			return locked, fmt.Errorf("put object %s retention error: %v", obj.Key, err)
+		}
+		locked++
+	}
+
+	log.Infof("s3 object lock, bucket: %s, prefix: %s, objects locked: %d, retain until: %s", o.bucket, o.prefix, locked, until.Format(time.RFC3339))
+
+	return locked, nil
+}
+
+// lockedUntil is the retention of the newest snapshot object, backups lock the objects they add, so
+// nothing of the repository may be pruned before it. It is zero when object lock is not enabled on the bucket
+func (o *objectLock) lockedUntil(ctx context.Context) (time.Time, error) {
+	if err := o.check(ctx); err != nil {
+		if errors.Is(err, ErrObjectLockDisabled) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	var newest minio.ObjectInfo
+	for obj := range o.client.ListObjects(ctx, o.bucket, minio.ListObjectsOptions{Prefix: o.prefix + "/snapshots/", Recursive: true}) {
+		if obj.Err != nil {
+			return time.Time{}, fmt.Errorf("list repository snapshots error: %v", obj.Err)
+		}
+		if obj.LastModified.After(newest.LastModified) {
+			newest = obj
+		}
+	}
+	if newest.Key == "" {
+		return time.Time{}, nil
+	}
+
+	lock, err := o.snapshotLock(ctx, path.Base(newest.Key))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lock.RetainUntil == nil {
+		return time.Time{}, nil
+	}
+	return *lock.RetainUntil, nil
+}
+
+// lockedSnapshots are the ids of the snapshots whose objects are still under retention
+func (o *objectLock) lockedSnapshots(ctx context.Context) ([]string, error) {
+	var res []string
+	for obj := range o.client.ListObjects(ctx, o.bucket, minio.ListObjectsOptions{Prefix: o.prefix + "/snapshots/", Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("list repository snapshots error: %v", obj.Err)
+		}
+		lock, err := o.snapshotLock(ctx, path.Base(obj.Key))
+		if err != nil {
+			return nil, err
+		}
+		if lock.Locked() {
+			res = append(res, lock.SnapshotId)
+		}
+	}
+	return res, nil
+}
+
+func (o *objectLock) snapshotLock(ctx context.Context, snapshotId string) (*SnapshotLock, error) {
+	var lock = &SnapshotLock{SnapshotId: snapshotId}
+	mode, until, err := o.client.GetObjectRetention(ctx, o.bucket, path.Join(o.prefix, "snapshots", snapshotId), "")
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchObjectLockConfiguration" {
+			return lock, nil
+		}
+		return nil, fmt.Errorf("get snapshot %s retention error: %v", snapshotId, err)
+	}
+	if mode != nil {
+		lock.Mode = string(*mode)
+	}
+	lock.RetainUntil = until
+	return lock, nil
+}
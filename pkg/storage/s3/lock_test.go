@@ -0,0 +1,191 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// fakeBucket serves the object lock calls of a bucket with object lock enabled
+type fakeBucket struct {
+	mu       sync.Mutex
+	disabled bool
+	modified map[string]time.Time
+	retain   map[string]time.Time
+	puts     []string
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var key = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+	var query = r.URL.Query()
+	switch {
+	case query.Has("location"):
+		fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+	case query.Has("object-lock"):
+		if b.disabled {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>ObjectLockConfigurationNotFoundError</Code></Error>`)
+			return
+		}
+		fmt.Fprint(w, `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`)
+	case query.Has("retention") && r.Method == http.MethodPut:
+		var retention struct {
+			RetainUntilDate time.Time
+		}
+		data, _ := io.ReadAll(r.Body)
+		_ = xml.Unmarshal(data, &retention)
+		b.retain[key] = retention.RetainUntilDate
+		b.puts = append(b.puts, key)
+	case query.Has("retention"):
+		until, ok := b.retain[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchObjectLockConfiguration</Code></Error>`)
+			return
+		}
+		fmt.Fprintf(w, `<Retention><Mode>COMPLIANCE</Mode><RetainUntilDate>%s</RetainUntilDate></Retention>`, until.Format(time.RFC3339))
+	case query.Get("list-type") == "2":
+		var keys []string
+		for k := range b.modified {
+			if strings.HasPrefix(k, query.Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(w, `<ListBucketResult><Name>bucket</Name><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>`, len(keys))
+		for _, k := range keys {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>1</Size></Contents>`, k, b.modified[k].UTC().Format(time.RFC3339))
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newFakeBucket(t *testing.T, b *fakeBucket) *Aws {
+	setupCredentialsEnv(t)
+	var server = httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	return &Aws{RepoId: "id", RepoName: "home", Endpoint: server.URL + "/bucket", AccessKey: "ak", SecretAccessKey: "sk"}
+}
+
+func TestLockedUntil(t *testing.T) {
+	var now = time.Now().Truncate(time.Second)
+	var prefix = "olares-backups/home-id/"
+	var tests = []struct {
+		name   string
+		bucket *fakeBucket
+		until  time.Time
+	}{
+		{
+			name:   "object lock disabled",
+			bucket: &fakeBucket{disabled: true},
+		},
+		{
+			name:   "no snapshot",
+			bucket: &fakeBucket{modified: map[string]time.Time{prefix + "config": now}},
+		},
+		{
+			name: "newest snapshot",
+			bucket: &fakeBucket{
+				modified: map[string]time.Time{prefix + "snapshots/old": now.Add(-48 * time.Hour), prefix + "snapshots/new": now},
+				retain:   map[string]time.Time{prefix + "snapshots/old": now.Add(time.Hour), prefix + "snapshots/new": now.Add(30 * 24 * time.Hour)},
+			},
+			until: now.Add(30 * 24 * time.Hour),
+		},
+		{
+			name:   "snapshot without retention",
+			bucket: &fakeBucket{modified: map[string]time.Time{prefix + "snapshots/new": now}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.bucket.retain == nil {
+				tt.bucket.retain = map[string]time.Time{}
+			}
+			var s = newFakeBucket(t, tt.bucket)
+			lock, err := s.newObjectLock()
+			assert.Equal(t, err, nil)
+			until, err := lock.lockedUntil(context.Background())
+			assert.Equal(t, err, nil)
+			assert.Equal(t, until.Equal(tt.until), true)
+		})
+	}
+}
+
+func TestExtend(t *testing.T) {
+	var now = time.Now().Truncate(time.Second)
+	var prefix = "olares-backups/home-id/"
+	var until = now.Add(30 * 24 * time.Hour)
+	var bucket = &fakeBucket{
+		modified: map[string]time.Time{
+			prefix + "config":        now.Add(-90 * 24 * time.Hour),
+			prefix + "keys/old":      now.Add(-90 * 24 * time.Hour),
+			prefix + "data/00/old":   now.Add(-48 * time.Hour),
+			prefix + "index/old":     now.Add(-48 * time.Hour),
+			prefix + "snapshots/old": now.Add(-48 * time.Hour),
+			prefix + "data/01/new":   now,
+			prefix + "index/new":     now,
+			prefix + "snapshots/new": now,
+			prefix + "locks/new":     now,
+			prefix + "data/02/again": now,
+		},
+		retain: map[string]time.Time{
+			prefix + "data/00/old":   now.Add(time.Hour),
+			prefix + "snapshots/old": now.Add(time.Hour),
+			prefix + "data/02/again": until.Add(time.Hour),
+		},
+	}
+	var s = newFakeBucket(t, bucket)
+	lock, err := s.newObjectLock()
+	assert.Equal(t, err, nil)
+
+	// the old pack the new snapshot reuses is locked again, the old snapshot, the lock file and a longer retention are kept
+	locked, err := lock.extend(context.Background(), now, until)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, locked, 7)
+	sort.Strings(bucket.puts)
+	assert.Equal(t, bucket.puts, []string{prefix + "config", prefix + "data/00/old", prefix + "data/01/new", prefix + "index/new",
+		prefix + "index/old", prefix + "keys/old", prefix + "snapshots/new"})
+	assert.Equal(t, bucket.retain[prefix+"data/00/old"].Equal(until), true)
+	assert.Equal(t, bucket.retain[prefix+"snapshots/old"].Equal(now.Add(time.Hour)), true)
+	assert.Equal(t, bucket.retain[prefix+"data/02/again"].Equal(until.Add(time.Hour)), true)
+}
+
+func TestLockedSnapshots(t *testing.T) {
+	var now = time.Now().Truncate(time.Second)
+	var prefix = "olares-backups/home-id/"
+	var bucket = &fakeBucket{
+		modified: map[string]time.Time{
+			prefix + "snapshots/expired":   now.Add(-90 * 24 * time.Hour),
+			prefix + "snapshots/unlocked":  now.Add(-48 * time.Hour),
+			prefix + "snapshots/locked":    now,
+			prefix + "data/00/locked-pack": now,
+		},
+		retain: map[string]time.Time{
+			prefix + "snapshots/expired":   now.Add(-time.Hour),
+			prefix + "snapshots/locked":    now.Add(time.Hour),
+			prefix + "data/00/locked-pack": now.Add(time.Hour),
+		},
+	}
+	var s = newFakeBucket(t, bucket)
+	lock, err := s.newObjectLock()
+	assert.Equal(t, err, nil)
+
+	locked, err := lock.lockedSnapshots(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, locked, []string{"locked"})
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
@@ -22,11 +24,17 @@ type Aws struct {
 	Endpoint                 string
 	AccessKey                string
 	SecretAccessKey          string
+	SessionToken             string
+	Profile                  string
 	Password                 string
 	LimitUploadRate          string
 	LimitDownloadRate        string
+	StorageClass             string
+	Immutable                bool
+	RetentionDays            int
 	Path                     string
 	Files                    []string
+	Excludes                 []string
 	FilesPrefixPath          string
 	Metadata                 string
 	BaseHandler              base.Interface
@@ -34,43 +42,86 @@ type Aws struct {
 	BackupType               string
 	BackupAppTypeName        string
 	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
+
+	credentials *Credentials
 }
 
+// credentials refresh attempts when a restic run fails with an expired token
+const maxCredentialsRefresh = 3
+
+// errCredentialsExpiring ends a run of restic before its temporary credentials expire
+var errCredentialsExpiring = errors.New("aws credentials expiring")
+
 func (s *Aws) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err = s.FormatRepository()
 	if err != nil {
 		return
 	}
 
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return
+	}
+
+	var immutableUntil time.Time
+	if s.Immutable {
+		if immutableUntil, err = s.checkObjectLock(ctx); err != nil {
+			return
+		}
+	}
+
 	var envs = s.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
 		RepoId:                   s.RepoId,
 		RepoName:                 s.RepoName,
 		Path:                     s.Path,
 		Files:                    s.Files,
+		Excludes:                 s.Excludes,
 		FilesPrefixPath:          s.FilesPrefixPath,
 		Metadata:                 s.Metadata,
 		LimitUploadRate:          s.LimitUploadRate,
+		StorageClass:             s.StorageClass,
+		ImmutableUntil:           immutableUntil,
 		Operator:                 s.Operator,
 		BackupType:               s.BackupType,
 		BackupAppTypeName:        s.BackupAppTypeName,
 		BackupFileTypeSourcePath: s.BackupFileTypeSourcePath,
+		BackupSetId:              s.BackupSetId,
 		RepoEnvs:                 envs,
 	}
 
-	logger.Debugf("s3 backup env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("s3 backup env vars: %s", envs.String())
 
 	s.BaseHandler.SetOptions(opts)
 
-	backupSummary, err = s.BaseHandler.Backup(ctx, dryRun, progressCallback)
+	var started = time.Now()
+	err = s.runRefreshed(ctx, storageInfo.RegionId, "backup", func(ctx context.Context) error {
+		var err error
+		opts.RepoEnvs = s.GetEnv(storageInfo.Url)
+		backupSummary, err = s.BaseHandler.Backup(ctx, dryRun, progressCallback)
+		return err
+	})
+
+	if err == nil && s.Immutable && !dryRun {
+		err = s.lockRepository(ctx, started, immutableUntil)
+	}
+
 	return backupSummary, storageInfo, err
 }
 
 func (s *Aws) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := s.FormatRepository()
 	if err != nil {
 		return nil, "", 0, err
 	}
+
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, "", 0, err
+	}
+
 	var envs = s.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
 		RepoId:            s.RepoId,
@@ -79,20 +130,36 @@ func (s *Aws) Restore(ctx context.Context, progressCallback func(percentDone flo
 		RepoEnvs:          envs,
 		Path:              s.Path,
 		LimitDownloadRate: s.LimitDownloadRate,
+		SkipSpaceCheck:    s.SkipSpaceCheck,
 	}
 
-	logger.Debugf("s3 restore env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("s3 restore env vars: %s", envs.String())
 
 	s.BaseHandler.SetOptions(opts)
-	return s.BaseHandler.Restore(ctx, progressCallback)
+
+	var restoreSummary map[string]*restic.RestoreSummaryOutput
+	var metadata string
+	var totalBytes uint64
+	err = s.runRefreshed(ctx, storageInfo.RegionId, "restore", func(ctx context.Context) error {
+		var err error
+		opts.RepoEnvs = s.GetEnv(storageInfo.Url)
+		restoreSummary, metadata, totalBytes, err = s.BaseHandler.Restore(ctx, progressCallback)
+		return err
+	})
+	return restoreSummary, metadata, totalBytes, err
 }
 
 func (s *Aws) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := s.FormatRepository()
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
 	var envs = s.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
 		RepoId:   s.RepoId,
@@ -100,18 +167,23 @@ func (s *Aws) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("s3 snapshots env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("s3 snapshots env vars: %s", envs.String())
 
 	s.BaseHandler.SetOptions(opts)
 	return s.BaseHandler.Snapshots(ctx)
 }
 
 func (s *Aws) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := s.FormatRepository()
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
 	var envs = s.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
 		RepoId:   s.RepoId,
@@ -119,18 +191,23 @@ func (s *Aws) GetSnapshot(ctx context.Context, snapshotId string) (*restic.Snaps
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("s3 snapshot env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("s3 snapshot env vars: %s", envs.String())
 
 	s.BaseHandler.SetOptions(opts)
 	return s.BaseHandler.GetSnapshot(ctx, snapshotId)
 }
 
 func (s *Aws) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
 	storageInfo, err := s.FormatRepository()
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
 	var envs = s.GetEnv(storageInfo.Url)
 	var opts = &restic.ResticOptions{
 		RepoId:   s.RepoId,
@@ -138,12 +215,191 @@ func (s *Aws) Stats(ctx context.Context) (*restic.StatsContainer, error) {
 		RepoEnvs: envs,
 	}
 
-	logger.Debugf("s3 stats env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("s3 stats env vars: %s", envs.String())
 
 	s.BaseHandler.SetOptions(opts)
 	return s.BaseHandler.Stats(ctx)
 }
 
+func (s *Aws) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
+	// the snapshots option does not tell whether the backups are immutable, the bucket does
+	lock, err := s.newObjectLock()
+	if err != nil {
+		return nil, err
+	}
+	immutableUntil, err := lock.lockedUntil(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the data is locked as long as the newest snapshot, the older snapshots may be forgotten before
+	var lockedSnapshots []string
+	if !immutableUntil.IsZero() {
+		if lockedSnapshots, err = lock.lockedSnapshots(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:          s.RepoId,
+		RepoName:        s.RepoName,
+		ImmutableUntil:  immutableUntil,
+		LockedSnapshots: lockedSnapshots,
+		RepoEnvs:        envs,
+	}
+
+	log.Debugf("s3 forget env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Forget(ctx, policy)
+}
+
+func (s *Aws) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:         s.RepoId,
+		RepoName:       s.RepoName,
+		ImmutableUntil: s.lockedUntil(ctx),
+		RepoEnvs:       envs,
+	}
+
+	log.Debugf("s3 check env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Check(ctx, readDataSubset)
+}
+
+func (s *Aws) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:         s.RepoId,
+		RepoName:       s.RepoName,
+		ImmutableUntil: s.lockedUntil(ctx),
+		RepoEnvs:       envs,
+	}
+
+	log.Debugf("s3 ls env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Ls(ctx, snapshotId, path, recursive)
+}
+
+// Locks reports the object lock retention of every snapshot in the repository
+func (s *Aws) Locks(ctx context.Context) (SnapshotLocks, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.resolveCredentials(ctx, storageInfo.RegionId); err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:   s.RepoId,
+		RepoName: s.RepoName,
+		RepoEnvs: envs,
+	}
+
+	log.Debugf("s3 locks env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	snapshots, err := s.BaseHandler.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := s.newObjectLock()
+	if err != nil {
+		return nil, err
+	}
+
+	var locks SnapshotLocks
+	for _, snapshot := range *snapshots {
+		l, err := lock.snapshotLock(ctx, snapshot.Id)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+
+	return locks, nil
+}
+
+// checkObjectLock verifies the bucket before the repository is initialized and
+// returns the retention that will be applied once the backup is finished
+func (s *Aws) checkObjectLock(ctx context.Context) (time.Time, error) {
+	if s.RetentionDays <= 0 {
+		return time.Time{}, errors.New("retention days must be greater than 0 for immutable backups")
+	}
+
+	lock, err := s.newObjectLock()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err = lock.check(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().AddDate(0, 0, s.RetentionDays), nil
+}
+
+// lockedUntil is the object lock retention of the repository for the operations that do not remove data,
+// they run without it when the bucket cannot be asked
+func (s *Aws) lockedUntil(ctx context.Context) time.Time {
+	var log = logger.FromContext(ctx)
+	lock, err := s.newObjectLock()
+	if err == nil {
+		var until time.Time
+		if until, err = lock.lockedUntil(ctx); err == nil {
+			return until
+		}
+	}
+	log.Warnf("s3 repo %s object lock retention unknown: %v", s.RepoName, err)
+	return time.Time{}
+}
+
+func (s *Aws) lockRepository(ctx context.Context, since time.Time, until time.Time) error {
+	lock, err := s.newObjectLock()
+	if err != nil {
+		return err
+	}
+	_, err = lock.extend(ctx, since, until)
+	return err
+}
+
 func (s *Aws) Regions() ([]map[string]string, error) {
 	return nil, nil
 }
@@ -152,88 +408,298 @@ func (s *Aws) GetEnv(repository string) *restic.ResticEnvs {
 	var envs = &restic.ResticEnvs{
 		AWS_ACCESS_KEY_ID:     s.AccessKey,
 		AWS_SECRET_ACCESS_KEY: s.SecretAccessKey,
+		AWS_SESSION_TOKEN:     s.SessionToken,
 		RESTIC_REPOSITORY:     repository,
 		RESTIC_PASSWORD:       s.Password,
 	}
+	if s.credentials != nil {
+		envs.AWS_ACCESS_KEY_ID = s.credentials.AccessKeyId
+		envs.AWS_SECRET_ACCESS_KEY = s.credentials.SecretAccessKey
+		envs.AWS_SESSION_TOKEN = s.credentials.SessionToken
+	}
 	return envs
 }
 
+func (s *Aws) resolveCredentials(ctx context.Context, region string) error {
+	if s.credentials != nil && !s.credentials.Expired() {
+		return nil
+	}
+	return s.refreshCredentials(ctx, region)
+}
+
+func (s *Aws) refreshCredentials(ctx context.Context, region string) error {
+	var chain = &CredentialsChain{
+		AccessKey:       s.AccessKey,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+		Profile:         s.Profile,
+		Region:          region,
+	}
+	creds, err := chain.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	s.credentials = creds
+	return nil
+}
+
+// shouldRefresh reports whether a failed run can be retried with refreshed temporary credentials
+func (s *Aws) shouldRefresh(err error) bool {
+	if s.credentials == nil || !s.credentials.CanExpire() {
+		return false
+	}
+	return restic.IsTokenExpired(err)
+}
+
+// runRefreshed calls run until it finishes with credentials that did not expire. restic reads the
+// credentials once at start, so a run with temporary credentials is stopped ahead of their expiration
+// and run again with refreshed ones, restic deduplicates and skips what the stopped run saved. A run
+// that failed with an expired token is retried too, maxCredentialsRefresh times at most
+func (s *Aws) runRefreshed(ctx context.Context, region string, operation string, run func(ctx context.Context) error) error {
+	var log = logger.FromContext(ctx)
+	for failures := 0; ; {
+		var runCtx, cancel = context.WithCancelCause(ctx)
+		var timer *time.Timer
+		if s.credentials != nil && s.credentials.CanExpire() {
+			// credentials that expire within the window run until they fail
+			if refreshAt := s.credentials.Expiration.Add(-credentialsExpiryWindow); refreshAt.After(time.Now()) {
+				timer = time.AfterFunc(time.Until(refreshAt), func() { cancel(errCredentialsExpiring) })
+			}
+		}
+		var err = run(runCtx)
+		var expiring = err != nil && ctx.Err() == nil && errors.Is(context.Cause(runCtx), errCredentialsExpiring)
+		if timer != nil {
+			timer.Stop()
+		}
+		cancel(nil)
+
+		switch {
+		case err == nil:
+			return nil
+		case expiring:
+			log.Infof("s3 %s stopped, aws credentials expire at %s, refresh and retrying...", operation, s.credentials.Expiration.Format(time.RFC3339))
+		case failures < maxCredentialsRefresh && s.shouldRefresh(err):
+			failures++
+			log.Infof("s3 %s stopped, aws credentials expired, refresh and retrying...", operation)
+		default:
+			return err
+		}
+		if err = s.refreshCredentials(ctx, region); err != nil {
+			return err
+		}
+	}
+}
+
 // {bucket}.{region}.amazonaws.com/{prefix}
 // {bucket}.s3.{region}.amazonaws.com/{prefix}
 // s3.{region}.amazonaws.com/{bucket}/{prefix}
+//
+// dualstack, fips and legacy s3-{region} hosts are accepted in both styles,
+// as well as the amazonaws.com.cn domain of the China partition.
+// Any other host is taken as an S3-compatible service (e.g. MinIO): http(s)://{host}/{bucket}/{prefix}
 func (s *Aws) FormatRepository() (storageInfo *model.StorageInfo, err error) {
 	if s.Endpoint == "" {
 		err = errors.New("s3 endpoint is required")
 		return
 	}
 
-	b, r, p, ep, err := s3format(s.Endpoint, s.RepoName, s.RepoId)
+	ep, err := s3format(s.Endpoint)
 	if err != nil {
 		return nil, err
 	}
 
+	var cloudName = constants.CloudAWSName
+	if ep.compatible {
+		cloudName = constants.CloudS3CompatibleName
+	}
+
 	storageInfo = &model.StorageInfo{
-		Location:  "awss3",
-		Url:       ep,
-		CloudName: constants.CloudAWSName,
-		RegionId:  r,
-		Bucket:    b,
-		Prefix:    p,
+		Location:     "awss3",
+		Url:          ep.repository(s.RepoName, s.RepoId),
+		CloudName:    cloudName,
+		RegionId:     ep.region,
+		Bucket:       ep.bucket,
+		Prefix:       ep.prefix,
+		Partition:    ep.partition,
+		Endpoint:     ep.host(),
+		StorageClass: s.StorageClass,
 	}
 
 	return
 }
 
-func s3format(rawurl string, repoName, repoId string) (bucket, region, prefix, endpoint string, err error) {
+var awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+type awsEndpoint struct {
+	bucket    string
+	region    string
+	prefix    string
+	partition string
+	domain    string
+	dualstack bool
+	fips      bool
+
+	// S3-compatible services such as MinIO, addressed path-style at scheme://{host}/{bucket}/{prefix}
+	compatible bool
+	scheme     string
+	hostport   string
+}
+
+// host rebuilds the path-style endpoint host, legacy s3-{region} hosts are normalized to s3.{region}
+func (e *awsEndpoint) host() string {
+	if e.compatible {
+		return e.hostport
+	}
+	var labels = []string{"s3"}
+	if e.fips {
+		labels[0] = "s3-fips"
+	}
+	if e.dualstack {
+		labels = append(labels, "dualstack")
+	}
+	labels = append(labels, e.region, e.domain)
+	return strings.Join(labels, ".")
+}
+
+func (e *awsEndpoint) repository(repoName, repoId string) string {
+	var scheme = "https"
+	if e.compatible {
+		scheme = e.scheme
+	}
+	return fmt.Sprintf("s3:%s://%s/%s/%s", scheme, e.host(), e.bucket, e.objectPrefix(repoName, repoId))
+}
+
+// objectPrefix is the key prefix of the restic repository inside the bucket
+func (e *awsEndpoint) objectPrefix(repoName, repoId string) string {
+	var repo = utils.JoinName(utils.EncodeURLPart(repoName), repoId)
+	if e.prefix != "" {
+		return fmt.Sprintf("%s/%s/%s", e.prefix, constants.OlaresStorageDefaultPrefix, repo)
+	}
+	return fmt.Sprintf("%s/%s", constants.OlaresStorageDefaultPrefix, repo)
+}
+
+func s3format(rawurl string) (*awsEndpoint, error) {
 	u, err := url.Parse(rawurl)
 	if err != nil {
-		return "", "", "", "", err
+		return nil, err
 	}
 
-	host := u.Host
-	path := strings.Trim(u.Path, "/")
-	parts := strings.Split(host, ".")
+	var ep = &awsEndpoint{}
+	var host = strings.ToLower(u.Hostname())
+	var path = strings.Trim(u.Path, "/")
 
-	if len(parts) < 3 || parts[len(parts)-2] != "amazonaws" || parts[len(parts)-1] != "com" {
-		return "", "", "", "", errors.New("host is not a valid amazonaws.com domain")
+	switch {
+	case strings.HasSuffix(host, "."+constants.StorageS3ChinaDomain):
+		ep.domain = constants.StorageS3ChinaDomain
+		ep.partition = constants.AwsPartitionChina
+	case strings.HasSuffix(host, "."+constants.StorageS3Domain):
+		ep.domain = constants.StorageS3Domain
+		ep.partition = constants.AwsPartitionDefault
+	case host == constants.StorageTencentDoman || strings.HasSuffix(host, "."+constants.StorageTencentDoman):
+		return nil, fmt.Errorf("host %s is a Tencent COS domain, use the cos location instead", host)
+	default:
+		return s3compatibleFormat(u)
 	}
 
-	switch {
-	case strings.HasPrefix(host, "s3."):
-		if len(parts) < 4 {
-			return "", "", "", "", errors.New("host format invalid for s3.region.amazonaws.com")
+	var labels = strings.Split(strings.TrimSuffix(host, "."+ep.domain), ".")
+	var service = -1
+	for i := len(labels) - 1; i >= 0; i-- {
+		if label := labels[i]; label == "s3" || label == "s3-fips" || label == "s3-external-1" ||
+			(strings.HasPrefix(label, "s3-") && awsRegionPattern.MatchString(strings.TrimPrefix(label, "s3-"))) {
+			service = i
+			break
 		}
-		region = parts[1]
-		pathParts := strings.SplitN(path, "/", 2)
-		if len(pathParts) < 1 || pathParts[0] == "" {
-			return "", "", "", "", errors.New("bucket not found in path")
+	}
+
+	var rest []string
+	switch {
+	case service >= 0:
+		ep.bucket = strings.Join(labels[:service], ".")
+		rest = labels[service+1:]
+
+		switch label := labels[service]; {
+		case label == "s3-fips":
+			ep.fips = true
+		case label == "s3-external-1":
+			ep.region = "us-east-1"
+		case strings.HasPrefix(label, "s3-"):
+			ep.region = strings.TrimPrefix(label, "s3-")
 		}
-		bucket = pathParts[0]
-		if len(pathParts) == 2 {
-			prefix = pathParts[1]
-		} else {
-			prefix = ""
+
+		if len(rest) > 0 && rest[0] == "dualstack" {
+			ep.dualstack = true
+			rest = rest[1:]
 		}
 
-	case len(parts) >= 5 && parts[1] == "s3":
-		bucket = parts[0]
-		region = parts[2]
-		prefix = path
+		switch {
+		case len(rest) == 1 && ep.region == "":
+			ep.region = rest[0]
+		case len(rest) == 0 && ep.region == "" && !ep.fips && ep.partition == constants.AwsPartitionDefault:
+			// global endpoint s3.amazonaws.com
+			ep.region = "us-east-1"
+		case len(rest) != 0 || ep.region == "":
+			return nil, fmt.Errorf("host format not recognized, host: %s", host)
+		}
 
-	case len(parts) >= 4:
-		bucket = parts[0]
-		region = parts[1]
-		prefix = path
+	case len(labels) == 2: // {bucket}.{region}.amazonaws.com
+		ep.bucket = labels[0]
+		ep.region = labels[1]
 
 	default:
-		return "", "", "", "", errors.New("host format not recognized")
+		return nil, fmt.Errorf("host format not recognized, host: %s", host)
+	}
+
+	switch {
+	case ep.partition == constants.AwsPartitionChina && !strings.HasPrefix(ep.region, "cn-"):
+		return nil, fmt.Errorf("region %s does not belong to the %s partition", ep.region, ep.partition)
+	case ep.partition == constants.AwsPartitionDefault && strings.HasPrefix(ep.region, "cn-"):
+		return nil, fmt.Errorf("region %s requires the %s domain", ep.region, constants.StorageS3ChinaDomain)
+	case strings.HasPrefix(ep.region, "us-gov-"):
+		ep.partition = constants.AwsPartitionGovCloud
 	}
 
-	if prefix != "" {
-		endpoint = fmt.Sprintf("s3:https://s3.%s.amazonaws.com/%s/%s/%s/%s", region, bucket, prefix, constants.OlaresStorageDefaultPrefix, utils.JoinName(utils.EncodeURLPart(repoName), repoId))
-	} else {
-		endpoint = fmt.Sprintf("s3:https://s3.%s.amazonaws.com/%s/%s/%s", region, bucket, constants.OlaresStorageDefaultPrefix, utils.JoinName(utils.EncodeURLPart(repoName), repoId))
+	ep.bucket, ep.prefix, err = bucketFromPath(ep.bucket, path)
+	if err != nil {
+		return nil, err
 	}
 
-	return bucket, region, prefix, endpoint, nil
+	return ep, nil
+}
+
+// s3compatibleFormat parses endpoints of S3-compatible services, only path-style addressing is supported
+func s3compatibleFormat(u *url.URL) (*awsEndpoint, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint scheme %q is not supported, use http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("endpoint host is required")
+	}
+
+	bucket, prefix, err := bucketFromPath("", strings.Trim(u.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &awsEndpoint{
+		bucket:     bucket,
+		region:     constants.S3CompatibleDefaultRegion,
+		prefix:     prefix,
+		compatible: true,
+		scheme:     u.Scheme,
+		hostport:   strings.ToLower(u.Host),
+	}, nil
+}
+
+func bucketFromPath(bucket, path string) (string, string, error) {
+	if bucket != "" {
+		return bucket, path, nil
+	}
+	pathParts := strings.SplitN(path, "/", 2)
+	if pathParts[0] == "" {
+		return "", "", errors.New("bucket not found in path")
+	}
+	if len(pathParts) == 2 {
+		return pathParts[0], pathParts[1], nil
+	}
+	return pathParts[0], "", nil
 }
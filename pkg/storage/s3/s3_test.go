@@ -0,0 +1,249 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestAwsFormatRepository(t *testing.T) {
+	var tests = []struct {
+		name      string
+		endpoint  string
+		url       string
+		region    string
+		bucket    string
+		prefix    string
+		partition string
+	}{
+		{
+			name:      "path style",
+			endpoint:  "https://s3.us-east-1.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.us-east-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-1",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "path style with prefix",
+			endpoint:  "https://s3.eu-west-1.amazonaws.com/mytest-bucket/folder1/folder2/",
+			url:       "s3:https://s3.eu-west-1.amazonaws.com/mytest-bucket/folder1/folder2/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "eu-west-1",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1/folder2",
+			partition: "aws",
+		},
+		{
+			name:      "virtual hosted",
+			endpoint:  "https://mytest-bucket.s3.ap-northeast-1.amazonaws.com/folder1",
+			url:       "s3:https://s3.ap-northeast-1.amazonaws.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "ap-northeast-1",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws",
+		},
+		{
+			name:      "virtual hosted dotted bucket",
+			endpoint:  "https://my.test.bucket.s3.us-west-2.amazonaws.com",
+			url:       "s3:https://s3.us-west-2.amazonaws.com/my.test.bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-west-2",
+			bucket:    "my.test.bucket",
+			partition: "aws",
+		},
+		{
+			name:      "bucket and region without s3 label",
+			endpoint:  "https://mytest-bucket.us-west-2.amazonaws.com/folder1",
+			url:       "s3:https://s3.us-west-2.amazonaws.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-west-2",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws",
+		},
+		{
+			name:      "global endpoint",
+			endpoint:  "https://s3.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.us-east-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-1",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "legacy dash region",
+			endpoint:  "https://s3-us-west-2.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.us-west-2.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-west-2",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "legacy dash region virtual hosted",
+			endpoint:  "https://mytest-bucket.s3-eu-west-1.amazonaws.com/folder1",
+			url:       "s3:https://s3.eu-west-1.amazonaws.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "eu-west-1",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws",
+		},
+		{
+			name:      "legacy external",
+			endpoint:  "https://s3-external-1.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.us-east-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-1",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "dualstack",
+			endpoint:  "https://s3.dualstack.us-east-2.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.dualstack.us-east-2.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-2",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "dualstack virtual hosted",
+			endpoint:  "https://mytest-bucket.s3.dualstack.us-east-2.amazonaws.com/folder1",
+			url:       "s3:https://s3.dualstack.us-east-2.amazonaws.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-2",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws",
+		},
+		{
+			name:      "fips",
+			endpoint:  "https://s3-fips.us-east-1.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3-fips.us-east-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-1",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "fips dualstack virtual hosted",
+			endpoint:  "https://mytest-bucket.s3-fips.dualstack.us-east-1.amazonaws.com",
+			url:       "s3:https://s3-fips.dualstack.us-east-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-east-1",
+			bucket:    "mytest-bucket",
+			partition: "aws",
+		},
+		{
+			name:      "govcloud",
+			endpoint:  "https://s3.us-gov-west-1.amazonaws.com/mytest-bucket",
+			url:       "s3:https://s3.us-gov-west-1.amazonaws.com/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-gov-west-1",
+			bucket:    "mytest-bucket",
+			partition: "aws-us-gov",
+		},
+		{
+			name:      "govcloud fips",
+			endpoint:  "https://mytest-bucket.s3-fips.us-gov-east-1.amazonaws.com/folder1",
+			url:       "s3:https://s3-fips.us-gov-east-1.amazonaws.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "us-gov-east-1",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws-us-gov",
+		},
+		{
+			name:      "china",
+			endpoint:  "https://s3.cn-north-1.amazonaws.com.cn/mytest-bucket",
+			url:       "s3:https://s3.cn-north-1.amazonaws.com.cn/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "cn-north-1",
+			bucket:    "mytest-bucket",
+			partition: "aws-cn",
+		},
+		{
+			name:      "china virtual hosted dualstack",
+			endpoint:  "https://mytest-bucket.s3.dualstack.cn-northwest-1.amazonaws.com.cn/folder1",
+			url:       "s3:https://s3.dualstack.cn-northwest-1.amazonaws.com.cn/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			region:    "cn-northwest-1",
+			bucket:    "mytest-bucket",
+			prefix:    "folder1",
+			partition: "aws-cn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Aws{
+				RepoId:       "00000000-0000-0000-0000-000000000000",
+				RepoName:     "mybackup",
+				Endpoint:     tt.endpoint,
+				StorageClass: "STANDARD_IA",
+			}
+			repo, err := s.FormatRepository()
+			assert.Equal(t, err, nil)
+			assert.Equal(t, repo.Url, tt.url)
+			assert.Equal(t, repo.StorageClass, "STANDARD_IA")
+			assert.Equal(t, repo.RegionId, tt.region)
+			assert.Equal(t, repo.Bucket, tt.bucket)
+			assert.Equal(t, repo.Prefix, tt.prefix)
+			assert.Equal(t, repo.Partition, tt.partition)
+		})
+	}
+}
+
+func TestS3CompatibleFormatRepository(t *testing.T) {
+	var tests = []struct {
+		name     string
+		endpoint string
+		url      string
+		bucket   string
+		prefix   string
+	}{
+		{
+			name:     "minio",
+			endpoint: "http://127.0.0.1:9000/mytest-bucket",
+			url:      "s3:http://127.0.0.1:9000/mytest-bucket/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			bucket:   "mytest-bucket",
+		},
+		{
+			name:     "minio with prefix",
+			endpoint: "https://minio.example.com/mytest-bucket/folder1/",
+			url:      "s3:https://minio.example.com/mytest-bucket/folder1/olares-backups/mybackup-00000000-0000-0000-0000-000000000000",
+			bucket:   "mytest-bucket",
+			prefix:   "folder1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Aws{
+				RepoId:   "00000000-0000-0000-0000-000000000000",
+				RepoName: "mybackup",
+				Endpoint: tt.endpoint,
+			}
+			repo, err := s.FormatRepository()
+			assert.Equal(t, err, nil)
+			assert.Equal(t, repo.Url, tt.url)
+			assert.Equal(t, repo.CloudName, "s3compatible")
+			assert.Equal(t, repo.Bucket, tt.bucket)
+			assert.Equal(t, repo.Prefix, tt.prefix)
+		})
+	}
+}
+
+func TestAwsFormatRepositoryInvalid(t *testing.T) {
+	var tests = []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "not aws", endpoint: "https://cos.ap-tokyo.myqcloud.com/mytest-bucket"},
+		{name: "missing bucket", endpoint: "https://s3.us-east-1.amazonaws.com/"},
+		{name: "china region on global domain", endpoint: "https://s3.cn-north-1.amazonaws.com/mytest-bucket"},
+		{name: "global region on china domain", endpoint: "https://s3.us-east-1.amazonaws.com.cn/mytest-bucket"},
+		{name: "china without region", endpoint: "https://s3.amazonaws.com.cn/mytest-bucket"},
+		{name: "compatible missing bucket", endpoint: "http://127.0.0.1:9000"},
+		{name: "compatible unsupported scheme", endpoint: "ftp://127.0.0.1/mytest-bucket"},
+		{name: "unknown labels", endpoint: "https://s3.foo.bar.us-east-1.amazonaws.com/mytest-bucket"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Aws{
+				RepoName: "mybackup",
+				Endpoint: tt.endpoint,
+			}
+			_, err := s.FormatRepository()
+			assert.NotEqual(t, err, nil)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/storage/base"
+	"olares.com/backups-sdk/pkg/storage/model"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+const defaultPort = 22
+
+type Sftp struct {
+	RepoId                   string
+	RepoName                 string
+	SnapshotId               string
+	Host                     string
+	Port                     int
+	User                     string
+	KeyFile                  string
+	KnownHosts               string
+	RemotePath               string
+	Password                 string
+	LimitUploadRate          string
+	LimitDownloadRate        string
+	Path                     string
+	Files                    []string
+	Excludes                 []string
+	FilesPrefixPath          string
+	Metadata                 string
+	BaseHandler              base.Interface
+	Operator                 string
+	BackupType               string
+	BackupAppTypeName        string
+	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
+}
+
+func (s *Sftp) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err = s.FormatRepository()
+	if err != nil {
+		return
+	}
+
+	sftpCommand, err := s.sftpCommand()
+	if err != nil {
+		return
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:                   s.RepoId,
+		RepoName:                 s.RepoName,
+		Path:                     s.Path,
+		Files:                    s.Files,
+		Excludes:                 s.Excludes,
+		FilesPrefixPath:          s.FilesPrefixPath,
+		Metadata:                 s.Metadata,
+		LimitUploadRate:          s.LimitUploadRate,
+		SftpCommand:              sftpCommand,
+		Operator:                 s.Operator,
+		BackupType:               s.BackupType,
+		BackupAppTypeName:        s.BackupAppTypeName,
+		BackupFileTypeSourcePath: s.BackupFileTypeSourcePath,
+		BackupSetId:              s.BackupSetId,
+		RepoEnvs:                 envs,
+	}
+
+	log.Debugf("sftp backup env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	backupSummary, err = s.BaseHandler.Backup(ctx, dryRun, progressCallback)
+
+	return backupSummary, storageInfo, err
+}
+
+func (s *Sftp) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	sftpCommand, err := s.sftpCommand()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:            s.RepoId,
+		RepoName:          s.RepoName,
+		SnapshotId:        s.SnapshotId,
+		RepoEnvs:          envs,
+		Path:              s.Path,
+		LimitDownloadRate: s.LimitDownloadRate,
+		SftpCommand:       sftpCommand,
+		SkipSpaceCheck:    s.SkipSpaceCheck,
+	}
+
+	log.Debugf("sftp restore env vars: %s", envs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Restore(ctx, progressCallback)
+}
+
+func (s *Sftp) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp snapshots env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Snapshots(ctx)
+}
+
+func (s *Sftp) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp snapshot env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.GetSnapshot(ctx, snapshotId)
+}
+
+func (s *Sftp) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp stats env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Stats(ctx)
+}
+
+func (s *Sftp) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp forget env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Forget(ctx, policy)
+}
+
+func (s *Sftp) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp check env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Check(ctx, readDataSubset)
+}
+
+func (s *Sftp) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	opts, err := s.queryOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("sftp ls env vars: %s", opts.RepoEnvs.String())
+
+	s.BaseHandler.SetOptions(opts)
+	return s.BaseHandler.Ls(ctx, snapshotId, path, recursive)
+}
+
+func (s *Sftp) Regions() ([]map[string]string, error) {
+	return nil, nil
+}
+
+func (s *Sftp) GetEnv(repository string) *restic.ResticEnvs {
+	var envs = &restic.ResticEnvs{
+		RESTIC_REPOSITORY: repository,
+		RESTIC_PASSWORD:   s.Password,
+	}
+	return envs
+}
+
+// sftp:{user}@{host}:{remote-path}/olares-backups/{name}-{id}
+//
+// the port, key file and known_hosts are passed to restic through the sftp.command option
+func (s *Sftp) FormatRepository() (storageInfo *model.StorageInfo, err error) {
+	if s.Host == "" {
+		return nil, errors.New("sftp host is required")
+	}
+	if s.User == "" {
+		return nil, errors.New("sftp user is required")
+	}
+	if s.RemotePath == "" {
+		return nil, errors.New("sftp remote path is required")
+	}
+
+	var remotePath = path.Join(s.RemotePath, constants.OlaresStorageDefaultPrefix, utils.JoinName(s.RepoName, s.RepoId))
+
+	storageInfo = &model.StorageInfo{
+		Location:  "sftp",
+		Url:       fmt.Sprintf("sftp:%s@%s:%s", s.User, s.Host, remotePath),
+		CloudName: constants.CloudSftpName,
+		RegionId:  "",
+		Bucket:    "",
+		Prefix:    strings.TrimRight(s.RemotePath, "/"),
+		Endpoint:  fmt.Sprintf("%s:%d", s.Host, s.port()),
+	}
+
+	return storageInfo, nil
+}
+
+func (s *Sftp) queryOptions() (*restic.ResticOptions, error) {
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	sftpCommand, err := s.sftpCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	return &restic.ResticOptions{
+		RepoId:      s.RepoId,
+		RepoName:    s.RepoName,
+		RepoEnvs:    s.GetEnv(storageInfo.Url),
+		SftpCommand: sftpCommand,
+	}, nil
+}
+
+func (s *Sftp) port() int {
+	if s.Port <= 0 {
+		return defaultPort
+	}
+	return s.Port
+}
+
+// sftpCommand builds the ssh command restic uses to start the sftp subsystem,
+// ssh runs in batch mode since restic can not answer any prompt
+func (s *Sftp) sftpCommand() (string, error) {
+	sshPath, err := utils.Lookup("ssh")
+	if err != nil {
+		return "", fmt.Errorf("ssh not found")
+	}
+
+	var args = []string{sshPath, fmt.Sprintf("%s@%s", s.User, s.Host), "-p", strconv.Itoa(s.port()), "-o", "BatchMode=yes"}
+	if s.KeyFile != "" {
+		if !utils.IsExist(s.KeyFile) {
+			return "", fmt.Errorf("sftp key file %s not found", s.KeyFile)
+		}
+		args = append(args, "-i", s.KeyFile, "-o", "IdentitiesOnly=yes")
+	}
+	if s.KnownHosts != "" {
+		if !utils.IsExist(s.KnownHosts) {
+			return "", fmt.Errorf("sftp known_hosts file %s not found", s.KnownHosts)
+		}
+		args = append(args, "-o", "UserKnownHostsFile="+s.KnownHosts, "-o", "StrictHostKeyChecking=yes")
+	}
+	args = append(args, "-s", "sftp")
+
+	for i, arg := range args {
+		if strings.ContainsAny(arg, `"'`) {
+			return "", fmt.Errorf("sftp option %s contains quotes", arg)
+		}
+		if strings.ContainsAny(arg, " \t") {
+			args[i] = `"` + arg + `"`
+		}
+	}
+
+	return strings.Join(args, " "), nil
+}
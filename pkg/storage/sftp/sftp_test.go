@@ -0,0 +1,49 @@
+package sftp
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestSftpFormatRepository(t *testing.T) {
+	var s = &Sftp{
+		RepoId:     "00000000-0000-0000-0000-000000000000",
+		RepoName:   "mybackup",
+		Host:       "nas.local",
+		Port:       2222,
+		User:       "backup",
+		RemotePath: "/volume1/backups/",
+	}
+	repo, err := s.FormatRepository()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, repo.Url, "sftp:backup@nas.local:/volume1/backups/olares-backups/mybackup-00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, repo.Endpoint, "nas.local:2222")
+	assert.Equal(t, repo.Prefix, "/volume1/backups")
+
+	s.Port = 0
+	s.RemotePath = "backups"
+	repo, err = s.FormatRepository()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, repo.Url, "sftp:backup@nas.local:backups/olares-backups/mybackup-00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, repo.Endpoint, "nas.local:22")
+}
+
+func TestSftpFormatRepositoryInvalid(t *testing.T) {
+	var tests = []struct {
+		name string
+		sftp *Sftp
+	}{
+		{name: "missing host", sftp: &Sftp{User: "backup", RemotePath: "/backups"}},
+		{name: "missing user", sftp: &Sftp{Host: "nas.local", RemotePath: "/backups"}},
+		{name: "missing remote path", sftp: &Sftp{Host: "nas.local", User: "backup"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sftp.RepoName = "mybackup"
+			_, err := tt.sftp.FormatRepository()
+			assert.NotEqual(t, err, nil)
+		})
+	}
+}
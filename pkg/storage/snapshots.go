@@ -2,31 +2,63 @@ package storage
 
 import (
 	"context"
-	"fmt"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"olares.com/backups-sdk/pkg/history"
 	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/options"
 	"olares.com/backups-sdk/pkg/restic"
-	"olares.com/backups-sdk/pkg/storage/cos"
-	"olares.com/backups-sdk/pkg/storage/filesystem"
-	"olares.com/backups-sdk/pkg/storage/s3"
-	"olares.com/backups-sdk/pkg/storage/space"
+	"olares.com/backups-sdk/pkg/storage/notification"
+	"olares.com/backups-sdk/pkg/tracing"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
 type SnapshotsOption struct {
-	Basedir      string
-	Password     string
-	Operator     string
-	SnapshotId   string
-	Logger       *zap.SugaredLogger
-	Space        *options.SpaceSnapshotsOption
-	Aws          *options.AwsSnapshotsOption
-	TencentCloud *options.TencentCloudSnapshotsOption
-	Filesystem   *options.FilesystemSnapshotsOption
+	Basedir         string
+	Password        string
+	PasswordFile    string
+	PasswordCommand string
+	Operator        string
+	SnapshotId      string
+	Timeout         time.Duration          // bounds the query, zero means no limit, the deprecated methods default to 30 seconds
+	Logger          *zap.SugaredLogger     // logger of the service, the global logger when nil
+	History         *history.Store         // forget and check are recorded here, nothing is recorded when nil
+	Notifier        *notification.Notifier // forget and check are sent to the matching rules, nothing is sent when nil
+	SecretRefs      bool                   // resolves env:, file: and cmd: references in the password and the credentials, they are used as they are when false
+	Space           *options.SpaceSnapshotsOption
+	Aws             *options.AwsSnapshotsOption
+	TencentCloud    *options.TencentCloudSnapshotsOption
+	Filesystem      *options.FilesystemSnapshotsOption
+	Sftp            *options.SftpSnapshotsOption
+	Rest            *options.RestSnapshotsOption
+
+	// Repository is the serializable descriptor of the repository and the operation
+	Repository *options.RepositorySnapshotsOption
+
+	// Location selects a registered backend by name, LocationOption is its snapshots option
+	Location       string
+	LocationOption options.Option
+}
+
+func (o *SnapshotsOption) location() (string, options.Option, error) {
+	switch {
+	case o.Space != nil:
+		return LocationSpace, o.Space, nil
+	case o.Aws != nil:
+		return LocationAws, o.Aws, nil
+	case o.TencentCloud != nil:
+		return LocationTencentCloud, o.TencentCloud, nil
+	case o.Filesystem != nil:
+		return LocationFilesystem, o.Filesystem, nil
+	case o.Sftp != nil:
+		return LocationSftp, o.Sftp, nil
+	case o.Rest != nil:
+		return LocationRest, o.Rest, nil
+	case o.Repository != nil:
+		return repositoryOption(&o.Repository.Repository, o.Repository)
+	}
+	return o.Location, o.LocationOption, nil
 }
 
 type SnapshotsService struct {
@@ -44,80 +76,55 @@ func NewSnapshotsService(option *SnapshotsOption) *SnapshotsService {
 	return snapshotsService
 }
 
+// Deprecated: use SnapshotsContext
 func (s *SnapshotsService) Snapshots() (*restic.SnapshotList, error) {
-	var password = s.password
-	var err error
-	if password == "" {
-		password, err = utils.InputPasswordWithConfirm(true)
-		if err != nil {
-			panic(err)
-		}
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout(s.option.Timeout, defaultQueryTimeout))
+	defer cancel()
 
-	var service Location
-	if s.option.Space != nil {
-		service = &space.Space{
-			RepoId:         s.option.Space.RepoId,
-			RepoName:       s.option.Space.RepoName,
-			OlaresDid:      s.option.Space.OlaresDid,
-			AccessToken:    s.option.Space.AccessToken,
-			ClusterId:      s.option.Space.ClusterId,
-			CloudName:      strings.ToLower(s.option.Space.CloudName),
-			RegionId:       strings.ToLower(s.option.Space.RegionId),
-			CloudApiMirror: s.option.Space.CloudApiMirror,
-			Password:       password,
-			StsToken:       &space.StsToken{},
-			Operator:       s.option.Operator,
-		}
-	} else if s.option.Aws != nil {
-		service = &s3.Aws{
-			RepoId:          s.option.Aws.RepoId,
-			RepoName:        s.option.Aws.RepoName,
-			Endpoint:        s.option.Aws.Endpoint,
-			AccessKey:       s.option.Aws.AccessKey,
-			SecretAccessKey: s.option.Aws.SecretAccessKey,
-			Password:        password,
-			BaseHandler:     &BaseHandler{},
-			Operator:        s.option.Operator,
-		}
-	} else if s.option.TencentCloud != nil {
-		service = &cos.TencentCloud{
-			RepoId:          s.option.TencentCloud.RepoId,
-			RepoName:        s.option.TencentCloud.RepoName,
-			Endpoint:        s.option.TencentCloud.Endpoint,
-			AccessKey:       s.option.TencentCloud.AccessKey,
-			SecretAccessKey: s.option.TencentCloud.SecretAccessKey,
-			Password:        password,
-			BaseHandler:     &BaseHandler{},
-			Operator:        s.option.Operator,
-		}
-	} else if s.option.Filesystem != nil {
-		service = &filesystem.Filesystem{
-			RepoId:      s.option.Filesystem.RepoId,
-			RepoName:    s.option.Filesystem.RepoName,
-			Endpoint:    s.option.Filesystem.Endpoint,
-			Password:    password,
-			BaseHandler: &BaseHandler{},
-			Operator:    s.option.Operator,
-		}
-	} else {
-		logger.Fatalf("There is no suitable recovery method.")
-		return nil, fmt.Errorf("There is no suitable recovery method.")
-	}
+	return s.SnapshotsContext(ctx)
+}
 
-	var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+// SnapshotsContext lists the snapshots of the repository, or the one of SnapshotsOption.SnapshotId
+func (s *SnapshotsService) SnapshotsContext(ctx context.Context) (result *restic.SnapshotList, err error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	ctx, cancel := withTimeout(logger.NewContext(utils.WithTraceId(ctx), s.option.Logger), s.option.Timeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, "snapshots")
+	defer tracing.End(span, &err)
+	var log = logger.FromContext(ctx)
+
+	password, err := resolvePassword(s.password, s.option.PasswordFile, s.option.PasswordCommand, s.option.SecretRefs, true)
+	if err != nil {
+		log.Errorf("get repository password error: %v", err)
+		return nil, err
+	}
 
-	var result *restic.SnapshotList
+	name, option, err := s.option.location()
+	if err != nil {
+		log.Errorf("resolve location error: %v", err)
+		return nil, err
+	}
+	spanLocation(span, name, option)
+	service, err := newLocation(name, option, &LocationParams{
+		Password:   password,
+		Operator:   s.option.Operator,
+		SecretRefs: s.option.SecretRefs,
+	})
+	if err != nil {
+		log.Errorf("new location error: %v", err)
+		return nil, err
+	}
 
 	if s.option.SnapshotId != "" {
 		if result, err = service.GetSnapshot(ctx, s.option.SnapshotId); err != nil {
-			logger.Errorf("Get Spanshot error: %v", err)
+			log.Errorf("Get Spanshot error: %v", err)
 			return nil, err
 		}
 	} else {
 		if result, err = service.Snapshots(ctx); err != nil {
-			logger.Errorf("List Spanshots error: %v", err)
+			log.Errorf("List Spanshots error: %v", err)
 			return nil, err
 		}
 	}
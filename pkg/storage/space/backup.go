@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
 	"olares.com/backups-sdk/pkg/restic"
 	"olares.com/backups-sdk/pkg/storage/model"
@@ -12,6 +11,7 @@ import (
 )
 
 func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(percentDone float64)) (backupSummary *restic.SummaryOutput, storageInfo *model.StorageInfo, err error) {
+	var log = logger.FromContext(ctx)
 	if err = s.getStsToken(ctx); err != nil {
 		return
 	}
@@ -26,7 +26,7 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 		return
 	}
 
-	var traceId = ctx.Value(constants.TraceId).(string)
+	var traceId = utils.GetTraceId(ctx)
 
 	// backupType = constants.FullyBackup
 
@@ -64,11 +64,14 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 			BackupType:               s.BackupType,
 			BackupAppTypeName:        s.BackupAppTypeName,
 			BackupFileTypeSourcePath: s.BackupFileTypeSourcePath,
+			BackupSetId:              s.BackupSetId,
 			RepoEnvs:                 envs,
 			LimitUploadRate:          s.LimitUploadRate,
+			Excludes:                 s.Excludes,
+			StorageClass:             s.StorageClass,
 		}
 
-		logger.Infof("space backup env vars: %s, traceId: %s", utils.Base64encode([]byte(envs.String())), traceId)
+		log.Infof("space backup env vars: %s, traceId: %s", envs.String(), traceId)
 
 		var r *restic.Restic
 		r, err = restic.NewRestic(ctx, opts)
@@ -76,13 +79,13 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 			break
 		}
 
-		logger.Infof("initializing repo %s, traceId: %s", s.RepoName, traceId)
+		log.Infof("initializing repo %s, traceId: %s", s.RepoName, traceId)
 		initResult, err = r.Init()
 		if err != nil {
 			if err.Error() == restic.MESSAGE_REPOSITORY_ALREADY_INITIALIZED {
 				initialized = true
 			} else {
-				logger.Errorf("error initializing repo %s, err: %s, traceId: %s", s.RepoName, err.Error(), traceId)
+				log.Errorf("error initializing repo %s, err: %s, traceId: %s", s.RepoName, err.Error(), traceId)
 				break
 			}
 		}
@@ -95,29 +98,29 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 		// }
 
 		if initialized {
-			logger.Infof("repo %s already initialized, traceId: %s", s.RepoName, traceId)
-			logger.Infof("repairing repo %s index, traceId: %s", s.RepoName, traceId)
+			log.Infof("repo %s already initialized, traceId: %s", s.RepoName, traceId)
+			log.Infof("repairing repo %s index, traceId: %s", s.RepoName, traceId)
 			if err = r.Repair(); err != nil {
 				break
 			}
 		} else {
-			logger.Infof("repo %s initialized, traceId: %s\n\n%s", s.RepoName, traceId, initResult)
+			log.Infof("repo %s initialized, traceId: %s\n\n%s", s.RepoName, traceId, initResult)
 		}
 
-		logger.Infof("preparing to start repo %s backup, traceId: %s", s.RepoName, traceId)
+		log.Infof("preparing to start repo %s backup, traceId: %s", s.RepoName, traceId)
 
 		var tags = s.getTags()
 		tags = append(tags, fmt.Sprintf("repo-suffix=%s", repoSuffix))
 
 		backupSummary, err = r.Backup(s.Path, s.Files, "", tags, traceId, dryRun, progressChan)
 		if err != nil {
-			logger.Infof("space backup error: %v, traceId: %s", err, traceId)
+			log.Infof("space backup error: %v, traceId: %s", err, traceId)
 			// switch err.Error() {
 			// case restic.ERROR_MESSAGE_BACKUP_CANCELED.Error():
-			// 	logger.Infof("backup canceled, stopping..., traceId: %s", traceId)
+			// 	log.Infof("backup canceled, stopping..., traceId: %s", traceId)
 			// 	return
 			// case restic.ERROR_MESSAGE_TOKEN_EXPIRED.Error():
-			// 	logger.Infof("space backup upload stopped, sts token expired, refresh and retring..., traceId: %s", traceId)
+			// 	log.Infof("space backup upload stopped, sts token expired, refresh and retring..., traceId: %s", traceId)
 			// 	if err = s.refreshStsTokens(ctx); err != nil {
 			// 		err = fmt.Errorf("space backup upload sts token service refresh-token error: %v, traceId: %s", err, traceId)
 			// 		return
@@ -132,13 +135,16 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 					if err = s.refreshStsTokens(ctx); err == nil {
 						continue
 					} else {
-						logger.Errorf("space backup upload sts token service refresh-token error: %v, traceId: %s", err, traceId)
+						log.Errorf("space backup upload sts token service refresh-token error: %v, traceId: %s", err, traceId)
 						// err = fmt.Errorf("space backup upload sts token service refresh-token error: %v, traceId: %s", err, traceId)
 					}
 				}
 
-				if e := r.Rollback(); e != nil {
-					logger.Errorf("space rollbackup error: %v, traceId: %s", e, traceId)
+				// a canceled run is restarted or given up, the packs it uploaded are kept for the next run to reuse
+				if ctx.Err() != nil {
+					log.Infof("space backup canceled: %v, skip rollback, traceId: %s", context.Cause(ctx), traceId)
+				} else if e := r.Rollback(); e != nil {
+					log.Errorf("space rollbackup error: %v, traceId: %s", e, traceId)
 				}
 				// e := r.Rollback()
 				// if e != nil {
@@ -151,7 +157,7 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 		// var currentBackupType = backupType
 		// if backupType == constants.FullyBackup {
 		// 	shortId := backupSummary.SnapshotID[:8]
-		// 	logger.Infof("reset tag, name: %s, snapshot: %s, type: %s", s.RepoName, shortId, backupType)
+		// 	log.Infof("reset tag, name: %s, snapshot: %s, type: %s", s.RepoName, shortId, backupType)
 		// 	snapshots, err := r.GetSnapshots(nil)
 		// 	if err == nil && snapshots != nil && snapshots.Len() > 0 {
 		// 		firstBackup := snapshots.First()
@@ -163,7 +169,7 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 		// 			fmt.Sprintf("type=%s", currentBackupType),
 		// 		}
 		// 		if err := r.Tag(backupSummary.SnapshotID, resetTags); err != nil {
-		// 			logger.Errorf("set tag %s error :%v", shortId, err)
+		// 			log.Errorf("set tag %s error :%v", shortId, err)
 		// 			break
 		// 		}
 		// 	}
@@ -174,9 +180,9 @@ func (s *Space) Backup(ctx context.Context, dryRun bool, progressCallback func(p
 			backupSummary.RestoreSize = restoreSize.TotalSize
 		}
 
-		logger.Infof("Backup successful, name: %s, result: %s, traceId: %s", s.RepoName, utils.ToJSON(backupSummary), traceId)
+		log.Infof("Backup successful, name: %s, result: %s, traceId: %s", s.RepoName, utils.ToJSON(backupSummary), traceId)
 		// if err := s.sendBackup(backupSummary, currentBackupType, opts.RepoEnvs.RESTIC_REPOSITORY); err != nil {
-		// 	logger.Errorf("send backup to cloud error: %v", err)
+		// 	log.Errorf("send backup to cloud error: %v", err)
 		// }
 		break
 	}
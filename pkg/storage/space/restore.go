@@ -11,6 +11,7 @@ import (
 )
 
 func (s *Space) Restore(ctx context.Context, progressCallback func(percentDone float64)) (map[string]*restic.RestoreSummaryOutput, string, uint64, error) {
+	var log = logger.FromContext(ctx)
 	// ctx, cancel := context.WithCancel(context.TODO())
 	// defer cancel()
 
@@ -56,9 +57,10 @@ func (s *Space) Restore(ctx context.Context, progressCallback func(percentDone f
 			RepoEnvs:          envs,
 			Path:              s.Path,
 			LimitDownloadRate: s.LimitDownloadRate,
+			SkipSpaceCheck:    s.SkipSpaceCheck,
 		}
 
-		logger.Debugf("space restore env vars: %s", utils.Base64encode([]byte(envs.String())))
+		log.Debugf("space restore env vars: %s", envs.String())
 
 		var r *restic.Restic
 		r, err = restic.NewRestic(ctx, opts)
@@ -76,14 +78,25 @@ func (s *Space) Restore(ctx context.Context, progressCallback func(percentDone f
 		var backupType = util.GetBackupType(currentSnapshot.Tags)
 		backupMetadata = util.GetMetadata(currentSnapshot.Tags)
 
-		logger.Infof("space restore spanshot: %s, backupType: %s, paths: %d, tags: %v, summary %s", currentSnapshot.Id, backupType, len(currentSnapshot.Paths), currentSnapshot.Tags, utils.ToJSON(currentSnapshot.Summary))
+		log.Infof("space restore spanshot: %s, backupType: %s, paths: %d, tags: %v, summary %s", currentSnapshot.Id, backupType, len(currentSnapshot.Paths), currentSnapshot.Tags, utils.ToJSON(currentSnapshot.Summary))
+
+		if storageClass := util.GetStorageClass(currentSnapshot.Tags); util.IsArchiveStorageClass(storageClass) {
+			log.Warnf("space restore spanshot: %s, data was uploaded with archive storage class %s, objects must be rehydrated before restore", currentSnapshot.Id, storageClass)
+		}
 
 		uploadPaths, _ = util.GetFilesPrefixPath(currentSnapshot.Tags)
 		if uploadPaths == nil || len(uploadPaths) == 0 {
 			uploadPaths = append(uploadPaths, currentSnapshot.Paths[0])
 		}
 
-		// logger.Infof("space restore spanshot %s detail: %s", s.SnapshotId, utils.ToJSON(currentSnapshot))
+		if !s.SkipSpaceCheck {
+			if err = util.CheckRestoreSpace(r, s.SnapshotId, backupType, restoreTargetPath, uploadPaths); err != nil {
+				log.Errorf("space restore %s snapshot %s, pre-flight space check error: %v", s.RepoName, s.SnapshotId, err)
+				break
+			}
+		}
+
+		// log.Infof("space restore spanshot %s detail: %s", s.SnapshotId, utils.ToJSON(currentSnapshot))
 
 		for phase, uploadPath := range uploadPaths {
 			var rs *restic.RestoreSummaryOutput
@@ -96,7 +109,7 @@ func (s *Space) Restore(ctx context.Context, progressCallback func(percentDone f
 			if err != nil {
 				switch err.Error() {
 				case restic.ERROR_MESSAGE_TOKEN_EXPIRED.Error():
-					logger.Infof("space restore download stopped, sts token expired, refresh and retring...")
+					log.Infof("space restore download stopped, sts token expired, refresh and retring...")
 					if err = s.refreshStsTokens(ctx); err != nil {
 						err = fmt.Errorf("space restore download sts token service refresh-token error: %v", err)
 						break
@@ -119,7 +132,7 @@ func (s *Space) Restore(ctx context.Context, progressCallback func(percentDone f
 		return nil, metadata, totalBytes, err
 	}
 
-	logger.Infof("Restore successful, name: %s, result: %s", s.RepoName, utils.ToJSON(restoreSummarys))
+	log.Infof("Restore successful, name: %s, result: %s", s.RepoName, utils.ToJSON(restoreSummarys))
 
 	metadata = backupMetadata
 	totalBytes = totalBytesTmp
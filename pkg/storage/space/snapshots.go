@@ -10,6 +10,7 @@ import (
 )
 
 func (s *Space) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	if err := s.getStsToken(ctx); err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -34,9 +35,9 @@ func (s *Space) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
 		Operator:   s.Operator,
 		RepoEnvs:   envs,
 	}
-	logger.Debugf("space snapshots env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("space snapshots env vars: %s", envs.String())
 
-	r, err := restic.NewRestic(context.Background(), opts)
+	r, err := restic.NewRestic(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +52,7 @@ func (s *Space) Snapshots(ctx context.Context) (*restic.SnapshotList, error) {
 }
 
 func (s *Space) GetSnapshot(ctx context.Context, snapshotId string) (*restic.SnapshotList, error) {
+	var log = logger.FromContext(ctx)
 	if err := s.getStsToken(ctx); err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -75,9 +77,9 @@ func (s *Space) GetSnapshot(ctx context.Context, snapshotId string) (*restic.Sna
 		Operator:   s.Operator,
 		RepoEnvs:   envs,
 	}
-	logger.Debugf("space snapshot env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("space snapshot env vars: %s", envs.String())
 
-	r, err := restic.NewRestic(context.Background(), opts)
+	r, err := restic.NewRestic(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
 	"olares.com/backups-sdk/pkg/restic"
 	"olares.com/backups-sdk/pkg/storage/model"
 	"olares.com/backups-sdk/pkg/utils"
@@ -28,16 +29,20 @@ type Space struct {
 	Password                 string
 	Path                     string
 	Files                    []string
+	Excludes                 []string
 	FilesPrefixPath          string
 	Metadata                 string
 	LimitUploadRate          string
 	LimitDownloadRate        string
+	StorageClass             string
 	CloudApiMirror           string
 	StsToken                 *StsToken
 	Operator                 string
 	BackupType               string
 	BackupAppTypeName        string
 	BackupFileTypeSourcePath string
+	BackupSetId              string
+	SkipSpaceCheck           bool
 }
 
 type StorageResponse struct {
@@ -64,11 +69,16 @@ func (s *Space) Regions() ([]map[string]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	return s.RegionsContext(ctx)
+}
+
+func (s *Space) RegionsContext(ctx context.Context) ([]map[string]string, error) {
 	var url = fmt.Sprintf("%s/v1/resource/backup/region", s.getCloudApi())
 	var headers = map[string]string{
 		restful.HEADER_ContentType: "application/x-www-form-urlencoded",
 	}
 	var data = fmt.Sprintf("userid=%s&token=%s", s.OlaresDid, s.AccessToken)
+	redact.Register(s.AccessToken)
 
 	result, err := utils.Post[CloudStorageRegionResponse](ctx, url, headers, data)
 	if err != nil {
@@ -76,7 +86,7 @@ func (s *Space) Regions() ([]map[string]string, error) {
 	}
 
 	if result.Data == nil {
-		return nil, errors.WithStack(fmt.Errorf("get regions invalid, code: %d, msg: %s, params: %s", result.Code, result.Message, data))
+		return nil, errors.WithStack(redact.Errorf("get regions invalid, code: %d, msg: %s, params: %s", result.Code, result.Message, data))
 	}
 
 	var regions []map[string]string
@@ -92,6 +102,7 @@ func (s *Space) Regions() ([]map[string]string, error) {
 }
 
 func (s *Space) Stats(ctx context.Context) (*restic.StatsContainer, error) {
+	var log = logger.FromContext(ctx)
 	if err := s.getStsToken(ctx); err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -109,7 +120,7 @@ func (s *Space) Stats(ctx context.Context) (*restic.StatsContainer, error) {
 		RegionId:  s.RegionId,
 		RepoEnvs:  envs,
 	}
-	logger.Debugf("space stats env vars: %s", utils.Base64encode([]byte(envs.String())))
+	log.Debugf("space stats env vars: %s", envs.String())
 
 	r, err := restic.NewRestic(ctx, opts)
 	if err != nil {
@@ -123,6 +134,93 @@ func (s *Space) Stats(ctx context.Context) (*restic.StatsContainer, error) {
 	return stats, nil
 }
 
+func (s *Space) Forget(ctx context.Context, policy *restic.ForgetPolicy) (*restic.ForgetSummary, error) {
+	var log = logger.FromContext(ctx)
+	if err := s.getStsToken(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    s.RepoId,
+		RepoName:  s.RepoName,
+		CloudName: s.CloudName,
+		RegionId:  s.RegionId,
+		RepoEnvs:  envs,
+	}
+	log.Debugf("space forget env vars: %s", envs.String())
+
+	r, err := restic.NewRestic(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Forget(policy)
+}
+
+func (s *Space) Check(ctx context.Context, readDataSubset string) (*restic.CheckSummary, error) {
+	var log = logger.FromContext(ctx)
+	if err := s.getStsToken(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    s.RepoId,
+		RepoName:  s.RepoName,
+		CloudName: s.CloudName,
+		RegionId:  s.RegionId,
+		RepoEnvs:  envs,
+	}
+	log.Debugf("space check env vars: %s", envs.String())
+
+	r, err := restic.NewRestic(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Check(readDataSubset)
+}
+
+func (s *Space) Ls(ctx context.Context, snapshotId string, path string, recursive bool) ([]*restic.LsNode, error) {
+	var log = logger.FromContext(ctx)
+	if err := s.getStsToken(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	storageInfo, err := s.FormatRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	var envs = s.GetEnv(storageInfo.Url)
+	var opts = &restic.ResticOptions{
+		RepoId:    s.RepoId,
+		RepoName:  s.RepoName,
+		CloudName: s.CloudName,
+		RegionId:  s.RegionId,
+		RepoEnvs:  envs,
+	}
+	log.Debugf("space ls env vars: %s", envs.String())
+
+	r, err := restic.NewRestic(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Ls(snapshotId, path, recursive)
+}
+
 func (s *Space) GetEnv(repository string) *restic.ResticEnvs {
 	var envs = &restic.ResticEnvs{
 		AWS_ACCESS_KEY_ID:     s.StsToken.AccessKey,
@@ -140,12 +238,13 @@ func (s *Space) getCosRepository() (storageInfo *model.StorageInfo, err error) {
 	var repository = fmt.Sprintf("s3:https://cos.%s.%s/%s/%s", s.RegionId, constants.StorageTencentDoman, s.StsToken.Bucket, repoPrefix)
 
 	storageInfo = &model.StorageInfo{
-		Location:  "space",
-		Url:       repository,
-		CloudName: s.CloudName,
-		RegionId:  s.RegionId,
-		Bucket:    s.StsToken.Bucket,
-		Prefix:    s.StsToken.Prefix,
+		Location:     "space",
+		Url:          repository,
+		CloudName:    s.CloudName,
+		RegionId:     s.RegionId,
+		Bucket:       s.StsToken.Bucket,
+		Prefix:       s.StsToken.Prefix,
+		StorageClass: s.StorageClass,
 	}
 
 	return
@@ -157,12 +256,13 @@ func (s *Space) getDefaultRepository() (storageInfo *model.StorageInfo, err erro
 	var repository = fmt.Sprintf("s3:https://s3.%s/%s/%s", domain, s.StsToken.Bucket, repoPrefix)
 
 	storageInfo = &model.StorageInfo{
-		Location:  "space",
-		Url:       repository,
-		CloudName: s.CloudName,
-		RegionId:  s.RegionId,
-		Bucket:    s.StsToken.Bucket,
-		Prefix:    s.StsToken.Prefix,
+		Location:     "space",
+		Url:          repository,
+		CloudName:    s.CloudName,
+		RegionId:     s.RegionId,
+		Bucket:       s.StsToken.Bucket,
+		Prefix:       s.StsToken.Prefix,
+		StorageClass: s.StorageClass,
 	}
 
 	return
@@ -226,5 +326,13 @@ func (s *Space) getTags() []string {
 		tags = append(tags, fmt.Sprintf("metadata=%s", utils.Base64encode([]byte(s.Metadata))))
 	}
 
+	if s.StorageClass != "" {
+		tags = append(tags, fmt.Sprintf("storage-class=%s", s.StorageClass))
+	}
+
+	if s.BackupSetId != "" {
+		tags = append(tags, fmt.Sprintf("backup-set=%s", s.BackupSetId))
+	}
+
 	return tags
 }
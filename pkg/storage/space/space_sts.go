@@ -11,6 +11,8 @@ import (
 	"github.com/pkg/errors"
 	"olares.com/backups-sdk/pkg/constants"
 	"olares.com/backups-sdk/pkg/logger"
+	"olares.com/backups-sdk/pkg/redact"
+	"olares.com/backups-sdk/pkg/tracing"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
@@ -51,8 +53,12 @@ type StsToken struct {
 	ClusterId    string `json:"cluster_id"`
 }
 
-func (s *StsToken) RefreshStsToken(ctx context.Context, cloudApiMirror string) error {
-	logger.Infof("refresh sts token")
+func (s *StsToken) RefreshStsToken(ctx context.Context, cloudApiMirror string) (err error) {
+	ctx, span := tracing.Start(ctx, "space refresh sts token")
+	defer tracing.End(span, &err)
+
+	var log = logger.FromContext(ctx)
+	log.Infof("refresh sts token")
 
 	var url = s.getRequestSpaceRefreshStsUrl(cloudApiMirror)
 	var headers = s.getRequestSpaceStsHeaders()
@@ -66,7 +72,7 @@ func (s *StsToken) RefreshStsToken(ctx context.Context, cloudApiMirror string) e
 	queryResp := result
 
 	if queryResp.Data == nil {
-		logger.Errorf("get sts token invalid, code: %d, msg: %s, params: %s", queryResp.Code, queryResp.Message, data)
+		log.Errorf("get sts token invalid, code: %d, msg: %s, params: %s", queryResp.Code, queryResp.Message, redact.String(data))
 		return errors.WithStack(fmt.Errorf("get sts token invalid, code: %d, message: %s", queryResp.Code, queryResp.Message))
 	}
 
@@ -78,14 +84,20 @@ func (s *StsToken) RefreshStsToken(ctx context.Context, cloudApiMirror string) e
 	s.SecretKey = queryResp.Data.Secret
 	s.SessionToken = queryResp.Data.Token
 	s.Expiration = queryResp.Data.Expiration
+	redact.Register(s.SecretKey, s.SessionToken)
 
 	return nil
 }
 
 func (s *StsToken) GetStsToken(ctx context.Context, olaresDid, accessToken,
 	cloudName, regionId, clusterId, prevOlaresDidPrefixSuffix,
-	cloudApiMirror string) error {
-	logger.Info("get sts token")
+	cloudApiMirror string) (err error) {
+	ctx, span := tracing.Start(ctx, "space get sts token")
+	defer tracing.End(span, &err)
+
+	var log = logger.FromContext(ctx)
+	log.Info("get sts token")
+	redact.Register(accessToken)
 
 	// ! test
 
@@ -105,7 +117,7 @@ func (s *StsToken) GetStsToken(ctx context.Context, olaresDid, accessToken,
 	}
 
 	if queryResp.Data == nil {
-		return errors.WithStack(fmt.Errorf("get sts token invalid, code: %d, msg: %s, data: %s", queryResp.Code, queryResp.Message, data))
+		return errors.WithStack(redact.Errorf("get sts token invalid, code: %d, msg: %s, data: %s", queryResp.Code, queryResp.Message, data))
 	}
 
 	s.Cloud = queryResp.Data.Cloud
@@ -116,6 +128,7 @@ func (s *StsToken) GetStsToken(ctx context.Context, olaresDid, accessToken,
 	s.SecretKey = queryResp.Data.Secret
 	s.SessionToken = queryResp.Data.Token
 	s.Expiration = queryResp.Data.Expiration
+	redact.Register(s.SecretKey, s.SessionToken)
 
 	return nil
 }
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/tracing"
+)
+
+// startSpan starts the span of a service call, end it deferred with tracing.End
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.StartJob(ctx, operation, attribute.String("backups.operation", operation))
+}
+
+// spanLocation adds the resolved location and repo to the span of a service call
+func spanLocation(span trace.Span, name string, option options.Option) {
+	span.SetAttributes(
+		attribute.String("backups.location", name),
+		attribute.String("backups.repo", optionField(option, "RepoName")),
+	)
+}
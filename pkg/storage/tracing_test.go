@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"olares.com/backups-sdk/pkg/options"
+	"olares.com/backups-sdk/pkg/tracing"
+)
+
+func TestServiceSpans(t *testing.T) {
+	var recorder = tracetest.NewSpanRecorder()
+	var prev = otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	_, _, err := NewBackupService(&BackupOption{PasswordCommand: "exit 1"}).BackupContext(context.Background(), false, nil)
+	assert.NotEqual(t, err, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewSnapshotsService(&SnapshotsOption{
+		Password:   "secret",
+		Filesystem: &options.FilesystemSnapshotsOption{RepoName: "home"},
+	}).SnapshotsContext(ctx)
+	assert.NotEqual(t, err, nil)
+
+	var spans = recorder.Ended()
+	var byName = make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+
+	backup, ok := byName["backup"]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, backup.Parent().IsValid(), false)
+	assert.Equal(t, backup.Status().Code, codes.Error)
+
+	snapshots, ok := byName["snapshots"]
+	assert.Equal(t, ok, true)
+	var attrs = make(map[string]string)
+	for _, a := range snapshots.Attributes() {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	assert.Equal(t, attrs["backups.location"], LocationFilesystem)
+	assert.Equal(t, attrs["backups.repo"], "home")
+	assert.NotEqual(t, attrs[tracing.TraceIdKey], "")
+}
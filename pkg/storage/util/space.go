@@ -0,0 +1,148 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/restic"
+	"olares.com/backups-sdk/pkg/utils"
+)
+
+// ErrInsufficientSpace is returned by the pre-flight checks, the error names the shortfall of every filesystem
+var ErrInsufficientSpace = errors.New("insufficient space")
+
+// CheckFreeSpace checks that the bytes written below each path fit on their filesystems, paths on the same
+// filesystem add up and FreeSpaceLimit must stay free, like the check of the running restic process.
+// A path that does not exist yet is written on the filesystem of its nearest existing parent
+func CheckFreeSpace(needs map[string]uint64) error {
+	partitions, _ := disk.Partitions(true)
+
+	type filesystem struct {
+		path  string
+		bytes uint64
+	}
+	var filesystems = make(map[string]*filesystem)
+	for p, bytes := range needs {
+		var existing = existingParent(p)
+		var mount = mountPoint(existing, partitions)
+		if filesystems[mount] == nil {
+			filesystems[mount] = &filesystem{path: existing}
+		}
+		filesystems[mount].bytes += bytes
+	}
+
+	var shortfalls []string
+	for _, fs := range filesystems {
+		usage, err := disk.Usage(fs.path)
+		if err != nil {
+			return fmt.Errorf("check free space of %s error: %v", fs.path, err)
+		}
+		var required = fs.bytes + constants.FreeSpaceLimit
+		if usage.Free < required {
+			shortfalls = append(shortfalls, fmt.Sprintf("%s needs %s and keeps %s free, %s is free, short by %s",
+				fs.path, utils.FormatBytes(fs.bytes), utils.FormatBytes(constants.FreeSpaceLimit),
+				utils.FormatBytes(usage.Free), utils.FormatBytes(required-usage.Free)))
+		}
+	}
+	if len(shortfalls) > 0 {
+		sort.Strings(shortfalls)
+		return fmt.Errorf("%w: %s", ErrInsufficientSpace, strings.Join(shortfalls, "; "))
+	}
+	return nil
+}
+
+// SourceSize is the size of the regular files below the path of a backup, or below the paths listed
+// in the files given to --files-from-verbatim, one per line
+func SourceSize(path string, files []string) (uint64, error) {
+	var paths []string
+	if path != "" {
+		paths = append(paths, path)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				paths = append(paths, line)
+			}
+		}
+	}
+
+	var size uint64
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// CheckRestoreSpace checks the free space for the restore of each upload path of the snapshot to
+// its target from GetRestoreTargetPath, the sizes come from the restore-size mode of restic stats
+func CheckRestoreSpace(r *restic.Restic, snapshotId string, backupType string, restoreTargetPath string, uploadPaths []string) error {
+	var needs = make(map[string]uint64)
+	for _, uploadPath := range uploadPaths {
+		var backupTrimPath, targetPath = GetRestoreTargetPath(backupType, restoreTargetPath, uploadPath)
+		size, err := r.RestoreSize(snapshotId, backupTrimPath)
+		if err != nil {
+			return fmt.Errorf("restore size of snapshot %s, subfolder: %s, error: %v", snapshotId, backupTrimPath, err)
+		}
+		needs[targetPath] += size
+	}
+	return CheckFreeSpace(needs)
+}
+
+func existingParent(p string) string {
+	p, _ = filepath.Abs(p)
+	for {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		var parent = filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		p = parent
+	}
+}
+
+// mountPoint is the longest mount point that contains p, p itself when the partitions are unknown
+func mountPoint(p string, partitions []disk.PartitionStat) string {
+	var res string
+	for _, partition := range partitions {
+		var m = partition.Mountpoint
+		if len(m) <= len(res) {
+			continue
+		}
+		if p == m || strings.HasPrefix(p, strings.TrimSuffix(m, string(filepath.Separator))+string(filepath.Separator)) {
+			res = m
+		}
+	}
+	if res == "" {
+		return p
+	}
+	return res
+}
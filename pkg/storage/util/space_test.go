@@ -0,0 +1,70 @@
+package util
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestCheckFreeSpace(t *testing.T) {
+	var dir = t.TempDir()
+	var tests = []struct {
+		name  string
+		needs map[string]uint64
+		err   bool
+	}{
+		{name: "nothing", needs: map[string]uint64{}},
+		{name: "fits", needs: map[string]uint64{dir: 1024}},
+		{name: "missing target", needs: map[string]uint64{filepath.Join(dir, "a", "b"): 1024}},
+		{name: "too large", needs: map[string]uint64{dir: math.MaxUint64 / 2}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err = CheckFreeSpace(tt.needs)
+			assert.Equal(t, err != nil, tt.err)
+			if tt.err {
+				assert.Equal(t, errors.Is(err, ErrInsufficientSpace), true)
+			}
+		})
+	}
+}
+
+func TestExistingParent(t *testing.T) {
+	var dir = t.TempDir()
+	assert.Equal(t, existingParent(dir), dir)
+	assert.Equal(t, existingParent(filepath.Join(dir, "a", "b")), dir)
+}
+
+func TestSourceSize(t *testing.T) {
+	var dir = t.TempDir()
+	assert.Equal(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755), nil)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "src", "a"), make([]byte, 100), 0644), nil)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "src", "sub", "b"), make([]byte, 20), 0644), nil)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "c"), make([]byte, 3), 0644), nil)
+	var list = filepath.Join(dir, "list")
+	assert.Equal(t, os.WriteFile(list, []byte(filepath.Join(dir, "src", "sub")+"\n\n"+filepath.Join(dir, "c")+"\n"), 0644), nil)
+
+	var tests = []struct {
+		name  string
+		path  string
+		files []string
+		size  uint64
+		err   bool
+	}{
+		{name: "path", path: filepath.Join(dir, "src"), size: 120},
+		{name: "files", files: []string{list}, size: 23},
+		{name: "missing path", path: filepath.Join(dir, "missing"), err: true},
+		{name: "missing list", files: []string{filepath.Join(dir, "missing")}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := SourceSize(tt.path, tt.files)
+			assert.Equal(t, err != nil, tt.err)
+			assert.Equal(t, size, tt.size)
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/restic"
 	"olares.com/backups-sdk/pkg/utils"
 )
 
@@ -67,6 +69,60 @@ func GetMetadata(tags []string) string {
 	return metadata
 }
 
+func GetStorageClass(tags []string) string {
+	for _, tag := range tags {
+		e := strings.Index(tag, "=")
+		if e >= 0 && tag[:e] == "storage-class" {
+			return tag[e+1:]
+		}
+	}
+	return ""
+}
+
+// GetBackupSetId is the id shared by the snapshots of one fan-out backup
+func GetBackupSetId(tags []string) string {
+	for _, tag := range tags {
+		e := strings.Index(tag, "=")
+		if e >= 0 && tag[:e] == "backup-set" {
+			return tag[e+1:]
+		}
+	}
+	return ""
+}
+
+// IsArchiveStorageClass reports whether objects of the storage class must be rehydrated before they can be read
+func IsArchiveStorageClass(storageClass string) bool {
+	switch strings.ToUpper(storageClass) {
+	case "GLACIER", "DEEP_ARCHIVE", "ARCHIVE":
+		return true
+	}
+	return false
+}
+
+// S3StorageClasses and CosStorageClasses are the storage classes a backup may upload with
+var (
+	S3StorageClasses  = []string{"STANDARD", "REDUCED_REDUNDANCY", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE"}
+	CosStorageClasses = []string{"STANDARD", "STANDARD_IA", "INTELLIGENT_TIERING", "ARCHIVE", "DEEP_ARCHIVE", "MAZ_STANDARD", "MAZ_STANDARD_IA", "MAZ_INTELLIGENT_TIERING"}
+)
+
+// ValidateStorageClass checks the storage class against the classes of the cloud, empty is the bucket default
+func ValidateStorageClass(storageClass string, classes []string) error {
+	if storageClass == "" || slices.Contains(classes, strings.ToUpper(storageClass)) {
+		return nil
+	}
+	return fmt.Errorf("unknown storage class %s, use one of %s", storageClass, strings.Join(classes, ", "))
+}
+
+// ArchiveStorageClass is the archive storage class of the first snapshot uploaded with one, empty when there is none
+func ArchiveStorageClass(snapshots []*restic.Snapshot) string {
+	for _, snapshot := range snapshots {
+		if storageClass := GetStorageClass(snapshot.Tags); IsArchiveStorageClass(storageClass) {
+			return storageClass
+		}
+	}
+	return ""
+}
+
 // files-prefix-path
 func GetFilesPrefixPath(tags []string) ([]string, error) {
 	var filesPrefixPath []string
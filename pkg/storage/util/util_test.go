@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"olares.com/backups-sdk/pkg/restic"
+)
+
+func TestValidateStorageClass(t *testing.T) {
+	var tests = []struct {
+		name         string
+		storageClass string
+		classes      []string
+		err          bool
+	}{
+		{name: "default", classes: S3StorageClasses},
+		{name: "s3", storageClass: "GLACIER_IR", classes: S3StorageClasses},
+		{name: "lower case", storageClass: "deep_archive", classes: S3StorageClasses},
+		{name: "cos", storageClass: "MAZ_STANDARD", classes: CosStorageClasses},
+		{name: "cos class on s3", storageClass: "ARCHIVE", classes: S3StorageClasses, err: true},
+		{name: "unknown", storageClass: "COLD", classes: CosStorageClasses, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ValidateStorageClass(tt.storageClass, tt.classes) != nil, tt.err)
+		})
+	}
+}
+
+func TestArchiveStorageClass(t *testing.T) {
+	var tests = []struct {
+		name      string
+		snapshots []*restic.Snapshot
+		class     string
+	}{
+		{name: "no snapshots"},
+		{name: "standard", snapshots: []*restic.Snapshot{{Tags: []string{"storage-class=STANDARD_IA"}}, {Tags: []string{"repo-name=home"}}}},
+		{name: "archive", snapshots: []*restic.Snapshot{{Tags: []string{"storage-class=STANDARD"}}, {Tags: []string{"storage-class=DEEP_ARCHIVE"}}}, class: "DEEP_ARCHIVE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ArchiveStorageClass(tt.snapshots), tt.class)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"olares.com/backups-sdk/pkg/constants"
+)
+
+const serviceName = "olares-backups"
+
+// Init sets a global tracer provider that appends the spans to file as json lines,
+// the returned shutdown flushes the spans and closes the file
+func Init(file string) (func(context.Context) error, error) {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return nil, fmt.Errorf("create trace dir error: %v", err)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file error: %v", err)
+	}
+
+	provider, err := NewProvider(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		defer f.Close()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// NewProvider returns a tracer provider that writes the spans to w,
+// root spans take the backups trace id of the context as their trace id
+func NewProvider(w io.Writer) (*sdktrace.TracerProvider, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter error: %v", err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithIDGenerator(newIDGenerator()),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	), nil
+}
+
+// idGenerator reuses the backups trace id of the context for new traces, ids are random otherwise
+type idGenerator struct {
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+func newIDGenerator() *idGenerator {
+	var seed int64
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err == nil {
+		for _, v := range b {
+			seed = seed<<8 | int64(v)
+		}
+	}
+	return &idGenerator{random: rand.New(rand.NewSource(seed))}
+}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var spanId = g.NewSpanID(ctx, trace.TraceID{})
+
+	if traceId, _ := ctx.Value(constants.TraceId).(string); traceId != "" {
+		if id, ok := TraceID(traceId); ok {
+			return id, spanId
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var id trace.TraceID
+	for !id.IsValid() {
+		_, _ = g.random.Read(id[:])
+	}
+	return id, spanId
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var id trace.SpanID
+	for !id.IsValid() {
+		_, _ = g.random.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"olares.com/backups-sdk/pkg/constants"
+	"olares.com/backups-sdk/pkg/redact"
+)
+
+// spans go to the global tracer provider, it is a no-op until the application sets one,
+// see otel.SetTracerProvider or Init
+const tracerName = "olares.com/backups-sdk"
+
+// TraceIdKey is the span attribute of the backups trace id, it is also the otel trace id of root spans
+const TraceIdKey = "backups.trace_id"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// StartJob starts the span of a service call, a root span unless ctx already has one,
+// for example of an application tracing its own requests.
+// The backups trace id of ctx is an attribute, and the otel trace id of root spans with the provider of Init
+func StartJob(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if traceId, _ := ctx.Value(constants.TraceId).(string); traceId != "" {
+		attrs = append(attrs, attribute.String(TraceIdKey, traceId))
+	}
+
+	var opts = []trace.SpanStartOption{trace.WithAttributes(attrs...)}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		opts = append(opts, trace.WithNewRoot())
+	}
+	return tracer().Start(ctx, name, opts...)
+}
+
+// Start starts a child span, for example of a restic command or a cloud api call
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error of the span, redacted, and ends it. Call it deferred with the error result
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		var msg = redact.String((*err).Error())
+		span.RecordError(redact.Error(*err))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// TraceID converts a backups trace id, a uuid, to an otel trace id
+func TraceID(traceId string) (trace.TraceID, bool) {
+	var id trace.TraceID
+	b, err := hex.DecodeString(strings.ReplaceAll(traceId, "-", ""))
+	if err != nil || len(b) != len(id) {
+		return id, false
+	}
+	copy(id[:], b)
+	return id, id.IsValid()
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"olares.com/backups-sdk/pkg/constants"
+)
+
+const testTraceId = "6f1c2a5e-8a3b-4d7e-9f10-2b3c4d5e6f70"
+
+func TestTraceID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{input: testTraceId, want: "6f1c2a5e8a3b4d7e9f102b3c4d5e6f70", ok: true},
+		{input: "6f1c2a5e8a3b4d7e9f102b3c4d5e6f70", want: "6f1c2a5e8a3b4d7e9f102b3c4d5e6f70", ok: true},
+		{input: "00000000-0000-0000-0000-000000000000", ok: false},
+		{input: "not-a-uuid", ok: false},
+		{input: "", ok: false},
+	}
+	for _, tt := range tests {
+		id, ok := TraceID(tt.input)
+		assert.Equal(t, ok, tt.ok)
+		if tt.ok {
+			assert.Equal(t, id.String(), tt.want)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	var recorder = tracetest.NewSpanRecorder()
+	var provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithIDGenerator(newIDGenerator()))
+	var prev = otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	var ctx = context.WithValue(context.Background(), constants.TraceId, testTraceId)
+	func() (err error) {
+		ctx, span := StartJob(ctx, "backup")
+		defer End(span, &err)
+
+		func() (err error) {
+			_, span := Start(ctx, "restic backup")
+			defer End(span, &err)
+			return errors.New("upload failed, url: https://bucket/key?X-Amz-Signature=abcdef123456")
+		}()
+		return nil
+	}()
+
+	var spans = recorder.Ended()
+	assert.Equal(t, len(spans), 2)
+	var child, root = spans[0], spans[1]
+
+	// the root span carries the backups trace id as its otel trace id
+	assert.Equal(t, root.SpanContext().TraceID().String(), strings.ReplaceAll(testTraceId, "-", ""))
+	assert.Equal(t, root.Parent().IsValid(), false)
+	assert.Equal(t, root.Status().Code, codes.Unset)
+	assert.Equal(t, child.Parent().SpanID(), root.SpanContext().SpanID())
+	assert.Equal(t, child.SpanContext().TraceID(), root.SpanContext().TraceID())
+
+	// errors are recorded without secrets
+	assert.Equal(t, child.Status().Code, codes.Error)
+	assert.Equal(t, strings.Contains(child.Status().Description, "abcdef123456"), false)
+	assert.Equal(t, len(child.Events()), 1)
+	for _, attr := range child.Events()[0].Attributes {
+		assert.Equal(t, strings.Contains(attr.Value.Emit(), "abcdef123456"), false)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	var buf bytes.Buffer
+	provider, err := NewProvider(&buf)
+	assert.Equal(t, err, nil)
+
+	var ctx = context.WithValue(context.Background(), constants.TraceId, testTraceId)
+	_, span := provider.Tracer(tracerName).Start(ctx, "stats")
+	span.End()
+	assert.Equal(t, provider.Shutdown(context.Background()), nil)
+
+	assert.Equal(t, strings.Contains(buf.String(), strings.ReplaceAll(testTraceId, "-", "")), true)
+	assert.Equal(t, strings.Contains(buf.String(), `"Name":"stats"`), true)
+}
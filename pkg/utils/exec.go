@@ -49,6 +49,7 @@ func (c *Command) Run() (string, error) {
 	var err error
 	c.cmd = exec.CommandContext(c.ctx, c.options.Path, c.options.Args...)
 	c.cmd.Env = append(os.Environ(), c.cmd.Env...)
+	SetProcessGroup(c.cmd)
 
 	for k, v := range c.options.Envs {
 		c.cmd.Env = append(c.cmd.Env, fmt.Sprintf("%s=%s", k, v))
@@ -60,7 +61,7 @@ func (c *Command) Run() (string, error) {
 	}
 	c.cmd.Stderr = c.cmd.Stdout
 
-	logger.Infof("[Cmd] %s", c.cmd.String())
+	logger.FromContext(c.ctx).Infof("[Cmd] %s", c.cmd.String())
 	if err := c.cmd.Start(); err != nil {
 		return "", errors.Wrap(err, "cmd start error")
 	}
@@ -5,6 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"path"
+
+	"github.com/joho/godotenv"
+	"olares.com/backups-sdk/pkg/constants"
 )
 
 func Chmod(p string) error {
@@ -21,6 +25,16 @@ func GetHomeDir() string {
 	return user.HomeDir
 }
 
+// GetBaseDir returns the Olares base dir, OLARES_BASE_DIR on an installed Olares, ~/.olares otherwise
+func GetBaseDir() string {
+	if err := godotenv.Load(constants.OlaresReleaseFile); err == nil {
+		if dir := os.Getenv(constants.ENV_OLARES_BASE_DIR); dir != "" {
+			return dir
+		}
+	}
+	return path.Join(GetHomeDir(), constants.DefaultBaseDir)
+}
+
 func CreateDir(path string) error {
 	if IsExist(path) == false {
 		err := os.MkdirAll(path, os.ModePerm)
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// processGroupWaitDelay is how long a canceled command may take to exit after SIGTERM before it is killed
+const processGroupWaitDelay = 10 * time.Second
+
+// SetProcessGroup runs cmd in its own process group, when the context of cmd is done the whole group
+// gets SIGTERM, so restic can remove its lock and helpers it started, such as ssh or rclone, do not outlive it
+func SetProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = processGroupWaitDelay
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package utils
+
+import (
+	"os/exec"
+	"time"
+)
+
+const processGroupWaitDelay = 10 * time.Second
+
+// SetProcessGroup only bounds the wait for canceled commands, windows has no process groups to signal
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = processGroupWaitDelay
+}
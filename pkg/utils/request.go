@@ -5,15 +5,22 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"olares.com/backups-sdk/pkg/tracing"
 )
 
-func Post[T any](ctx context.Context, url string, headers map[string]string, data interface{}) (*T, error) {
+// Post sends a cloud api request, it is traced as a child span of ctx
+func Post[T any](ctx context.Context, url string, headers map[string]string, data interface{}) (_ *T, err error) {
+	ctx, span := tracing.Start(ctx, "POST "+spanUrl(url), attribute.String("http.request.method", http.MethodPost))
+	defer tracing.End(span, &err)
+
 	var result T
 	client := resty.New().SetTimeout(60 * time.Second).
-		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).R().SetDebug(true)
+		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).R()
 
 	if headers != nil {
 		client.SetHeaders(headers)
@@ -28,9 +35,19 @@ func Post[T any](ctx context.Context, url string, headers map[string]string, dat
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode()))
 	if resp.StatusCode() != http.StatusOK {
 		return nil, fmt.Errorf("request failed, status code: %d", resp.StatusCode())
 	}
 
 	return &result, nil
 }
+
+// spanUrl is the url without query and credentials, the name of a request span
+func spanUrl(rawUrl string) string {
+	u, err := neturl.Parse(rawUrl)
+	if err != nil {
+		return "request"
+	}
+	return u.Host + u.Path
+}
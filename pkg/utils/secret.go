@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// prefixes of a secret reference, any other value is the secret itself
+const (
+	SecretEnvPrefix     = "env:"
+	SecretFilePrefix    = "file:"
+	SecretCommandPrefix = "cmd:"
+)
+
+var secretCommandTimeout = 30 * time.Second
+
+// IsSecretRef reports whether the value is a secret reference rather than the secret itself
+func IsSecretRef(value string) bool {
+	for _, prefix := range []string{SecretEnvPrefix, SecretFilePrefix, SecretCommandPrefix} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecret resolves a secret reference, env:NAME reads an environment variable,
+// file:PATH reads a file and cmd:COMMAND runs a shell command and reads its output
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SecretEnvPrefix):
+		var name = strings.TrimPrefix(value, SecretEnvPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, SecretFilePrefix):
+		return ReadSecretFile(strings.TrimPrefix(value, SecretFilePrefix))
+	case strings.HasPrefix(value, SecretCommandPrefix):
+		return RunSecretCommand(strings.TrimPrefix(value, SecretCommandPrefix))
+	}
+	return value, nil
+}
+
+// ReadSecretFile returns the first line of the file
+func ReadSecretFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read secret file error: %v", err)
+	}
+	var secret = strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", file)
+	}
+	return secret, nil
+}
+
+// RunSecretCommand runs the command with sh and returns the first line of its output
+func RunSecretCommand(command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", errors.New("secret command is empty")
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// the command line is not part of the error, it may contain the secret
+		return "", fmt.Errorf("secret command failed: %v", err)
+	}
+	var secret = strings.TrimRight(strings.SplitN(string(out), "\n", 2)[0], "\r")
+	if secret == "" {
+		return "", errors.New("secret command returned an empty secret")
+	}
+	return secret, nil
+}
@@ -2,8 +2,8 @@ package utils
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"log"
 	"net/url"
 	"strings"
 	"syscall"
@@ -11,7 +11,14 @@ import (
 	"golang.org/x/term"
 )
 
+// ErrNoTerminal is returned when a password has to be typed in but stdin is not a terminal
+var ErrNoTerminal = errors.New("stdin is not a terminal, the password can not be read interactively")
+
 func InputPasswordWithConfirm(confirmRequired bool) (string, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return "", ErrNoTerminal
+	}
+
 	if confirmRequired {
 		fmt.Println("\nPlease create a password for this backup. This password will be required to restore your data in the future. The system will NOT save or store this password, so make sure to remember it. If you lose or forget this password, you will not be able to recover your backup.")
 	}
@@ -24,8 +31,7 @@ func InputPasswordWithConfirm(confirmRequired bool) (string, error) {
 		fmt.Print("\nEnter password for repository: ")
 		password, err := term.ReadPassword(int(syscall.Stdin))
 		if err != nil {
-			log.Fatalf("Failed to read password: %v", err)
-			return "", err
+			return "", fmt.Errorf("failed to read password: %v", err)
 		}
 		password = bytes.TrimSpace(password)
 		if len(password) == 0 {
@@ -38,8 +44,7 @@ func InputPasswordWithConfirm(confirmRequired bool) (string, error) {
 		fmt.Print("\nRe-enter the password to confirm: ")
 		confirmed, err = term.ReadPassword(int(syscall.Stdin))
 		if err != nil {
-			log.Fatalf("Failed to read re-enter password: %v", err)
-			return "", err
+			return "", fmt.Errorf("failed to read re-enter password: %v", err)
 		}
 		if !bytes.Equal(password, confirmed) {
 			fmt.Printf("\nPasswords do not match. Please try again.\n")
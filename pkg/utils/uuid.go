@@ -1,6 +1,11 @@
 package utils
 
-import "github.com/google/uuid"
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"olares.com/backups-sdk/pkg/constants"
+)
 
 func NewUUID() string {
 	return uuid.New().String()
@@ -10,3 +15,20 @@ func IsValid(s string) bool {
 	_, err := uuid.Parse(s)
 	return err == nil
 }
+
+// GetTraceId returns the trace id of the context, empty when there is none
+func GetTraceId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceId, _ := ctx.Value(constants.TraceId).(string)
+	return traceId
+}
+
+// WithTraceId makes sure the context carries a trace id
+func WithTraceId(ctx context.Context) context.Context {
+	if GetTraceId(ctx) != "" {
+		return ctx
+	}
+	return context.WithValue(ctx, constants.TraceId, NewUUID())
+}